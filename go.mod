@@ -1,5 +1,5 @@
 module github.com/jaeyeom/gomemocache
 
-go 1.13
+go 1.20
 
 require github.com/jaeyeom/sugo v0.0.0-20191112020940-956d7a785c73
@@ -0,0 +1,174 @@
+package memocache
+
+import (
+	"context"
+	"errors"
+)
+
+// errNotLoaded is the load error of a key that getValues of LoadOrCallMany
+// didn't return a value for.
+var errNotLoaded = errors.New("memocache: getValues returned no value for the key")
+
+// batchEntry is an entry of a key of LoadOrCallMany.
+type batchEntry struct {
+	key   interface{}
+	v     *Value
+	call  *valueCall  // The claimed call of v, or nil if another goroutine loads v
+	stale *valueState // The expired state to serve if the load fails
+}
+
+// LoadOrCallMany gets the values of the keys, calling getValues once with all
+// the keys that are missing, e.g. to fetch them with a single query instead of
+// one query per key. The returned map has the values of the keys that are
+// cached or returned by getValues. Keys that getValues doesn't return a value
+// for are not cached and are missing from the result.
+//
+// Loads are still deduplicated per key: keys already being loaded by other
+// goroutines are not passed to getValues but waited for. If such a load fails,
+// getValues is called again with that key alone. Stale entries served in the
+// degraded mode of WithLatencyBudget are refreshed with getValues of the key
+// alone as well. Each key is loaded through the same options as LoadOrCallErr,
+// e.g. the hooks of WithLoadHook are called for each key passed to getValues,
+// and WithRetry retries a key missing from the result with getValues of the
+// key alone.
+func (c *Cache) LoadOrCallMany(keys []interface{}, getValues func(missing []interface{}) map[interface{}]interface{}) map[interface{}]interface{} {
+	values := make(map[interface{}]interface{}, len(keys))
+	seen := make(map[interface{}]bool, len(keys))
+	var claimed, waiting []batchEntry
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		v := c.entry(key)
+		var stale *valueState
+		if s := v.state.Load(); s != nil {
+			if s.fresh() {
+				c.hit(key, v)
+				values[key] = s.value
+				continue
+			}
+			if c.latency.isDegraded() {
+				c.refreshStale(key, v, loadOne(getValues, key))
+				c.hit(key, v)
+				values[key] = s.value
+				continue
+			}
+			stale = c.staleState(s)
+			c.compareAndDelete(key, v, EvictionDeleted)
+			v = c.entry(key)
+		}
+		e := batchEntry{key: key, v: v, call: v.claim(), stale: stale}
+		if e.call != nil {
+			claimed = append(claimed, e)
+		} else {
+			waiting = append(waiting, e)
+		}
+	}
+	if len(claimed) > 0 {
+		c.loadMany(claimed, getValues, values)
+	}
+	for _, e := range waiting {
+		if value, err := c.loadOrCallSlow(context.Background(), e.key, e.v, loadOne(getValues, e.key)); err == nil {
+			values[e.key] = value
+		}
+	}
+	return values
+}
+
+// loadOne returns a loader of the key alone with getValues.
+func loadOne(getValues func(missing []interface{}) map[interface{}]interface{}, key interface{}) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		value, ok := getValues([]interface{}{key})[key]
+		if !ok {
+			return nil, errNotLoaded
+		}
+		return value, nil
+	}
+}
+
+// loadMany loads the claimed entries, each through the loader of its key
+// decorated like in loadOrCallSlow, and adds their values to values. The
+// first loader to run calls getValues with all the keys, and the others take
+// their values from its result; a key loaded again, e.g. retried, is loaded
+// alone. If getValues panics, the entries are removed and their calls are
+// finished, so that waiters and later calls try again.
+func (c *Cache) loadMany(claimed []batchEntry, getValues func(missing []interface{}) map[interface{}]interface{}, values map[interface{}]interface{}) {
+	missing := make([]interface{}, len(claimed))
+	for i, e := range claimed {
+		missing[i] = e.key
+	}
+	var results map[interface{}]interface{}
+	fetched := false
+	next := 0
+	defer func() {
+		// Only left if a loader panicked, which removed its own entry.
+		for _, e := range claimed[next:] {
+			c.compareAndDelete(e.key, e.v, EvictionDeleted)
+			e.v.finish(e.call, nil, false)
+		}
+	}()
+	for next < len(claimed) {
+		e := claimed[next]
+		next++
+		attempted := false
+		getValue := func() (interface{}, error) {
+			if attempted {
+				return loadOne(getValues, e.key)()
+			}
+			attempted = true
+			if !fetched {
+				fetched = true
+				results = getValues(missing)
+			}
+			value, ok := results[e.key]
+			if !ok {
+				return nil, errNotLoaded
+			}
+			return value, nil
+		}
+		getValue = onPanic(c.loader(context.Background(), e.key, getValue), func() {
+			c.compareAndDelete(e.key, e.v, EvictionDeleted)
+		})
+		if e.stale != nil {
+			getValue = c.serveStale(e.key, e.stale, getValue)
+		}
+		value, err := e.v.doCall(e.call, getValue)
+		c.stats.record(true, err)
+		c.report.record(false)
+		c.hot.record(e.key)
+		c.config.record(OpLoad, false, e.key)
+		c.config.observe(false, e.key)
+		if err != nil {
+			continue
+		}
+		values[e.key] = value
+		if m, ok := c.m.(mapReweigher); ok {
+			m.Reweigh(e.key, e.v)
+		}
+	}
+}
+
+// claim claims the call of the value if it's neither ready nor being loaded,
+// and returns the claimed call, which should be finished with finish.
+// Otherwise it returns nil.
+func (e *Value) claim() *valueCall {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.state.Load() != nil || e.call != nil {
+		return nil
+	}
+	e.call = &valueCall{done: make(chan struct{})}
+	return e.call
+}
+
+// finish finishes the claimed call c, publishing the value if set is true.
+func (e *Value) finish(c *valueCall, value interface{}, set bool) {
+	if set {
+		e.state.Store(e.newState(value, nil))
+	}
+	e.mu.Lock()
+	e.call = nil
+	e.mu.Unlock()
+	close(c.done)
+}
@@ -0,0 +1,135 @@
+package memocache
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func ExampleCache_LoadOrCallMany() {
+	c := NewCache(&sync.Map{})
+	query := func(ids []interface{}) map[interface{}]interface{} {
+		fmt.Println("SELECT name FROM users WHERE id IN", ids)
+		names := make(map[interface{}]interface{})
+		for _, id := range ids {
+			if id != 0 {
+				names[id] = fmt.Sprint("user", id)
+			}
+		}
+		return names
+	}
+	fmt.Println(len(c.LoadOrCallMany([]interface{}{1, 2}, query)))
+	fmt.Println(len(c.LoadOrCallMany([]interface{}{0, 1, 2, 3}, query)))
+	// Output:
+	// SELECT name FROM users WHERE id IN [1 2]
+	// 2
+	// SELECT name FROM users WHERE id IN [0 3]
+	// 3
+}
+
+func TestLoadOrCallMany_Concurrent(t *testing.T) {
+	c := NewCache(&sync.Map{})
+	var mu sync.Mutex
+	loads := make(map[interface{}]int)
+	getValues := func(keys []interface{}) map[interface{}]interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		values := make(map[interface{}]interface{})
+		for _, key := range keys {
+			loads[key]++
+			values[key] = key
+		}
+		return values
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			keys := []interface{}{g, g + 1, g + 2, g + 2}
+			values := c.LoadOrCallMany(keys, getValues)
+			if len(values) != 3 {
+				t.Errorf("LoadOrCallMany(%v) = %v", keys, values)
+			}
+		}(g)
+	}
+	wg.Wait()
+	var keys []int
+	for key, n := range loads {
+		if n != 1 {
+			t.Errorf("key %v is loaded %d times", key, n)
+		}
+		keys = append(keys, key.(int))
+	}
+	sort.Ints(keys)
+	if want := "[0 1 2 3 4 5 6 7 8 9]"; fmt.Sprint(keys) != want {
+		t.Errorf("loaded keys %v, want %v", keys, want)
+	}
+	if s := c.Stats(); s.Misses != 10 || s.Hits != 14 {
+		t.Errorf("Stats() = %+v, want 10 misses and 14 hits", s)
+	}
+}
+
+func TestLoadOrCallMany_Panic(t *testing.T) {
+	c := NewCache(&sync.Map{})
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("getValues didn't panic")
+			}
+		}()
+		c.LoadOrCallMany([]interface{}{1, 2}, func(keys []interface{}) map[interface{}]interface{} {
+			panic("failed")
+		})
+	}()
+	if got := c.LoadOrCall(1, func() interface{} { return "one" }); got != "one" {
+		t.Errorf("LoadOrCall(1) = %v, want one", got)
+	}
+	if got := c.LoadOrCall(2, func() interface{} { return "two" }); got != "two" {
+		t.Errorf("LoadOrCall(2) = %v, want two", got)
+	}
+}
+
+func TestLoadOrCallMany_Options(t *testing.T) {
+	clock := newFakeClock()
+	var started []interface{}
+	c := NewCache(&sync.Map{},
+		WithClock(clock),
+		WithTTL(time.Minute),
+		WithRetry(RetryPolicy{MaxAttempts: 2}),
+		WithServeStaleOnError(0, nil),
+		WithLoadHook(func(key interface{}) { started = append(started, key) }, nil),
+	)
+	var calls [][]interface{}
+	up := true
+	getValues := func(keys []interface{}) map[interface{}]interface{} {
+		calls = append(calls, keys)
+		values := make(map[interface{}]interface{})
+		for _, key := range keys {
+			// Key 2 is missing from the batch but found when retried.
+			if up && (key != 2 || len(keys) == 1) {
+				values[key] = fmt.Sprint("v", key)
+			}
+		}
+		return values
+	}
+	values := c.LoadOrCallMany([]interface{}{1, 2}, getValues)
+	if want := "map[1:v1 2:v2]"; fmt.Sprint(values) != want {
+		t.Errorf("LoadOrCallMany() = %v, want %v", values, want)
+	}
+	if want := "[[1 2] [2]]"; fmt.Sprint(calls) != want {
+		t.Errorf("getValues called with %v, want %v", calls, want)
+	}
+	if want := "[1 2]"; fmt.Sprint(started) != want {
+		t.Errorf("load hook called with %v, want %v", started, want)
+	}
+
+	clock.Advance(time.Minute)
+	up = false
+	values = c.LoadOrCallMany([]interface{}{1, 2}, getValues)
+	if want := "map[1:v1 2:v2]"; fmt.Sprint(values) != want {
+		t.Errorf("LoadOrCallMany() of expired keys while down = %v, want the stale %v", values, want)
+	}
+}
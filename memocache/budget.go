@@ -0,0 +1,137 @@
+package memocache
+
+import (
+	"container/list"
+	"math/rand"
+	"sync/atomic"
+)
+
+// Budget is a capacity shared by the caches of the levels of a MultiLevelMap,
+// so that the maximum size bounds the entries of the whole tree. It owns the
+// state the levels have to share, e.g. the list of LRUMaps, so it can't be
+// wired wrongly:
+//
+//	m := NewMultiLevelMap(NewLRUBudget(10000).NewLevel)
+//
+// A Budget should be created with NewLRUBudget or NewRRBudget and may be
+// resized while it's in use.
+type Budget struct {
+	policy    Policy
+	maxSize   atomic.Int32
+	targetNum atomic.Int32
+	evictions atomic.Uint64
+	opts      []Option
+
+	list   *list.List // Shared by the LRUMaps
+	anchor *LRUMap    // Evicts from the list on Resize
+
+	size int32                   // Shared by the RRCaches
+	root atomic.Pointer[RRCache] // First level, evicting down the tree on Resize
+}
+
+// BudgetStats is a snapshot of the usage of a Budget.
+type BudgetStats struct {
+	// Len is the number of entries of all the levels, including the nodes.
+	Len int
+	// Cap is the maximum size.
+	Cap int
+	// Evictions is the number of entries evicted for capacity.
+	Evictions uint64
+}
+
+// NewLRUBudget returns a new Budget of up to maxSize entries evicted in least
+// recently used order across the levels. The levels are Caches backed by
+// LRUMaps and given opts.
+func NewLRUBudget(maxSize int, opts ...Option) *Budget {
+	b := &Budget{policy: PolicyLRU, list: list.New(), opts: opts}
+	b.maxSize.Store(int32(maxSize))
+	b.anchor = NewLRUMap(b.list, maxSize)
+	b.anchor.budget = b
+	return b
+}
+
+// NewRRBudget returns a new Budget of up to maxSize entries evicted randomly
+// down to about targetNum entries, see NewRRCache. The levels are RRCaches
+// given opts.
+func NewRRBudget(maxSize, targetNum int32, opts ...Option) *Budget {
+	b := &Budget{policy: PolicyRandom, opts: opts}
+	b.maxSize.Store(maxSize)
+	b.targetNum.Store(targetNum)
+	return b
+}
+
+// NewLevel returns a new cache of a level drawing from the budget. Pass it as
+// the newMap of NewMultiLevelMap.
+func (b *Budget) NewLevel() CacheInterface {
+	if b.policy == PolicyRandom {
+		r := NewRRCache(&b.size, b.maxSize.Load(), b.targetNum.Load(), rand.Intn, b.opts...)
+		r.budget = b
+		b.root.CompareAndSwap(nil, r)
+		return r
+	}
+	l := NewLRUMap(b.list, int(b.maxSize.Load()))
+	l.budget = b
+	return NewCache(l, b.opts...)
+}
+
+// Len returns the number of entries of all the levels.
+func (b *Budget) Len() int {
+	if b.policy == PolicyRandom {
+		return int(atomic.LoadInt32(&b.size))
+	}
+	b.anchor.mu.Lock()
+	defer b.anchor.mu.Unlock()
+	return b.list.Len()
+}
+
+// Resize changes the maximum size, evicting entries at once if the levels hold
+// more. A Budget of random replacement evicts down to its target, which is
+// scaled with the maximum size.
+func (b *Budget) Resize(maxSize int) {
+	if b.policy == PolicyRandom {
+		old := b.maxSize.Swap(int32(maxSize))
+		if old > 0 {
+			b.targetNum.Store(int32(int64(b.targetNum.Load()) * int64(maxSize) / int64(old)))
+		}
+		if r := b.root.Load(); r != nil {
+			r.evict(r.limits())
+		}
+		return
+	}
+	b.anchor.mu.Lock()
+	defer b.anchor.unlock()
+	b.maxSize.Store(int32(maxSize))
+	b.anchor.evict()
+}
+
+// Stats returns the usage of the budget.
+func (b *Budget) Stats() BudgetStats {
+	return BudgetStats{
+		Len:       b.Len(),
+		Cap:       int(b.maxSize.Load()),
+		Evictions: b.evictions.Load(),
+	}
+}
+
+// evicted counts an eviction for capacity. It's a no-op on a nil budget.
+func (b *Budget) evicted() {
+	if b != nil {
+		b.evictions.Add(1)
+	}
+}
+
+// capacity returns the maximum number of keys in the list.
+func (l *LRUMap) capacity() int {
+	if l.budget != nil {
+		return int(l.budget.maxSize.Load())
+	}
+	return l.maxSize
+}
+
+// limits returns the maximum size and the target of the evictions.
+func (r *RRCache) limits() (maxSize, targetNum int32) {
+	if r.budget != nil {
+		return r.budget.maxSize.Load(), r.budget.targetNum.Load()
+	}
+	return atomic.LoadInt32(&r.maxSize), atomic.LoadInt32(&r.targetNum)
+}
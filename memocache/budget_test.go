@@ -0,0 +1,57 @@
+package memocache
+
+import (
+	"fmt"
+	"testing"
+)
+
+func ExampleBudget() {
+	b := NewLRUBudget(3)
+	m := NewMultiLevelMap(b.NewLevel)
+	m.LoadOrCall(func() interface{} { return 1 }, "a", "x")
+	m.LoadOrCall(func() interface{} { return 2 }, "a", "y")
+	m.LoadOrCall(func() interface{} { return 3 }, "b", "x")
+	fmt.Printf("%+v\n", b.Stats())
+	// Output:
+	// {Len:3 Cap:3 Evictions:2}
+}
+
+func TestLRUBudget_Resize(t *testing.T) {
+	b := NewLRUBudget(10)
+	m := NewMultiLevelMap(b.NewLevel)
+	for i := 0; i < 4; i++ {
+		m.LoadOrCall(func() interface{} { return i }, "a", i)
+	}
+	if got := b.Len(); got != 5 {
+		t.Fatalf("Len() = %d, want 4 values and their node", got)
+	}
+	b.Resize(3)
+	if got := b.Len(); got != 3 {
+		t.Errorf("Len() after Resize(3) = %d, want 3", got)
+	}
+	b.Resize(20)
+	for i := 0; i < 20; i++ {
+		m.LoadOrCall(func() interface{} { return i }, "b", i)
+	}
+	if got := b.Stats(); got.Len != 20 || got.Cap != 20 {
+		t.Errorf("Stats() after Resize(20) = %+v, want 20 of 20 entries", got)
+	}
+}
+
+func TestRRBudget(t *testing.T) {
+	b := NewRRBudget(20, 10)
+	m := NewMultiLevelMap(b.NewLevel)
+	for i := 0; i < 100; i++ {
+		m.LoadOrCall(func() interface{} { return i }, i%5, i)
+	}
+	if got := b.Len(); got > 20 {
+		t.Errorf("Len() = %d, want at most 20", got)
+	}
+	if b.Stats().Evictions == 0 {
+		t.Error("Stats().Evictions = 0, want evictions")
+	}
+	b.Resize(6)
+	if got := b.Len(); got > 6 {
+		t.Errorf("Len() after Resize(6) = %d, want at most 6", got)
+	}
+}
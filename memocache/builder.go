@@ -0,0 +1,110 @@
+package memocache
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// WithMaxSize bounds the number of entries of the caches made by New and
+// Builder. The entries are evicted in LRU order unless another policy is given
+// with WithEvictionPolicy.
+func WithMaxSize(n int) Option {
+	return func(c *config) {
+		c.maxSize = n
+	}
+}
+
+// WithEvictionPolicy sets the replacement policy of the caches made by New and
+// Builder. All the policies but PolicyUnbounded need WithMaxSize.
+func WithEvictionPolicy(p Policy) Option {
+	return func(c *config) {
+		c.policy = p
+	}
+}
+
+// New returns a new cache configured by opts. It's a shorthand for
+// NewBuilder(opts...).Cache(). It panics if the policy is unknown or needs a
+// maximum size that isn't given.
+func New(opts ...Option) ExtendedCache {
+	return NewBuilder(opts...).Cache()
+}
+
+// Builder makes caches of the same configuration, wiring the maps of the
+// replacement policy and the state they share, so that callers don't compose
+// them by hand. The caches made by a Builder for PolicyLRU share a single list
+// and those for PolicyRandom share a single size, so WithMaxSize bounds the
+// entries of all of them together, e.g. of all the levels of a MultiLevelMap.
+// The caches of the other policies are bounded one by one. Builder should be
+// created with NewBuilder.
+type Builder struct {
+	config config
+
+	list *list.List // Shared by the LRUMaps
+	size int32      // Shared by the RRCaches
+}
+
+// NewBuilder returns a new Builder of the caches configured by opts. It panics
+// if the policy is unknown or needs a maximum size that isn't given.
+func NewBuilder(opts ...Option) *Builder {
+	b := &Builder{config: applyOptions(opts), list: list.New()}
+	switch b.config.policy {
+	case "":
+		if b.config.maxSize > 0 {
+			b.config.policy = PolicyLRU
+		} else {
+			b.config.policy = PolicyUnbounded
+		}
+	case PolicyUnbounded, PolicyLRU, PolicyRandom, PolicyFIFO, PolicyLFU:
+	default:
+		panic(fmt.Sprintf("memocache: unknown policy %q", b.config.policy))
+	}
+	if b.config.policy != PolicyUnbounded && b.config.maxSize <= 0 {
+		panic(fmt.Sprintf("memocache: policy %q needs a positive max size, got %d", b.config.policy, b.config.maxSize))
+	}
+	if b.config.policy == PolicyRandom && b.config.maxSize > math.MaxInt32 {
+		panic(fmt.Sprintf("memocache: policy %q supports max sizes up to %d, got %d", b.config.policy, math.MaxInt32, b.config.maxSize))
+	}
+	return b
+}
+
+// Cache returns a new cache of the configuration.
+func (b *Builder) Cache() ExtendedCache {
+	return b.newCache(b.config)
+}
+
+// MultiLevelMap returns a new MultiLevelMap whose levels are caches of the
+// configuration. The options that NewMultiLevelMap applies to the
+// MultiLevelMap itself apply to it, and the other options to the caches of
+// its levels. It panics for PolicyLFU, whose admission would reject inner
+// levels and reload their subtrees on every call.
+func (b *Builder) MultiLevelMap() *MultiLevelMap {
+	if b.config.policy == PolicyLFU {
+		panic(fmt.Sprintf("memocache: policy %q can't be used for a MultiLevelMap", PolicyLFU))
+	}
+	levels := b.config
+	levels.recorder = nil
+	levels.bus = nil
+	levels.onBusError = nil
+	return NewMultiLevelMap(func() CacheInterface {
+		return b.newCache(levels)
+	}, func(c *config) { *c = b.config })
+}
+
+// newCache returns a new cache of the policy of the builder configured by cfg.
+func (b *Builder) newCache(cfg config) ExtendedCache {
+	opts := []Option{func(c *config) { *c = cfg }}
+	switch cfg.policy {
+	case PolicyLRU:
+		return NewCache(NewLRUMap(b.list, cfg.maxSize), opts...)
+	case PolicyRandom:
+		return NewRRCache(&b.size, int32(cfg.maxSize), int32(cfg.maxSize/2), rand.Intn, opts...)
+	case PolicyFIFO:
+		return NewCache(NewFIFOMap(cfg.maxSize), opts...)
+	case PolicyLFU:
+		return NewCache(NewTinyLFUMap(cfg.maxSize), opts...)
+	}
+	return NewCache(&sync.Map{}, opts...)
+}
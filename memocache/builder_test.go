@@ -0,0 +1,89 @@
+package memocache
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func ExampleNewBuilder() {
+	b := NewBuilder(WithMaxSize(3))
+	m := b.MultiLevelMap()
+	for i := 0; i < 3; i++ {
+		m.LoadOrCall(func() interface{} { return i }, "users", i)
+	}
+	// The levels share the bound of 3 entries including the branch of the
+	// users, so the least recently used user has been evicted.
+	fmt.Println(m.Load("users", 0))
+	fmt.Println(m.Load("users", 2))
+	// Output:
+	// <nil> false
+	// 2 true
+}
+
+func TestNew(t *testing.T) {
+	for _, tc := range []struct {
+		opts []Option
+		want string
+	}{
+		{nil, "*memocache.Cache"},
+		{[]Option{WithMaxSize(2)}, "*memocache.Cache"},
+		{[]Option{WithMaxSize(2), WithEvictionPolicy(PolicyFIFO)}, "*memocache.Cache"},
+		{[]Option{WithMaxSize(2), WithEvictionPolicy(PolicyLFU)}, "*memocache.Cache"},
+		{[]Option{WithMaxSize(2), WithEvictionPolicy(PolicyRandom)}, "*memocache.RRCache"},
+	} {
+		c := New(tc.opts...)
+		if got := fmt.Sprintf("%T", c); got != tc.want {
+			t.Errorf("New() = %s, want %s", got, tc.want)
+		}
+		for i := 0; i < 5; i++ {
+			if v := c.LoadOrCall(i, func() interface{} { return i }); v != i {
+				t.Errorf("LoadOrCall(%d) = %v, want %d", i, v, i)
+			}
+		}
+	}
+
+	var evicted []interface{}
+	c := New(WithMaxSize(2), WithOnEvict(func(key, value interface{}, reason EvictionReason) {
+		evicted = append(evicted, key)
+	}))
+	for i := 0; i < 3; i++ {
+		c.LoadOrCall(i, func() interface{} { return i })
+	}
+	if len(evicted) != 1 || evicted[0] != 0 {
+		t.Errorf("evicted = %v, want [0]", evicted)
+	}
+
+	mustPanic(t, func() { New(WithEvictionPolicy(PolicyLRU)) })
+	mustPanic(t, func() { New(WithMaxSize(2), WithEvictionPolicy("arc")) })
+}
+
+func TestBuilder_InvalidMultiLevelMap(t *testing.T) {
+	mustPanic(t, func() {
+		NewBuilder(WithMaxSize(10), WithEvictionPolicy(PolicyLFU)).MultiLevelMap()
+	})
+	if math.MaxInt > math.MaxInt32 {
+		tooBig := int64(math.MaxInt32) + 1
+		mustPanic(t, func() {
+			NewBuilder(WithMaxSize(int(tooBig)), WithEvictionPolicy(PolicyRandom))
+		})
+	}
+}
+
+func TestBuilderSharesBound(t *testing.T) {
+	b := NewBuilder(WithMaxSize(2))
+	c1, c2 := b.Cache(), b.Cache()
+	c1.LoadOrCall(1, func() interface{} { return 1 })
+	c2.LoadOrCall(2, func() interface{} { return 2 })
+	c2.LoadOrCall(3, func() interface{} { return 3 })
+	if _, ok := c1.(*Cache).Load(1); ok {
+		t.Error("key 1 is not evicted by the keys of the other cache")
+	}
+
+	var ops []Op
+	m := NewBuilder(WithRecorder(func(op Op) { ops = append(ops, op) })).MultiLevelMap()
+	m.LoadOrCall(func() interface{} { return 1 }, "a", "b")
+	if len(ops) != 1 {
+		t.Errorf("recorded %d ops, want 1 of the MultiLevelMap", len(ops))
+	}
+}
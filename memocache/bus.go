@@ -0,0 +1,153 @@
+package memocache
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+)
+
+// Invalidation is a Delete or Prune made on a cache, published to the caches
+// of the other replicas of a service through an InvalidationBus.
+type Invalidation struct {
+	// Source identifies the cache that made the invalidation, so that it
+	// ignores its own invalidations delivered back to it.
+	Source string
+	// Path is the deleted key of a Cache as a path of one element, or the
+	// pruned path of a MultiLevelMap. An empty path invalidates everything.
+	Path []interface{}
+}
+
+// InvalidationBus carries invalidations between the caches of the replicas of
+// a service, e.g. over a Redis channel or a message queue. Publish should not
+// block for long, since it's called by Delete and Prune. Subscribe registers a
+// handler of the invalidations published by all the caches, including the
+// subscriber's own, and returns a function that cancels the subscription.
+type InvalidationBus interface {
+	Publish(inv Invalidation) error
+	Subscribe(handler func(inv Invalidation)) (cancel func())
+}
+
+// WithInvalidationBus publishes the invalidations made by a Cache or a
+// MultiLevelMap to the bus, and applies the invalidations published by the
+// caches of other replicas, so that their entries don't go stale. A Cache
+// publishes the keys of Delete, DeleteFunc and Lease.Delete, and publishes
+// Clear as an empty path. A MultiLevelMap publishes the paths of Prune,
+// CompareAndPrune and Clear alike, and both paths of Move; give the option to
+// the MultiLevelMap rather than to the caches of its levels. Store doesn't
+// publish. Invalidations received from the bus are applied locally without
+// being published again. The errors of Publish are passed to onError if it's
+// not nil. Call Close to cancel the subscription.
+func WithInvalidationBus(bus InvalidationBus, onError func(err error)) Option {
+	return func(c *config) {
+		c.bus = bus
+		c.onBusError = onError
+	}
+}
+
+// busClient is the connection of a cache to its InvalidationBus.
+type busClient struct {
+	bus     InvalidationBus
+	onError func(err error)
+	source  string
+	cancel  func()
+}
+
+// newBusClient subscribes apply to the bus of the config and returns the
+// client, or returns nil if there is no bus.
+func newBusClient(c *config, apply func(path []interface{})) *busClient {
+	if c.bus == nil {
+		return nil
+	}
+	var b [16]byte
+	rand.Read(b[:])
+	bc := &busClient{
+		bus:     c.bus,
+		onError: c.onBusError,
+		source:  hex.EncodeToString(b[:]),
+	}
+	bc.cancel = c.bus.Subscribe(func(inv Invalidation) {
+		if inv.Source != bc.source {
+			apply(inv.Path)
+		}
+	})
+	return bc
+}
+
+// publish publishes the invalidation of the path. It's a no-op on a nil
+// client.
+func (bc *busClient) publish(path ...interface{}) {
+	if bc == nil {
+		return
+	}
+	err := bc.bus.Publish(Invalidation{Source: bc.source, Path: path})
+	if err != nil && bc.onError != nil {
+		bc.onError(err)
+	}
+}
+
+// close cancels the subscription. It's a no-op on a nil client.
+func (bc *busClient) close() {
+	if bc != nil {
+		bc.cancel()
+	}
+}
+
+// LocalBus is an InvalidationBus delivering invalidations to the caches in the
+// process synchronously, e.g. for tests or to keep several caches of the same
+// data consistent. The zero value is ready to use.
+type LocalBus struct {
+	mu       sync.RWMutex
+	handlers map[int]func(inv Invalidation)
+	nextID   int
+}
+
+var _ InvalidationBus = (*LocalBus)(nil)
+
+// Publish calls the handlers of all the subscriptions with inv.
+func (b *LocalBus) Publish(inv Invalidation) error {
+	b.mu.RLock()
+	handlers := make([]func(inv Invalidation), 0, len(b.handlers))
+	for _, h := range b.handlers {
+		handlers = append(handlers, h)
+	}
+	b.mu.RUnlock()
+	for _, h := range handlers {
+		h(inv)
+	}
+	return nil
+}
+
+// Subscribe registers the handler of the invalidations.
+func (b *LocalBus) Subscribe(handler func(inv Invalidation)) (cancel func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.handlers == nil {
+		b.handlers = make(map[int]func(inv Invalidation))
+	}
+	id := b.nextID
+	b.nextID++
+	b.handlers[id] = handler
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.handlers, id)
+	}
+}
+
+// applyInvalidation applies the invalidation of the path received from the
+// bus without publishing it again.
+func (c *Cache) applyInvalidation(path []interface{}) {
+	switch len(path) {
+	case 0:
+		c.clear()
+	case 1:
+		c.RevokeLease(path[0])
+		c.delete(path[0])
+	}
+}
+
+// Close cancels the subscription to the bus of WithInvalidationBus if any.
+func (m *MultiLevelMap) Close() error {
+	m.bus.close()
+	return nil
+}
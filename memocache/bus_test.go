@@ -0,0 +1,70 @@
+package memocache
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestWithInvalidationBus(t *testing.T) {
+	var bus LocalBus
+	a := NewCache(&sync.Map{}, WithInvalidationBus(&bus, nil))
+	b := NewCache(&sync.Map{}, WithInvalidationBus(&bus, nil))
+	defer a.Close()
+	defer b.Close()
+	for _, c := range []*Cache{a, b} {
+		c.LoadOrCall("k", func() interface{} { return 1 })
+		c.LoadOrCall("l", func() interface{} { return 2 })
+	}
+	a.Delete("k")
+	if _, ok := b.Load("k"); ok {
+		t.Error("k survived Delete(k) on the other cache")
+	}
+	if _, ok := b.Load("l"); !ok {
+		t.Error("l was deleted by Delete(k) on the other cache")
+	}
+	b.LoadOrCall("k", func() interface{} { return 1 })
+	l, err := a.Lease("k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Delete(); err != nil {
+		t.Fatalf("Lease.Delete() error = %v", err)
+	}
+	if _, ok := b.Load("k"); ok {
+		t.Error("k survived Lease.Delete() on the other cache")
+	}
+	l.Release()
+	b.Clear()
+	if n := a.Len(); n != 0 {
+		t.Errorf("Len() after Clear() on the other cache = %d, want 0", n)
+	}
+	b.Close()
+	a.LoadOrCall("k", func() interface{} { return 1 })
+	b.LoadOrCall("k", func() interface{} { return 1 })
+	a.Delete("k")
+	if _, ok := b.Load("k"); !ok {
+		t.Error("closed cache applied Delete(k)")
+	}
+}
+
+func TestMultiLevelMap_WithInvalidationBus(t *testing.T) {
+	var bus LocalBus
+	a := NewMultiLevelMap(nil, WithInvalidationBus(&bus, nil))
+	b := NewMultiLevelMap(nil, WithInvalidationBus(&bus, nil))
+	defer a.Close()
+	defer b.Close()
+	for _, m := range []*MultiLevelMap{a, b} {
+		m.LoadOrCall(func() interface{} { return 1 }, "x", "y")
+		m.LoadOrCall(func() interface{} { return 2 }, "z", "y")
+	}
+	a.Prune("x")
+	if _, ok := b.Load("x", "y"); ok {
+		t.Error("x/y survived Prune(x) on the other map")
+	}
+	if _, ok := b.Load("z", "y"); !ok {
+		t.Error("z/y was pruned by Prune(x) on the other map")
+	}
+	if _, ok := a.Load("z", "y"); !ok {
+		t.Error("z/y was pruned by Prune(x)")
+	}
+}
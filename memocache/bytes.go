@@ -0,0 +1,292 @@
+package memocache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"hash/maphash"
+	"sync"
+)
+
+// Codec encodes values to bytes and decodes them back, so that they can be
+// kept in a BytesCache.
+type Codec interface {
+	Encode(value interface{}) ([]byte, error)
+	Decode(data []byte) (interface{}, error)
+}
+
+// GobValueCodec is a Codec encoding values with encoding/gob. The concrete
+// types of the values other than the basic types should be registered with
+// gob.Register.
+var GobValueCodec Codec = gobValueCodec{}
+
+type gobValueCodec struct{}
+
+func (gobValueCodec) Encode(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobValueCodec) Decode(data []byte) (interface{}, error) {
+	var value interface{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+const (
+	// Number of shards of a BytesCache.
+	bytesShards = 16
+	// Number of segments of a shard of a BytesCache.
+	bytesSegments = 4
+	// Size of the header of an entry of a segment: the hash of the key and
+	// the lengths of the key and the value.
+	bytesHeaderSize = 16
+)
+
+// BytesCache is a cache that keeps its keys and values encoded in a few large
+// byte slices, like bigcache and freecache do, instead of keeping a pointer
+// per entry. The garbage collector doesn't scan the entries, so millions of
+// small values don't lengthen its pauses. The values are encoded with a Codec
+// when they are loaded and decoded on every hit, so the values returned are
+// copies. BytesCache should be created with NewBytesCache.
+//
+// Each shard of the cache writes its entries into a ring of segments, and the
+// oldest segment is dropped as a whole when the shard runs out of space, so
+// the entries are evicted in first-in first-out order. Overwritten and deleted
+// entries take their space until their segment is dropped.
+//
+// Keys are compared by their formatted values unless they are strings, so
+// keys of other types should format uniquely like integers do. Loads are
+// deduplicated per key like LoadOrCall of Cache. Errors, including
+// *CachedError, are not cached. BytesCache can't be made by the newMap of a
+// MultiLevelMap, since the levels of the tree hold the levels below them.
+type BytesCache struct {
+	codec  Codec
+	seed   maphash.Seed
+	shards [bytesShards]bytesShard
+}
+
+// bytesShard is a shard of a BytesCache.
+type bytesShard struct {
+	mu      sync.Mutex
+	segs    [bytesSegments][]byte
+	cur     int
+	index   map[uint64]uint64 // Hash of the key to the position of its entry
+	loading map[string]*Value // Loads in flight by the key bytes
+}
+
+// NewBytesCache returns a new BytesCache holding up to about maxBytes bytes of
+// encoded keys and values, which are encoded with the codec. An entry larger
+// than a segment, which is maxBytes divided by 64, is not cached.
+func NewBytesCache(maxBytes int, codec Codec) *BytesCache {
+	segSize := maxBytes / (bytesShards * bytesSegments)
+	b := &BytesCache{codec: codec, seed: maphash.MakeSeed()}
+	for i := range b.shards {
+		s := &b.shards[i]
+		for j := range s.segs {
+			s.segs[j] = make([]byte, 0, segSize)
+		}
+		s.index = make(map[uint64]uint64)
+		s.loading = make(map[string]*Value)
+	}
+	return b
+}
+
+// keyBytes returns the bytes the key is compared by.
+func keyBytes(key interface{}) []byte {
+	if k, ok := key.(string); ok {
+		return append([]byte{'s'}, k...)
+	}
+	return []byte(fmt.Sprintf("f%T:%v", key, key))
+}
+
+// shard returns the shard and the hash of the key bytes.
+func (b *BytesCache) shard(kb []byte) (*bytesShard, uint64) {
+	h := maphash.Bytes(b.seed, kb)
+	return &b.shards[h%bytesShards], h
+}
+
+// LoadOrCall gets the value of the key, calling getValue only once for
+// concurrent calls if it's not cached. The value is returned as is to the
+// calls that waited for its load, and decoded from its encoding on later hits.
+func (b *BytesCache) LoadOrCall(key interface{}, getValue func() interface{}) interface{} {
+	value, _ := b.LoadOrCallErr(key, func() (interface{}, error) {
+		return getValue(), nil
+	})
+	return value
+}
+
+// LoadOrCallErr is like LoadOrCall but getValue may fail. Errors are not
+// cached, and neither are values that the codec fails to encode, which are
+// returned with the error of the codec.
+func (b *BytesCache) LoadOrCallErr(key interface{}, getValue func() (interface{}, error)) (interface{}, error) {
+	kb := keyBytes(key)
+	s, h := b.shard(kb)
+	s.mu.Lock()
+	if data, ok := s.get(h, kb); ok {
+		s.mu.Unlock()
+		return b.codec.Decode(data)
+	}
+	v, ok := s.loading[string(kb)]
+	if !ok {
+		v = &Value{}
+		s.loading[string(kb)] = v
+	}
+	s.mu.Unlock()
+	// The load is forgotten once the value is written or the load fails, so
+	// that later calls read the value or try again.
+	done := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.loading[string(kb)] == v {
+			delete(s.loading, string(kb))
+		}
+	}
+	return v.LoadOrCallErr(func() (interface{}, error) {
+		defer done()
+		value, err := getValue()
+		if err != nil {
+			return value, err
+		}
+		data, err := b.codec.Encode(value)
+		if err != nil {
+			return value, err
+		}
+		s.mu.Lock()
+		s.put(h, kb, data)
+		s.mu.Unlock()
+		return value, nil
+	})
+}
+
+// Load returns the decoded value of the key if it's cached.
+func (b *BytesCache) Load(key interface{}) (value interface{}, ok bool) {
+	kb := keyBytes(key)
+	s, h := b.shard(kb)
+	s.mu.Lock()
+	data, ok := s.get(h, kb)
+	s.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	value, err := b.codec.Decode(data)
+	return value, err == nil
+}
+
+// Store encodes and sets the value of the key, overwriting the existing value
+// if any. It returns the error of the codec if the value can't be encoded.
+func (b *BytesCache) Store(key, value interface{}) error {
+	data, err := b.codec.Encode(value)
+	if err != nil {
+		return err
+	}
+	kb := keyBytes(key)
+	s, h := b.shard(kb)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.put(h, kb, data)
+	return nil
+}
+
+// Delete deletes the value of the key. Prior LoadOrCall of the key won't be
+// affected.
+func (b *BytesCache) Delete(key interface{}) {
+	kb := keyBytes(key)
+	s, h := b.shard(kb)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.get(h, kb); ok {
+		delete(s.index, h)
+	}
+}
+
+// Len returns the number of cached keys.
+func (b *BytesCache) Len() int {
+	n := 0
+	for i := range b.shards {
+		s := &b.shards[i]
+		s.mu.Lock()
+		n += len(s.index)
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// Clear deletes all the values. Loads in flight are not affected.
+func (b *BytesCache) Clear() {
+	for i := range b.shards {
+		s := &b.shards[i]
+		s.mu.Lock()
+		for j := range s.segs {
+			s.segs[j] = s.segs[j][:0]
+		}
+		s.index = make(map[uint64]uint64)
+		s.mu.Unlock()
+	}
+}
+
+// get returns a copy of the encoded value of the key bytes kb with the hash h.
+// It should be called with s.mu held.
+func (s *bytesShard) get(h uint64, kb []byte) ([]byte, bool) {
+	pos, ok := s.index[h]
+	if !ok {
+		return nil, false
+	}
+	seg := s.segs[pos>>32]
+	off := int(uint32(pos))
+	klen := int(binary.LittleEndian.Uint32(seg[off+8:]))
+	vlen := int(binary.LittleEndian.Uint32(seg[off+12:]))
+	off += bytesHeaderSize
+	if !bytes.Equal(seg[off:off+klen], kb) {
+		return nil, false
+	}
+	off += klen
+	return append([]byte(nil), seg[off:off+vlen]...), true
+}
+
+// put writes the entry of the key bytes kb with the hash h and the encoded
+// value data, dropping the oldest segment if the current one is full. It
+// should be called with s.mu held.
+func (s *bytesShard) put(h uint64, kb, data []byte) {
+	size := bytesHeaderSize + len(kb) + len(data)
+	if size > cap(s.segs[s.cur]) {
+		delete(s.index, h)
+		return
+	}
+	if len(s.segs[s.cur])+size > cap(s.segs[s.cur]) {
+		s.cur = (s.cur + 1) % bytesSegments
+		s.drop(s.cur)
+	}
+	seg := s.segs[s.cur]
+	off := len(seg)
+	var header [bytesHeaderSize]byte
+	binary.LittleEndian.PutUint64(header[:], h)
+	binary.LittleEndian.PutUint32(header[8:], uint32(len(kb)))
+	binary.LittleEndian.PutUint32(header[12:], uint32(len(data)))
+	seg = append(seg, header[:]...)
+	seg = append(seg, kb...)
+	s.segs[s.cur] = append(seg, data...)
+	s.index[h] = uint64(s.cur)<<32 | uint64(off)
+}
+
+// drop empties the segment i, deleting the index entries of its entries. It
+// should be called with s.mu held.
+func (s *bytesShard) drop(i int) {
+	seg := s.segs[i]
+	for off := 0; off < len(seg); {
+		h := binary.LittleEndian.Uint64(seg[off:])
+		if s.index[h] == uint64(i)<<32|uint64(off) {
+			delete(s.index, h)
+		}
+		klen := int(binary.LittleEndian.Uint32(seg[off+8:]))
+		vlen := int(binary.LittleEndian.Uint32(seg[off+12:]))
+		off += bytesHeaderSize + klen + vlen
+	}
+	s.segs[i] = seg[:0]
+}
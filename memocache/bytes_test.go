@@ -0,0 +1,80 @@
+package memocache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestBytesCache(t *testing.T) {
+	b := NewBytesCache(1<<20, GobValueCodec)
+	var calls atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got := b.LoadOrCall("a", func() interface{} {
+				calls.Add(1)
+				return 1
+			})
+			if got != 1 {
+				t.Errorf("LoadOrCall(a) = %v, want 1", got)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := calls.Load(); n != 1 {
+		t.Errorf("getValue was called %d times, want 1", n)
+	}
+	if v, ok := b.Load("a"); !ok || v != 1 {
+		t.Errorf("Load(a) = %v, %v, want 1, true", v, ok)
+	}
+	if err := b.Store(1, "one"); err != nil {
+		t.Fatalf("Store(1) = %v", err)
+	}
+	if v, ok := b.Load(1); !ok || v != "one" {
+		t.Errorf("Load(1) = %v, %v, want one, true", v, ok)
+	}
+	if _, ok := b.Load("1"); ok {
+		t.Error("the string key 1 matched the integer key 1")
+	}
+	if _, err := b.LoadOrCallErr("e", func() (interface{}, error) {
+		return nil, errors.New("failed")
+	}); err == nil {
+		t.Error("LoadOrCallErr(e) didn't fail")
+	}
+	if got := b.LoadOrCall("e", func() interface{} { return 2 }); got != 2 {
+		t.Errorf("LoadOrCall(e) after error = %v, want 2", got)
+	}
+	b.Delete("a")
+	if _, ok := b.Load("a"); ok {
+		t.Error("a survived Delete(a)")
+	}
+	if n := b.Len(); n != 2 {
+		t.Errorf("Len() = %d, want 2", n)
+	}
+	b.Clear()
+	if n := b.Len(); n != 0 {
+		t.Errorf("Len() after Clear() = %d, want 0", n)
+	}
+}
+
+func TestBytesCache_Evict(t *testing.T) {
+	b := NewBytesCache(64<<10, GobValueCodec)
+	for i := 0; i < 10000; i++ {
+		b.Store(i, fmt.Sprint("value ", i))
+	}
+	n := b.Len()
+	if n == 0 || n == 10000 {
+		t.Errorf("Len() = %d, want some but not all keys evicted", n)
+	}
+	if v, ok := b.Load(9999); !ok || v != "value 9999" {
+		t.Errorf("Load(9999) = %v, %v, want the last stored value", v, ok)
+	}
+	if _, ok := b.Load(0); ok {
+		t.Error("the first stored key 0 wasn't evicted")
+	}
+}
@@ -0,0 +1,230 @@
+// Package cachetest provides conformance tests of the contracts of the
+// interfaces of package memocache, so that the implementations outside the
+// package, e.g. other backends of MapInterface, can be verified by their own
+// tests:
+//
+//	func TestMyMap(t *testing.T) {
+//		cachetest.TestMapInterface(t, func() memocache.MapInterface {
+//			return NewMyMap()
+//		})
+//	}
+//
+// The tests run concurrent calls, so they are best run with the race detector.
+package cachetest
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jaeyeom/gomemocache/memocache"
+)
+
+// Number of goroutines of the concurrent tests.
+const numGoroutines = 16
+
+// How long a call may take before it's taken as blocked.
+const blockTimeout = 5 * time.Second
+
+// TestCacheInterface tests that the caches made by newCache load a key once
+// for concurrent calls, don't block the calls of a key on the load of another
+// key, forget deleted keys and stay consistent under concurrent use. The
+// caches should hold at least 100 keys.
+func TestCacheInterface(t *testing.T, newCache func() memocache.CacheInterface) {
+	t.Run("SingleFlight", func(t *testing.T) {
+		testSingleFlight(t, newCache())
+	})
+	t.Run("IndependentKeys", func(t *testing.T) {
+		testIndependentKeys(t, newCache())
+	})
+	t.Run("Delete", func(t *testing.T) {
+		testCacheDelete(t, newCache())
+	})
+	t.Run("Concurrent", func(t *testing.T) {
+		testCacheConcurrent(t, newCache())
+	})
+}
+
+func testSingleFlight(t *testing.T, c memocache.CacheInterface) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	results := make(chan interface{}, numGoroutines)
+	for i := 0; i < numGoroutines; i++ {
+		go func() {
+			results <- c.LoadOrCall("key", func() interface{} {
+				<-release
+				return calls.Add(1)
+			})
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	for i := 0; i < numGoroutines; i++ {
+		if v := <-results; v != int32(1) {
+			t.Errorf("LoadOrCall() = %v, want the value of the first load 1", v)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("getValue was called %d times for concurrent calls, want 1", n)
+	}
+}
+
+func testIndependentKeys(t *testing.T, c memocache.CacheInterface) {
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	go c.LoadOrCall("slow", func() interface{} {
+		close(started)
+		<-release
+		return "slow"
+	})
+	<-started
+	done := make(chan interface{})
+	go func() {
+		done <- c.LoadOrCall("fast", func() interface{} { return "fast" })
+	}()
+	select {
+	case v := <-done:
+		if v != "fast" {
+			t.Errorf("LoadOrCall(fast) = %v, want fast", v)
+		}
+	case <-time.After(blockTimeout):
+		t.Error("LoadOrCall(fast) is blocked by the load of another key")
+	}
+}
+
+func testCacheDelete(t *testing.T, c memocache.CacheInterface) {
+	if v := c.LoadOrCall("key", func() interface{} { return 1 }); v != 1 {
+		t.Fatalf("LoadOrCall() = %v, want 1", v)
+	}
+	if v := c.LoadOrCall("key", func() interface{} { return 2 }); v != 1 {
+		t.Errorf("LoadOrCall() of a cached key = %v, want 1", v)
+	}
+	c.Delete("key")
+	if v := c.LoadOrCall("key", func() interface{} { return 3 }); v != 3 {
+		t.Errorf("LoadOrCall() after Delete() = %v, want 3", v)
+	}
+	c.Delete("missing")
+}
+
+func testCacheConcurrent(t *testing.T, c memocache.CacheInterface) {
+	var wg sync.WaitGroup
+	for g := 0; g < numGoroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := (g + i) % 10
+				if i%7 == 0 {
+					c.Delete(key)
+					continue
+				}
+				if v := c.LoadOrCall(key, func() interface{} { return fmt.Sprint(key) }); v != fmt.Sprint(key) {
+					t.Errorf("LoadOrCall(%d) = %v, want %q", key, v, fmt.Sprint(key))
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+// TestMapInterface tests that the maps made by newMap store the first value of
+// a key, forget deleted keys and stay consistent under concurrent use, and
+// runs TestCacheInterface on caches backed by them. The maps should hold at
+// least 100 keys.
+func TestMapInterface(t *testing.T, newMap func() memocache.MapInterface) {
+	t.Run("LoadOrStore", func(t *testing.T) {
+		m := newMap()
+		if actual, loaded := m.LoadOrStore("key", 1); actual != 1 || loaded {
+			t.Errorf("LoadOrStore() of a new key = %v, %v, want 1, false", actual, loaded)
+		}
+		if actual, loaded := m.LoadOrStore("key", 2); actual != 1 || !loaded {
+			t.Errorf("LoadOrStore() of a stored key = %v, %v, want 1, true", actual, loaded)
+		}
+	})
+	t.Run("Delete", func(t *testing.T) {
+		m := newMap()
+		m.LoadOrStore("key", 1)
+		m.Delete("key")
+		if actual, loaded := m.LoadOrStore("key", 2); actual != 2 || loaded {
+			t.Errorf("LoadOrStore() after Delete() = %v, %v, want 2, false", actual, loaded)
+		}
+		m.Delete("missing")
+	})
+	t.Run("Concurrent", func(t *testing.T) {
+		m := newMap()
+		var stored atomic.Int32
+		actuals := make(chan interface{}, numGoroutines)
+		for g := 0; g < numGoroutines; g++ {
+			go func(g int) {
+				actual, loaded := m.LoadOrStore("key", g)
+				if !loaded {
+					stored.Add(1)
+				}
+				actuals <- actual
+			}(g)
+		}
+		first := <-actuals
+		for g := 1; g < numGoroutines; g++ {
+			if actual := <-actuals; actual != first {
+				t.Errorf("LoadOrStore() = %v and %v for concurrent calls, want the same value", first, actual)
+			}
+		}
+		if n := stored.Load(); n != 1 {
+			t.Errorf("LoadOrStore() stored %d values for concurrent calls, want 1", n)
+		}
+	})
+	TestCacheInterface(t, func() memocache.CacheInterface {
+		return memocache.NewCache(newMap())
+	})
+}
+
+// TestBoundedMap tests that the maps made by newMap with a maximum size hold no
+// more keys than the size. The keys are counted with the Len method of the map
+// or its Range method like *sync.Map has, and the test is skipped if the map
+// has neither.
+func TestBoundedMap(t *testing.T, newMap func(maxSize int) memocache.MapInterface) {
+	for _, maxSize := range []int{1, 10, 100} {
+		t.Run(fmt.Sprint(maxSize), func(t *testing.T) {
+			m := newMap(maxSize)
+			if _, ok := count(m); !ok {
+				t.Skip("the map has neither Len nor Range")
+			}
+			var wg sync.WaitGroup
+			for g := 0; g < numGoroutines; g++ {
+				wg.Add(1)
+				go func(g int) {
+					defer wg.Done()
+					for i := 0; i < 10*maxSize; i++ {
+						m.LoadOrStore(fmt.Sprint(g, "/", i), i)
+					}
+				}(g)
+			}
+			wg.Wait()
+			if n, _ := count(m); n > maxSize {
+				t.Errorf("the map holds %d keys, want at most %d", n, maxSize)
+			}
+		})
+	}
+}
+
+// count returns the number of the keys of the map if it can be counted.
+func count(m memocache.MapInterface) (int, bool) {
+	switch m := m.(type) {
+	case interface{ Len() int }:
+		return m.Len(), true
+	case interface {
+		Range(f func(key, value interface{}) bool)
+	}:
+		n := 0
+		m.Range(func(key, value interface{}) bool {
+			n++
+			return true
+		})
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,46 @@
+package cachetest
+
+import (
+	"container/list"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/jaeyeom/gomemocache/memocache"
+)
+
+func TestMaps(t *testing.T) {
+	for name, newMap := range map[string]func(maxSize int) memocache.MapInterface{
+		"LRUMap":     func(n int) memocache.MapInterface { return memocache.NewLRUMap(list.New(), n) },
+		"FIFOMap":    func(n int) memocache.MapInterface { return memocache.NewFIFOMap(n) },
+		"SieveMap":   func(n int) memocache.MapInterface { return memocache.NewSieveMap(n) },
+		"SampledLRU": func(n int) memocache.MapInterface { return memocache.NewSampledLRUMap(n, 5) },
+		"TinyLFUMap": func(n int) memocache.MapInterface { return memocache.NewTinyLFUMap(n) },
+		// The shard sizes are rounded up, so they should divide the size.
+		"ShardedMap": func(n int) memocache.MapInterface { return memocache.NewShardedLRUMap(n, n) },
+	} {
+		newMap := newMap
+		t.Run(name, func(t *testing.T) {
+			TestMapInterface(t, func() memocache.MapInterface { return newMap(1000) })
+			TestBoundedMap(t, newMap)
+		})
+	}
+	t.Run("sync.Map", func(t *testing.T) {
+		TestMapInterface(t, func() memocache.MapInterface { return &sync.Map{} })
+	})
+}
+
+func TestCaches(t *testing.T) {
+	for name, newCache := range map[string]func() memocache.CacheInterface{
+		"Map": func() memocache.CacheInterface { return &memocache.Map{} },
+		"RRCache": func() memocache.CacheInterface {
+			return memocache.NewRRCache(new(int32), 1000, 500, rand.Intn)
+		},
+		"New": func() memocache.CacheInterface { return memocache.New(memocache.WithMaxSize(1000)) },
+	} {
+		newCache := newCache
+		t.Run(name, func(t *testing.T) {
+			TestCacheInterface(t, newCache)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package memocache
+
+import (
+	"container/list"
+	"sync"
+	"testing"
+)
+
+func TestCache_Clear(t *testing.T) {
+	for name, m := range map[string]MapInterface{
+		"sync.Map":     &sync.Map{},
+		"LRUMap":       NewLRUMap(list.New(), 10),
+		"sharedLRUMap": NewLRUMap(sharedListWith(t, 3), 10),
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := NewCache(m)
+			for i := 0; i < 5; i++ {
+				c.Store(i, i)
+			}
+			c.Clear()
+			if c.Len() != 0 {
+				t.Errorf("Len() = %d after Clear(), want 0", c.Len())
+			}
+			if got := c.LoadOrCall(1, func() interface{} { return "new" }); got != "new" {
+				t.Errorf("LoadOrCall(1) = %v after Clear(), want new", got)
+			}
+		})
+	}
+}
+
+func TestLRUMap_ClearSharedList(t *testing.T) {
+	l := list.New()
+	a := NewLRUMap(l, 10)
+	b := NewLRUMap(l, 10)
+	a.LoadOrStore("a1", 1)
+	b.LoadOrStore("b1", 1)
+	a.LoadOrStore("a2", 2)
+
+	a.Clear()
+	if a.Len() != 0 || l.Len() != 1 {
+		t.Errorf("a.Len() = %d, list.Len() = %d after a.Clear(), want 0, 1", a.Len(), l.Len())
+	}
+	if _, ok := b.Load("b1"); !ok {
+		t.Error("a.Clear() deleted b1 from b")
+	}
+}
+
+// sharedListWith returns a list shared with another LRUMap holding n values.
+func sharedListWith(t *testing.T, n int) *list.List {
+	t.Helper()
+	l := list.New()
+	other := NewLRUMap(l, 100)
+	for i := 0; i < n; i++ {
+		other.LoadOrStore(i, i)
+	}
+	return l
+}
@@ -0,0 +1,59 @@
+package memocache
+
+import "time"
+
+// Clock tells the time to the time-dependent behavior of the caches: the
+// expiration of WithTTL, the timeout of WithWaitTimeout, leases, reports and
+// the schedules of Refresher. It's the real clock unless WithClock replaces
+// it, e.g. with a fake clock that tests advance by hand.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a timer made by a Clock, like *time.Timer.
+type Timer interface {
+	// C returns the channel the time is sent to when the timer fires.
+	C() <-chan time.Time
+	// Stop stops the timer. It returns false if the timer has already
+	// fired or been stopped.
+	Stop() bool
+}
+
+// WithClock replaces the real clock of a cache or a Refresher with the clock.
+func WithClock(clock Clock) Option {
+	return func(c *config) {
+		c.clock = clock
+	}
+}
+
+// realTimer is a Timer of the real clock.
+type realTimer struct {
+	t *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t realTimer) Stop() bool {
+	return t.t.Stop()
+}
+
+// now returns the current time of the clock, or of the real clock if clock is
+// nil.
+func now(clock Clock) time.Time {
+	if clock == nil {
+		return time.Now()
+	}
+	return clock.Now()
+}
+
+// newTimer returns a new timer of the clock, or of the real clock if clock is
+// nil, that fires after d.
+func newTimer(clock Clock, d time.Duration) Timer {
+	if clock == nil {
+		return realTimer{t: time.NewTimer(d)}
+	}
+	return clock.NewTimer(d)
+}
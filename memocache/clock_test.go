@@ -0,0 +1,127 @@
+package memocache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when it's advanced.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	c    chan time.Time
+	when time.Time
+	done bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1, 0)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{c: make(chan time.Time, 1), when: c.now.Add(d)}
+	c.timers = append(c.timers, t)
+	return fakeTimerRef{c: c, t: t}
+}
+
+// numTimers returns the number of the timers that haven't fired or been
+// stopped.
+func (c *fakeClock) numTimers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, t := range c.timers {
+		if !t.done {
+			n++
+		}
+	}
+	return n
+}
+
+// Advance moves the clock by d and fires the timers that are due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for _, t := range c.timers {
+		if !t.done && !t.when.After(c.now) {
+			t.done = true
+			t.c <- c.now
+		}
+	}
+}
+
+type fakeTimerRef struct {
+	c *fakeClock
+	t *fakeTimer
+}
+
+func (r fakeTimerRef) C() <-chan time.Time {
+	return r.t.c
+}
+
+func (r fakeTimerRef) Stop() bool {
+	r.c.mu.Lock()
+	defer r.c.mu.Unlock()
+	stopped := !r.t.done
+	r.t.done = true
+	return stopped
+}
+
+func TestWithClock(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCache(&sync.Map{}, WithClock(clock), WithTTL(time.Minute), WithLeaseDuration(time.Second))
+	n := 0
+	load := func() interface{} { n++; return n }
+	c.LoadOrCall("k", load)
+	clock.Advance(59 * time.Second)
+	if v := c.LoadOrCall("k", load); v != 1 {
+		t.Errorf("LoadOrCall() before the TTL = %v, want 1", v)
+	}
+	clock.Advance(time.Second)
+	if v := c.LoadOrCall("k", load); v != 2 {
+		t.Errorf("LoadOrCall() after the TTL = %v, want 2", v)
+	}
+
+	l, err := c.Lease("leased")
+	if err != nil {
+		t.Fatalf("Lease() error = %v", err)
+	}
+	clock.Advance(time.Second)
+	if l.Valid() {
+		t.Error("lease is valid after its duration")
+	}
+}
+
+func TestRefresherWithClock(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCache(&sync.Map{})
+	r := NewRefresher(context.Background(), WithClock(clock))
+	defer r.Close()
+	var n atomic.Int32
+	r.Register(c, "config", time.Minute, func() (interface{}, error) {
+		return n.Add(1), nil
+	})
+	for want := int32(1); want <= 3; want++ {
+		if !waitFor(func() bool { v, _ := c.Load("config"); return v == want && clock.numTimers() == 1 }) {
+			v, _ := c.Load("config")
+			t.Fatalf("Load() = %v, want %d", v, want)
+		}
+		clock.Advance(time.Minute)
+	}
+}
@@ -0,0 +1,130 @@
+package memocache
+
+import "reflect"
+
+// valueCompareDeleter is implemented by the caches that can delete the entry
+// of a key only if it still holds a value.
+type valueCompareDeleter interface {
+	compareAndDeleteValue(key, old interface{}) bool
+}
+
+var (
+	_ valueCompareDeleter = (*Cache)(nil)
+	_ valueCompareDeleter = (*Map)(nil)
+	_ valueCompareDeleter = (*RRCache)(nil)
+)
+
+// CompareAndPrune prunes the value in path if it's still old, e.g. to drop an
+// expired value without dropping the value another goroutine has loaded in its
+// place, and returns true if it's pruned. The values are compared with ==, so
+// a value of a type that isn't comparable is never pruned. It never adds the
+// levels of the path. If the leaf level can neither compare and delete its
+// entries like Cache can nor load them, the path is pruned as by Prune.
+func (m *MultiLevelMap) CompareAndPrune(old interface{}, path ...interface{}) bool {
+	path = m.canonical(path)
+	n := len(path)
+	if n == 0 {
+		panic("path was not given")
+	}
+	leaf, ok := m.existingLevel(path[:n-1])
+	if !ok {
+		return false
+	}
+	l, ok := leaf.(CacheInterface)
+	if !ok || !deleteValue(l, path[n-1], old) {
+		return false
+	}
+	m.dropSubtreeStats(path)
+	m.dropPathExpiries(path)
+	m.pruneDependents(path)
+	m.bus.publish(path...)
+	return true
+}
+
+// existingLevel returns the level in path without adding the missing levels.
+func (m *MultiLevelMap) existingLevel(path []interface{}) (level interface{}, ok bool) {
+	level, ok = m.v.Load()
+	for _, key := range path {
+		if !ok {
+			return nil, false
+		}
+		l, isLoader := level.(mapLoader)
+		if !isLoader {
+			return nil, false
+		}
+		level, ok = l.Load(key)
+	}
+	return level, ok
+}
+
+// deleteValue deletes the entry of the key from the leaf if its value is
+// still old, and returns true if it's deleted. Leaves that can't compare their
+// values have the key deleted.
+func deleteValue(leaf CacheInterface, key, old interface{}) bool {
+	switch l := leaf.(type) {
+	case valueCompareDeleter:
+		return l.compareAndDeleteValue(key, old)
+	case mapCompareDeleter:
+		return isComparable(old) && l.CompareAndDelete(key, old)
+	case mapLoader:
+		if value, ok := l.Load(key); !ok || !isComparable(old) || value != old {
+			return false
+		}
+	}
+	leaf.Delete(key)
+	return true
+}
+
+// isComparable returns true if == doesn't panic on the value.
+func isComparable(value interface{}) bool {
+	return value == nil || reflect.TypeOf(value).Comparable()
+}
+
+// entryHolds returns true if the entry e holds the ready value.
+func entryHolds(e, value interface{}) bool {
+	v, ok := e.(*Value)
+	if !ok {
+		return false
+	}
+	s := v.state.Load()
+	return s != nil && isComparable(value) && s.value == value
+}
+
+// CompareAndDelete deletes the value of the key if it's still old, e.g. to
+// drop an expired value without dropping the value another goroutine has
+// loaded in its place, and returns true if it's deleted. The values are
+// compared with ==. Unlike Delete, it's housekeeping of the cache alone: the
+// store of WithWriteThrough, the bus of WithInvalidationBus, the recorder and
+// the lease of the key aren't involved. It returns false if the backing map
+// doesn't have a Load method like *sync.Map has.
+func (c *Cache) CompareAndDelete(key, old interface{}) bool {
+	return c.compareAndDeleteValue(key, old)
+}
+
+func (c *Cache) compareAndDeleteValue(key, old interface{}) bool {
+	m, ok := c.m.(mapLoader)
+	if !ok {
+		return false
+	}
+	e, ok := m.Load(key)
+	if !ok || !entryHolds(e, old) {
+		return false
+	}
+	if !c.compareAndDelete(key, e.(*Value), EvictionDeleted) {
+		if c.canCompare() {
+			return false
+		}
+		c.deleteKey(key, EvictionDeleted)
+	}
+	return true
+}
+
+func (m *Map) compareAndDeleteValue(key, old interface{}) bool {
+	e, ok := m.m.Load(key)
+	return ok && entryHolds(e, old) && m.m.CompareAndDelete(key, e)
+}
+
+func (r *RRCache) compareAndDeleteValue(key, old interface{}) bool {
+	e, ok := r.m.Load(key)
+	return ok && entryHolds(e, old) && r.compareAndDelete(key, e, EvictionDeleted)
+}
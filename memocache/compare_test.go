@@ -0,0 +1,64 @@
+package memocache
+
+import (
+	"container/list"
+	"sync"
+	"testing"
+)
+
+func TestMultiLevelMap_CompareAndPrune(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		newMap func() CacheInterface
+	}{
+		{"Map", nil},
+		{"Cache", func() CacheInterface { return NewCache(&sync.Map{}) }},
+		{"LRUCache", func() CacheInterface { return NewCache(NewLRUMap(list.New(), 10)) }},
+		{"RRCache", func() CacheInterface {
+			var size int32
+			return NewRRCache(&size, 10, 5, nil)
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewMultiLevelMap(tc.newMap)
+			m.StorePath("old", "a", "b")
+			if m.CompareAndPrune("other", "a", "b") {
+				t.Error("CompareAndPrune() of another value = true")
+			}
+			if m.CompareAndPrune("old", "a", "missing") || m.CompareAndPrune("old", "x", "b") {
+				t.Error("CompareAndPrune() of a missing path = true")
+			}
+			if !m.CompareAndPrune("old", "a", "b") {
+				t.Error("CompareAndPrune() of the value = false")
+			}
+			if v, ok := m.Load("a", "b"); ok {
+				t.Errorf("Load() = %v after CompareAndPrune()", v)
+			}
+			m.StorePath([]int{1}, "a", "b")
+			if m.CompareAndPrune([]int{1}, "a", "b") {
+				t.Error("CompareAndPrune() of an incomparable value = true")
+			}
+		})
+	}
+}
+
+func TestCache_CompareAndDelete(t *testing.T) {
+	var published int
+	var bus LocalBus
+	defer bus.Subscribe(func(Invalidation) { published++ })()
+	c := NewCache(&sync.Map{}, WithInvalidationBus(&bus, nil))
+	defer c.Close()
+	c.Store("k", 1)
+	if c.CompareAndDelete("k", 2) {
+		t.Error("CompareAndDelete(k, 2) = true for 1")
+	}
+	if !c.CompareAndDelete("k", 1) {
+		t.Error("CompareAndDelete(k, 1) = false for 1")
+	}
+	if v, ok := c.Load("k"); ok {
+		t.Errorf("Load(k) = %v after CompareAndDelete(), want none", v)
+	}
+	if published != 0 {
+		t.Errorf("CompareAndDelete() published %d invalidations, want 0", published)
+	}
+}
@@ -0,0 +1,161 @@
+package memocache
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Policy is a replacement policy of a cache described by a Config.
+type Policy string
+
+// Replacement policies of a Config.
+const (
+	// PolicyUnbounded never evicts. It's backed by a sync.Map. It's the
+	// default policy.
+	PolicyUnbounded Policy = "unbounded"
+	// PolicyLRU evicts the least recently used entries. It's backed by a
+	// LRUMap.
+	PolicyLRU Policy = "lru"
+	// PolicyRandom evicts random entries. It's backed by a RRCache.
+	PolicyRandom Policy = "random"
+	// PolicyFIFO evicts the oldest entries. It's backed by a FIFOMap.
+	PolicyFIFO Policy = "fifo"
+	// PolicyLFU is LRU with TinyLFU admission rather than a true LFU: it
+	// evicts the least recently used entries but only admits new entries
+	// more frequently used than the entries they would evict. It's backed by
+	// a TinyLFUMap, so it can't be used for the levels of a MultiLevelMap,
+	// whose rejected inner levels would lose their subtrees.
+	PolicyLFU Policy = "lfu"
+)
+
+// Duration is a time.Duration that is written as a string like "1m30s" in
+// configuration files.
+type Duration time.Duration
+
+// MarshalText encodes the duration like "1m30s".
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText decodes a duration like "1m30s".
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Config describes a cache, so that services can declare their caches in
+// configuration files and construct them uniformly with NewFromConfig. The
+// zero Config is an unbounded cache.
+type Config struct {
+	// Name identifies the cache in error messages.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// Policy is the replacement policy. Empty means PolicyUnbounded.
+	Policy Policy `json:"policy,omitempty" yaml:"policy,omitempty"`
+	// MaxSize is the maximum number of entries. It's required by all the
+	// policies but PolicyUnbounded, and at most math.MaxInt32 for
+	// PolicyRandom.
+	MaxSize int `json:"maxSize,omitempty" yaml:"maxSize,omitempty"`
+	// TargetSize is the number of entries PolicyRandom evicts down to when
+	// MaxSize is exceeded. Zero means half of MaxSize.
+	TargetSize int `json:"targetSize,omitempty" yaml:"targetSize,omitempty"`
+	// TTL is the time to live of the values. Zero means no expiration. See
+	// WithTTL.
+	TTL Duration `json:"ttl,omitempty" yaml:"ttl,omitempty"`
+	// LatencyBudget is the p99 load latency budget. Zero means no budget.
+	// See WithLatencyBudget.
+	LatencyBudget Duration `json:"latencyBudget,omitempty" yaml:"latencyBudget,omitempty"`
+	// ReadYourWrites enables WithReadYourWrites.
+	ReadYourWrites bool `json:"readYourWrites,omitempty" yaml:"readYourWrites,omitempty"`
+}
+
+// Validate returns an error describing all the problems of the config, or nil
+// if the config is valid.
+func (cfg Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	switch cfg.Policy {
+	case "", PolicyUnbounded:
+		if cfg.MaxSize != 0 {
+			fail("maxSize %d is given to an unbounded cache", cfg.MaxSize)
+		}
+	case PolicyLRU, PolicyRandom, PolicyFIFO, PolicyLFU:
+		if cfg.MaxSize <= 0 {
+			fail("maxSize should be positive for policy %q, got %d", cfg.Policy, cfg.MaxSize)
+		}
+	default:
+		fail("unknown policy %q, want one of %q, %q, %q, %q and %q", cfg.Policy, PolicyUnbounded, PolicyLRU, PolicyRandom, PolicyFIFO, PolicyLFU)
+	}
+	if cfg.Policy == PolicyRandom {
+		if cfg.MaxSize > math.MaxInt32 {
+			fail("maxSize should be at most %d for policy %q, got %d", math.MaxInt32, cfg.Policy, cfg.MaxSize)
+		}
+		if cfg.TargetSize < 0 || cfg.MaxSize > 0 && cfg.TargetSize >= cfg.MaxSize {
+			fail("targetSize should be in [0, maxSize), got %d", cfg.TargetSize)
+		}
+		for _, opt := range []struct {
+			name  string
+			given bool
+		}{
+			{"ttl", cfg.TTL != 0},
+			{"latencyBudget", cfg.LatencyBudget != 0},
+			{"readYourWrites", cfg.ReadYourWrites},
+		} {
+			if opt.given {
+				fail("%s is not supported by policy %q", opt.name, cfg.Policy)
+			}
+		}
+	} else if cfg.TargetSize != 0 {
+		fail("targetSize is only supported by policy %q", PolicyRandom)
+	}
+	if cfg.TTL < 0 {
+		fail("ttl should not be negative, got %v", time.Duration(cfg.TTL))
+	}
+	if cfg.LatencyBudget < 0 {
+		fail("latencyBudget should not be negative, got %v", time.Duration(cfg.LatencyBudget))
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	name := "memocache: config"
+	if cfg.Name != "" {
+		name = fmt.Sprintf("memocache: config of %q", cfg.Name)
+	}
+	return fmt.Errorf("%s: %w", name, errors.Join(errs...))
+}
+
+// NewFromConfig returns a new cache described by cfg, or an error if cfg is
+// invalid. The opts are applied after the options derived from cfg, e.g. to
+// add hooks that can't be written in a configuration file.
+func NewFromConfig(cfg Config, opts ...Option) (ExtendedCache, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	var cfgOpts []Option
+	if cfg.TTL != 0 {
+		cfgOpts = append(cfgOpts, WithTTL(time.Duration(cfg.TTL)))
+	}
+	if cfg.LatencyBudget != 0 {
+		cfgOpts = append(cfgOpts, WithLatencyBudget(time.Duration(cfg.LatencyBudget), nil))
+	}
+	if cfg.ReadYourWrites {
+		cfgOpts = append(cfgOpts, WithReadYourWrites())
+	}
+	opts = append(cfgOpts, opts...)
+
+	if cfg.Policy == PolicyRandom {
+		targetSize := cfg.TargetSize
+		if targetSize == 0 {
+			targetSize = cfg.MaxSize / 2
+		}
+		return NewRRCacheLocal(int32(cfg.MaxSize), int32(targetSize), nil, opts...), nil
+	}
+	return New(append([]Option{WithEvictionPolicy(cfg.Policy), WithMaxSize(cfg.MaxSize)}, opts...)...), nil
+}
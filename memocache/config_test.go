@@ -0,0 +1,103 @@
+package memocache
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func ExampleNewFromConfig() {
+	var cfgs []Config
+	if err := json.Unmarshal([]byte(`[
+		{"name": "users", "policy": "lru", "maxSize": 1000, "ttl": "10m"},
+		{"name": "flags"}
+	]`), &cfgs); err != nil {
+		panic(err)
+	}
+
+	caches := map[string]ExtendedCache{}
+	for _, cfg := range cfgs {
+		c, err := NewFromConfig(cfg)
+		if err != nil {
+			panic(err)
+		}
+		caches[cfg.Name] = c
+	}
+	fmt.Println(caches["users"].LoadOrCall(1, func() interface{} { return "alice" }))
+
+	_, err := NewFromConfig(Config{Name: "sessions", Policy: "arc"})
+	fmt.Println(err)
+	// Output:
+	// alice
+	// memocache: config of "sessions": unknown policy "arc", want one of "unbounded", "lru", "random", "fifo" and "lfu"
+}
+
+func TestNewFromConfig(t *testing.T) {
+	for _, cfg := range []Config{
+		{},
+		{Policy: PolicyUnbounded, TTL: Duration(time.Minute), ReadYourWrites: true},
+		{Policy: PolicyLRU, MaxSize: 10, LatencyBudget: Duration(time.Second)},
+		{Policy: PolicyRandom, MaxSize: 10},
+		{Policy: PolicyRandom, MaxSize: 10, TargetSize: 3},
+		{Policy: PolicyFIFO, MaxSize: 10},
+		{Policy: PolicyLFU, MaxSize: 10, TTL: Duration(time.Minute)},
+	} {
+		c, err := NewFromConfig(cfg)
+		if err != nil {
+			t.Errorf("NewFromConfig(%+v) error = %v", cfg, err)
+			continue
+		}
+		if v := c.LoadOrCall("k", func() interface{} { return 1 }); v != 1 {
+			t.Errorf("NewFromConfig(%+v).LoadOrCall() = %v, want 1", cfg, v)
+		}
+	}
+
+	tooLarge := math.MaxInt32
+	tooLarge++ // Wraps to a negative size where int is 32 bits
+	for _, tc := range []struct {
+		cfg  Config
+		want []string
+	}{
+		{Config{Policy: PolicyRandom, MaxSize: tooLarge}, []string{"maxSize should be"}},
+		{Config{MaxSize: 10}, []string{"maxSize 10 is given to an unbounded cache"}},
+		{Config{Policy: PolicyLRU}, []string{"maxSize should be positive"}},
+		{Config{Policy: PolicyLRU, MaxSize: 10, TargetSize: 5}, []string{"targetSize is only supported"}},
+		{Config{Policy: PolicyRandom, MaxSize: 10, TargetSize: 10, TTL: Duration(time.Second)}, []string{
+			"targetSize should be in [0, maxSize), got 10",
+			`ttl is not supported by policy "random"`,
+		}},
+		{Config{TTL: Duration(-time.Second), LatencyBudget: Duration(-time.Second)}, []string{
+			"ttl should not be negative",
+			"latencyBudget should not be negative",
+		}},
+	} {
+		_, err := NewFromConfig(tc.cfg)
+		if err == nil {
+			t.Errorf("NewFromConfig(%+v) succeeded, want error", tc.cfg)
+			continue
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("NewFromConfig(%+v) error = %v, want %q", tc.cfg, err, want)
+			}
+		}
+	}
+}
+
+func TestDuration_JSON(t *testing.T) {
+	cfg := Config{TTL: Duration(90 * time.Second)}
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), `{"ttl":"1m30s"}`; got != want {
+		t.Errorf("json.Marshal() = %s, want %s", got, want)
+	}
+	var got Config
+	if err := json.Unmarshal(b, &got); err != nil || got != cfg {
+		t.Errorf("json.Unmarshal() = %+v, %v, want %+v", got, err, cfg)
+	}
+}
@@ -0,0 +1,47 @@
+package memocache
+
+import "fmt"
+
+// PathConflictError is the error of a call on a path of a MultiLevelMap that
+// conflicts with the tree: a prefix of the path holds a value rather than a
+// subtree, or the path holds a subtree rather than a value. For example, a
+// value in ("a", "b") conflicts with any path under it like ("a", "b", "c").
+// LoadOrCallErr returns the error, and the methods without an error result,
+// e.g. LoadOrCall and StorePath, panic with it.
+type PathConflictError struct {
+	// Path is the path of the call.
+	Path []interface{}
+	// Conflict is the prefix of Path holding a value, or Path itself if it
+	// holds a subtree.
+	Conflict []interface{}
+}
+
+func (e *PathConflictError) Error() string {
+	if len(e.Conflict) == len(e.Path) {
+		return fmt.Sprintf("memocache: path %v holds a subtree rather than a value", Path(e.Path))
+	}
+	return fmt.Sprintf("memocache: path %v is under path %v, which holds a value", Path(e.Path), Path(e.Conflict))
+}
+
+// leaf returns the level holding the value of the non-empty path, adding the
+// missing levels, or a *PathConflictError if a prefix of the path holds a
+// value.
+func (m *MultiLevelMap) leaf(path []interface{}) (CacheInterface, error) {
+	leaf, i := m.findLeafNode(m.getRoot(), path[:len(path)-1]...)
+	if i >= 0 {
+		p := append([]interface{}(nil), path...)
+		return nil, &PathConflictError{Path: p, Conflict: p[:i+1]}
+	}
+	return leaf, nil
+}
+
+// checkLeafValue returns a *PathConflictError if the value found in the path
+// is a subtree. Like Walk, a value that is a CacheInterface is taken as a
+// level.
+func checkLeafValue(path []interface{}, value interface{}) error {
+	if _, ok := value.(CacheInterface); !ok {
+		return nil
+	}
+	p := append([]interface{}(nil), path...)
+	return &PathConflictError{Path: p, Conflict: p}
+}
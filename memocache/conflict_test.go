@@ -0,0 +1,36 @@
+package memocache
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPathConflict(t *testing.T) {
+	m := NewMultiLevelMap(nil)
+	m.LoadOrCall(func() interface{} { return 1 }, "a", "b")
+
+	_, err := m.LoadOrCallErr(func() (interface{}, error) { return 2, nil }, "a", "b", "c")
+	var pce *PathConflictError
+	if !errors.As(err, &pce) || len(pce.Conflict) != 2 {
+		t.Errorf("LoadOrCallErr(a, b, c) error = %v, want a conflict at (a, b)", err)
+	} else if want := "memocache: path a/b/c is under path a/b, which holds a value"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+	_, err = m.LoadOrCallErr(func() (interface{}, error) { return 2, nil }, "a")
+	if !errors.As(err, &pce) || len(pce.Conflict) != 1 {
+		t.Errorf("LoadOrCallErr(a) error = %v, want a conflict at (a)", err)
+	}
+	mustPanic(t, func() { m.LoadOrCall(func() interface{} { return 2 }, "a", "b", "c") })
+	mustPanic(t, func() { m.LoadOrCall(func() interface{} { return 2 }, "a") })
+	mustPanic(t, func() { m.StorePath(2, "a", "b", "c") })
+
+	// Pruning under a value does nothing, and the value stays.
+	m.Prune("a", "b", "c")
+	if v, ok := m.Load("a", "b"); !ok || v != 1 {
+		t.Errorf("Load(a, b) = %v, %v, want 1, true", v, ok)
+	}
+	m.Prune("a", "b")
+	if v := m.LoadOrCall(func() interface{} { return 3 }, "a", "b", "c"); v != 3 {
+		t.Errorf("LoadOrCall(a, b, c) after Prune(a, b) = %v, want 3", v)
+	}
+}
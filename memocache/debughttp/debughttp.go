@@ -0,0 +1,302 @@
+// Package debughttp provides an http.Handler for inspecting the caches of a
+// live process, in the spirit of expvar and net/http/pprof. It renders the
+// statistics, the sizes, the configurations and the hottest keys of the
+// registered caches, and optionally lets operators delete a key or prune a
+// path:
+//
+//	h := debughttp.NewHandler()
+//	h.Register("users", usersCache, usersConfig)
+//	h.Register("permissions", permissionsMultiLevelMap, nil)
+//	http.Handle("/debug/memocache", h)
+//
+// The page is HTML, or JSON with the query parameter format=json. The handler
+// exposes the keys of the caches, so it should be served only to operators,
+// like pprof.
+package debughttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jaeyeom/gomemocache/memocache"
+)
+
+// Source is an inspected cache. *memocache.Cache, *memocache.RRCache and
+// *memocache.MultiLevelMap implement Source. If the cache also has the
+// following methods, the handler uses them too:
+//
+//	Len() int                            // The number of entries is shown.
+//	TopKeys(n int) []memocache.HotKey    // The hottest keys are shown.
+//	Delete(key interface{})              // Keys can be deleted.
+//	Prune(path ...interface{})           // Paths can be pruned.
+type Source interface {
+	Stats() memocache.Stats
+}
+
+// Optional methods of a Source.
+type (
+	lener interface {
+		Len() int
+	}
+	topKeyer interface {
+		TopKeys(n int) []memocache.HotKey
+	}
+	deleter interface {
+		Delete(key interface{})
+	}
+	pruner interface {
+		Prune(path ...interface{})
+	}
+)
+
+// Handler is an http.Handler serving the state of the registered caches. GET
+// requests render the caches. If AllowInvalidation is set, POST requests with
+// the form values cache, the name of a cache, and either key, a key to delete,
+// or path, a path to prune with its elements separated by "/", invalidate the
+// cache. An empty path is rejected; the whole tree is pruned only with the form
+// value all=1. The POST requests of browsers from other origins are rejected,
+// so that a cross-site form can't invalidate the caches. A Handler should be
+// created with NewHandler, and its fields should be set before it serves
+// requests.
+type Handler struct {
+	// AllowInvalidation enables the POST requests deleting keys and pruning
+	// paths.
+	AllowInvalidation bool
+	// ParseKey converts the keys and the path elements of the POST requests
+	// to the keys of the caches. Nil keeps them strings.
+	ParseKey func(s string) interface{}
+	// TopN is the number of the hottest keys shown per cache, which are
+	// tracked only by the caches created with memocache.WithHotKeys.
+	TopN int
+
+	mu     sync.Mutex
+	caches map[string]registered
+}
+
+// registered is a registered cache and its configuration.
+type registered struct {
+	cache  Source
+	config interface{}
+}
+
+// NewHandler returns a new Handler showing the 10 hottest keys per cache and
+// not allowing invalidation.
+func NewHandler() *Handler {
+	return &Handler{TopN: 10, caches: make(map[string]registered)}
+}
+
+// Register adds the cache under the name, replacing the cache registered under
+// the same name if any. The config, e.g. the memocache.Config the cache was
+// created from, is shown as JSON unless it's nil.
+func (h *Handler) Register(name string, cache Source, config interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.caches[name] = registered{cache: cache, config: config}
+}
+
+// Unregister removes the cache of the name.
+func (h *Handler) Unregister(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.caches, name)
+}
+
+// CacheState is the state of a cache served by a Handler.
+type CacheState struct {
+	Name  string          `json:"name"`
+	Stats memocache.Stats `json:"stats"`
+	// Len is the number of entries, or -1 if the cache doesn't tell.
+	Len int `json:"len"`
+	// Config is the registered configuration.
+	Config json.RawMessage `json:"config,omitempty"`
+	// TopKeys are the hottest keys formatted with fmt.Sprint.
+	TopKeys []memocache.KeyHits `json:"topKeys,omitempty"`
+	// Deletable and Prunable tell whether the cache can be invalidated by
+	// key and by path.
+	Deletable bool `json:"deletable"`
+	Prunable  bool `json:"prunable"`
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		h.serveState(w, r)
+	case http.MethodPost:
+		h.serveInvalidation(w, r)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// states returns the states of the registered caches sorted by their names.
+func (h *Handler) states() []CacheState {
+	h.mu.Lock()
+	caches := make(map[string]registered, len(h.caches))
+	for name, c := range h.caches {
+		caches[name] = c
+	}
+	h.mu.Unlock()
+
+	states := make([]CacheState, 0, len(caches))
+	for name, c := range caches {
+		s := CacheState{Name: name, Stats: c.cache.Stats(), Len: -1}
+		if l, ok := c.cache.(lener); ok {
+			s.Len = l.Len()
+		}
+		if c.config != nil {
+			if data, err := json.Marshal(c.config); err == nil {
+				s.Config = data
+			}
+		}
+		if t, ok := c.cache.(topKeyer); ok && h.TopN > 0 {
+			for _, k := range t.TopKeys(h.TopN) {
+				s.TopKeys = append(s.TopKeys, memocache.KeyHits{Key: fmt.Sprint(k.Key), Hits: k.Count})
+			}
+		}
+		_, s.Deletable = c.cache.(deleter)
+		_, s.Prunable = c.cache.(pruner)
+		states = append(states, s)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states
+}
+
+// serveState renders the states of the caches.
+func (h *Handler) serveState(w http.ResponseWriter, r *http.Request) {
+	states := h.states()
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(states)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	page.Execute(w, struct {
+		Caches            []CacheState
+		AllowInvalidation bool
+	}{states, h.AllowInvalidation})
+}
+
+// serveInvalidation deletes a key or prunes a path of a cache.
+func (h *Handler) serveInvalidation(w http.ResponseWriter, r *http.Request) {
+	if !h.AllowInvalidation {
+		http.Error(w, "invalidation is not allowed", http.StatusForbidden)
+		return
+	}
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin invalidation is not allowed", http.StatusForbidden)
+		return
+	}
+	name := r.FormValue("cache")
+	h.mu.Lock()
+	c, ok := h.caches[name]
+	h.mu.Unlock()
+	if !ok {
+		http.Error(w, "unknown cache "+name, http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.Form.Has("key"):
+		d, ok := c.cache.(deleter)
+		if !ok {
+			http.Error(w, "cache "+name+" can't delete keys", http.StatusBadRequest)
+			return
+		}
+		d.Delete(h.parseKey(r.FormValue("key")))
+	case r.Form.Has("path") || r.Form.Has("all"):
+		p, ok := c.cache.(pruner)
+		if !ok {
+			http.Error(w, "cache "+name+" can't prune paths", http.StatusBadRequest)
+			return
+		}
+		var path []interface{}
+		if s := r.FormValue("path"); s != "" {
+			for _, elem := range strings.Split(s, "/") {
+				path = append(path, h.parseKey(elem))
+			}
+		}
+		if len(path) == 0 && r.FormValue("all") != "1" {
+			http.Error(w, "empty path; set all=1 to prune the whole tree", http.StatusBadRequest)
+			return
+		}
+		p.Prune(path...)
+	default:
+		http.Error(w, "missing key or path", http.StatusBadRequest)
+		return
+	}
+	// Go back to the page after a form submission, like a browser expects.
+	http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+}
+
+// sameOrigin returns whether the request comes from a page of the origin of
+// the handler, or from a client that isn't a browser and sends no Origin.
+func sameOrigin(r *http.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "", "same-origin", "none":
+	default:
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+// parseKey converts a key given in a request to a key of the caches.
+func (h *Handler) parseKey(s string) interface{} {
+	if h.ParseKey == nil {
+		return s
+	}
+	return h.ParseKey(s)
+}
+
+var page = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head><title>memocache</title></head>
+<body>
+<h1>memocache</h1>
+{{- range .Caches}}
+<h2>{{.Name}}</h2>
+<table>
+<tr><td>Hits</td><td>{{.Stats.Hits}}</td></tr>
+<tr><td>Misses</td><td>{{.Stats.Misses}}</td></tr>
+<tr><td>Load errors</td><td>{{.Stats.LoadErrors}}</td></tr>
+{{- if ge .Len 0}}
+<tr><td>Entries</td><td>{{.Len}}</td></tr>
+{{- end}}
+</table>
+{{- if .Config}}
+<h3>Configuration</h3>
+<pre>{{printf "%s" .Config}}</pre>
+{{- end}}
+{{- if .TopKeys}}
+<h3>Hottest keys</h3>
+<table>
+{{- range .TopKeys}}
+<tr><td>{{.Key}}</td><td>{{.Hits}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if $.AllowInvalidation}}
+{{- if .Deletable}}
+<form method="post"><input type="hidden" name="cache" value="{{.Name}}">
+<input name="key" placeholder="key"> <button>Delete</button></form>
+{{- end}}
+{{- if .Prunable}}
+<form method="post"><input type="hidden" name="cache" value="{{.Name}}">
+<input name="path" placeholder="path/to/prune"> <button>Prune</button></form>
+{{- end}}
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
@@ -0,0 +1,118 @@
+package debughttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jaeyeom/gomemocache/memocache"
+)
+
+func TestHandler(t *testing.T) {
+	c := memocache.NewCache(&sync.Map{}, memocache.WithHotKeys(10, time.Minute))
+	c.LoadOrCall("a", func() interface{} { return 1 })
+	c.LoadOrCall("a", func() interface{} { return 1 })
+	m := memocache.NewMultiLevelMap(func() memocache.CacheInterface { return memocache.NewCache(&sync.Map{}) })
+	m.LoadOrCall(func() interface{} { return 2 }, "x", "y")
+
+	h := NewHandler()
+	h.Register("keys", c, memocache.Config{Policy: memocache.PolicyLRU, MaxSize: 100})
+	h.Register("paths", m, nil)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/?format=json", nil))
+	var states []CacheState
+	if err := json.NewDecoder(rec.Body).Decode(&states); err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 2 || states[0].Name != "keys" || states[1].Name != "paths" {
+		t.Fatalf("states = %+v, want keys and paths", states)
+	}
+	keys := states[0]
+	if keys.Stats.Hits != 1 || keys.Stats.Misses != 1 {
+		t.Errorf("keys.Stats = %+v, want 1 hit and 1 miss", keys.Stats)
+	}
+	if len(keys.TopKeys) != 1 || keys.TopKeys[0] != (memocache.KeyHits{Key: "a", Hits: 2}) {
+		t.Errorf("keys.TopKeys = %+v, want a with 2 requests", keys.TopKeys)
+	}
+	if !strings.Contains(string(keys.Config), `"maxSize":100`) {
+		t.Errorf("keys.Config = %s, want the maximum size", keys.Config)
+	}
+	if !keys.Deletable || keys.Prunable || !states[1].Prunable {
+		t.Errorf("states = %+v, want keys deletable and paths prunable", states)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "<h2>keys</h2>") || !strings.Contains(body, "maxSize") || strings.Contains(body, "<form") {
+		t.Errorf("page = %s, want the caches without forms", body)
+	}
+}
+
+func TestHandler_Invalidation(t *testing.T) {
+	c := memocache.NewCache(&sync.Map{})
+	c.LoadOrCall("a", func() interface{} { return 1 })
+	m := memocache.NewMultiLevelMap(func() memocache.CacheInterface { return memocache.NewCache(&sync.Map{}) })
+	m.LoadOrCall(func() interface{} { return 2 }, "x", "y")
+	m.LoadOrCall(func() interface{} { return 3 }, "z", "y")
+	h := NewHandler()
+	h.Register("keys", c, nil)
+	h.Register("paths", m, nil)
+
+	post := func(form url.Values, origin ...string) int {
+		req := httptest.NewRequest("POST", "/debug/memocache", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if len(origin) > 0 {
+			req.Header.Set("Origin", origin[0])
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := post(url.Values{"cache": {"keys"}, "key": {"a"}}); code != http.StatusForbidden {
+		t.Errorf("POST without AllowInvalidation = %d, want %d", code, http.StatusForbidden)
+	}
+
+	h.AllowInvalidation = true
+	if code := post(url.Values{"cache": {"keys"}, "key": {"a"}}); code != http.StatusSeeOther {
+		t.Errorf("POST key = %d, want %d", code, http.StatusSeeOther)
+	}
+	if _, ok := c.Load("a"); ok {
+		t.Error("key a is still cached after POST key=a")
+	}
+	if code := post(url.Values{"cache": {"paths"}, "path": {"x/y"}}); code != http.StatusSeeOther {
+		t.Errorf("POST path = %d, want %d", code, http.StatusSeeOther)
+	}
+	if _, ok := m.Load("x", "y"); ok {
+		t.Error("path x/y is still cached after POST path=x/y")
+	}
+	if _, ok := m.Load("z", "y"); !ok {
+		t.Error("path z/y isn't cached after POST path=x/y")
+	}
+	if code := post(url.Values{"cache": {"paths"}, "path": {""}}); code != http.StatusBadRequest {
+		t.Errorf("POST empty path = %d, want %d", code, http.StatusBadRequest)
+	}
+	if _, ok := m.Load("z", "y"); !ok {
+		t.Error("path z/y isn't cached after POST of an empty path")
+	}
+	if code := post(url.Values{"cache": {"paths"}, "path": {"z/y"}}, "https://evil.example"); code != http.StatusForbidden {
+		t.Errorf("POST from another origin = %d, want %d", code, http.StatusForbidden)
+	}
+	if code := post(url.Values{"cache": {"paths"}, "path": {"z/y"}}, "http://example.com"); code != http.StatusSeeOther {
+		t.Errorf("POST from the same origin = %d, want %d", code, http.StatusSeeOther)
+	}
+	if code := post(url.Values{"cache": {"paths"}, "all": {"1"}}); code != http.StatusSeeOther {
+		t.Errorf("POST all=1 = %d, want %d", code, http.StatusSeeOther)
+	}
+	if code := post(url.Values{"cache": {"paths"}, "key": {"x"}}); code != http.StatusBadRequest {
+		t.Errorf("POST key to a MultiLevelMap = %d, want %d", code, http.StatusBadRequest)
+	}
+	if code := post(url.Values{"cache": {"nope"}, "key": {"a"}}); code != http.StatusNotFound {
+		t.Errorf("POST to an unknown cache = %d, want %d", code, http.StatusNotFound)
+	}
+}
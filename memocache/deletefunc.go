@@ -0,0 +1,72 @@
+package memocache
+
+// DeleteFunc deletes the entries whose key and ready value satisfy f, e.g. the
+// keys with a prefix, and returns the number of deleted entries. Each matched
+// key is deleted as by Delete, so a value stored for it concurrently may be
+// deleted as well. Entries being loaded and expired entries are not visited.
+// Nothing is deleted if the backing map doesn't have a Range method like
+// *sync.Map has.
+func (c *Cache) DeleteFunc(f func(key, value interface{}) bool) int {
+	var keys []interface{}
+	c.Range(func(key, value interface{}) bool {
+		if f(key, value) {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	for _, key := range keys {
+		c.Delete(key)
+	}
+	return len(keys)
+}
+
+// DeleteFunc deletes the entries whose key and ready value satisfy f, and
+// returns the number of deleted entries. Each matched key is deleted as by
+// Delete. Entries being loaded are not visited.
+func (r *RRCache) DeleteFunc(f func(key, value interface{}) bool) int {
+	var keys []interface{}
+	r.Range(func(key, value interface{}) bool {
+		if f(key, value) {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	for _, key := range keys {
+		r.Delete(key)
+	}
+	return len(keys)
+}
+
+// DeleteFunc deletes the keys whose key and value satisfy f at once, and
+// returns the number of deleted keys. f is called with the lock of the list
+// held, so it must not call the methods of the maps sharing the list.
+func (l *LRUMap) DeleteFunc(f func(key, value interface{}) bool) int {
+	l.mu.Lock()
+	defer l.unlock()
+	n := 0
+	for _, e := range l.m {
+		kv := e.Value.(*keyValue)
+		if f(kv.Key, kv.Value) {
+			l.deleteElement(e, EvictionDeleted)
+			n++
+		}
+	}
+	return n
+}
+
+// PruneFunc prunes the paths whose ready leaf value satisfies f, e.g. the
+// values older than a time, and returns the number of pruned paths. The leaves
+// are visited as by Walk and each matched path is pruned as by Prune.
+func (m *MultiLevelMap) PruneFunc(f func(path []interface{}, value interface{}) bool) int {
+	var paths [][]interface{}
+	m.Walk(func(path []interface{}, value interface{}) bool {
+		if f(path, value) {
+			paths = append(paths, path)
+		}
+		return true
+	})
+	for _, path := range paths {
+		m.Prune(path...)
+	}
+	return len(paths)
+}
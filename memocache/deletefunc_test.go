@@ -0,0 +1,76 @@
+package memocache
+
+import (
+	"container/list"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDeleteFunc(t *testing.T) {
+	var size int32
+	for _, tc := range []struct {
+		name string
+		c    interface {
+			CacheInterface
+			Range(f func(key, value interface{}) bool)
+			DeleteFunc(f func(key, value interface{}) bool) int
+		}
+	}{
+		{"Cache", NewCache(&sync.Map{})},
+		{"LRUCache", NewCache(NewLRUMap(list.New(), 10))},
+		{"RRCache", NewRRCache(&size, 10, 5, rand.Intn)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, key := range []string{"user/1", "user/2", "group/1"} {
+				key := key
+				tc.c.LoadOrCall(key, func() interface{} { return len(key) })
+			}
+			n := tc.c.DeleteFunc(func(key, value interface{}) bool {
+				return strings.HasPrefix(key.(string), "user/")
+			})
+			if n != 2 {
+				t.Errorf("DeleteFunc() = %d, want 2", n)
+			}
+			var keys []interface{}
+			tc.c.Range(func(key, value interface{}) bool {
+				keys = append(keys, key)
+				return true
+			})
+			if len(keys) != 1 || keys[0] != "group/1" {
+				t.Errorf("keys after DeleteFunc() = %v, want [group/1]", keys)
+			}
+		})
+	}
+}
+
+func TestLRUMap_DeleteFunc(t *testing.T) {
+	m := NewLRUMap(list.New(), 10)
+	for i := 0; i < 10; i++ {
+		m.LoadOrStore(i, i)
+	}
+	if n := m.DeleteFunc(func(key, value interface{}) bool { return value.(int)%2 == 0 }); n != 5 {
+		t.Errorf("DeleteFunc() = %d, want 5", n)
+	}
+	if n := m.Len(); n != 5 {
+		t.Errorf("Len() = %d, want 5", n)
+	}
+}
+
+func TestMultiLevelMap_PruneFunc(t *testing.T) {
+	m := NewMultiLevelMap(func() CacheInterface { return NewCache(&sync.Map{}) })
+	for i := 0; i < 4; i++ {
+		i := i
+		m.LoadOrCall(func() interface{} { return i }, "a", i)
+	}
+	n := m.PruneFunc(func(path []interface{}, value interface{}) bool {
+		return value.(int) >= 2
+	})
+	if n != 2 {
+		t.Errorf("PruneFunc() = %d, want 2", n)
+	}
+	if s := m.Size(); s != 2 {
+		t.Errorf("Size() = %d, want 2", s)
+	}
+}
@@ -0,0 +1,200 @@
+package memocache
+
+import "sync/atomic"
+
+// AddDependency declares that the value of the key is derived from the value
+// of dependsOn, e.g. an aggregate computed from a raw fetch, so that the key is
+// deleted whenever dependsOn is deleted, stored, reloaded, expired or evicted,
+// and so on for the keys derived from it. The dependencies of a key are dropped
+// when the key leaves the cache or is stored, so they should be declared again
+// with the new value, e.g. by getValue of the key. Both keys should be in the
+// cache; for keys in different levels of a MultiLevelMap, use
+// AddPathDependency.
+func (c *Cache) AddDependency(key, dependsOn interface{}) {
+	c.depMu.Lock()
+	defer c.depMu.Unlock()
+	if c.dependents == nil {
+		c.dependents = make(map[interface{}]map[interface{}]bool)
+		c.derivedFrom = make(map[interface{}]map[interface{}]bool)
+	}
+	addEdge(c.dependents, dependsOn, key)
+	addEdge(c.derivedFrom, key, dependsOn)
+	atomic.StoreInt32(&c.numDeps, int32(len(c.derivedFrom)))
+}
+
+// deleteDependents drops the dependencies of the key and deletes the keys
+// derived from it.
+func (c *Cache) deleteDependents(key interface{}) {
+	c.invalidateDependents(key, true)
+}
+
+// invalidateDependents deletes the keys derived from the key. The dependencies
+// of the key itself are dropped too if drop is true.
+func (c *Cache) invalidateDependents(key interface{}, drop bool) {
+	if atomic.LoadInt32(&c.numDeps) == 0 {
+		return
+	}
+	c.depMu.Lock()
+	if drop {
+		for dep := range c.derivedFrom[key] {
+			removeEdge(c.dependents, dep, key)
+		}
+		delete(c.derivedFrom, key)
+	}
+	dependents := c.dependents[key]
+	delete(c.dependents, key)
+	for d := range dependents {
+		removeEdge(c.derivedFrom, d, key)
+	}
+	atomic.StoreInt32(&c.numDeps, int32(len(c.derivedFrom)))
+	c.depMu.Unlock()
+	// The dependencies of the dependents are dropped as they are deleted, so a
+	// cycle of dependencies ends.
+	for d := range dependents {
+		c.delete(d)
+	}
+}
+
+// dropDependencies drops all the dependencies between the keys.
+func (c *Cache) dropDependencies() {
+	c.depMu.Lock()
+	defer c.depMu.Unlock()
+	c.dependents = nil
+	c.derivedFrom = nil
+	atomic.StoreInt32(&c.numDeps, 0)
+}
+
+// addEdge adds to to the set of from in edges.
+func addEdge(edges map[interface{}]map[interface{}]bool, from, to interface{}) {
+	set, ok := edges[from]
+	if !ok {
+		set = make(map[interface{}]bool)
+		edges[from] = set
+	}
+	set[to] = true
+}
+
+// removeEdge removes to from the set of from in edges.
+func removeEdge(edges map[interface{}]map[interface{}]bool, from, to interface{}) {
+	delete(edges[from], to)
+	if len(edges[from]) == 0 {
+		delete(edges, from)
+	}
+}
+
+// pathDependency is a dependency declared by AddPathDependency.
+type pathDependency struct {
+	path, dependsOn []interface{}
+}
+
+// AddPathDependency declares that the value in path is derived from the value
+// in dependsOn, so that a Prune of dependsOn or of a subtree containing it, or
+// a StorePath of dependsOn, also prunes path, and so on for the paths derived
+// from it. Like Cache.AddDependency, the dependencies of a path are dropped
+// when the path or a subtree containing it is pruned or stored. The
+// dependencies are kept in a list scanned by every Prune and StorePath while
+// there are any, so they suit a moderate number of derived values. Paths
+// removed by their levels, e.g. evicted or expired from a Cache, are noticed
+// when the list has doubled since it was last swept: their dependencies are
+// dropped, and the paths derived from them are pruned.
+func (m *MultiLevelMap) AddPathDependency(path, dependsOn []interface{}) {
+	if len(path) == 0 || len(dependsOn) == 0 {
+		panic("path was not given")
+	}
+	m.depMu.Lock()
+	m.deps = append(m.deps, pathDependency{
+		path:      append([]interface{}(nil), m.canonical(path)...),
+		dependsOn: append([]interface{}(nil), m.canonical(dependsOn)...),
+	})
+	var dependents [][]interface{}
+	if len(m.deps) >= 2*m.depsSwept+minDepsSweep {
+		dependents = m.sweepPathDependencies()
+	}
+	m.depMu.Unlock()
+	for _, path := range dependents {
+		m.Prune(path...)
+	}
+}
+
+// minDepsSweep is the number of path dependencies added before the first
+// sweep.
+const minDepsSweep = 64
+
+// sweepPathDependencies drops the dependencies whose path has left the tree,
+// and returns the paths derived from the paths that have left it. The
+// dependencies added since the last sweep are kept, as their paths may still
+// be loading. It should be called with m.depMu held.
+func (m *MultiLevelMap) sweepPathDependencies() (dependents [][]interface{}) {
+	old := m.depsSwept
+	if old > len(m.deps) {
+		old = len(m.deps)
+	}
+	kept := m.deps[:0]
+	for i, d := range m.deps {
+		if i < old {
+			if _, ok := m.lookup(true, d.path); !ok {
+				continue
+			}
+			if _, ok := m.lookup(true, d.dependsOn); !ok {
+				dependents = append(dependents, d.path)
+				continue
+			}
+		}
+		kept = append(kept, d)
+	}
+	for i := len(kept); i < len(m.deps); i++ {
+		m.deps[i] = pathDependency{}
+	}
+	m.deps = kept
+	m.depsSwept = len(kept)
+	return dependents
+}
+
+// pruneDependents drops the dependencies of the paths under prefix and prunes
+// the paths derived from them.
+func (m *MultiLevelMap) pruneDependents(prefix []interface{}) {
+	m.depMu.Lock()
+	if len(m.deps) == 0 {
+		m.depMu.Unlock()
+		return
+	}
+	var dependents [][]interface{}
+	kept := m.deps[:0]
+	for _, d := range m.deps {
+		switch {
+		case hasPathPrefix(d.dependsOn, prefix):
+			dependents = append(dependents, d.path)
+		case !hasPathPrefix(d.path, prefix):
+			kept = append(kept, d)
+		}
+	}
+	for i := len(kept); i < len(m.deps); i++ {
+		m.deps[i] = pathDependency{}
+	}
+	m.deps = kept
+	m.depMu.Unlock()
+	for _, path := range dependents {
+		m.Prune(path...)
+	}
+}
+
+// dropPathDependencies drops all the dependencies between the paths.
+func (m *MultiLevelMap) dropPathDependencies() {
+	m.depMu.Lock()
+	defer m.depMu.Unlock()
+	m.deps = nil
+	m.depsSwept = 0
+}
+
+// hasPathPrefix returns true if path starts with prefix.
+func hasPathPrefix(path, prefix []interface{}) bool {
+	if len(path) < len(prefix) {
+		return false
+	}
+	for i, key := range prefix {
+		if path[i] != key {
+			return false
+		}
+	}
+	return true
+}
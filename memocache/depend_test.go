@@ -0,0 +1,139 @@
+package memocache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCache_AddDependency(t *testing.T) {
+	c := NewCache(&sync.Map{})
+	for _, key := range []string{"raw", "sum", "report", "other"} {
+		key := key
+		c.LoadOrCall(key, func() interface{} { return key })
+	}
+	c.AddDependency("sum", "raw")
+	c.AddDependency("report", "sum")
+	c.AddDependency("raw", "report") // A cycle ends at the first repeat.
+	c.Delete("raw")
+	for _, key := range []string{"raw", "sum", "report"} {
+		if _, ok := c.Load(key); ok {
+			t.Errorf("%s survived Delete(raw)", key)
+		}
+	}
+	if _, ok := c.Load("other"); !ok {
+		t.Error("unrelated key was deleted")
+	}
+
+	c.LoadOrCall("sum", func() interface{} { return "sum" })
+	c.AddDependency("sum", "other")
+	c.Store("other", "new")
+	if _, ok := c.Load("sum"); ok {
+		t.Error("sum survived Store(other)")
+	}
+	c.LoadOrCall("sum", func() interface{} { return "sum" })
+	c.Delete("other")
+	if _, ok := c.Load("sum"); !ok {
+		t.Error("the dropped dependency deleted sum again")
+	}
+}
+
+func TestMultiLevelMap_AddPathDependency(t *testing.T) {
+	m := NewMultiLevelMap(nil)
+	load := func(path ...interface{}) {
+		m.LoadOrCall(func() interface{} { return path[len(path)-1] }, path...)
+	}
+	load("raw", "a", 1)
+	load("raw", "b", 1)
+	load("agg", "total")
+	load("agg", "report")
+	m.AddPathDependency([]interface{}{"agg", "total"}, []interface{}{"raw", "a", 1})
+	m.AddPathDependency([]interface{}{"agg", "report"}, []interface{}{"agg", "total"})
+	m.Prune("raw", "a")
+	if _, ok := m.Load("agg", "total"); ok {
+		t.Error("total survived Prune(raw, a)")
+	}
+	if _, ok := m.Load("agg", "report"); ok {
+		t.Error("report survived Prune(raw, a)")
+	}
+	if _, ok := m.Load("raw", "b", 1); !ok {
+		t.Error("unrelated path was pruned")
+	}
+
+	load("agg", "total")
+	m.AddPathDependency([]interface{}{"agg", "total"}, []interface{}{"raw", "b", 1})
+	m.StorePath(2, "raw", "b", 1)
+	if _, ok := m.Load("agg", "total"); ok {
+		t.Error("total survived StorePath(raw, b, 1)")
+	}
+}
+
+func TestCache_AddDependencyEvicted(t *testing.T) {
+	c := NewCache(NewLRUMap(list.New(), 2))
+	c.LoadOrCall("raw", func() interface{} { return "raw" })
+	c.LoadOrCall("sum", func() interface{} { return "sum" })
+	c.AddDependency("sum", "raw")
+	c.LoadOrCall("other", func() interface{} { return "other" }) // Evicts raw.
+	if _, ok := c.Load("sum"); ok {
+		t.Error("sum survived the eviction of raw")
+	}
+	if n := atomic.LoadInt32(&c.numDeps); n != 0 {
+		t.Errorf("%d keys have dependencies after they left the cache", n)
+	}
+}
+
+func TestCache_AddDependencyExpired(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCache(&sync.Map{}, WithClock(clock), WithTTL(time.Minute))
+	c.LoadOrCall("raw", func() interface{} { return "raw" })
+	clock.Advance(time.Second)
+	c.LoadOrCall("sum", func() interface{} { return "sum" })
+	c.AddDependency("sum", "raw")
+	clock.Advance(time.Minute - time.Second)
+	c.DeleteExpired()
+	if _, ok := c.Load("sum"); ok {
+		t.Error("sum survived the expiry of raw")
+	}
+
+	c.LoadOrCall("raw", func() interface{} { return "raw" })
+	clock.Advance(time.Second)
+	c.LoadOrCall("sum", func() interface{} { return "sum" })
+	c.AddDependency("sum", "raw")
+	clock.Advance(time.Minute - time.Second)
+	if v := c.LoadOrCall("raw", func() interface{} { return "reloaded" }); v != "reloaded" {
+		t.Errorf("LoadOrCall(raw) = %v, want reloaded", v)
+	}
+	if _, ok := c.Load("sum"); ok {
+		t.Error("sum survived the reload of raw")
+	}
+	if n := atomic.LoadInt32(&c.numDeps); n != 0 {
+		t.Errorf("%d keys have dependencies after they left the cache", n)
+	}
+}
+
+func TestMultiLevelMap_AddPathDependencySwept(t *testing.T) {
+	m := NewMultiLevelMap(nil)
+	m.LoadOrCall(func() interface{} { return 1 }, "raw", 1)
+	m.LoadOrCall(func() interface{} { return 1 }, "agg", 1)
+	m.AddPathDependency([]interface{}{"agg", 1}, []interface{}{"raw", 1})
+	// Removed by the level itself, as if evicted.
+	leaf, err := m.leaf([]interface{}{"raw", 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf.Delete(1)
+	for i := 0; i < 4*minDepsSweep; i++ {
+		m.AddPathDependency([]interface{}{"x", i}, []interface{}{"y", i})
+	}
+	if _, ok := m.Load("agg", 1); ok {
+		t.Error("agg survived the removal of raw")
+	}
+	m.depMu.Lock()
+	n := len(m.deps)
+	m.depMu.Unlock()
+	if n >= 4*minDepsSweep {
+		t.Errorf("%d dependencies kept, want the dependencies of removed paths dropped", n)
+	}
+}
@@ -0,0 +1,168 @@
+package memocache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// TreeNode is a node of the tree of a MultiLevelMap rendered by Tree and
+// DumpTree for debugging, e.g. to see what is cached under the path of a
+// tenant reporting stale data. The keys are formatted with fmt.Sprint and the
+// values are described by their types rather than included, so that the tree
+// can always be serialized to JSON and read back by ReadTreeDump.
+type TreeNode struct {
+	// Key is the key of the node in its level. It's empty for the root.
+	Key string `json:"key,omitempty"`
+	// Children are the entries of the level of the node sorted by their
+	// keys, or nil for a leaf.
+	Children []*TreeNode `json:"children,omitempty"`
+	// Type is the type of the value of a leaf.
+	Type string `json:"type,omitempty"`
+	// Error is the message of the cached error of a leaf.
+	Error string `json:"error,omitempty"`
+	// Loading tells the value is being loaded for the first time.
+	Loading bool `json:"loading,omitempty"`
+	// Stale tells the value was marked stale, e.g. by Refresh.
+	Stale bool `json:"stale,omitempty"`
+	// Expired tells the value has expired but hasn't been dropped yet.
+	Expired bool `json:"expired,omitempty"`
+	// Created is when the value was set, and Expires is when it expires, or
+	// nil if it never does. They are unknown for the levels whose entries
+	// can't be inspected, i.e. other than Cache and RRCache.
+	Created *time.Time `json:"created,omitempty"`
+	Expires *time.Time `json:"expires,omitempty"`
+	// Hits is the number of hits of the entry counted by its level.
+	Hits uint64 `json:"hits,omitempty"`
+}
+
+// Tree returns the tree of the subtree of the path, or of the whole map
+// without a path, or nil if the path isn't cached. Unlike Walk, it includes
+// the entries being loaded, expired or holding cached errors, with their
+// metadata. It doesn't mark the paths as recently used. The levels without a
+// Range method like Cache has are rendered without children.
+func (m *MultiLevelMap) Tree(path ...interface{}) *TreeNode {
+	if len(path) == 0 {
+		root, ok := m.v.Load()
+		if !ok {
+			return &TreeNode{}
+		}
+		return dumpLevel(root)
+	}
+	path = m.canonical(path)
+	value, ok := m.load(true, path...)
+	if !ok {
+		return nil
+	}
+	node := &TreeNode{Key: fmt.Sprint(path[len(path)-1])}
+	if _, ok := value.(CacheInterface); ok {
+		node.Children = dumpLevel(value).Children
+	} else {
+		node.Type = fmt.Sprintf("%T", value)
+	}
+	return node
+}
+
+// DumpTree writes the tree of the subtree of the path, or of the whole map
+// without a path, to w as indented JSON. It writes null if the path isn't
+// cached. See Tree.
+func (m *MultiLevelMap) DumpTree(w io.Writer, path ...interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m.Tree(path...))
+}
+
+// MarshalJSON implements json.Marshaler with the tree of the whole map.
+func (m *MultiLevelMap) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.Tree())
+}
+
+// ReadTreeDump reads a tree written by DumpTree or MarshalJSON, e.g. for a
+// support tool inspecting the dump of another process.
+func ReadTreeDump(r io.Reader) (*TreeNode, error) {
+	var node *TreeNode
+	if err := json.NewDecoder(r).Decode(&node); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// Find returns the node of the path under n, with the elements of the path
+// formatted with fmt.Sprint like the keys of the tree, or nil if there is no
+// such node.
+func (n *TreeNode) Find(path ...interface{}) *TreeNode {
+	for _, key := range path {
+		if n == nil {
+			return nil
+		}
+		s := fmt.Sprint(key)
+		i := sort.Search(len(n.Children), func(i int) bool {
+			return n.Children[i].Key >= s
+		})
+		if i == len(n.Children) || n.Children[i].Key != s {
+			return nil
+		}
+		n = n.Children[i]
+	}
+	return n
+}
+
+// dumpLevel returns the node of the level with its entries as children.
+func dumpLevel(level interface{}) *TreeNode {
+	node := &TreeNode{Children: []*TreeNode{}}
+	add := func(key interface{}, e *Value, value interface{}) {
+		child := &TreeNode{Key: fmt.Sprint(key)}
+		if e != nil {
+			s := e.state.Load()
+			if s == nil {
+				child.Loading = true
+				node.Children = append(node.Children, child)
+				return
+			}
+			value = s.value
+			if s.err != nil {
+				child.Error = s.err.Error()
+			}
+			child.Stale = s.stale
+			child.Expired = s.expired()
+			created := time.Unix(0, s.created)
+			child.Created = &created
+			if s.expires != 0 {
+				expires := time.Unix(0, s.expires)
+				child.Expires = &expires
+			}
+			child.Hits = e.hits.Load()
+		}
+		if _, ok := value.(CacheInterface); ok {
+			child.Children = dumpLevel(value).Children
+		} else if child.Error == "" {
+			child.Type = fmt.Sprintf("%T", value)
+		}
+		node.Children = append(node.Children, child)
+	}
+	switch l := level.(type) {
+	case *Cache:
+		if r, ok := l.m.(mapRanger); ok {
+			r.Range(func(key, e interface{}) bool {
+				add(key, e.(*Value), nil)
+				return true
+			})
+		}
+	case *RRCache:
+		l.m.Range(func(key, e interface{}) bool {
+			add(key, e.(*Value), nil)
+			return true
+		})
+	case mapRanger:
+		l.Range(func(key, value interface{}) bool {
+			add(key, nil, value)
+			return true
+		})
+	}
+	sort.Slice(node.Children, func(i, j int) bool {
+		return node.Children[i].Key < node.Children[j].Key
+	})
+	return node
+}
@@ -0,0 +1,76 @@
+package memocache
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMultiLevelMap_DumpTree(t *testing.T) {
+	clock := newFakeClock()
+	m := NewMultiLevelMap(func() CacheInterface {
+		return NewCache(&sync.Map{}, WithClock(clock), WithTTL(time.Minute))
+	})
+	m.LoadOrCall(func() interface{} { return 1 }, "t1", "a")
+	m.LoadOrCall(func() interface{} { return 1 }, "t1", "a")
+	m.LoadOrCallErr(func() (interface{}, error) {
+		return nil, NewCachedError("not found", errNotLoaded)
+	}, "t1", "b")
+	m.LoadOrCall(func() interface{} { return "x" }, "t2", "x")
+	release := make(chan struct{})
+	defer close(release)
+	go m.LoadOrCall(func() interface{} {
+		<-release
+		return 0
+	}, "t1", "c")
+	waitFor(func() bool {
+		n := m.Tree("t1").Find("c")
+		return n != nil && n.Loading
+	})
+	clock.Advance(time.Second)
+
+	var buf bytes.Buffer
+	if err := m.DumpTree(&buf, "t1"); err != nil {
+		t.Fatalf("DumpTree() = %v", err)
+	}
+	tree, err := ReadTreeDump(&buf)
+	if err != nil {
+		t.Fatalf("ReadTreeDump() = %v", err)
+	}
+	if tree.Key != "t1" || len(tree.Children) != 3 {
+		t.Fatalf("DumpTree(t1) = %+v, want t1 with 3 children", tree)
+	}
+	a := tree.Find("a")
+	created := clock.Now().Add(-time.Second)
+	if a == nil || a.Type != "int" || a.Hits != 1 || a.Created == nil || !a.Created.Equal(created) ||
+		a.Expires == nil || !a.Expires.Equal(created.Add(time.Minute)) {
+		t.Errorf("Find(a) = %+v, want an int hit once created at %v expiring a minute later", a, created)
+	}
+	if b := tree.Find("b"); b == nil || b.Error == "" || b.Type != "" {
+		t.Errorf("Find(b) = %+v, want the cached error", b)
+	}
+	if c := tree.Find("c"); c == nil || !c.Loading {
+		t.Errorf("Find(c) = %+v, want it loading", c)
+	}
+	if n := tree.Find("x"); n != nil {
+		t.Errorf("Find(x) = %+v, want nil out of the subtree", n)
+	}
+
+	clock.Advance(time.Minute)
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("json.Marshal() = %v", err)
+	}
+	tree, err = ReadTreeDump(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("ReadTreeDump() = %v", err)
+	}
+	if x := tree.Find("t2", "x"); x == nil || x.Type != "string" || !x.Expired {
+		t.Errorf("Find(t2, x) = %+v, want an expired string", x)
+	}
+	if n := m.Tree("t3"); n != nil {
+		t.Errorf("Tree(t3) = %+v, want nil", n)
+	}
+}
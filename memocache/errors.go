@@ -0,0 +1,66 @@
+package memocache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// CachedError is an error produced for a key that is kept in the cache like a
+// value. A loader may return a *CachedError to have the failure memoized, so
+// that subsequent calls for the same key get the same error without hitting
+// the backend again. Key, Time and Attempts let callers tell errors apart,
+// e.g. "backend said not-found 2s ago" from "backend unreachable 30s ago".
+type CachedError struct {
+	// Key is the key that failed to load.
+	Key interface{}
+	// Err is the underlying error returned by the backend.
+	Err error
+	// Time is when the error was produced. A cache memoizing the error
+	// stamps it with the time of its clock, see WithClock.
+	Time time.Time
+	// Attempts is the number of load attempts made before giving up.
+	Attempts int
+
+	clock Clock // Clock of the cache that memoized the error, or nil
+}
+
+// NewCachedError returns a new CachedError for the key, stamped with the
+// current time and a single attempt.
+func NewCachedError(key interface{}, err error) *CachedError {
+	return &CachedError{
+		Key:      key,
+		Err:      err,
+		Time:     time.Now(),
+		Attempts: 1,
+	}
+}
+
+// Error implements the error interface.
+func (e *CachedError) Error() string {
+	return fmt.Sprintf("memocache: cached error for key %v: %v", e.Key, e.Err)
+}
+
+// Unwrap returns the underlying error, so errors.Is and errors.As see through
+// a CachedError.
+func (e *CachedError) Unwrap() error {
+	return e.Err
+}
+
+// Age returns how long ago the error was produced, by the clock of the cache
+// that memoized it.
+func (e *CachedError) Age() time.Duration {
+	return now(e.clock).Sub(e.Time)
+}
+
+// stamp stamps the error memoized by a cache with the time of its clock.
+func (e *CachedError) stamp(clock Clock) {
+	e.Time = now(clock)
+	e.clock = clock
+}
+
+// isContextErr returns whether err is the error of a done context.
+func isContextErr(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
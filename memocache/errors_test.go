@@ -0,0 +1,62 @@
+package memocache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func ExampleCachedError() {
+	m := NewCache(&sync.Map{})
+
+	var numCalls int
+	lookup := func(name string) interface{} {
+		return m.LoadOrCall(name, func() interface{} {
+			numCalls++
+			return NewCachedError(name, os.ErrNotExist)
+		})
+	}
+
+	lookup("config.yaml")
+	v := lookup("config.yaml")
+	if err, ok := v.(*CachedError); ok {
+		fmt.Println("key:", err.Key)
+		fmt.Println("not found:", errors.Is(err, os.ErrNotExist))
+		fmt.Println("attempts:", err.Attempts)
+	}
+	fmt.Println("calls:", numCalls)
+	// Output:
+	// key: config.yaml
+	// not found: true
+	// attempts: 1
+	// calls: 1
+}
+
+func TestCachedError_Clock(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCache(&sync.Map{}, WithClock(clock))
+	_, err := c.LoadOrCallErr("a", func() (interface{}, error) {
+		return nil, NewCachedError("a", os.ErrNotExist)
+	})
+	var ce *CachedError
+	if !errors.As(err, &ce) {
+		t.Fatalf("LoadOrCallErr() error = %v, want a *CachedError", err)
+	}
+	if !ce.Time.Equal(clock.Now()) {
+		t.Errorf("Time = %v, want the time of the clock %v", ce.Time, clock.Now())
+	}
+	clock.Advance(time.Minute)
+	if got := ce.Age(); got != time.Minute {
+		t.Errorf("Age() = %v, want %v", got, time.Minute)
+	}
+
+	v := c.LoadOrCall("b", func() interface{} {
+		return NewCachedError("b", os.ErrNotExist)
+	})
+	if got := v.(*CachedError).Age(); got != 0 {
+		t.Errorf("Age() of a memoized value = %v, want 0", got)
+	}
+}
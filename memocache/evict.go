@@ -0,0 +1,127 @@
+package memocache
+
+import "fmt"
+
+// EvictionReason is the reason why a value was removed from a cache.
+type EvictionReason uint8
+
+// Reasons of removals.
+const (
+	// EvictionCapacity is a removal to make room for another value.
+	EvictionCapacity EvictionReason = iota
+	// EvictionDeleted is a removal by Delete or Prune.
+	EvictionDeleted
+	// EvictionReplaced is a removal by an overwrite of the value, e.g. by
+	// Store.
+	EvictionReplaced
+	// EvictionExpired is a removal of an expired value.
+	EvictionExpired
+	// EvictionCleared is a removal by Clear.
+	EvictionCleared
+)
+
+// String returns the name of the reason.
+func (r EvictionReason) String() string {
+	switch r {
+	case EvictionCapacity:
+		return "capacity"
+	case EvictionDeleted:
+		return "deleted"
+	case EvictionReplaced:
+		return "replaced"
+	case EvictionExpired:
+		return "expired"
+	case EvictionCleared:
+		return "cleared"
+	}
+	return fmt.Sprintf("EvictionReason(%d)", uint8(r))
+}
+
+// WithOnEvict sets a function that is called with every ready value removed
+// from a Cache or a RRCache and the reason of the removal, so that resources
+// stored as values, e.g. pooled connections and file handles, can be
+// released. Values still being loaded are not notified. The function is called
+// synchronously from the goroutine that removed the value, without holding the
+// locks of the cache, except that LRUMap.Clear notifies in a new goroutine.
+//
+// A Cache backed by a map with a SetOnEvict method like *LRUMap has sets it
+// to be notified of evictions made by the map itself. A listener already set
+// on a map of this package is kept and called after the one of the Cache with
+// the entries of the map, whose values are *Value. With other maps, only the
+// removals made through the Cache are notified, and deletions only if the map
+// has a Load method like *sync.Map has.
+func WithOnEvict(f func(key, value interface{}, reason EvictionReason)) Option {
+	return func(c *config) {
+		c.onEvict = f
+	}
+}
+
+// removal is a removed entry to notify the listener of.
+type removal struct {
+	key, value interface{}
+	reason     EvictionReason
+}
+
+// evictNotifier is implemented by maps that notify of their removals.
+type evictNotifier interface {
+	SetOnEvict(f func(key, value interface{}, reason EvictionReason))
+}
+
+// evictListenerGetter is implemented by the maps of this package that
+// notify of their removals, to chain the listener already set.
+type evictListenerGetter interface {
+	evictListener() func(key, value interface{}, reason EvictionReason)
+}
+
+// chainOnEvict returns f followed by the listener already set on m, if any.
+func chainOnEvict(f func(key, value interface{}, reason EvictionReason), m evictNotifier) func(key, value interface{}, reason EvictionReason) {
+	g, ok := m.(evictListenerGetter)
+	if !ok || g.evictListener() == nil {
+		return f
+	}
+	prev := g.evictListener()
+	return func(key, value interface{}, reason EvictionReason) {
+		f(key, value, reason)
+		prev(key, value, reason)
+	}
+}
+
+// evicted notifies the listener of the removed entry e of the key if its value
+// is ready. A deleted expired value is notified as EvictionExpired.
+func (c *config) evicted(key, e interface{}, reason EvictionReason) {
+	v, ok := e.(*Value)
+	if !ok {
+		return
+	}
+	s := v.state.Load()
+	if s == nil {
+		return
+	}
+	if reason == EvictionDeleted && s.expired() {
+		reason = EvictionExpired
+	}
+	if c.onEvictedEntry != nil {
+		c.onEvictedEntry(reason, v.hits.Load())
+	}
+	c.onEvict(key, s.value, reason)
+}
+
+// mapRemoved is the listener of the removals made by the backing map of the
+// Cache. The workers of a removed key are stopped and the keys derived from it
+// are deleted, except when the Cache replaces the entry and takes care of them
+// itself.
+func (c *Cache) mapRemoved(key, value interface{}, reason EvictionReason) {
+	if c.config.onEvict != nil {
+		c.config.evicted(key, value, reason)
+	}
+	if reason != EvictionReplaced {
+		c.stopRemovedWorkers(key, value)
+		c.deleteDependents(key)
+	}
+}
+
+// notifiesEvictions returns true if the Cache itself should notify of the
+// removals it makes, which is when the backing map doesn't.
+func (c *Cache) notifiesEvictions() bool {
+	return c.config.onEvict != nil && !c.mapNotifies
+}
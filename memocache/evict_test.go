@@ -0,0 +1,121 @@
+package memocache
+
+import (
+	"container/list"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func ExampleWithOnEvict() {
+	c := NewCache(NewLRUMap(list.New(), 2), WithOnEvict(func(key, value interface{}, reason EvictionReason) {
+		fmt.Printf("closing %v (%v)\n", value, reason)
+	}))
+
+	for _, key := range []string{"a", "b", "c"} {
+		c.LoadOrCall(key, func() interface{} { return "conn-" + key })
+	}
+	c.Store("b", "conn-b2")
+	c.Delete("c")
+	// Output:
+	// closing conn-a (capacity)
+	// closing conn-b (replaced)
+	// closing conn-c (deleted)
+}
+
+// evictions records the notifications of removals.
+type evictions struct {
+	mu  sync.Mutex
+	got []string
+}
+
+func (e *evictions) record(key, value interface{}, reason EvictionReason) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.got = append(e.got, fmt.Sprint(key, "=", value, ":", reason))
+}
+
+func (e *evictions) String() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	got := append([]string(nil), e.got...)
+	sort.Strings(got)
+	return fmt.Sprint(got)
+}
+
+func TestWithOnEvict(t *testing.T) {
+	for name, m := range map[string]func() MapInterface{
+		"sync.Map": func() MapInterface { return &sync.Map{} },
+		"LRUMap":   func() MapInterface { return NewLRUMap(list.New(), 10) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			var ev evictions
+			c := NewCache(m(), WithOnEvict(ev.record), WithTTL(time.Hour))
+			c.Store("a", 1)
+			c.Store("a", 2)
+			c.LoadOrCall("b", func() interface{} { return 3 })
+			c.Delete("b")
+			c.Delete("missing")
+			if got, want := ev.String(), "[a=1:replaced b=3:deleted]"; got != want {
+				t.Errorf("notified %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestWithOnEvict_MapListener(t *testing.T) {
+	for name, m := range map[string]func() MapInterface{
+		"LRUMap":     func() MapInterface { return NewLRUMap(list.New(), 1) },
+		"ShardedMap": func() MapInterface { return NewShardedLRUMap(1, 1) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			var mapEv, cacheEv evictions
+			m := m()
+			m.(evictNotifier).SetOnEvict(func(key, value interface{}, reason EvictionReason) {
+				mapEv.record(key, "*Value", reason)
+			})
+			c := NewCache(m, WithOnEvict(cacheEv.record))
+			c.Store("a", 1)
+			c.Store("b", 2)
+			if got, want := mapEv.String(), "[a=*Value:capacity]"; got != want {
+				t.Errorf("map listener notified %v, want %v", got, want)
+			}
+			if got, want := cacheEv.String(), "[a=1:capacity]"; got != want {
+				t.Errorf("cache listener notified %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestWithOnEvict_ExpiredAndCleared(t *testing.T) {
+	var ev evictions
+	clock := newFakeClock()
+	c := NewCache(&sync.Map{}, WithClock(clock), WithOnEvict(ev.record), WithTTL(time.Millisecond))
+	c.Store("a", 1)
+	clock.Advance(time.Millisecond)
+	c.DeleteExpired()
+	c.Store("b", 2)
+	c.Clear()
+	if got, want := ev.String(), "[a=1:expired b=2:cleared]"; got != want {
+		t.Errorf("notified %v, want %v", got, want)
+	}
+}
+
+func TestWithOnEvict_RRCache(t *testing.T) {
+	var ev evictions
+	var currentSize int32
+	c := NewRRCache(&currentSize, 4, 2, rand.Intn, WithOnEvict(ev.record))
+	for i := 0; i < 5; i++ {
+		c.Store(i, i)
+	}
+	c.Store(0, "new")
+	ev.mu.Lock()
+	n := len(ev.got)
+	ev.mu.Unlock()
+	if want := 5 - c.Len(); n < want {
+		t.Errorf("notified %d removals (%v), want at least %d evictions", n, ev.String(), want)
+	}
+}
@@ -0,0 +1,46 @@
+package memocache
+
+// WithAsyncEviction makes a RRCache evict in a worker goroutine instead of in
+// the load that makes it exceed maxSize, so that no request pays for a range
+// over the whole cache. The caches may exceed maxSize until the worker catches
+// up. The worker is started by the first eviction and stopped by Close.
+// SetMaxSize still evicts at once.
+func WithAsyncEviction() Option {
+	return func(c *config) {
+		c.asyncEviction = true
+	}
+}
+
+// evictAsync wakes up the eviction worker, starting it if needed, if there are
+// more than maxSize items.
+func (r *RRCache) evictAsync() {
+	limit, _ := r.limits()
+	if r.size.len() <= limit {
+		return
+	}
+	r.evictOnce.Do(func() {
+		r.evictCh = make(chan struct{}, 1)
+		r.evictClose = make(chan struct{})
+		go r.evictWorker(r.evictCh, r.evictClose)
+	})
+	if r.evictCh == nil {
+		return // Closed before the worker started.
+	}
+	select {
+	case r.evictCh <- struct{}{}:
+	default:
+		// The worker already has a pending wake-up.
+	}
+}
+
+// evictWorker evicts every time it's woken up until closed.
+func (r *RRCache) evictWorker(wake, closed <-chan struct{}) {
+	for {
+		select {
+		case <-wake:
+			r.evict(r.limits())
+		case <-closed:
+			return
+		}
+	}
+}
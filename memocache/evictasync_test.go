@@ -0,0 +1,35 @@
+package memocache
+
+import (
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+func TestWithAsyncEviction(t *testing.T) {
+	before := runtime.NumGoroutine()
+	r := NewRRCache(nil, 10, 5, rand.Intn, WithAsyncEviction())
+	for i := 0; i < 100; i++ {
+		r.LoadOrCall(i, func() interface{} { return i })
+	}
+	if !waitFor(func() bool { return r.Size() <= 10 }) {
+		t.Errorf("Size() = %d, want at most 10 after the worker evicts", r.Size())
+	}
+
+	r.Close()
+	if !waitFor(func() bool { return runtime.NumGoroutine() <= before }) {
+		t.Error("the eviction worker is still running after Close()")
+	}
+	for i := 200; i < 300; i++ {
+		r.LoadOrCall(i, func() interface{} { return i })
+	}
+}
+
+func TestWithAsyncEviction_CloseUnused(t *testing.T) {
+	r := NewRRCache(nil, 10, 5, rand.Intn, WithAsyncEviction())
+	r.Close()
+	r.Close()
+	for i := 0; i < 20; i++ {
+		r.LoadOrCall(i, func() interface{} { return i })
+	}
+}
@@ -0,0 +1,30 @@
+package memocache
+
+import "expvar"
+
+// StatsSource is a cache that reports its statistics. *Cache, *RRCache,
+// *MultiLevelMap, *Namespace and the generic caches implement StatsSource.
+type StatsSource interface {
+	Stats() Stats
+}
+
+// PublishExpvar publishes the statistics of the cache as the expvar variable
+// of the name, so that monitoring reading /debug/vars picks them up. The
+// variable is a map of hits, misses, loadErrors and hitRatio computed on every
+// read, and of len too if the cache has a Len() int method. Like
+// expvar.Publish, it panics if the name is already published.
+func PublishExpvar(name string, c StatsSource) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		s := c.Stats()
+		vars := map[string]interface{}{
+			"hits":       s.Hits,
+			"misses":     s.Misses,
+			"loadErrors": s.LoadErrors,
+			"hitRatio":   hitRatio(s.Hits, s.Misses),
+		}
+		if l, ok := c.(interface{ Len() int }); ok {
+			vars["len"] = l.Len()
+		}
+		return vars
+	}))
+}
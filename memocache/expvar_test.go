@@ -0,0 +1,33 @@
+package memocache
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+var expvarRuns atomic.Int32
+
+func TestPublishExpvar(t *testing.T) {
+	// expvar can't unpublish, so every run of the test needs its own name.
+	name := fmt.Sprintf("memocache_test_cache_%d", expvarRuns.Add(1))
+	c := NewCache(&sync.Map{})
+	PublishExpvar(name, c)
+	c.LoadOrCall("a", func() interface{} { return 1 })
+	c.LoadOrCall("a", func() interface{} { return 1 })
+
+	var got map[string]float64
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float64{"hits": 1, "misses": 1, "loadErrors": 0, "hitRatio": 0.5, "len": 1}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+	mustPanic(t, func() { PublishExpvar(name, c) })
+}
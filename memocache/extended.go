@@ -0,0 +1,537 @@
+package memocache
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// ExtendedCache is a CacheInterface with all the capabilities of the caches in
+// this package. Libraries can accept an ExtendedCache instead of type
+// asserting for each optional method. *Cache, *RRCache and the deprecated *Map
+// implement ExtendedCache, and MultiLevelMap.Flat adapts a MultiLevelMap to it.
+type ExtendedCache interface {
+	CacheInterface
+
+	// LoadOrCallErr is like LoadOrCall but getValue may fail. Errors are
+	// returned to the caller and are not cached unless they are a
+	// *CachedError.
+	LoadOrCallErr(key interface{}, getValue func() (interface{}, error)) (interface{}, error)
+
+	// LoadOrCallCtx is like LoadOrCallErr but getValue takes the ctx. A
+	// call waiting for another goroutine's load returns ctx.Err() when ctx
+	// is done.
+	LoadOrCallCtx(ctx context.Context, key interface{}, getValue func(ctx context.Context) (interface{}, error)) (interface{}, error)
+
+	// Load returns the cached value for the key if present. It never calls
+	// a loader.
+	Load(key interface{}) (value interface{}, ok bool)
+
+	// Store sets the value for the key, overwriting the existing value.
+	Store(key, value interface{})
+
+	// Len returns the number of cached entries.
+	Len() int
+
+	// Range calls f for each cached key and value until f returns false.
+	Range(f func(key, value interface{}) bool)
+
+	// Stats returns a snapshot of the statistics of the cache.
+	Stats() Stats
+
+	// Close releases the resources held by the cache. The cache should not
+	// be used after Close.
+	Close() error
+}
+
+var (
+	_ ExtendedCache = (*Cache)(nil)
+	_ ExtendedCache = (*RRCache)(nil)
+	_ ExtendedCache = (*Map)(nil)
+	_ ExtendedCache = flatMap{}
+)
+
+// Stats is a snapshot of the statistics of a cache.
+type Stats struct {
+	// Hits is the number of calls served without calling a loader,
+	// including the calls that waited for another goroutine's load.
+	Hits uint64
+	// Misses is the number of calls that called a loader.
+	Misses uint64
+	// LoadErrors is the number of loader calls that returned an error.
+	LoadErrors uint64
+}
+
+// counters are the live statistics of a cache.
+type counters struct {
+	hits       atomic.Uint64
+	misses     atomic.Uint64
+	loadErrors atomic.Uint64
+}
+
+// record counts a call that reached the slow path. The called result tells
+// whether the loader of the call was called.
+func (c *counters) record(called bool, err error) {
+	if !called {
+		c.hits.Add(1)
+		return
+	}
+	c.misses.Add(1)
+	if err != nil {
+		c.loadErrors.Add(1)
+	}
+}
+
+// snapshot returns the current values of the counters.
+func (c *counters) snapshot() Stats {
+	return Stats{
+		Hits:       c.hits.Load(),
+		Misses:     c.misses.Load(),
+		LoadErrors: c.loadErrors.Load(),
+	}
+}
+
+// Optional methods of a MapInterface. *sync.Map and *LRUMap implement all of
+// them but Len, Cap, Peek and Clear, which only *LRUMap has, Swap, which only
+// *sync.Map has, and Reweigh and Weight, which only *WeightedLRUMap has.
+type (
+	mapLoader interface {
+		Load(key interface{}) (value interface{}, ok bool)
+	}
+	mapPeeker interface {
+		Peek(key interface{}) (value interface{}, ok bool)
+	}
+	mapStorer interface {
+		Store(key, value interface{})
+	}
+	mapRanger interface {
+		Range(f func(key, value interface{}) bool)
+	}
+	mapLener interface {
+		Len() int
+	}
+	mapCapper interface {
+		Cap() int
+	}
+	mapClearer interface {
+		Clear()
+	}
+	mapSwapper interface {
+		Swap(key, value interface{}) (previous interface{}, loaded bool)
+	}
+	mapReweigher interface {
+		Reweigh(key, value interface{})
+	}
+	mapWeighter interface {
+		Weight() int64
+	}
+	mapCompareSwapper interface {
+		CompareAndSwap(key, old, new interface{}) (swapped bool)
+	}
+	mapCompareDeleter interface {
+		CompareAndDelete(key, old interface{}) (deleted bool)
+	}
+)
+
+// LoadOrCallErr is like LoadOrCall but getValue may fail. If getValue returns
+// an error, nothing is cached for the key and the error is returned, so the
+// next call for the key calls its getValue again. To cache a failure, e.g. a
+// "not found" answer from the backend, return a *CachedError from getValue.
+func (c *Cache) LoadOrCallErr(key interface{}, getValue func() (interface{}, error)) (interface{}, error) {
+	v := c.entry(key)
+	if s := v.state.Load(); s != nil && s.fresh() {
+		c.hit(key, v)
+		return s.result()
+	}
+	return c.loadOrCallSlow(context.Background(), key, v, getValue)
+}
+
+// LoadOrCallCtx is like LoadOrCallErr but getValue is called with ctx. If ctx
+// is already done, ctx.Err() is returned without loading. If ctx is done while
+// waiting for a load started by another goroutine, ctx.Err() is returned
+// without waiting any longer; the other load carries on and its result is
+// cached for later calls.
+func (c *Cache) LoadOrCallCtx(ctx context.Context, key interface{}, getValue func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	v := c.entry(key)
+	if s := v.state.Load(); s != nil && s.fresh() {
+		c.hit(key, v)
+		return s.result()
+	}
+	return c.loadOrCallSlow(ctx, key, v, func() (interface{}, error) {
+		return getValue(ctx)
+	})
+}
+
+// Load returns the cached value for the key if it's present and ready. It
+// never calls a loader. It always returns false if the backing map doesn't
+// have a Load method like *sync.Map has.
+func (c *Cache) Load(key interface{}) (value interface{}, ok bool) {
+	m, ok := c.m.(mapLoader)
+	if !ok {
+		return nil, false
+	}
+	return c.value(m.Load(key))
+}
+
+// Peek is like Load but doesn't mark the key as recently used if the backing
+// map has a Peek method like *LRUMap has. Use it for inspection, e.g. metrics,
+// that shouldn't affect what is evicted.
+func (c *Cache) Peek(key interface{}) (value interface{}, ok bool) {
+	if m, ok := c.m.(mapPeeker); ok {
+		return c.value(m.Peek(key))
+	}
+	return c.Load(key)
+}
+
+// value returns the ready value of the entry e found in the backing map.
+func (c *Cache) value(e interface{}, ok bool) (interface{}, bool) {
+	if !ok {
+		return nil, false
+	}
+	s := e.(*Value).state.Load()
+	if s == nil || s.expired() {
+		return nil, false
+	}
+	return s.value, true
+}
+
+// Store sets the value for the key, overwriting the existing value. Prior
+// LoadOrCall() with the same key won't be affected. Store revokes the lease of
+// the key if any.
+func (c *Cache) Store(key, value interface{}) {
+	c.writer.set(key, value)
+	c.RevokeLease(key)
+	c.store(key, value)
+}
+
+// store sets the value for the key without revoking the lease, and returns
+// the entry of the value.
+func (c *Cache) store(key, value interface{}) *Value {
+	defer c.deleteDependents(key)
+	v := &Value{ttl: c.config.jitterTTL(c.config.ttl), clock: c.config.clock}
+	v.state.Store(v.newState(value, nil))
+	if c.config.readYourWrites {
+		v.version = c.version.Add(1)
+	}
+	if m, ok := c.m.(mapSwapper); ok && c.notifiesEvictions() {
+		if old, loaded := m.Swap(key, v); loaded {
+			c.config.evicted(key, old, EvictionReplaced)
+		}
+		return v
+	}
+	if m, ok := c.m.(mapStorer); ok {
+		if c.notifiesEvictions() {
+			c.deleteKey(key, EvictionReplaced)
+		}
+		m.Store(key, v)
+		return v
+	}
+	if !c.config.readYourWrites {
+		c.deleteKey(key, EvictionReplaced)
+		c.m.LoadOrStore(key, v)
+		return v
+	}
+	// Replace entries older than this write until v is in place or a newer
+	// write wins.
+	for {
+		e, loaded := c.m.LoadOrStore(key, v)
+		if !loaded || e.(*Value).version > v.version {
+			return v
+		}
+		if !c.compareAndDelete(key, e.(*Value), EvictionReplaced) && !c.canCompare() {
+			c.deleteKey(key, EvictionReplaced)
+		}
+	}
+}
+
+// Len returns the number of entries in the cache, including the entries whose
+// values are still being loaded. It's zero if the backing map has neither a
+// Len nor a Range method.
+func (c *Cache) Len() int {
+	if m, ok := c.m.(mapLener); ok {
+		return m.Len()
+	}
+	n := 0
+	if m, ok := c.m.(mapRanger); ok {
+		m.Range(func(key, value interface{}) bool {
+			n++
+			return true
+		})
+	}
+	return n
+}
+
+// Cap returns the capacity of the backing map if it has a Cap method like
+// *LRUMap has, otherwise zero, which means it's unbounded.
+func (c *Cache) Cap() int {
+	if m, ok := c.m.(mapCapper); ok {
+		return m.Cap()
+	}
+	return 0
+}
+
+// Range calls f sequentially for each key and ready value in the cache. If f
+// returns false, range stops the iteration. Expired values are skipped.
+// Nothing is visited if the backing map doesn't have a Range method like
+// *sync.Map has.
+func (c *Cache) Range(f func(key, value interface{}) bool) {
+	m, ok := c.m.(mapRanger)
+	if !ok {
+		return
+	}
+	m.Range(func(key, e interface{}) bool {
+		s := e.(*Value).state.Load()
+		if s == nil || s.expired() {
+			return true
+		}
+		return f(key, s.value)
+	})
+}
+
+// Clear deletes all the entries. Loads in flight are not affected, but their
+// values are not cached. The leases are revoked and the workers started by
+// LoadOrRun are stopped. If the backing map has a Clear method like *LRUMap
+// has, it's used to drop the entries at once; otherwise the entries are
+// deleted one by one with Range. Entries are not deleted if the backing map
+// has neither method.
+func (c *Cache) Clear() {
+	c.clear()
+	c.bus.publish()
+}
+
+// clear deletes all the entries without publishing it.
+func (c *Cache) clear() {
+	c.stopWorkers()
+	c.dropDependencies()
+	c.leaseMu.Lock()
+	c.leases = nil
+	atomic.StoreInt32(&c.numLeases, 0)
+	c.leaseMu.Unlock()
+	if c.config.readYourWrites {
+		c.version.Add(1)
+	}
+	c.invalidations.invalidateAll()
+	if m, ok := c.m.(mapClearer); ok && !c.notifiesEvictions() {
+		m.Clear()
+		return
+	}
+	if m, ok := c.m.(mapRanger); ok {
+		m.Range(func(key, e interface{}) bool {
+			if !c.compareAndDelete(key, e.(*Value), EvictionCleared) && !c.canCompare() {
+				c.deleteKey(key, EvictionCleared)
+			}
+			return true
+		})
+	}
+}
+
+// Stats returns a snapshot of the statistics of the cache.
+func (c *Cache) Stats() Stats {
+	return c.stats.snapshot()
+}
+
+// Close releases the resources held by the cache. It stops the workers started
+// by LoadOrRun, cancels the subscription of WithInvalidationBus and flushes
+// the writes queued by WithWriteBehind.
+func (c *Cache) Close() error {
+	c.stopWorkers()
+	c.bus.close()
+	c.writer.close()
+	return nil
+}
+
+// LoadOrCallCtx is like LoadOrCallErr but getValue is called with ctx. If ctx
+// is already done, ctx.Err() is returned without loading. If ctx is done while
+// waiting for a load started by another goroutine, ctx.Err() is returned
+// without waiting any longer.
+func (r *RRCache) LoadOrCallCtx(ctx context.Context, key interface{}, getValue func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.loadOrCall(ctx, key, func() (interface{}, error) {
+		return getValue(ctx)
+	})
+}
+
+// Load returns the cached value for the key if it's present and ready. It
+// never calls a loader.
+func (r *RRCache) Load(key interface{}) (value interface{}, ok bool) {
+	e, ok := r.m.Load(key)
+	if !ok {
+		return nil, false
+	}
+	return e.(*Value).Load()
+}
+
+// Store sets the value for the key, overwriting the existing value. If the
+// number of items exceeds the maxSize, it will evict random items.
+func (r *RRCache) Store(key, value interface{}) {
+	r.writer.set(key, value)
+	v := newReadyValue(value)
+	r.mu.Lock()
+	old, loaded := r.m.Swap(key, v)
+	var child *RRCache
+	if loaded {
+		child = r.dropChild(old)
+	} else {
+		r.countAdded()
+	}
+	r.mu.Unlock()
+	if child != nil {
+		child.detach()
+	}
+	if loaded {
+		if r.config.onEvict != nil {
+			r.config.evicted(key, old, EvictionReplaced)
+		}
+		return
+	}
+	r.maybeEvict()
+}
+
+// Len returns the number of entries in this cache, including the entries whose
+// values are still being loaded. Entries of other caches sharing the size
+// counter are not counted.
+func (r *RRCache) Len() int {
+	n := 0
+	r.m.Range(func(key, value interface{}) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// Cap returns the maximum number of entries of the caches sharing the size
+// counter.
+func (r *RRCache) Cap() int {
+	maxSize, _ := r.limits()
+	return int(maxSize)
+}
+
+// Size returns the number of entries of the caches sharing the size counter.
+func (r *RRCache) Size() int {
+	return int(r.size.len())
+}
+
+// Range calls f sequentially for each key and ready value in the cache. If f
+// returns false, range stops the iteration.
+func (r *RRCache) Range(f func(key, value interface{}) bool) {
+	r.m.Range(func(key, e interface{}) bool {
+		value, ok := e.(*Value).Load()
+		if !ok {
+			return true
+		}
+		return f(key, value)
+	})
+}
+
+// Stats returns a snapshot of the statistics of the cache.
+func (r *RRCache) Stats() Stats {
+	return r.stats.snapshot()
+}
+
+// Close releases the resources held by the cache, stopping the eviction worker
+// of WithAsyncEviction if any and flushing the writes queued by
+// WithWriteBehind.
+func (r *RRCache) Close() error {
+	r.closeOnce.Do(func() {
+		r.evictOnce.Do(func() {})
+		if r.evictClose != nil {
+			close(r.evictClose)
+		}
+	})
+	r.writer.close()
+	return nil
+}
+
+// Len returns the number of entries in the map, including the entries whose
+// values are still being loaded.
+func (m *Map) Len() int {
+	n := 0
+	m.m.Range(func(key, value interface{}) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// Stats returns a snapshot of the statistics of the map.
+func (m *Map) Stats() Stats {
+	return m.stats.snapshot()
+}
+
+// Close does nothing, as a Map holds no resources.
+func (m *Map) Close() error {
+	return nil
+}
+
+// Flat returns the map as an ExtendedCache whose keys are paths: a Path key
+// stands for the path of its elements and any other key for the path of the
+// key alone, and Range visits the leaves with their paths as Path keys. Delete
+// prunes the path and Len is Size. An empty Path panics like an empty path
+// does with the MultiLevelMap, so that Delete never prunes the whole tree; use
+// MultiLevelMap.Clear for that.
+func (m *MultiLevelMap) Flat() ExtendedCache {
+	return flatMap{m}
+}
+
+// flatMap is the ExtendedCache returned by MultiLevelMap.Flat.
+type flatMap struct {
+	m *MultiLevelMap
+}
+
+// flatPath returns the path of a key of flatMap. It panics if the key is an
+// empty Path.
+func flatPath(key interface{}) []interface{} {
+	if p, ok := key.(Path); ok {
+		if len(p) == 0 {
+			panic("path was not given")
+		}
+		return p
+	}
+	return []interface{}{key}
+}
+
+func (f flatMap) LoadOrCall(key interface{}, getValue func() interface{}) interface{} {
+	return f.m.LoadOrCall(getValue, flatPath(key)...)
+}
+
+func (f flatMap) LoadOrCallErr(key interface{}, getValue func() (interface{}, error)) (interface{}, error) {
+	return f.m.LoadOrCallErr(getValue, flatPath(key)...)
+}
+
+func (f flatMap) LoadOrCallCtx(ctx context.Context, key interface{}, getValue func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	return f.m.LoadOrCallCtx(ctx, getValue, flatPath(key)...)
+}
+
+func (f flatMap) Load(key interface{}) (value interface{}, ok bool) {
+	return f.m.Load(flatPath(key)...)
+}
+
+func (f flatMap) Store(key, value interface{}) {
+	f.m.StorePath(value, flatPath(key)...)
+}
+
+func (f flatMap) Delete(key interface{}) {
+	f.m.Prune(flatPath(key)...)
+}
+
+func (f flatMap) Len() int {
+	return f.m.Size()
+}
+
+func (f flatMap) Range(fn func(key, value interface{}) bool) {
+	f.m.Walk(func(path []interface{}, value interface{}) bool {
+		return fn(P(path...), value)
+	})
+}
+
+func (f flatMap) Stats() Stats {
+	return f.m.Stats()
+}
+
+func (f flatMap) Close() error {
+	return f.m.Close()
+}
@@ -0,0 +1,170 @@
+package memocache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func ExampleExtendedCache() {
+	var c ExtendedCache = NewCache(&sync.Map{})
+
+	c.Store("a", 1)
+	fmt.Println(c.LoadOrCall("a", func() interface{} { return 10 }))
+
+	_, err := c.LoadOrCallErr("b", func() (interface{}, error) {
+		return nil, errors.New("backend unavailable")
+	})
+	fmt.Println(err)
+	fmt.Println(c.LoadOrCallErr("b", func() (interface{}, error) {
+		return 2, nil
+	}))
+
+	fmt.Println(c.Len())
+	fmt.Printf("%+v\n", c.Stats())
+	// Output:
+	// 1
+	// backend unavailable
+	// 2 <nil>
+	// 2
+	// {Hits:1 Misses:2 LoadErrors:1}
+}
+
+func TestExtendedCache(t *testing.T) {
+	var currentSize int32
+	for name, c := range map[string]ExtendedCache{
+		"sync.Map": NewCache(&sync.Map{}),
+		"LRUMap":   NewCache(NewLRUMap(list.New(), 10)),
+		"RRCache":  NewRRCache(&currentSize, 10, 5, rand.Intn),
+		"Map":      &Map{},
+		"Flat":     NewMultiLevelMap(nil).Flat(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer c.Close()
+
+			if _, ok := c.Load("a"); ok {
+				t.Error("Load() found a value in an empty cache")
+			}
+			c.Store("a", 1)
+			c.Store("a", 2)
+			if v, ok := c.Load("a"); !ok || v != 2 {
+				t.Errorf("Load() = %v, %v, want 2, true", v, ok)
+			}
+
+			wantErr := errors.New("failed")
+			if _, err := c.LoadOrCallErr("b", func() (interface{}, error) {
+				return nil, wantErr
+			}); err != wantErr {
+				t.Errorf("LoadOrCallErr() error = %v, want %v", err, wantErr)
+			}
+			if v, err := c.LoadOrCallErr("b", func() (interface{}, error) {
+				return 3, nil
+			}); err != nil || v != 3 {
+				t.Errorf("LoadOrCallErr() = %v, %v, want 3, nil", v, err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if _, err := c.LoadOrCallCtx(ctx, "c", func(ctx context.Context) (interface{}, error) {
+				t.Error("getValue called with a canceled context")
+				return nil, nil
+			}); err != context.Canceled {
+				t.Errorf("LoadOrCallCtx() error = %v, want %v", err, context.Canceled)
+			}
+
+			var keys []string
+			c.Range(func(key, value interface{}) bool {
+				keys = append(keys, fmt.Sprint(key, "=", value))
+				return true
+			})
+			sort.Strings(keys)
+			if got, want := fmt.Sprint(keys), "[a=2 b=3]"; got != want {
+				t.Errorf("Range() visited %v, want %v", got, want)
+			}
+
+			if got := c.Stats(); got.Misses != 2 || got.LoadErrors != 1 {
+				t.Errorf("Stats() = %+v, want 2 misses and 1 load error", got)
+			}
+
+			if n := c.Len(); n != 2 {
+				t.Errorf("Len() = %d, want 2", n)
+			}
+			c.Delete("a")
+			if _, ok := c.Load("a"); ok {
+				t.Error("Load() found a deleted value")
+			}
+		})
+	}
+}
+
+func TestExtendedCache_LoadOrCallCtxCancelsWaiter(t *testing.T) {
+	var currentSize int32
+	for name, c := range map[string]ExtendedCache{
+		"Cache":   NewCache(&sync.Map{}),
+		"RRCache": NewRRCache(&currentSize, 10, 5, rand.Intn),
+		"Map":     &Map{},
+		"Flat":    NewMultiLevelMap(nil).Flat(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			started := make(chan struct{})
+			release := make(chan struct{})
+			go c.LoadOrCallCtx(context.Background(), "k", func(ctx context.Context) (interface{}, error) {
+				close(started)
+				<-release
+				return 1, nil
+			})
+			<-started
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			if _, err := c.LoadOrCallCtx(ctx, "k", func(ctx context.Context) (interface{}, error) {
+				t.Error("getValue called while another load is in flight")
+				return nil, nil
+			}); err != context.DeadlineExceeded {
+				t.Errorf("LoadOrCallCtx() error = %v, want %v", err, context.DeadlineExceeded)
+			}
+
+			close(release)
+			if v, err := c.LoadOrCallCtx(context.Background(), "k", func(ctx context.Context) (interface{}, error) {
+				return 2, nil
+			}); err != nil || v != 1 {
+				t.Errorf("LoadOrCallCtx() = %v, %v, want 1, nil", v, err)
+			}
+		})
+	}
+}
+
+func TestRRCache_StoreOverwrite(t *testing.T) {
+	var currentSize int32
+	c := NewRRCache(&currentSize, 10, 5, rand.Intn)
+	c.Store("a", 1)
+	c.Store("a", 2)
+	c.LoadOrCall("b", func() interface{} { return 3 })
+	c.Store("b", 4)
+	if currentSize != 2 || c.Len() != 2 {
+		t.Errorf("size = %d and Len() = %d after overwriting, want 2", currentSize, c.Len())
+	}
+}
+
+func TestMultiLevelMap_FlatEmptyPath(t *testing.T) {
+	m := NewMultiLevelMap(nil)
+	c := m.Flat()
+	c.Store(P("a", "b"), 1)
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Delete() of an empty path didn't panic")
+			}
+		}()
+		c.Delete(P())
+	}()
+	if v, ok := c.Load(P("a", "b")); !ok || v != 1 {
+		t.Errorf("Load(a, b) = %v, %v after Delete() of an empty path, want 1, true", v, ok)
+	}
+}
@@ -0,0 +1,239 @@
+package memocache
+
+import "sync"
+
+// FIFOMap is a bounded map that evicts the oldest inserted keys first. It
+// doesn't track recency, so hits only take a read lock and don't write
+// anything, and inserts append the key to a queue without allocating list
+// elements. It suits caches of immutable values where recency matters little.
+// FIFOMap should be created with NewFIFOMap.
+type FIFOMap struct {
+	mu      sync.RWMutex
+	m       map[interface{}]fifoEntry
+	queue   []fifoKey // Keys in insertion order, including deleted ones
+	head    int       // Index of the oldest key in queue
+	seq     uint64    // Sequence number of the last inserted key
+	maxSize int
+
+	onEvict func(key, value interface{}, reason EvictionReason)
+	pending []removal // Removed values to notify of, guarded by mu
+}
+
+// fifoEntry is a value of a FIFOMap with the sequence number of its insertion.
+type fifoEntry struct {
+	value interface{}
+	seq   uint64
+}
+
+// fifoKey is a key in the queue of a FIFOMap. It's stale if the key was
+// deleted or inserted again after it.
+type fifoKey struct {
+	key interface{}
+	seq uint64
+}
+
+// NewFIFOMap returns a new FIFOMap holding up to maxSize keys.
+func NewFIFOMap(maxSize int) *FIFOMap {
+	return &FIFOMap{
+		m:       make(map[interface{}]fifoEntry),
+		maxSize: maxSize,
+	}
+}
+
+// NewFIFOCache returns a new cache backed by a FIFOMap holding up to maxSize
+// keys.
+func NewFIFOCache(maxSize int, opts ...Option) *Cache {
+	return NewCache(NewFIFOMap(maxSize), opts...)
+}
+
+// SetOnEvict sets a function that is called with every value removed from the
+// map and the reason of the removal. It's called after the lock of the map is
+// released, so it may call the methods of the map. SetOnEvict should be called
+// before the map is used. A Cache wrapping the map installs its own listener,
+// which calls the one set before.
+func (f *FIFOMap) SetOnEvict(fn func(key, value interface{}, reason EvictionReason)) {
+	f.onEvict = fn
+}
+
+// evictListener returns the listener set by SetOnEvict.
+func (f *FIFOMap) evictListener() func(key, value interface{}, reason EvictionReason) {
+	return f.onEvict
+}
+
+// unlock releases the lock and notifies the listener of the values removed
+// while it was held.
+func (f *FIFOMap) unlock() {
+	pending := f.pending
+	f.pending = nil
+	f.mu.Unlock()
+	for _, ev := range pending {
+		f.onEvict(ev.key, ev.value, ev.reason)
+	}
+}
+
+// removed records the removal of the value of the key to notify of. It should
+// be called with f.mu held.
+func (f *FIFOMap) removed(key, value interface{}, reason EvictionReason) {
+	if f.onEvict != nil {
+		f.pending = append(f.pending, removal{key: key, value: value, reason: reason})
+	}
+}
+
+// LoadOrStore returns the existing value for the key if present. Otherwise, it
+// stores and returns the given value. The loaded result is true if the value
+// was loaded, false if stored. If the map gets over maxSize, the oldest key is
+// evicted.
+func (f *FIFOMap) LoadOrStore(key, value interface{}) (actual interface{}, loaded bool) {
+	if value, ok := f.Load(key); ok {
+		return value, true
+	}
+	f.mu.Lock()
+	defer f.unlock()
+	if en, ok := f.m[key]; ok {
+		return en.value, true
+	}
+	f.add(key, value)
+	return value, false
+}
+
+// Load returns the value stored in the map for a key, or nil if no value is
+// present. The ok result indicates whether value was found in the map.
+func (f *FIFOMap) Load(key interface{}) (value interface{}, ok bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	en, ok := f.m[key]
+	return en.value, ok
+}
+
+// Store sets the value for a key, overwriting the existing value if any. An
+// existing key keeps its place in the insertion order.
+func (f *FIFOMap) Store(key, value interface{}) {
+	f.mu.Lock()
+	defer f.unlock()
+	if en, ok := f.m[key]; ok {
+		f.removed(key, en.value, EvictionReplaced)
+		f.m[key] = fifoEntry{value: value, seq: en.seq}
+		return
+	}
+	f.add(key, value)
+}
+
+// CompareAndSwap swaps the old and new values for key if the value stored in
+// the map is equal to old.
+func (f *FIFOMap) CompareAndSwap(key, old, new interface{}) (swapped bool) {
+	f.mu.Lock()
+	defer f.unlock()
+	en, ok := f.m[key]
+	if !ok || en.value != old {
+		return false
+	}
+	f.removed(key, en.value, EvictionReplaced)
+	f.m[key] = fifoEntry{value: new, seq: en.seq}
+	return true
+}
+
+// CompareAndDelete deletes the entry for key if its value is equal to old.
+func (f *FIFOMap) CompareAndDelete(key, old interface{}) (deleted bool) {
+	f.mu.Lock()
+	defer f.unlock()
+	en, ok := f.m[key]
+	if !ok || en.value != old {
+		return false
+	}
+	f.delete(key, en, EvictionDeleted)
+	return true
+}
+
+// Delete deletes the value for a key.
+func (f *FIFOMap) Delete(key interface{}) {
+	f.mu.Lock()
+	defer f.unlock()
+	if en, ok := f.m[key]; ok {
+		f.delete(key, en, EvictionDeleted)
+	}
+}
+
+// Range calls fn sequentially for each key and value present in the map. If fn
+// returns false, range stops the iteration. Range iterates over a snapshot, so
+// fn may call other methods of the map.
+func (f *FIFOMap) Range(fn func(key, value interface{}) bool) {
+	f.mu.RLock()
+	kvs := make([]removal, 0, len(f.m))
+	for key, en := range f.m {
+		kvs = append(kvs, removal{key: key, value: en.value})
+	}
+	f.mu.RUnlock()
+	for _, kv := range kvs {
+		if !fn(kv.key, kv.value) {
+			return
+		}
+	}
+}
+
+// Len returns the number of keys in the map.
+func (f *FIFOMap) Len() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return len(f.m)
+}
+
+// Cap returns the maximum number of keys in the map.
+func (f *FIFOMap) Cap() int {
+	return f.maxSize
+}
+
+// Clear deletes all the values.
+func (f *FIFOMap) Clear() {
+	f.mu.Lock()
+	defer f.unlock()
+	for key, en := range f.m {
+		f.removed(key, en.value, EvictionCleared)
+	}
+	f.m = make(map[interface{}]fifoEntry)
+	f.queue = nil
+	f.head = 0
+}
+
+// add inserts a new key at the end of the queue and evicts the oldest keys
+// until the map fits in maxSize. It should be called with f.mu held.
+func (f *FIFOMap) add(key, value interface{}) {
+	f.seq++
+	f.m[key] = fifoEntry{value: value, seq: f.seq}
+	f.queue = append(f.queue, fifoKey{key: key, seq: f.seq})
+	for len(f.m) > f.maxSize && f.head < len(f.queue) {
+		k := f.queue[f.head]
+		f.queue[f.head] = fifoKey{}
+		f.head++
+		if en, ok := f.m[k.key]; ok && en.seq == k.seq {
+			f.delete(k.key, en, EvictionCapacity)
+		}
+	}
+	f.compact()
+}
+
+// delete removes the key from the map for the reason. Its key in the queue is
+// left to become stale. It should be called with f.mu held.
+func (f *FIFOMap) delete(key interface{}, en fifoEntry, reason EvictionReason) {
+	delete(f.m, key)
+	f.removed(key, en.value, reason)
+}
+
+// compact drops the stale keys from the queue once they make up most of it,
+// so that the queue stays proportional to the map. It should be called with
+// f.mu held.
+func (f *FIFOMap) compact() {
+	if len(f.queue)-f.head <= 2*len(f.m)+16 && f.head <= len(f.queue)/2 {
+		return
+	}
+	live := f.queue[:0]
+	for _, k := range f.queue[f.head:] {
+		if en, ok := f.m[k.key]; ok && en.seq == k.seq {
+			live = append(live, k)
+		}
+	}
+	for i := len(live); i < len(f.queue); i++ {
+		f.queue[i] = fifoKey{}
+	}
+	f.queue = live
+	f.head = 0
+}
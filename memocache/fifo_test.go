@@ -0,0 +1,101 @@
+package memocache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func ExampleFIFOMap() {
+	c := NewFIFOCache(2)
+	load := func(key string) {
+		c.LoadOrCall(key, func() interface{} {
+			fmt.Println("loading", key)
+			return key
+		})
+	}
+	load("a")
+	load("b")
+	load("a") // Doesn't change the order.
+	load("c") // Evicts a, the oldest key.
+	load("b")
+	load("a")
+	// Output:
+	// loading a
+	// loading b
+	// loading c
+	// loading a
+}
+
+func TestFIFOMap_Evict(t *testing.T) {
+	var evicted []interface{}
+	m := NewFIFOMap(3)
+	m.SetOnEvict(func(key, value interface{}, reason EvictionReason) {
+		if reason == EvictionCapacity {
+			evicted = append(evicted, key)
+		}
+	})
+	for _, key := range []int{1, 2, 3} {
+		m.LoadOrStore(key, key)
+	}
+	m.Delete(1)
+	m.Store(1, 1)       // Inserted again after 3.
+	m.Store(2, "two")   // Keeps its place.
+	m.LoadOrStore(4, 4) // Evicts 2.
+	m.LoadOrStore(5, 5) // Evicts 3.
+	if want := []interface{}{2, 3}; fmt.Sprint(evicted) != fmt.Sprint(want) {
+		t.Errorf("evicted %v, want %v", evicted, want)
+	}
+	if n := m.Len(); n != 3 {
+		t.Errorf("Len() = %d, want 3", n)
+	}
+	m.Clear()
+	if n := m.Len(); n != 0 {
+		t.Errorf("Len() after Clear() = %d, want 0", n)
+	}
+}
+
+func TestFIFOMap_Compact(t *testing.T) {
+	m := NewFIFOMap(10)
+	for i := 0; i < 1000; i++ {
+		m.LoadOrStore(i, i)
+		if i%2 == 0 {
+			m.Delete(i)
+		}
+	}
+	if n := m.Len(); n != 10 {
+		t.Errorf("Len() = %d, want 10", n)
+	}
+	if n := len(m.queue) - m.head; n > 2*m.Len()+16 {
+		t.Errorf("queue has %d keys for %d values", n, m.Len())
+	}
+	for i := 981; i < 1000; i += 2 {
+		if _, ok := m.Load(i); !ok {
+			t.Errorf("Load(%d) not found", i)
+		}
+	}
+}
+
+func TestFIFOMap_Concurrent(t *testing.T) {
+	c := NewFIFOCache(50)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := (i * (g + 1)) % 100
+				if got := c.LoadOrCall(key, func() interface{} { return key }); got != key {
+					t.Errorf("LoadOrCall(%d) = %v", key, got)
+				}
+				if i%10 == 0 {
+					c.Delete(key)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if n := c.Len(); n > 50 {
+		t.Errorf("Len() = %d, want at most 50", n)
+	}
+}
@@ -0,0 +1,54 @@
+package memocache
+
+// Forget removes the entry for the key if its value is still being loaded, so
+// that the next LoadOrCall of the key calls its getValue instead of waiting for
+// a possibly hung loader, like singleflight.Group.Forget. The running loader
+// isn't stopped and its value is discarded. Calls already waiting for it keep
+// waiting; use LoadOrCallCtx to bound their wait. A ready value isn't removed;
+// use Delete for it. Forget returns true if an entry was removed. It always
+// returns false if the backing map doesn't have a Load method.
+func (c *Cache) Forget(key interface{}) bool {
+	m, ok := c.m.(mapLoader)
+	if !ok {
+		return false
+	}
+	e, ok := m.Load(key)
+	if !ok {
+		return false
+	}
+	v := e.(*Value)
+	if v.state.Load() != nil {
+		return false
+	}
+	return c.compareAndDelete(key, v, EvictionDeleted)
+}
+
+// Forget removes the entry for the key if its value is still being loaded. See
+// Cache.Forget.
+func (m *Map) Forget(key interface{}) bool {
+	e, ok := m.m.Load(key)
+	if !ok || e.(*Value).state.Load() != nil {
+		return false
+	}
+	return m.m.CompareAndDelete(key, e)
+}
+
+// ForgetPath removes the entry in path if its value is still being loaded, so
+// that the next LoadOrCall of the path calls its getValue. See Cache.Forget.
+// It never adds the levels of the path, and returns false if the leaf level
+// doesn't have a Forget method like Cache has.
+func (m *MultiLevelMap) ForgetPath(path ...interface{}) bool {
+	path = m.canonical(path)
+	n := len(path)
+	if n == 0 {
+		panic("path was not given")
+	}
+	level, ok := m.existingLevel(path[:n-1])
+	if !ok {
+		return false
+	}
+	f, ok := level.(interface {
+		Forget(key interface{}) bool
+	})
+	return ok && f.Forget(path[n-1])
+}
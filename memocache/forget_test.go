@@ -0,0 +1,68 @@
+package memocache
+
+import (
+	"container/list"
+	"sync"
+	"testing"
+)
+
+func TestCache_Forget(t *testing.T) {
+	c := NewCache(NewLRUMap(list.New(), 10))
+	started := make(chan struct{})
+	hung := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.LoadOrCall("a", func() interface{} {
+			close(started)
+			<-hung
+			return "stale"
+		})
+	}()
+	<-started
+	if !c.Forget("a") {
+		t.Error("Forget(a) = false while loading")
+	}
+	if got := c.LoadOrCall("a", func() interface{} { return "fresh" }); got != "fresh" {
+		t.Errorf("LoadOrCall(a) = %v, want fresh", got)
+	}
+	if c.Forget("a") {
+		t.Error("Forget(a) = true for a ready value")
+	}
+	close(hung)
+	wg.Wait()
+	if got, _ := c.Load("a"); got != "fresh" {
+		t.Errorf("Load(a) = %v after the hung loader returned, want fresh", got)
+	}
+}
+
+func TestMultiLevelMap_ForgetPath(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		newMap func() CacheInterface
+	}{
+		{"Map", nil},
+		{"Cache", func() CacheInterface { return NewCache(&sync.Map{}) }},
+	} {
+		m := NewMultiLevelMap(tc.newMap)
+		if m.ForgetPath("a", "b") {
+			t.Errorf("%s: ForgetPath(a, b) = true on an empty map", tc.name)
+		}
+		started := make(chan struct{})
+		hung := make(chan struct{})
+		go m.LoadOrCall(func() interface{} {
+			close(started)
+			<-hung
+			return 1
+		}, "a", "b")
+		<-started
+		if !m.ForgetPath("a", "b") {
+			t.Errorf("%s: ForgetPath(a, b) = false while loading", tc.name)
+		}
+		if got := m.LoadOrCall(func() interface{} { return 2 }, "a", "b"); got != 2 {
+			t.Errorf("%s: LoadOrCall(a, b) = %v, want 2", tc.name, got)
+		}
+		close(hung)
+	}
+}
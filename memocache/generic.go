@@ -0,0 +1,211 @@
+package memocache
+
+import (
+	"container/list"
+	"context"
+)
+
+// CacheOf is a type-safe wrapper of an ExtendedCache with keys of type K and
+// values of type V. It saves the type assertions at every call site. K should
+// be comparable unless the keys are compared by a Hasher as by NewHashCacheOf.
+// CacheOf should not be copied after first use.
+type CacheOf[K, V any] struct {
+	c ExtendedCache
+}
+
+// NewCacheOf returns a new CacheOf backed by the given m, e.g. &sync.Map{} or
+// a *LRUMap. See NewCache for the options.
+func NewCacheOf[K comparable, V any](m MapInterface, opts ...Option) *CacheOf[K, V] {
+	return WrapCacheOf[K, V](NewCache(m, opts...))
+}
+
+// NewLRUCacheOf returns a new CacheOf backed by a LRUMap of the maxSize with
+// its own list.
+func NewLRUCacheOf[K comparable, V any](maxSize int, opts ...Option) *CacheOf[K, V] {
+	return NewCacheOf[K, V](NewLRUMap(list.New(), maxSize), opts...)
+}
+
+// NewRRCacheOf returns a new CacheOf backed by a RRCache. See NewRRCache for
+// the arguments.
+func NewRRCacheOf[K comparable, V any](currentSize *int32, maxSize, targetNum int32, intn func(n int) int, opts ...Option) *CacheOf[K, V] {
+	return WrapCacheOf[K, V](NewRRCache(currentSize, maxSize, targetNum, intn, opts...))
+}
+
+// WrapCacheOf returns a CacheOf using c. All values in c should be of type V.
+func WrapCacheOf[K comparable, V any](c ExtendedCache) *CacheOf[K, V] {
+	return &CacheOf[K, V]{c: c}
+}
+
+// Unwrap returns the underlying cache.
+func (c *CacheOf[K, V]) Unwrap() ExtendedCache {
+	return c.c
+}
+
+// LoadOrCall gets pre-cached value associated with the given key or calls
+// getValue to get the value for the key. See Cache.LoadOrCall. A memoized
+// *CachedError, which isn't a V, is returned as the zero value of V; use
+// LoadOrCallErr to get the error.
+func (c *CacheOf[K, V]) LoadOrCall(key K, getValue func() V) V {
+	return valueOf[V](c.c.LoadOrCall(key, func() interface{} {
+		return getValue()
+	}))
+}
+
+// LoadOrCallErr is like LoadOrCall but getValue may fail. See
+// Cache.LoadOrCallErr.
+func (c *CacheOf[K, V]) LoadOrCallErr(key K, getValue func() (V, error)) (V, error) {
+	value, err := c.c.LoadOrCallErr(key, func() (interface{}, error) {
+		return getValue()
+	})
+	return valueOf[V](value), err
+}
+
+// LoadOrCallCtx is like LoadOrCallErr but getValue is called with ctx. See
+// Cache.LoadOrCallCtx.
+func (c *CacheOf[K, V]) LoadOrCallCtx(ctx context.Context, key K, getValue func(ctx context.Context) (V, error)) (V, error) {
+	value, err := c.c.LoadOrCallCtx(ctx, key, func(ctx context.Context) (interface{}, error) {
+		return getValue(ctx)
+	})
+	return valueOf[V](value), err
+}
+
+// Load returns the cached value for the key if present. It never calls a
+// loader. It returns false for a memoized *CachedError.
+func (c *CacheOf[K, V]) Load(key K) (value V, ok bool) {
+	return loadedOf[V](c.c.Load(key))
+}
+
+// Peek is like Load but doesn't mark the key as recently used if the
+// underlying cache supports it. See Cache.Peek.
+func (c *CacheOf[K, V]) Peek(key K) (value V, ok bool) {
+	p, isPeeker := c.c.(interface {
+		Peek(key interface{}) (value interface{}, ok bool)
+	})
+	if !isPeeker {
+		return c.Load(key)
+	}
+	return loadedOf[V](p.Peek(key))
+}
+
+// Store sets the value for the key, overwriting the existing value.
+func (c *CacheOf[K, V]) Store(key K, value V) {
+	c.c.Store(key, value)
+}
+
+// Delete deletes the cache value for the key.
+func (c *CacheOf[K, V]) Delete(key K) {
+	c.c.Delete(key)
+}
+
+// Len returns the number of cached entries.
+func (c *CacheOf[K, V]) Len() int {
+	return c.c.Len()
+}
+
+// Range calls f sequentially for each cached key and value until f returns
+// false. Memoized *CachedErrors are skipped.
+func (c *CacheOf[K, V]) Range(f func(key K, value V) bool) {
+	c.c.Range(func(key, value interface{}) bool {
+		v, ok := loadedOf[V](value, true)
+		return !ok || f(key.(K), v)
+	})
+}
+
+// Stats returns a snapshot of the statistics of the cache.
+func (c *CacheOf[K, V]) Stats() Stats {
+	return c.c.Stats()
+}
+
+// Close releases the resources held by the cache.
+func (c *CacheOf[K, V]) Close() error {
+	return c.c.Close()
+}
+
+// MultiLevelMapOf is a type-safe wrapper of a MultiLevelMap whose leaf values
+// are of type V. MultiLevelMapOf should not be copied after first use.
+type MultiLevelMapOf[V any] struct {
+	m MultiLevelMap
+}
+
+// NewMultiLevelMapOf returns a new MultiLevelMapOf with the given newMap
+// factory. See NewMultiLevelMap.
+func NewMultiLevelMapOf[V any](newMap func() CacheInterface) *MultiLevelMapOf[V] {
+	m := &MultiLevelMapOf[V]{}
+	if newMap != nil {
+		m.m.newLevel = func(level int) CacheInterface {
+			return newMap()
+		}
+	}
+	return m
+}
+
+// LoadOrCall loads the value in path. If the value doesn't exist, it calls
+// getValue only once. See MultiLevelMap.LoadOrCall. A memoized *CachedError is
+// returned as the zero value of V.
+func (m *MultiLevelMapOf[V]) LoadOrCall(getValue func() V, path ...interface{}) V {
+	return valueOf[V](m.m.LoadOrCall(func() interface{} {
+		return getValue()
+	}, path...))
+}
+
+// Load returns the cached value in path if present. It never calls a loader.
+// See MultiLevelMap.Load. It returns false for a memoized *CachedError.
+func (m *MultiLevelMapOf[V]) Load(path ...interface{}) (value V, ok bool) {
+	return loadedOf[V](m.m.Load(path...))
+}
+
+// Peek is like Load but doesn't mark the path as recently used. See
+// MultiLevelMap.Peek.
+func (m *MultiLevelMapOf[V]) Peek(path ...interface{}) (value V, ok bool) {
+	return loadedOf[V](m.m.Peek(path...))
+}
+
+// StorePath sets the value in path, overwriting the existing value. See
+// MultiLevelMap.StorePath.
+func (m *MultiLevelMapOf[V]) StorePath(value V, path ...interface{}) {
+	m.m.StorePath(value, path...)
+}
+
+// Walk calls f sequentially for each path and value of the leaves until f
+// returns false. Memoized *CachedErrors are skipped. See MultiLevelMap.Walk.
+func (m *MultiLevelMapOf[V]) Walk(f func(path []interface{}, value V) bool) {
+	m.m.Walk(func(path []interface{}, value interface{}) bool {
+		v, ok := loadedOf[V](value, true)
+		return !ok || f(path, v)
+	})
+}
+
+// Clear deletes all the values. See MultiLevelMap.Clear.
+func (m *MultiLevelMapOf[V]) Clear() {
+	m.m.Clear()
+}
+
+// Size returns the total number of leaf values. See MultiLevelMap.Size.
+func (m *MultiLevelMapOf[V]) Size() int {
+	return m.m.Size()
+}
+
+// Stats returns a snapshot of the statistics of the MultiLevelMapOf. See
+// MultiLevelMap.Stats.
+func (m *MultiLevelMapOf[V]) Stats() Stats {
+	return m.m.Stats()
+}
+
+// Prune removes a subtree of the path. See MultiLevelMap.Prune.
+func (m *MultiLevelMapOf[V]) Prune(path ...interface{}) {
+	m.m.Prune(path...)
+}
+
+// valueOf converts v to V. A v that isn't a V, e.g. nil for a missing key or a
+// memoized *CachedError, becomes the zero value of V.
+func valueOf[V any](v interface{}) V {
+	value, _ := v.(V)
+	return value
+}
+
+// loadedOf converts the loaded v to V. It returns false if v isn't a V, e.g. a
+// memoized *CachedError, unless v is nil.
+func loadedOf[V any](v interface{}, ok bool) (V, bool) {
+	value, isV := v.(V)
+	return value, ok && (isV || v == nil)
+}
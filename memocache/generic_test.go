@@ -0,0 +1,100 @@
+package memocache
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func ExampleCacheOf() {
+	m := NewCacheOf[int, string](&sync.Map{})
+
+	fmt.Println(m.LoadOrCall(1, func() string { return "one" }))
+	fmt.Println(m.LoadOrCall(1, func() string { return "not one" }))
+	m.Delete(1)
+	fmt.Println(m.LoadOrCall(1, func() string { return "maybe not one" }))
+	if _, ok := m.Load(2); !ok {
+		fmt.Println("2 is not cached")
+	}
+	// Output:
+	// one
+	// one
+	// maybe not one
+	// 2 is not cached
+}
+
+func ExampleNewLRUCacheOf() {
+	m := NewLRUCacheOf[string, int](2)
+
+	for _, key := range []string{"a", "bb", "a", "ccc", "bb"} {
+		m.LoadOrCall(key, func() int {
+			fmt.Printf("computing %q\n", key)
+			return len(key)
+		})
+	}
+	// Output:
+	// computing "a"
+	// computing "bb"
+	// computing "ccc"
+	// computing "bb"
+}
+
+func ExampleMultiLevelMapOf() {
+	var m MultiLevelMapOf[string]
+
+	names := []string{"John", "Mary"}
+	lookup := func(id int) string {
+		return m.LoadOrCall(func() string {
+			return names[id]
+		}, "users", id)
+	}
+
+	fmt.Println(lookup(0), lookup(1))
+	names[0], names[1] = strings.ToUpper(names[0]), strings.ToUpper(names[1])
+	m.Prune("users", 1)
+	fmt.Println(lookup(0), lookup(1))
+	// Output:
+	// John Mary
+	// John MARY
+}
+
+func TestCacheOf_CachedError(t *testing.T) {
+	c := NewCacheOf[string, int](&sync.Map{})
+	_, err := c.LoadOrCallErr("a", func() (int, error) {
+		return 0, &CachedError{Err: errors.New("not found")}
+	})
+	var ce *CachedError
+	if !errors.As(err, &ce) {
+		t.Fatalf("LoadOrCallErr() error = %v, want a *CachedError", err)
+	}
+	if got := c.LoadOrCall("a", func() int { return 1 }); got != 0 {
+		t.Errorf("LoadOrCall() = %v, want 0", got)
+	}
+	if got, ok := c.Load("a"); ok {
+		t.Errorf("Load() = %v, true, want false", got)
+	}
+	if got, ok := c.Peek("a"); ok {
+		t.Errorf("Peek() = %v, true, want false", got)
+	}
+	c.Range(func(key string, value int) bool {
+		t.Errorf("Range() called with %v=%v", key, value)
+		return true
+	})
+
+	m := NewMultiLevelMapOf[int](nil)
+	m.m.LoadOrCallErr(func() (interface{}, error) {
+		return nil, &CachedError{Err: errors.New("not found")}
+	}, "a", "b")
+	if got := m.LoadOrCall(func() int { return 1 }, "a", "b"); got != 0 {
+		t.Errorf("MultiLevelMapOf.LoadOrCall() = %v, want 0", got)
+	}
+	if got, ok := m.Load("a", "b"); ok {
+		t.Errorf("MultiLevelMapOf.Load() = %v, true, want false", got)
+	}
+	m.Walk(func(path []interface{}, value int) bool {
+		t.Errorf("MultiLevelMapOf.Walk() called with %v=%v", path, value)
+		return true
+	})
+}
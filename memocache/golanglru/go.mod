@@ -0,0 +1,10 @@
+module github.com/jaeyeom/gomemocache/memocache/golanglru
+
+go 1.20
+
+replace github.com/jaeyeom/gomemocache => ../..
+
+require (
+	github.com/hashicorp/golang-lru/v2 v2.0.7
+	github.com/jaeyeom/gomemocache v0.0.0-00010101000000-000000000000
+)
@@ -0,0 +1,125 @@
+// Package golanglru adapts a github.com/hashicorp/golang-lru/v2 cache as the
+// backing map of a memocache.Cache, so that the once-per-key LoadOrCall and
+// the MultiLevelMap of memocache can be layered on top of an existing
+// golang-lru cache without migrating the storage.
+//
+//	l, err := lru.New[interface{}, interface{}](10000)
+//	if err != nil {
+//		return err
+//	}
+//	c := memocache.NewCache(golanglru.New(l))
+package golanglru
+
+import (
+	"sync"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/jaeyeom/gomemocache/memocache"
+)
+
+// Map is a memocache.MapInterface backed by a golang-lru cache. Besides
+// LoadOrStore and Delete, it has the optional Load, Store, Peek, Range, Len,
+// Clear, CompareAndSwap and CompareAndDelete methods that memocache.Cache uses
+// if present.
+type Map struct {
+	c  *lru.Cache[interface{}, interface{}]
+	mu sync.Mutex // Serializes writes so that the comparisons are atomic
+}
+
+var _ memocache.MapInterface = (*Map)(nil)
+
+// New returns a new Map backed by c. The entries in c should only be added
+// through the Map.
+func New(c *lru.Cache[interface{}, interface{}]) *Map {
+	return &Map{c: c}
+}
+
+// LoadOrStore returns the existing value for the key if present, marking it
+// as recently used. Otherwise, it stores and returns the given value. The
+// loaded result is true if the value was loaded, false if stored.
+func (m *Map) LoadOrStore(key, value interface{}) (actual interface{}, loaded bool) {
+	if v, ok := m.c.Get(key); ok {
+		return v, true
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if v, ok, _ := m.c.PeekOrAdd(key, value); ok {
+		return v, true
+	}
+	return value, false
+}
+
+// Load returns the value for the key if present, marking it as recently used.
+func (m *Map) Load(key interface{}) (value interface{}, ok bool) {
+	return m.c.Get(key)
+}
+
+// Peek returns the value for the key if present without marking it as
+// recently used.
+func (m *Map) Peek(key interface{}) (value interface{}, ok bool) {
+	return m.c.Peek(key)
+}
+
+// Store sets the value for the key.
+func (m *Map) Store(key, value interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.c.Add(key, value)
+}
+
+// Delete deletes the value for the key.
+func (m *Map) Delete(key interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.c.Remove(key)
+}
+
+// CompareAndSwap swaps the old and new values for the key if the value stored
+// in the map is equal to old.
+func (m *Map) CompareAndSwap(key, old, new interface{}) (swapped bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if v, ok := m.c.Peek(key); !ok || v != old {
+		return false
+	}
+	m.c.Add(key, new)
+	return true
+}
+
+// CompareAndDelete deletes the entry for the key if its value is equal to old.
+func (m *Map) CompareAndDelete(key, old interface{}) (deleted bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if v, ok := m.c.Peek(key); !ok || v != old {
+		return false
+	}
+	m.c.Remove(key)
+	return true
+}
+
+// Range calls f sequentially for each key and value present in the map from
+// the oldest to the newest. If f returns false, range stops the iteration. The
+// keys are snapshotted before the iteration.
+func (m *Map) Range(f func(key, value interface{}) bool) {
+	for _, key := range m.c.Keys() {
+		value, ok := m.c.Peek(key)
+		if !ok {
+			continue
+		}
+		if !f(key, value) {
+			return
+		}
+	}
+}
+
+// Len returns the number of values in the map.
+func (m *Map) Len() int {
+	return m.c.Len()
+}
+
+// Clear deletes all the values.
+func (m *Map) Clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.c.Purge()
+}
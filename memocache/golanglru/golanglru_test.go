@@ -0,0 +1,95 @@
+package golanglru
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/jaeyeom/gomemocache/memocache"
+	"github.com/jaeyeom/gomemocache/memocache/cachetest"
+)
+
+func Example() {
+	l, err := lru.New[interface{}, interface{}](2)
+	if err != nil {
+		panic(err)
+	}
+	c := memocache.NewCache(New(l))
+
+	for _, key := range []string{"a", "b", "a", "c", "a", "b"} {
+		c.LoadOrCall(key, func() interface{} {
+			fmt.Println("loading", key)
+			return key
+		})
+	}
+	// Output:
+	// loading a
+	// loading b
+	// loading c
+	// loading b
+}
+
+func TestMap(t *testing.T) {
+	l, err := lru.New[interface{}, interface{}](10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := memocache.NewCache(New(l))
+	c.Store("a", 1)
+	c.LoadOrCall("b", func() interface{} { return 2 })
+	if v, ok := c.Peek("a"); !ok || v != 1 {
+		t.Errorf("Peek(a) = %v, %v, want 1, true", v, ok)
+	}
+	if n := c.Len(); n != 2 {
+		t.Errorf("Len() = %d, want 2", n)
+	}
+	var keys []interface{}
+	c.Range(func(key, value interface{}) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if got := fmt.Sprint(keys); got != "[a b]" {
+		t.Errorf("Range() visited %v, want [a b]", got)
+	}
+	c.Clear()
+	if n := l.Len(); n != 0 {
+		t.Errorf("Len() = %d after Clear(), want 0", n)
+	}
+}
+
+func TestMap_TTL(t *testing.T) {
+	l, err := lru.New[interface{}, interface{}](10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	c := memocache.NewCache(New(l), memocache.WithClock(clock), memocache.WithTTL(time.Minute))
+	c.LoadOrCall("a", func() interface{} { return 1 })
+	clock.now = clock.now.Add(time.Minute)
+	if v := c.LoadOrCall("a", func() interface{} { return 2 }); v != 2 {
+		t.Errorf("LoadOrCall(a) = %v after expiry, want 2", v)
+	}
+}
+
+// fakeClock is a memocache.Clock that only moves when told to.
+type fakeClock struct {
+	memocache.Clock
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func TestConformance(t *testing.T) {
+	newMap := func(maxSize int) memocache.MapInterface {
+		l, err := lru.New[interface{}, interface{}](maxSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return New(l)
+	}
+	cachetest.TestMapInterface(t, func() memocache.MapInterface { return newMap(1000) })
+	cachetest.TestBoundedMap(t, newMap)
+}
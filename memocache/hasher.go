@@ -0,0 +1,215 @@
+package memocache
+
+import "sync"
+
+// Hasher hashes and compares keys, so that keys that aren't comparable, e.g.
+// slices, or whose identity isn't their Go equality, e.g. protobuf messages,
+// can be used without formatting them into strings. Keys that are Equal should
+// have the same Hash.
+type Hasher interface {
+	Hash(key interface{}) uint64
+	Equal(a, b interface{}) bool
+}
+
+// HasherFunc returns a Hasher of the keys of type K with the functions.
+func HasherFunc[K any](hash func(key K) uint64, equal func(a, b K) bool) Hasher {
+	return hasherFunc[K]{hash: hash, equal: equal}
+}
+
+type hasherFunc[K any] struct {
+	hash  func(key K) uint64
+	equal func(a, b K) bool
+}
+
+func (h hasherFunc[K]) Hash(key interface{}) uint64 {
+	return h.hash(key.(K))
+}
+
+func (h hasherFunc[K]) Equal(a, b interface{}) bool {
+	return h.equal(a.(K), b.(K))
+}
+
+// NewShardedHashMap returns a new unbounded ShardedMap with numShards shards
+// whose keys are hashed and compared by the hasher rather than by Go equality.
+// See NewShardedMap for numShards. A Cache backed by the map accepts any keys
+// the hasher accepts, but LoadOrCallMany, Lease, LoadOrRun and AddDependency
+// still need comparable keys.
+func NewShardedHashMap(numShards int, hasher Hasher) *ShardedMap {
+	return newShardedMap(numShards, hasher.Hash, func() MapInterface {
+		return &hashedMap{hasher: hasher, m: make(map[uint64][]hashedEntry)}
+	})
+}
+
+// NewHashCacheOf returns a new CacheOf whose keys of type K, which need not be
+// comparable, are hashed and compared by the functions. It's backed by a
+// ShardedMap made by NewShardedHashMap with numShards shards. See NewCache for
+// the options.
+func NewHashCacheOf[K, V any](numShards int, hash func(key K) uint64, equal func(a, b K) bool, opts ...Option) *CacheOf[K, V] {
+	return &CacheOf[K, V]{c: NewCache(NewShardedHashMap(numShards, HasherFunc(hash, equal)), opts...)}
+}
+
+// hashedMap is a map of keys hashed and compared by a Hasher guarded by a lock.
+// It's the shard of a ShardedMap made by NewShardedHashMap. The entries of a
+// hash are kept in a slice, so the map is as fast as the hash is good.
+type hashedMap struct {
+	mu     sync.RWMutex
+	hasher Hasher
+	m      map[uint64][]hashedEntry
+	n      int
+
+	onEvict func(key, value interface{}, reason EvictionReason)
+}
+
+// hashedEntry is an entry of a hashedMap.
+type hashedEntry struct {
+	key, value interface{}
+}
+
+func (h *hashedMap) SetOnEvict(f func(key, value interface{}, reason EvictionReason)) {
+	h.onEvict = f
+}
+
+// evictListener returns the listener set by SetOnEvict.
+func (h *hashedMap) evictListener() func(key, value interface{}, reason EvictionReason) {
+	return h.onEvict
+}
+
+// notify notifies the listener of the removal of the value of the key if the
+// value was removed.
+func (h *hashedMap) notify(key, value interface{}, removed bool, reason EvictionReason) {
+	if removed && h.onEvict != nil {
+		h.onEvict(key, value, reason)
+	}
+}
+
+// find returns the hash of the key and the index of its entry, or -1 if it's
+// missing. It should be called with h.mu held.
+func (h *hashedMap) find(key interface{}) (uint64, int) {
+	hash := h.hasher.Hash(key)
+	for i, kv := range h.m[hash] {
+		if h.hasher.Equal(kv.key, key) {
+			return hash, i
+		}
+	}
+	return hash, -1
+}
+
+// remove removes the entry i of the hash. It should be called with h.mu held.
+func (h *hashedMap) remove(hash uint64, i int) {
+	kvs := h.m[hash]
+	if len(kvs) == 1 {
+		delete(h.m, hash)
+	} else {
+		kvs[i] = kvs[len(kvs)-1]
+		kvs[len(kvs)-1] = hashedEntry{}
+		h.m[hash] = kvs[:len(kvs)-1]
+	}
+	h.n--
+}
+
+func (h *hashedMap) LoadOrStore(key, value interface{}) (actual interface{}, loaded bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hash, i := h.find(key)
+	if i >= 0 {
+		return h.m[hash][i].value, true
+	}
+	h.m[hash] = append(h.m[hash], hashedEntry{key: key, value: value})
+	h.n++
+	return value, false
+}
+
+func (h *hashedMap) Load(key interface{}) (value interface{}, ok bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	hash, i := h.find(key)
+	if i < 0 {
+		return nil, false
+	}
+	return h.m[hash][i].value, true
+}
+
+func (h *hashedMap) Store(key, value interface{}) {
+	h.mu.Lock()
+	hash, i := h.find(key)
+	var old interface{}
+	if i >= 0 {
+		old = h.m[hash][i].value
+		h.m[hash][i].value = value
+	} else {
+		h.m[hash] = append(h.m[hash], hashedEntry{key: key, value: value})
+		h.n++
+	}
+	h.mu.Unlock()
+	h.notify(key, old, i >= 0, EvictionReplaced)
+}
+
+func (h *hashedMap) CompareAndSwap(key, old, new interface{}) (swapped bool) {
+	h.mu.Lock()
+	hash, i := h.find(key)
+	swapped = i >= 0 && h.m[hash][i].value == old
+	if swapped {
+		h.m[hash][i].value = new
+	}
+	h.mu.Unlock()
+	h.notify(key, old, swapped, EvictionReplaced)
+	return swapped
+}
+
+func (h *hashedMap) CompareAndDelete(key, old interface{}) (deleted bool) {
+	h.mu.Lock()
+	hash, i := h.find(key)
+	deleted = i >= 0 && h.m[hash][i].value == old
+	if deleted {
+		h.remove(hash, i)
+	}
+	h.mu.Unlock()
+	h.notify(key, old, deleted, EvictionDeleted)
+	return deleted
+}
+
+func (h *hashedMap) Delete(key interface{}) {
+	h.mu.Lock()
+	hash, i := h.find(key)
+	var old interface{}
+	if i >= 0 {
+		old = h.m[hash][i].value
+		h.remove(hash, i)
+	}
+	h.mu.Unlock()
+	h.notify(key, old, i >= 0, EvictionDeleted)
+}
+
+// Range iterates over a snapshot, so f may call other methods of the map.
+func (h *hashedMap) Range(f func(key, value interface{}) bool) {
+	h.mu.RLock()
+	kvs := make([]hashedEntry, 0, h.n)
+	for _, entries := range h.m {
+		kvs = append(kvs, entries...)
+	}
+	h.mu.RUnlock()
+	for _, kv := range kvs {
+		if !f(kv.key, kv.value) {
+			return
+		}
+	}
+}
+
+func (h *hashedMap) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.n
+}
+
+func (h *hashedMap) Clear() {
+	h.mu.Lock()
+	old := h.m
+	h.m = make(map[uint64][]hashedEntry)
+	h.n = 0
+	h.mu.Unlock()
+	for _, entries := range old {
+		for _, kv := range entries {
+			h.notify(kv.key, kv.value, true, EvictionCleared)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package memocache
+
+import (
+	"bytes"
+	"hash/maphash"
+	"testing"
+)
+
+func TestNewHashCacheOf(t *testing.T) {
+	seed := maphash.MakeSeed()
+	c := NewHashCacheOf[[]byte, string](4, func(key []byte) uint64 {
+		return maphash.Bytes(seed, key)
+	}, bytes.Equal)
+	calls := 0
+	load := func() string { calls++; return "v" }
+	c.LoadOrCall([]byte("key"), load)
+	if v := c.LoadOrCall([]byte("key"), load); v != "v" || calls != 1 {
+		t.Errorf("LoadOrCall() of an equal key = %q after %d calls, want v after 1", v, calls)
+	}
+	if v, ok := c.Load([]byte("key")); !ok || v != "v" {
+		t.Errorf("Load() = %q, %v, want v, true", v, ok)
+	}
+	c.Delete([]byte("key"))
+	if _, ok := c.Load([]byte("key")); ok {
+		t.Error("Load() found a deleted key")
+	}
+	c.Store([]byte("a"), "a")
+	c.Store([]byte("b"), "b")
+	c.Store([]byte("a"), "A")
+	if n := c.Len(); n != 2 {
+		t.Errorf("Len() = %d, want 2", n)
+	}
+	got := map[string]string{}
+	c.Range(func(key []byte, value string) bool {
+		got[string(key)] = value
+		return true
+	})
+	if len(got) != 2 || got["a"] != "A" || got["b"] != "b" {
+		t.Errorf("Range() = %v, want map[a:A b:b]", got)
+	}
+}
+
+func TestShardedHashMapCollisions(t *testing.T) {
+	// All the keys collide, so they are told apart by Equal only.
+	m := NewShardedHashMap(1, HasherFunc(func(key []int) uint64 { return 0 }, func(a, b []int) bool {
+		return len(a) == len(b) && (len(a) == 0 || a[0] == b[0])
+	}))
+	for i := 0; i < 3; i++ {
+		if _, loaded := m.LoadOrStore([]int{i}, i); loaded {
+			t.Errorf("LoadOrStore([%d]) loaded, want stored", i)
+		}
+	}
+	m.Delete([]int{0})
+	for i := 0; i < 3; i++ {
+		v, ok := m.Load([]int{i})
+		if want := i != 0; ok != want || ok && v != i {
+			t.Errorf("Load([%d]) = %v, %v, want %d, %v", i, v, ok, i, want)
+		}
+	}
+	if !m.CompareAndDelete([]int{2}, 2) || m.Len() != 1 {
+		t.Errorf("CompareAndDelete([2]) didn't delete, Len() = %d", m.Len())
+	}
+}
@@ -0,0 +1,36 @@
+package memocache
+
+// LoadOrCallHit is like LoadOrCall but also reports whether the value was a
+// hit, i.e. served without calling getValue, like the loaded result of
+// sync.Map.LoadOrStore. A call that waited for the load of another goroutine is
+// a hit, as it's counted in Stats.
+func (c *Cache) LoadOrCallHit(key interface{}, getValue func() interface{}) (value interface{}, hit bool) {
+	called := false
+	value = c.LoadOrCall(key, func() interface{} {
+		called = true
+		return getValue()
+	})
+	return value, !called
+}
+
+// LoadOrCallHit is like LoadOrCall but also reports whether the value was
+// served without calling getValue.
+func (m *Map) LoadOrCallHit(key interface{}, getValue func() interface{}) (value interface{}, hit bool) {
+	called := false
+	value = m.LoadOrCall(key, func() interface{} {
+		called = true
+		return getValue()
+	})
+	return value, !called
+}
+
+// LoadOrCallHit is like LoadOrCall but also reports whether the value in path
+// was served without calling getValue.
+func (m *MultiLevelMap) LoadOrCallHit(getValue func() interface{}, path ...interface{}) (value interface{}, hit bool) {
+	called := false
+	value = m.LoadOrCall(func() interface{} {
+		called = true
+		return getValue()
+	}, path...)
+	return value, !called
+}
@@ -0,0 +1,33 @@
+package memocache
+
+import (
+	"container/list"
+	"fmt"
+	"testing"
+)
+
+func ExampleCache_LoadOrCallHit() {
+	c := NewCache(NewLRUMap(list.New(), 10))
+	for i := 0; i < 2; i++ {
+		value, hit := c.LoadOrCallHit("a", func() interface{} {
+			return "value"
+		})
+		fmt.Println(value, hit)
+	}
+	// Output:
+	// value false
+	// value true
+}
+
+func TestLoadOrCallHit(t *testing.T) {
+	var m Map
+	var mm MultiLevelMap
+	for i, want := range []bool{false, true} {
+		if _, hit := m.LoadOrCallHit("a", func() interface{} { return i }); hit != want {
+			t.Errorf("Map.LoadOrCallHit() #%d hit = %v, want %v", i, hit, want)
+		}
+		if _, hit := mm.LoadOrCallHit(func() interface{} { return i }, "a", "b"); hit != want {
+			t.Errorf("MultiLevelMap.LoadOrCallHit() #%d hit = %v, want %v", i, hit, want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package memocache
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// HotKey is a frequently requested key reported by Cache.TopKeys.
+type HotKey struct {
+	Key interface{}
+	// Count is the estimated number of requests of the key in the window.
+	// It may overestimate a key that entered the tracker late by up to
+	// Error.
+	Count uint64
+	// Error is the maximum overestimation of Count.
+	Error uint64
+}
+
+// WithHotKeys makes a Cache track the k most requested keys, hits and misses
+// alike, over a sliding window of the given length, so that Cache.TopKeys can
+// report them, e.g. to decide what to pre-warm or to detect a skew of the
+// keys. The keys are counted with the space-saving algorithm in k counters, so
+// the memory is bounded however many keys are requested, and the counts of
+// the keys dropped by the tracker are estimated. The counts of the previous
+// window are weighted by how much of it still overlaps the sliding window.
+// Every request takes a lock and a reading of the clock, and a request of an
+// untracked key scans the k counters, so k should be small, e.g. 100.
+func WithHotKeys(k int, window time.Duration) Option {
+	return func(c *config) {
+		c.hotKeys = k
+		c.hotKeysWindow = window
+	}
+}
+
+// hotKeys tracks the most requested keys enabled by WithHotKeys.
+type hotKeys struct {
+	mu     sync.Mutex
+	k      int
+	window time.Duration
+	clock  Clock
+	start  int64 // Start of the current window in Unix nanoseconds
+	cur    map[interface{}]*hotCount
+	prev   map[interface{}]*hotCount
+}
+
+// hotCount is a counter of the space-saving algorithm.
+type hotCount struct {
+	count, err uint64
+}
+
+// newHotKeys returns a tracker for the config, or nil if the tracking is not
+// enabled.
+func newHotKeys(c *config) *hotKeys {
+	if c.hotKeys <= 0 || c.hotKeysWindow <= 0 {
+		return nil
+	}
+	return &hotKeys{
+		k:      c.hotKeys,
+		window: c.hotKeysWindow,
+		clock:  c.clock,
+		start:  now(c.clock).UnixNano(),
+		cur:    make(map[interface{}]*hotCount, c.hotKeys),
+	}
+}
+
+// record counts a request of the key. It's a no-op on a nil tracker.
+func (h *hotKeys) record(key interface{}) {
+	if h == nil {
+		return
+	}
+	now := now(h.clock).UnixNano()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.advance(now)
+	if c, ok := h.cur[key]; ok {
+		c.count++
+		return
+	}
+	if len(h.cur) < h.k {
+		h.cur[key] = &hotCount{count: 1}
+		return
+	}
+	// Replace the least counted key, which the new key may have been all
+	// along.
+	var minKey interface{}
+	var min *hotCount
+	for k, c := range h.cur {
+		if min == nil || c.count < min.count {
+			minKey, min = k, c
+		}
+	}
+	delete(h.cur, minKey)
+	min.err = min.count
+	min.count++
+	h.cur[key] = min
+}
+
+// advance starts a new window if the current one is over at the time now. It
+// should be called with h.mu held.
+func (h *hotKeys) advance(now int64) {
+	elapsed := now - h.start
+	if elapsed < int64(h.window) {
+		return
+	}
+	h.prev = h.cur
+	if elapsed >= 2*int64(h.window) {
+		h.prev = nil
+	}
+	h.start = now - elapsed%int64(h.window)
+	h.cur = make(map[interface{}]*hotCount, h.k)
+}
+
+// top returns up to n keys with the highest counts over the sliding window.
+func (h *hotKeys) top(n int) []HotKey {
+	now := now(h.clock).UnixNano()
+	h.mu.Lock()
+	h.advance(now)
+	// Weight of the previous window still in the sliding window.
+	weight := 1 - float64(now-h.start)/float64(h.window)
+	counts := make(map[interface{}]*HotKey, len(h.cur)+len(h.prev))
+	for key, c := range h.prev {
+		counts[key] = &HotKey{
+			Key:   key,
+			Count: uint64(float64(c.count) * weight),
+			Error: uint64(float64(c.err) * weight),
+		}
+	}
+	for key, c := range h.cur {
+		hk, ok := counts[key]
+		if !ok {
+			hk = &HotKey{Key: key}
+			counts[key] = hk
+		}
+		hk.Count += c.count
+		hk.Error += c.err
+	}
+	h.mu.Unlock()
+
+	top := make([]HotKey, 0, len(counts))
+	for _, hk := range counts {
+		if hk.Count > 0 {
+			top = append(top, *hk)
+		}
+	}
+	sort.Slice(top, func(i, j int) bool {
+		return top[i].Count > top[j].Count
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// TopKeys returns up to n most requested keys over the window of WithHotKeys
+// from the most requested, or nil without the option. Keys with equal counts
+// are in no particular order.
+func (c *Cache) TopKeys(n int) []HotKey {
+	if c.hot == nil || n <= 0 {
+		return nil
+	}
+	return c.hot.top(n)
+}
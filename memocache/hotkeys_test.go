@@ -0,0 +1,73 @@
+package memocache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func ExampleCache_TopKeys() {
+	c := NewCache(&sync.Map{}, WithHotKeys(10, time.Minute))
+	for i := 0; i < 5; i++ {
+		c.LoadOrCall("hot", func() interface{} { return 1 })
+	}
+	c.LoadOrCall("cold", func() interface{} { return 2 })
+
+	for _, k := range c.TopKeys(1) {
+		fmt.Printf("%v: %d requests\n", k.Key, k.Count)
+	}
+	// Output:
+	// hot: 5 requests
+}
+
+func TestCache_TopKeys(t *testing.T) {
+	c := NewCache(&sync.Map{}, WithHotKeys(3, time.Minute))
+	for i := 0; i < 100; i++ {
+		key := i % 10
+		if i%2 == 0 {
+			key = 42
+		}
+		c.LoadOrCall(key, func() interface{} { return key })
+	}
+
+	top := c.TopKeys(1)
+	if len(top) != 1 || top[0].Key != 42 || top[0].Count != 50 || top[0].Error != 0 {
+		t.Errorf("TopKeys(1) = %+v, want key 42 with 50 requests", top)
+	}
+	if got := len(c.TopKeys(10)); got != 3 {
+		t.Errorf("len(TopKeys(10)) = %d, want the 3 tracked keys", got)
+	}
+	if got := NewCache(&sync.Map{}).TopKeys(10); got != nil {
+		t.Errorf("TopKeys() without WithHotKeys = %v, want nil", got)
+	}
+}
+
+func TestCache_TopKeysWindow(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCache(&sync.Map{}, WithClock(clock), WithHotKeys(10, time.Minute))
+	for i := 0; i < 100; i++ {
+		c.LoadOrCall("old", func() interface{} { return 1 })
+	}
+	clock.Advance(time.Minute)
+	for i := 0; i < 60; i++ {
+		c.LoadOrCall("new", func() interface{} { return 1 })
+	}
+	clock.Advance(30 * time.Second)
+
+	want := []HotKey{{Key: "new", Count: 60}, {Key: "old", Count: 50}}
+	if got := c.TopKeys(10); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("TopKeys() half a window later = %v, want %v", got, want)
+	}
+
+	clock.Advance(time.Minute)
+	want = []HotKey{{Key: "new", Count: 30}}
+	if got := c.TopKeys(10); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("TopKeys() a window and a half later = %v, want %v", got, want)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if got := c.TopKeys(10); len(got) != 0 {
+		t.Errorf("TopKeys() after two idle windows = %v, want none", got)
+	}
+}
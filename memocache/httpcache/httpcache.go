@@ -0,0 +1,249 @@
+// Package httpcache provides a net/http middleware caching the responses of a
+// handler in a memocache.MultiLevelMap, so that concurrent identical requests
+// are served by a single call of the handler and the cached responses can be
+// invalidated by URL path:
+//
+//	c := httpcache.New(nil, time.Minute, "Accept-Language")
+//	http.Handle("/", c.Middleware(handler))
+//	...
+//	c.Prune("example.com", "/users/42")
+//
+// Only GET and HEAD requests are cached, and only the responses with status
+// 200 whose Cache-Control header doesn't forbid storing them. The requests
+// waiting for an uncacheable response, e.g. a 404, are served the same
+// response, unless it's private or no-store, in which case each of them calls
+// the handler. The responses are buffered, so the middleware doesn't suit
+// streaming handlers.
+//
+// The responses of the middleware have an X-Cache header telling whether they
+// were served from the cache (HIT), by the handler (MISS), or from the cache
+// after they expired because the handler failed (STALE). The responses from
+// the cache also have an Age header, and the stale ones a Warning header. A
+// request with Cache-Control: no-cache refreshes the cached response.
+package httpcache
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jaeyeom/gomemocache/memocache"
+)
+
+// Cache caches the responses of handlers under (host, path segments...,
+// request) paths of a MultiLevelMap, where the request is the method, the
+// query and the values of the selected headers. Cache should be created with
+// New.
+type Cache struct {
+	m       *memocache.MultiLevelMap
+	ttl     time.Duration
+	headers []string
+	now     func() time.Time
+}
+
+// DefaultMaxEntries is the number of entries, counting the levels of the URL
+// path segments, up to which a Cache made by New with a nil newMap holds
+// responses before evicting the least recently used ones.
+const DefaultMaxEntries = 10000
+
+// New returns a new Cache whose levels are created by newMap, see
+// memocache.NewMultiLevelMap, and whose responses expire after ttl. If newMap
+// is nil, the levels share an LRU budget of DefaultMaxEntries entries, so the
+// responses that are no longer requested are evicted eventually. The values
+// of the given request headers, e.g. Accept-Encoding, are a part of the key of
+// a response.
+func New(newMap func() memocache.CacheInterface, ttl time.Duration, headers ...string) *Cache {
+	if newMap == nil {
+		newMap = memocache.NewLRUBudget(DefaultMaxEntries).NewLevel
+	}
+	return &Cache{
+		m:       memocache.NewMultiLevelMap(newMap),
+		ttl:     ttl,
+		headers: headers,
+		now:     time.Now,
+	}
+}
+
+// requestKey is the last element of the path of a response, so that the
+// responses of a URL path don't conflict with the levels of the paths below
+// it.
+type requestKey struct {
+	method  string
+	query   string
+	headers string
+}
+
+// response is a cached response.
+type response struct {
+	status  int
+	header  http.Header
+	body    []byte
+	date    time.Time
+	expires time.Time
+}
+
+// The values of the X-Cache header.
+const (
+	cacheHit   = "HIT"
+	cacheMiss  = "MISS"
+	cacheStale = "STALE"
+)
+
+// uncacheable is the error of a load whose response shouldn't be cached.
+type uncacheable struct {
+	resp *response
+}
+
+func (uncacheable) Error() string {
+	return "httpcache: uncacheable response"
+}
+
+// urlPath returns the path of the cache for the host and the URL path.
+func urlPath(host, p string) []interface{} {
+	path := []interface{}{host}
+	for _, seg := range strings.Split(strings.Trim(p, "/"), "/") {
+		if seg != "" {
+			path = append(path, seg)
+		}
+	}
+	return path
+}
+
+// Prune removes the cached responses of the URL path of the host and of the
+// paths below it.
+func (c *Cache) Prune(host, urlPathPrefix string) {
+	c.m.Prune(urlPath(host, urlPathPrefix)...)
+}
+
+// Middleware returns a handler serving the cacheable requests from the cache,
+// calling next to fill it.
+func (c *Cache) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		var values []string
+		for _, h := range c.headers {
+			values = append(values, r.Header.Values(h)...)
+			values = append(values, "")
+		}
+		path := append(urlPath(r.Host, r.URL.Path), requestKey{
+			method:  r.Method,
+			query:   r.URL.RawQuery,
+			headers: strings.Join(values, "\n"),
+		})
+		called := false
+		load := func() (interface{}, error) {
+			called = true
+			rec := &recorder{header: make(http.Header), status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+			date := c.now()
+			resp := &response{
+				status:  rec.status,
+				header:  rec.header,
+				body:    rec.body.Bytes(),
+				date:    date,
+				expires: date.Add(c.ttl),
+			}
+			if !cacheable(resp) {
+				return nil, uncacheable{resp: resp}
+			}
+			return resp, nil
+		}
+		if noCache(r) {
+			c.m.Prune(path...)
+		}
+		value, err := c.m.LoadOrCallErr(load, path...)
+		var expired *response
+		if err == nil && c.now().After(value.(*response).expires) {
+			expired = value.(*response)
+			c.m.CompareAndPrune(expired, path...)
+			value, err = c.m.LoadOrCallErr(load, path...)
+		}
+		var u uncacheable
+		switch {
+		case errors.As(err, &u) && expired != nil && u.resp.status >= http.StatusInternalServerError:
+			expired.write(w, cacheStale, c.now())
+		case errors.As(err, &u) && !called && u.resp.personal():
+			next.ServeHTTP(w, r)
+		case errors.As(err, &u):
+			u.resp.write(w, cacheMiss, c.now())
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		case called:
+			value.(*response).write(w, cacheMiss, c.now())
+		default:
+			value.(*response).write(w, cacheHit, c.now())
+		}
+	})
+}
+
+// noCache returns true if the Cache-Control header of the request has the
+// no-cache directive.
+func noCache(r *http.Request) bool {
+	for _, directive := range strings.Split(r.Header.Get("Cache-Control"), ",") {
+		if strings.EqualFold(strings.TrimSpace(directive), "no-cache") {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheable returns true if the response can be cached.
+func cacheable(resp *response) bool {
+	return resp.status == http.StatusOK && !resp.personal()
+}
+
+// personal returns true if the Cache-Control header of the response forbids
+// storing it, so it isn't handed to the other requests either.
+func (resp *response) personal() bool {
+	cc := strings.ToLower(resp.header.Get("Cache-Control"))
+	return strings.Contains(cc, "no-store") || strings.Contains(cc, "private")
+}
+
+// write writes the response to w with the X-Cache header set to the cache
+// status. The responses served from the cache have their Age at now, and the
+// stale ones a Warning.
+func (resp *response) write(w http.ResponseWriter, status string, now time.Time) {
+	h := w.Header()
+	for k, v := range resp.header {
+		h[k] = v
+	}
+	h.Set("X-Cache", status)
+	if status != cacheMiss {
+		h.Set("Age", strconv.FormatInt(int64(now.Sub(resp.date)/time.Second), 10))
+	}
+	if status == cacheStale {
+		h.Set("Warning", `110 - "Response is Stale"`)
+	}
+	w.WriteHeader(resp.status)
+	w.Write(resp.body)
+}
+
+// recorder is an http.ResponseWriter recording the response of a handler.
+type recorder struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rec *recorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	return rec.body.Write(b)
+}
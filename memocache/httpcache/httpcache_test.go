@@ -0,0 +1,233 @@
+package httpcache
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jaeyeom/gomemocache/memocache"
+)
+
+func TestMiddleware(t *testing.T) {
+	var calls atomic.Int32
+	c := New(nil, time.Minute, "Accept-Language")
+	h := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		if r.URL.Query().Get("private") != "" {
+			w.Header().Set("Cache-Control", "private")
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "%s %s %d", r.URL.Path, r.Header.Get("Accept-Language"), n)
+	}))
+	get := func(target, lang string) string {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.Header.Set("Accept-Language", lang)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if ct := w.Header().Get("Content-Type"); ct != "text/plain" {
+			t.Errorf("Content-Type of %s = %q, want text/plain", target, ct)
+		}
+		body, _ := io.ReadAll(w.Body)
+		return string(body)
+	}
+	for _, tc := range []struct {
+		target, lang, want string
+	}{
+		{"http://example.com/users/1", "en", "/users/1 en 1"},
+		{"http://example.com/users/1", "en", "/users/1 en 1"},
+		{"http://example.com/users/1", "ko", "/users/1 ko 2"},
+		{"http://example.com/users", "en", "/users en 3"},
+		{"http://example.com/users/1?private=1", "en", "/users/1 en 4"},
+		{"http://example.com/users/1?private=1", "en", "/users/1 en 5"},
+	} {
+		if got := get(tc.target, tc.lang); got != tc.want {
+			t.Errorf("GET %s in %s = %q, want %q", tc.target, tc.lang, got, tc.want)
+		}
+	}
+	c.Prune("example.com", "/users/1")
+	if got, want := get("http://example.com/users/1", "en"), "/users/1 en 6"; got != want {
+		t.Errorf("GET after Prune() = %q, want %q", got, want)
+	}
+	if got, want := get("http://example.com/users", "en"), "/users en 3"; got != want {
+		t.Errorf("GET of the parent after Prune() = %q, want %q", got, want)
+	}
+}
+
+func TestMiddleware_TTL(t *testing.T) {
+	var calls atomic.Int32
+	c := New(nil, time.Minute)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	h := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, calls.Add(1))
+	}))
+	for i := 0; i < 2; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		now = now.Add(2 * time.Minute)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("handler was called %d times, want 2 after the response expired", n)
+	}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil))
+	if n := calls.Load(); n != 3 {
+		t.Errorf("handler was called %d times, want POST to pass through", n)
+	}
+}
+
+func TestMiddleware_Headers(t *testing.T) {
+	var calls atomic.Int32
+	var failing atomic.Bool
+	c := New(nil, time.Minute)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	h := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, calls.Add(1))
+	}))
+	for _, tc := range []struct {
+		advance      time.Duration
+		cacheControl string
+		fail         bool
+		status       int
+		body         string
+		xCache, age  string
+		warning      string
+	}{
+		{status: 200, body: "1", xCache: "MISS"},
+		{advance: 10 * time.Second, status: 200, body: "1", xCache: "HIT", age: "10"},
+		{cacheControl: "max-age=0, no-cache", status: 200, body: "2", xCache: "MISS"},
+		{advance: 2 * time.Minute, fail: true, status: 200, body: "2", xCache: "STALE", age: "120", warning: `110 - "Response is Stale"`},
+		{fail: true, status: 503, body: "unavailable\n", xCache: "MISS"},
+	} {
+		now = now.Add(tc.advance)
+		failing.Store(tc.fail)
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.cacheControl != "" {
+			r.Header.Set("Cache-Control", tc.cacheControl)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tc.status || w.Body.String() != tc.body {
+			t.Errorf("response = %d %q, want %d %q", w.Code, w.Body.String(), tc.status, tc.body)
+		}
+		if got := w.Header().Get("X-Cache"); got != tc.xCache {
+			t.Errorf("X-Cache of %q = %q, want %q", tc.body, got, tc.xCache)
+		}
+		if got := w.Header().Get("Age"); got != tc.age {
+			t.Errorf("Age of %q = %q, want %q", tc.body, got, tc.age)
+		}
+		if got := w.Header().Get("Warning"); got != tc.warning {
+			t.Errorf("Warning of %q = %q, want %q", tc.body, got, tc.warning)
+		}
+	}
+}
+
+func TestMiddleware_DefaultBound(t *testing.T) {
+	c := New(nil, time.Minute)
+	h := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.URL.RawQuery)
+	}))
+	for i := 0; i < DefaultMaxEntries+100; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, fmt.Sprintf("/?q=%d", i), nil))
+	}
+	if n := c.m.Size(); n > DefaultMaxEntries {
+		t.Errorf("Size() = %d, want at most %d", n, DefaultMaxEntries)
+	}
+}
+
+// waitClock is a memocache.Clock whose timers never fire and signal their
+// creation, which a call of a Cache with WithWaitTimeout makes right before it
+// waits for the load of another request.
+type waitClock struct {
+	waiting chan struct{}
+}
+
+func (c waitClock) Now() time.Time {
+	return time.Now()
+}
+
+func (c waitClock) NewTimer(d time.Duration) memocache.Timer {
+	c.waiting <- struct{}{}
+	return stoppedTimer{}
+}
+
+// stoppedTimer is a memocache.Timer that never fires.
+type stoppedTimer struct{}
+
+func (stoppedTimer) C() <-chan time.Time {
+	return nil
+}
+
+func (stoppedTimer) Stop() bool {
+	return true
+}
+
+func TestMiddleware_ConcurrentUncacheable(t *testing.T) {
+	const numWaiters = 9
+	for _, tc := range []struct {
+		name      string
+		private   bool
+		wantCalls int32
+	}{
+		{"NotFound", false, 1},
+		{"Private", true, numWaiters + 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := waitClock{waiting: make(chan struct{}, numWaiters)}
+			c := New(func() memocache.CacheInterface {
+				return memocache.NewCache(&sync.Map{}, memocache.WithClock(clock), memocache.WithWaitTimeout(time.Hour, nil))
+			}, time.Minute)
+			var calls atomic.Int32
+			started := make(chan struct{})
+			release := make(chan struct{})
+			h := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if calls.Add(1) == 1 {
+					close(started)
+					<-release
+				}
+				if tc.private {
+					w.Header().Set("Cache-Control", "private")
+					fmt.Fprint(w, "mine")
+					return
+				}
+				http.NotFound(w, r)
+			}))
+			codes := make(chan int, numWaiters+1)
+			get := func() {
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://example.com/missing", nil))
+				codes <- w.Code
+			}
+			go get()
+			<-started
+			for i := 0; i < numWaiters; i++ {
+				go get()
+			}
+			for i := 0; i < numWaiters; i++ {
+				<-clock.waiting
+			}
+			close(release)
+			want := http.StatusNotFound
+			if tc.private {
+				want = http.StatusOK
+			}
+			for i := 0; i < numWaiters+1; i++ {
+				if code := <-codes; code != want {
+					t.Errorf("status = %d, want %d", code, want)
+				}
+			}
+			if n := calls.Load(); n != tc.wantCalls {
+				t.Errorf("handler called %d times, want %d", n, tc.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jaeyeom/gomemocache/memocache"
+)
+
+// Transport is an http.RoundTripper caching the responses of GET requests in a
+// memocache.Cache by their URLs, so that concurrent requests of a URL share a
+// single round trip. A response is cached for the max-age of its
+// Cache-Control header, or for the default TTL if it has none. Responses with
+// status other than 200, with no-store, no-cache or private in their
+// Cache-Control, with a Vary header or with a Set-Cookie header are not
+// cached, and the requests with an Authorization or a Cookie header bypass the
+// cache. The shared round trip isn't canceled with the request that made it,
+// as other requests wait for it. Transport should be created with
+// NewTransport.
+type Transport struct {
+	base  http.RoundTripper
+	cache *memocache.Cache
+	ttl   time.Duration
+}
+
+var _ http.RoundTripper = (*Transport)(nil)
+
+// NewTransport returns a new Transport making the round trips with base, or
+// with http.DefaultTransport if base is nil, and caching the responses in
+// cache. A zero ttl means that responses without a max-age aren't cached.
+func NewTransport(base http.RoundTripper, cache *memocache.Cache, ttl time.Duration) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{base: base, cache: cache, ttl: ttl}
+}
+
+// RoundTrip returns the cached response of the request if it's fresh, and
+// makes the round trip otherwise. The body of a response returned from the
+// cache is a new reader of the cached body. A response found uncacheable from
+// its headers is returned as is to the request that made the round trip, and
+// the requests that waited for it make their own at once. An expired response
+// is dropped only if it's still cached, so a response another request has
+// just cached survives.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("Authorization") != "" || req.Header.Get("Cookie") != "" || req.Header.Get("Range") != "" {
+		return t.base.RoundTrip(req)
+	}
+	key := req.URL.String()
+	var live *http.Response
+	load := func(ctx context.Context) (interface{}, error) {
+		resp, err := t.base.RoundTrip(req.WithContext(detachedContext{req.Context()}))
+		if err != nil {
+			return nil, err
+		}
+		ttl, ok := t.lifetime(resp)
+		if !ok {
+			resp.Request = req
+			live = resp
+			return nil, uncacheable{}
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return &response{
+			status:  resp.StatusCode,
+			header:  resp.Header,
+			body:    body,
+			expires: time.Now().Add(ttl),
+		}, nil
+	}
+	value, err := t.cache.LoadOrCallCtx(req.Context(), key, load)
+	if err == nil && time.Now().After(value.(*response).expires) {
+		t.cache.CompareAndDelete(key, value)
+		value, err = t.cache.LoadOrCallCtx(req.Context(), key, load)
+	}
+	var u uncacheable
+	switch {
+	case live != nil:
+		return live, nil
+	case errors.As(err, &u):
+		return t.base.RoundTrip(req)
+	case err != nil:
+		return nil, err
+	}
+	return value.(*response).httpResponse(req), nil
+}
+
+// detachedContext is a context with the values of its parent but without its
+// cancellation and deadline.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (deadline time.Time, ok bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+// lifetime returns how long the response can be cached, or false if it can't
+// be cached.
+func (t *Transport) lifetime(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Vary") != "" || resp.Header.Get("Set-Cookie") != "" {
+		return 0, false
+	}
+	ttl := t.ttl
+	for _, directive := range strings.Split(resp.Header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-store" || directive == "no-cache" || directive == "private":
+			return 0, false
+		case strings.HasPrefix(directive, "max-age="):
+			seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+			if err != nil {
+				return 0, false
+			}
+			ttl = time.Duration(seconds) * time.Second
+		}
+	}
+	return ttl, ttl > 0
+}
+
+// httpResponse returns a new http.Response of the cached response to the
+// request.
+func (resp *response) httpResponse(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        strconv.Itoa(resp.status) + " " + http.StatusText(resp.status),
+		StatusCode:    resp.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        resp.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(resp.body)),
+		ContentLength: int64(len(resp.body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,254 @@
+package httpcache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jaeyeom/gomemocache/memocache"
+)
+
+func TestTransport(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		switch r.URL.Path {
+		case "/slow":
+			<-release
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store")
+		case "/expired":
+			w.Header().Set("Cache-Control", "max-age=0")
+		}
+		fmt.Fprint(w, n)
+	}))
+	defer srv.Close()
+	client := &http.Client{Transport: NewTransport(nil, memocache.NewCache(&sync.Map{}), time.Minute)}
+	get := func(path string) string {
+		t.Helper()
+		resp, err := client.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s error = %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := get("/slow"); got != "1" {
+				t.Errorf("GET /slow = %s, want 1", got)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	for _, tc := range []struct {
+		path, want string
+	}{
+		{"/slow", "1"},
+		{"/default", "2"},
+		{"/default", "2"},
+		{"/no-store", "3"},
+		{"/no-store", "4"},
+		{"/expired", "5"},
+		{"/expired", "6"},
+	} {
+		if got := get(tc.path); got != tc.want {
+			t.Errorf("GET %s = %s, want %s", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestTransport_Bypass(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		if r.URL.Path == "/set-cookie" {
+			w.Header().Set("Set-Cookie", "session=1")
+		}
+		fmt.Fprint(w, n)
+	}))
+	defer srv.Close()
+	client := &http.Client{Transport: NewTransport(nil, memocache.NewCache(&sync.Map{}), time.Minute)}
+	get := func(path, cookie string) string {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		if cookie != "" {
+			req.Header.Set("Cookie", cookie)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s error = %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+	for _, tc := range []struct {
+		path, cookie, want string
+	}{
+		{"/cookie", "user=a", "1"},
+		{"/cookie", "user=b", "2"},
+		{"/set-cookie", "", "3"},
+		{"/set-cookie", "", "4"},
+	} {
+		if got := get(tc.path, tc.cookie); got != tc.want {
+			t.Errorf("GET %s with cookie %q = %s, want %s", tc.path, tc.cookie, got, tc.want)
+		}
+	}
+}
+
+func TestTransport_CanceledLeader(t *testing.T) {
+	var calls atomic.Int32
+	arrived := make(chan struct{})
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		if n == 1 {
+			close(arrived)
+			<-release
+		}
+		fmt.Fprint(w, n)
+	}))
+	defer srv.Close()
+	tr := NewTransport(nil, memocache.NewCache(&sync.Map{}), time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan struct{})
+	go func() {
+		defer close(leader)
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+		if resp, err := tr.RoundTrip(req); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-arrived
+	cancel()
+	waiter := make(chan string)
+	go func() {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		resp, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Errorf("RoundTrip() of the waiter error = %v", err)
+			waiter <- ""
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		waiter <- string(body)
+	}()
+	close(release)
+	if got := <-waiter; got != "1" {
+		t.Errorf("waiter got %q, want the response of the shared round trip 1", got)
+	}
+	<-leader
+}
+
+// countingStore is a memocache.Store counting the deletes made to the store it
+// wraps.
+type countingStore struct {
+	memocache.Store
+	deletes atomic.Int32
+}
+
+func (s *countingStore) Delete(ctx context.Context, key interface{}) error {
+	s.deletes.Add(1)
+	return s.Store.Delete(ctx, key)
+}
+
+func TestTransport_Expired(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "fresh")
+	}))
+	defer srv.Close()
+	store := &countingStore{Store: memocache.CacheStore(memocache.NewCache(&sync.Map{}))}
+	var bus memocache.LocalBus
+	var published atomic.Int32
+	defer bus.Subscribe(func(memocache.Invalidation) { published.Add(1) })()
+	cache := memocache.NewCache(&sync.Map{}, memocache.WithWriteThrough(store, nil), memocache.WithInvalidationBus(&bus, nil))
+	defer cache.Close()
+	cache.Store(srv.URL, &response{status: http.StatusOK, body: []byte("expired"), expires: time.Now().Add(-time.Second)})
+
+	resp, err := (&http.Client{Transport: NewTransport(nil, cache, time.Minute)}).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); string(body) != "fresh" {
+		t.Errorf("GET of an expired response = %q, want fresh", body)
+	}
+	if n := store.deletes.Load(); n != 0 {
+		t.Errorf("dropping the expired response deleted %d times from the store, want 0", n)
+	}
+	if n := published.Load(); n != 0 {
+		t.Errorf("dropping the expired response published %d invalidations, want 0", n)
+	}
+}
+
+func TestTransport_ConcurrentUncacheable(t *testing.T) {
+	const numWaiters = 9
+	var calls atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var waiters sync.WaitGroup
+	waiters.Add(numWaiters)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			close(started)
+			<-release
+		} else {
+			// The waiters make their round trips at once, or never get here
+			// together.
+			waiters.Done()
+			waiters.Wait()
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		fmt.Fprint(w, "uncacheable")
+	}))
+	defer srv.Close()
+	clock := waitClock{waiting: make(chan struct{}, numWaiters)}
+	client := &http.Client{Transport: NewTransport(nil, memocache.NewCache(&sync.Map{}, memocache.WithClock(clock), memocache.WithWaitTimeout(time.Hour, nil)), time.Minute)}
+	bodies := make(chan string, numWaiters+1)
+	get := func() {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Errorf("GET error = %v", err)
+			bodies <- ""
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		bodies <- string(body)
+	}
+	go get()
+	<-started
+	for i := 0; i < numWaiters; i++ {
+		go get()
+	}
+	for i := 0; i < numWaiters; i++ {
+		<-clock.waiting
+	}
+	close(release)
+	for i := 0; i < numWaiters+1; i++ {
+		if body := <-bodies; body != "uncacheable" {
+			t.Errorf("GET = %q, want uncacheable", body)
+		}
+	}
+	if n := calls.Load(); n != numWaiters+1 {
+		t.Errorf("server called %d times, want %d", n, numWaiters+1)
+	}
+}
@@ -0,0 +1,106 @@
+package memocache
+
+import (
+	"context"
+	"sync"
+)
+
+// loadTracker counts the loads in flight of a cache for InFlight and WaitIdle.
+// The zero value is ready to use.
+type loadTracker struct {
+	mu   sync.Mutex
+	n    int
+	idle chan struct{} // Closed when n drops back to zero
+}
+
+// start counts a load that should be uncounted by done.
+func (t *loadTracker) start() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.n == 0 {
+		t.idle = make(chan struct{})
+	}
+	t.n++
+}
+
+// done uncounts the load counted by start.
+func (t *loadTracker) done() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.n--; t.n == 0 {
+		close(t.idle)
+	}
+}
+
+// wrap returns getValue counted while it runs.
+func (t *loadTracker) wrap(getValue func() (interface{}, error)) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		t.start()
+		defer t.done()
+		return getValue()
+	}
+}
+
+// count returns the number of the loads in flight.
+func (t *loadTracker) count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.n
+}
+
+// wait waits until no load is in flight or the context is done.
+func (t *loadTracker) wait(ctx context.Context) error {
+	t.mu.Lock()
+	if t.n == 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	idle := t.idle
+	t.mu.Unlock()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// InFlight returns the number of the loaders of the cache running now,
+// including the background refreshes and the loads waiting for a slot of
+// WithMaxConcurrentLoads. The calls waiting for the load of another goroutine
+// aren't counted, and a batch of LoadOrCallMany counts as one load. Unlike
+// Len, it doesn't count the entries.
+func (c *Cache) InFlight() int {
+	return c.loads.count()
+}
+
+// WaitIdle blocks until no loader of the cache is running, e.g. on a graceful
+// shutdown to drain the loads before closing the resources they use, and
+// returns nil, or the error of the context if it's done first. The loads
+// started once the cache is idle aren't waited for, so the callers of the
+// cache should be stopped first.
+func (c *Cache) WaitIdle(ctx context.Context) error {
+	return c.loads.wait(ctx)
+}
+
+// InFlight is like Cache.InFlight.
+func (r *RRCache) InFlight() int {
+	return r.loads.count()
+}
+
+// WaitIdle is like Cache.WaitIdle.
+func (r *RRCache) WaitIdle(ctx context.Context) error {
+	return r.loads.wait(ctx)
+}
+
+// InFlight returns the number of the loaders of the paths of the map running
+// now. See Cache.InFlight.
+func (m *MultiLevelMap) InFlight() int {
+	return m.loads.count()
+}
+
+// WaitIdle blocks until no loader of the paths of the map is running. See
+// Cache.WaitIdle.
+func (m *MultiLevelMap) WaitIdle(ctx context.Context) error {
+	return m.loads.wait(ctx)
+}
@@ -0,0 +1,85 @@
+package memocache
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCache_WaitIdle(t *testing.T) {
+	c := NewCache(&sync.Map{})
+	if err := c.WaitIdle(context.Background()); err != nil {
+		t.Fatalf("WaitIdle() on an idle cache = %v", err)
+	}
+	release := make(chan struct{})
+	for i := 0; i < 3; i++ {
+		go c.LoadOrCall(i, func() interface{} {
+			<-release
+			return 0
+		})
+	}
+	go c.LoadOrCall(0, func() interface{} { return 1 })
+	waitFor(func() bool { return c.InFlight() == 3 })
+	if n := c.Len(); n != 3 {
+		t.Errorf("Len() = %d, want 3", n)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := c.WaitIdle(ctx); err != context.DeadlineExceeded {
+		t.Errorf("WaitIdle() with loads in flight = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	close(release)
+	if err := c.WaitIdle(context.Background()); err != nil {
+		t.Errorf("WaitIdle() = %v", err)
+	}
+	if n := c.InFlight(); n != 0 {
+		t.Errorf("InFlight() after WaitIdle() = %d, want 0", n)
+	}
+}
+
+func TestCache_InFlightPanic(t *testing.T) {
+	c := NewCache(&sync.Map{})
+	mustPanic(t, func() {
+		c.LoadOrCall(1, func() interface{} { panic("boom") })
+	})
+	if n := c.InFlight(); n != 0 {
+		t.Errorf("InFlight() after a panic = %d, want 0", n)
+	}
+}
+
+func TestRRCache_WaitIdle(t *testing.T) {
+	r := NewRRCache(nil, 10, 5, rand.Intn)
+	release := make(chan struct{})
+	go r.LoadOrCall(1, func() interface{} {
+		<-release
+		return 0
+	})
+	waitFor(func() bool { return r.InFlight() == 1 })
+	close(release)
+	if err := r.WaitIdle(context.Background()); err != nil {
+		t.Errorf("WaitIdle() = %v", err)
+	}
+}
+
+func TestMultiLevelMap_WaitIdle(t *testing.T) {
+	m := NewMultiLevelMap(nil)
+	release := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		go m.LoadOrCallErr(func() (interface{}, error) {
+			<-release
+			return 0, nil
+		}, "a", i)
+	}
+	waitFor(func() bool { return m.InFlight() == 2 })
+	close(release)
+	if err := m.WaitIdle(context.Background()); err != nil {
+		t.Errorf("WaitIdle() = %v", err)
+	}
+	if n := m.InFlight(); n != 0 {
+		t.Errorf("InFlight() after WaitIdle() = %d, want 0", n)
+	}
+}
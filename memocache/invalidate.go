@@ -0,0 +1,92 @@
+package memocache
+
+import (
+	"hash/maphash"
+	"sync/atomic"
+)
+
+// Number of stripes of the invalidation versions of the keys.
+const invalidationStripes = 256
+
+// WithInvalidationVersions makes every Delete and Clear of a Cache record a
+// monotonically increasing invalidation version, and discards the result of a
+// load that started before an invalidation of its key instead of storing it.
+// It closes the race where a background refresh that read the source before
+// an update overwrites the invalidation made after the update, even if the
+// backing map can't compare and swap entries. Loads made by LoadOrCall are
+// already safe since their entries are dropped by Delete. For a
+// MultiLevelMap, give the option to the caches of all levels to order the
+// loads after Prune.
+//
+// The versions are kept in a fixed number of stripes by the hash of the key,
+// so an invalidation of a key may also discard an unrelated load, which only
+// costs another load.
+func WithInvalidationVersions() Option {
+	return func(c *config) {
+		c.invalidationVersions = true
+	}
+}
+
+// invalidations keeps the invalidation versions enabled by
+// WithInvalidationVersions.
+type invalidations struct {
+	seed    maphash.Seed
+	clock   atomic.Uint64
+	all     atomic.Uint64 // Version of the last invalidation of all keys
+	stripes [invalidationStripes]atomic.Uint64
+}
+
+// newInvalidations returns the invalidation versions if they are enabled,
+// otherwise nil.
+func newInvalidations(c *config) *invalidations {
+	if !c.invalidationVersions {
+		return nil
+	}
+	return &invalidations{seed: maphash.MakeSeed()}
+}
+
+// now returns the current version to be passed to invalidatedSince by a load
+// that starts now. It's zero on nil invalidations.
+func (iv *invalidations) now() uint64 {
+	if iv == nil {
+		return 0
+	}
+	return iv.clock.Load()
+}
+
+// invalidate records an invalidation of the key. It's a no-op on nil
+// invalidations.
+func (iv *invalidations) invalidate(key interface{}) {
+	if iv == nil {
+		return
+	}
+	raise(&iv.stripes[maphashKey(iv.seed, key)%invalidationStripes], iv.clock.Add(1))
+}
+
+// invalidateAll records an invalidation of all keys. It's a no-op on nil
+// invalidations.
+func (iv *invalidations) invalidateAll() {
+	if iv == nil {
+		return
+	}
+	raise(&iv.all, iv.clock.Add(1))
+}
+
+// invalidatedSince returns true if the key was invalidated after the version.
+// It's always false on nil invalidations.
+func (iv *invalidations) invalidatedSince(key interface{}, version uint64) bool {
+	if iv == nil {
+		return false
+	}
+	return iv.all.Load() > version || iv.stripes[maphashKey(iv.seed, key)%invalidationStripes].Load() > version
+}
+
+// raise sets v to version unless it's already newer.
+func raise(v *atomic.Uint64, version uint64) {
+	for {
+		old := v.Load()
+		if old >= version || v.CompareAndSwap(old, version) {
+			return
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package memocache
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// latencyWindow is the number of most recent load latencies used to
+	// estimate the p99 latency.
+	latencyWindow = 100
+	// minLatencySamples is the number of samples needed before the budget
+	// is enforced.
+	minLatencySamples = 20
+)
+
+// LatencyEvent is emitted when a cache with a latency budget switches between
+// the normal and the degraded mode.
+type LatencyEvent struct {
+	// Degraded is true if the p99 load latency went over the budget and
+	// false if it recovered.
+	Degraded bool
+	// P99 is the estimated p99 load latency at the time of the switch.
+	P99 time.Duration
+	// Budget is the configured latency budget.
+	Budget time.Duration
+}
+
+// WithLatencyBudget sets a budget for the p99 latency of the loaders of a
+// Cache. While the estimated p99 latency is over the budget, the cache is in
+// the degraded mode: expired and stale entries keep being served and are
+// refreshed in the background by the next LoadOrCall, so callers don't wait on
+// a slow backend. Deleted entries are still removed, so explicit invalidations
+// take effect. When the latency recovers, the cache switches back and stale
+// entries are loaded again as usual. The onChange function, if not nil, is
+// called from a loader goroutine on every switch.
+func WithLatencyBudget(budget time.Duration, onChange func(LatencyEvent)) Option {
+	return func(c *config) {
+		c.latencyBudget = budget
+		c.onLatencyChange = onChange
+	}
+}
+
+// latencyMonitor keeps track of recent load latencies and decides whether the
+// cache is in the degraded mode.
+type latencyMonitor struct {
+	budget   time.Duration
+	onChange func(LatencyEvent)
+	degraded int32
+
+	mu      sync.Mutex
+	samples [latencyWindow]time.Duration
+	n       int
+}
+
+// newLatencyMonitor returns a new monitor if the config has a latency budget.
+func newLatencyMonitor(c *config) *latencyMonitor {
+	if c.latencyBudget <= 0 {
+		return nil
+	}
+	return &latencyMonitor{
+		budget:   c.latencyBudget,
+		onChange: c.onLatencyChange,
+	}
+}
+
+// isDegraded returns true if the cache is in the degraded mode. It's safe to
+// call on a nil monitor.
+func (l *latencyMonitor) isDegraded() bool {
+	return l != nil && atomic.LoadInt32(&l.degraded) == 1
+}
+
+// record adds a load latency and switches the mode if needed.
+func (l *latencyMonitor) record(d time.Duration) {
+	l.mu.Lock()
+	l.samples[l.n%latencyWindow] = d
+	l.n++
+	if l.n < minLatencySamples {
+		l.mu.Unlock()
+		return
+	}
+	p99 := l.p99()
+	l.mu.Unlock()
+
+	degraded := p99 > l.budget
+	var old, new int32 = 1, 0
+	if degraded {
+		old, new = 0, 1
+	}
+	if !atomic.CompareAndSwapInt32(&l.degraded, old, new) {
+		return
+	}
+	if l.onChange != nil {
+		l.onChange(LatencyEvent{
+			Degraded: degraded,
+			P99:      p99,
+			Budget:   l.budget,
+		})
+	}
+}
+
+// p99 returns the estimated p99 latency. It should be called with l.mu held.
+func (l *latencyMonitor) p99() time.Duration {
+	n := l.n
+	if n > latencyWindow {
+		n = latencyWindow
+	}
+	sorted := make([]time.Duration, n)
+	copy(sorted, l.samples[:n])
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[(n*99+99)/100-1]
+}
@@ -0,0 +1,55 @@
+package memocache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLatencyBudget(t *testing.T) {
+	clock := newFakeClock()
+	events := make(chan LatencyEvent, 10)
+	c := NewCache(&sync.Map{}, WithClock(clock), WithTTL(time.Minute), WithLatencyBudget(time.Millisecond, func(ev LatencyEvent) {
+		events <- ev
+	}))
+
+	for i := 0; i < minLatencySamples; i++ {
+		c.LoadOrCall(i, func() interface{} {
+			clock.Advance(2 * time.Millisecond)
+			return i
+		})
+	}
+	if ev := <-events; !ev.Degraded || ev.P99 <= ev.Budget {
+		t.Fatalf("got event %+v, want degraded", ev)
+	}
+
+	c.Delete(0)
+	if got := c.LoadOrCall(0, func() interface{} { return "new" }); got != "new" {
+		t.Errorf("LoadOrCall(0) = %v after Delete(0) during brownout, want new", got)
+	}
+
+	clock.Advance(time.Minute)
+	release := make(chan struct{})
+	if got := c.LoadOrCall(1, func() interface{} {
+		<-release
+		return "refreshed"
+	}); got != 1 {
+		t.Errorf("LoadOrCall(1) = %v after expiry during brownout, want stale value 1", got)
+	}
+	close(release)
+	if !waitFor(func() bool { return c.LoadOrCall(1, func() interface{} { return "unexpected" }) == "refreshed" }) {
+		t.Error("the expired value of 1 wasn't refreshed in the background")
+	}
+
+	for i := 0; i < latencyWindow; i++ {
+		c.LoadOrCall(100+i, func() interface{} { return i })
+	}
+	if ev := <-events; ev.Degraded {
+		t.Fatalf("got event %+v, want recovered", ev)
+	}
+
+	clock.Advance(time.Minute)
+	if got := c.LoadOrCall(2, func() interface{} { return "reloaded" }); got != "reloaded" {
+		t.Errorf("LoadOrCall(2) = %v after expiry and recovery, want reloaded", got)
+	}
+}
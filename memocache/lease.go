@@ -0,0 +1,222 @@
+package memocache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultLeaseDuration is the duration of the leases of a cache created without
+// WithLeaseDuration.
+const DefaultLeaseDuration = 30 * time.Second
+
+// minLeasesSweep is the number of leases below which the expired leases aren't
+// swept.
+const minLeasesSweep = 64
+
+var (
+	// ErrLeased is returned by Cache.Lease if the key is leased by someone
+	// else.
+	ErrLeased = errors.New("memocache: key is already leased")
+	// ErrLeaseExpired is returned by the methods of a Lease that has expired,
+	// was released or was revoked.
+	ErrLeaseExpired = errors.New("memocache: lease expired or revoked")
+)
+
+// WithLeaseDuration sets the duration of the leases taken with Cache.Lease and
+// extended by Lease.Renew. Without it, leases last DefaultLeaseDuration.
+func WithLeaseDuration(d time.Duration) Option {
+	return func(c *config) {
+		c.leaseDuration = d
+	}
+}
+
+// Lease is an exclusive and time-bounded ownership of a cache entry. While a
+// key is leased, nobody else can lease it and the cache doesn't replace the
+// entry by itself: a background refresh is discarded, and a load of a missing
+// or expired value returns the loaded value without caching it. A Store or
+// Delete of the key made directly on the cache revokes the lease, so that a
+// holder that read the entry before the write fails to write it back instead
+// of overwriting the newer value. The zero Lease is never held.
+type Lease struct {
+	e *leaseEntry
+}
+
+// leaseEntry is a lease in the lease table of a Cache.
+type leaseEntry struct {
+	c       *Cache
+	key     interface{}
+	expires time.Time // guarded by c.leaseMu
+}
+
+// Lease leases the entry of the key for the duration of WithLeaseDuration. It
+// returns ErrLeased if the key is leased by someone else.
+func (c *Cache) Lease(key interface{}) (Lease, error) {
+	c.leaseMu.Lock()
+	defer c.leaseMu.Unlock()
+	if e, ok := c.leases[key]; ok && e.validLocked() {
+		return Lease{}, ErrLeased
+	}
+	if c.leases == nil {
+		c.leases = make(map[interface{}]*leaseEntry)
+	}
+	if len(c.leases) >= 2*c.leasesSwept+minLeasesSweep {
+		c.sweepLeasesLocked()
+	}
+	e := &leaseEntry{
+		c:       c,
+		key:     key,
+		expires: now(c.config.clock).Add(c.leaseDuration()),
+	}
+	c.leases[key] = e
+	atomic.StoreInt32(&c.numLeases, int32(len(c.leases)))
+	return Lease{e: e}, nil
+}
+
+// leaseDuration returns the duration of the leases of the cache.
+func (c *Cache) leaseDuration() time.Duration {
+	if c.config.leaseDuration > 0 {
+		return c.config.leaseDuration
+	}
+	return DefaultLeaseDuration
+}
+
+// sweepLeasesLocked removes the expired leases, so that the table doesn't grow
+// with the keys leased once and never again. It should be called with
+// c.leaseMu held.
+func (c *Cache) sweepLeasesLocked() {
+	for key, e := range c.leases {
+		if !e.validLocked() {
+			delete(c.leases, key)
+		}
+	}
+	c.leasesSwept = len(c.leases)
+	atomic.StoreInt32(&c.numLeases, int32(len(c.leases)))
+}
+
+// RevokeLease revokes the lease of the key if any.
+func (c *Cache) RevokeLease(key interface{}) {
+	if atomic.LoadInt32(&c.numLeases) == 0 {
+		return
+	}
+	c.leaseMu.Lock()
+	defer c.leaseMu.Unlock()
+	c.deleteLeaseLocked(key)
+}
+
+// leased returns true if the key is currently leased. An expired lease of the
+// key is removed.
+func (c *Cache) leased(key interface{}) bool {
+	if atomic.LoadInt32(&c.numLeases) == 0 {
+		return false
+	}
+	c.leaseMu.Lock()
+	defer c.leaseMu.Unlock()
+	e, ok := c.leases[key]
+	if ok && !e.validLocked() {
+		c.deleteLeaseLocked(key)
+		return false
+	}
+	return ok
+}
+
+// loadLeased calls getValue for the leased key without caching the value,
+// since the holder of the lease owns the entry.
+func (c *Cache) loadLeased(ctx context.Context, key interface{}, getValue func() (interface{}, error)) (interface{}, error) {
+	value, err := c.loader(ctx, key, getValue)()
+	c.stats.record(true, err)
+	c.config.record(OpLoad, false, key)
+	c.config.observe(false, key)
+	return value, err
+}
+
+// deleteLeaseLocked removes the lease of the key. It should be called with
+// c.leaseMu held.
+func (c *Cache) deleteLeaseLocked(key interface{}) {
+	delete(c.leases, key)
+	atomic.StoreInt32(&c.numLeases, int32(len(c.leases)))
+}
+
+// Key returns the leased key.
+func (l Lease) Key() interface{} {
+	if l.e == nil {
+		return nil
+	}
+	return l.e.key
+}
+
+// Valid returns true if the lease is still held.
+func (l Lease) Valid() bool {
+	if l.e == nil {
+		return false
+	}
+	l.e.c.leaseMu.Lock()
+	defer l.e.c.leaseMu.Unlock()
+	return l.e.validLocked()
+}
+
+// validLocked returns true if the lease is still held. It should be called with
+// e.c.leaseMu held.
+func (e *leaseEntry) validLocked() bool {
+	return e.c.leases[e.key] == e && now(e.c.config.clock).Before(e.expires)
+}
+
+// Renew extends the lease to the duration of WithLeaseDuration from now.
+func (l Lease) Renew() error {
+	return l.locked(func(e *leaseEntry) {
+		e.expires = now(e.c.config.clock).Add(e.c.leaseDuration())
+	})
+}
+
+// Store sets the value of the leased entry if the lease is still held. Like
+// Cache.Store, it writes the value to the store of WithWriteThrough.
+func (l Lease) Store(value interface{}) error {
+	return l.locked(func(e *leaseEntry) {
+		e.c.writer.set(e.key, value)
+		e.c.store(e.key, value)
+	})
+}
+
+// Delete deletes the leased entry if the lease is still held. Like
+// Cache.Delete, it deletes the key from the store of WithWriteThrough and
+// publishes the key to the bus of WithInvalidationBus.
+func (l Lease) Delete() error {
+	err := l.locked(func(e *leaseEntry) {
+		e.c.config.record(OpDelete, false, e.key)
+		e.c.writer.delete(e.key)
+		e.c.delete(e.key)
+	})
+	if err != nil {
+		return err
+	}
+	l.e.c.bus.publish(l.e.key)
+	return nil
+}
+
+// locked calls f with c.leaseMu held if the lease is still held, and returns
+// ErrLeaseExpired otherwise.
+func (l Lease) locked(f func(e *leaseEntry)) error {
+	if l.e == nil {
+		return ErrLeaseExpired
+	}
+	l.e.c.leaseMu.Lock()
+	defer l.e.c.leaseMu.Unlock()
+	if !l.e.validLocked() {
+		return ErrLeaseExpired
+	}
+	f(l.e)
+	return nil
+}
+
+// Release gives up the lease. It's a no-op if the lease is no longer held.
+func (l Lease) Release() {
+	if l.e == nil {
+		return
+	}
+	l.e.c.leaseMu.Lock()
+	defer l.e.c.leaseMu.Unlock()
+	if l.e.c.leases[l.e.key] == l.e {
+		l.e.c.deleteLeaseLocked(l.e.key)
+	}
+}
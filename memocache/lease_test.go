@@ -0,0 +1,131 @@
+package memocache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func ExampleLease() {
+	c := NewCache(&sync.Map{}, WithLeaseDuration(time.Minute))
+	c.Store("counter", 1)
+
+	l, err := c.Lease("counter")
+	if err != nil {
+		panic(err)
+	}
+	defer l.Release()
+
+	if _, err := c.Lease("counter"); err != nil {
+		fmt.Println(err)
+	}
+	v, _ := c.Load("counter")
+	fmt.Println(l.Store(v.(int) + 1))
+	fmt.Println(c.Load("counter"))
+	// Output:
+	// memocache: key is already leased
+	// <nil>
+	// 2 true
+}
+
+func TestLease(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCache(&sync.Map{}, WithClock(clock), WithLeaseDuration(time.Minute))
+
+	l, err := c.Lease("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Lease("a"); err != ErrLeased {
+		t.Errorf("Lease() of a leased key error = %v, want %v", err, ErrLeased)
+	}
+	clock.Advance(30 * time.Second)
+	if err := l.Renew(); err != nil {
+		t.Errorf("Renew() error = %v", err)
+	}
+	clock.Advance(30 * time.Second)
+	if !l.Valid() {
+		t.Error("Valid() = false within the renewed duration")
+	}
+	clock.Advance(30 * time.Second)
+	if l.Valid() {
+		t.Error("Valid() = true after expiry")
+	}
+	if err := l.Renew(); err != ErrLeaseExpired {
+		t.Errorf("Renew() after expiry error = %v, want %v", err, ErrLeaseExpired)
+	}
+
+	l, err = c.Lease("a")
+	if err != nil {
+		t.Fatalf("Lease() after expiry error = %v", err)
+	}
+	c.Store("a", "external")
+	if err := l.Store("stale"); err != ErrLeaseExpired {
+		t.Errorf("Store() after a direct write error = %v, want %v", err, ErrLeaseExpired)
+	}
+	if v, _ := c.Load("a"); v != "external" {
+		t.Errorf("Load() = %v, want external", v)
+	}
+
+	l, err = c.Lease("a")
+	if err != nil {
+		t.Fatalf("Lease() after revocation error = %v", err)
+	}
+	if err := l.Delete(); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+	if _, ok := c.Load("a"); ok {
+		t.Error("Load() found a deleted key")
+	}
+	l.Release()
+	if _, err := c.Lease("a"); err != nil {
+		t.Errorf("Lease() after Release() error = %v", err)
+	}
+
+	var zero Lease
+	if zero.Valid() || zero.Store(1) != ErrLeaseExpired {
+		t.Error("the zero Lease is held")
+	}
+}
+
+func TestLease_Load(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCache(&sync.Map{}, WithClock(clock), WithTTL(time.Minute), WithLeaseDuration(time.Hour))
+	c.Store("a", 1)
+	l, err := c.Lease("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Minute)
+	if got := c.LoadOrCall("a", func() interface{} { return 2 }); got != 2 {
+		t.Errorf("LoadOrCall() of an expired leased key = %v, want 2", got)
+	}
+	if _, ok := c.Load("a"); ok {
+		t.Error("the load of a leased key was cached")
+	}
+	if err := l.Store(3); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	l.Release()
+	if got := c.LoadOrCall("a", func() interface{} { return 4 }); got != 3 {
+		t.Errorf("LoadOrCall() after Release() = %v, want the stored 3", got)
+	}
+}
+
+func TestLease_Sweep(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCache(&sync.Map{}, WithClock(clock), WithLeaseDuration(time.Second))
+	for i := 0; i < 1000; i++ {
+		if _, err := c.Lease(i); err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(time.Second)
+	}
+	c.leaseMu.Lock()
+	n := len(c.leases)
+	c.leaseMu.Unlock()
+	if n > 2*minLeasesSweep {
+		t.Errorf("%d leases in the table, want the expired ones swept", n)
+	}
+}
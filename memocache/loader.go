@@ -0,0 +1,70 @@
+package memocache
+
+import (
+	"context"
+	"errors"
+)
+
+// Loader loads the value of a key, e.g. from a database. See WithLoader.
+type Loader func(ctx context.Context, key interface{}) (interface{}, error)
+
+// ErrNoLoader is returned by Get of a cache created without WithLoader.
+var ErrNoLoader = errors.New("memocache: cache has no loader")
+
+// getter is implemented by the caches that support Get.
+type getter interface {
+	Get(ctx context.Context, key interface{}) (interface{}, error)
+}
+
+var (
+	_ getter = (*Cache)(nil)
+	_ getter = (*RRCache)(nil)
+)
+
+// WithLoader binds the loader to a Cache or an RRCache, making it a
+// read-through cache: Get loads the missing keys with the loader, so that every
+// caller of a key loads it the same way instead of passing its own closure. The
+// other methods, e.g. LoadOrCallCtx, still take their own loaders.
+func WithLoader(l Loader) Option {
+	return func(c *config) {
+		c.loader = l
+	}
+}
+
+// Get returns the value for the key, loading it with the loader of WithLoader
+// if it's missing. It's like LoadOrCallCtx with the loader, so the errors of
+// ctx and the loader are returned the same way. It returns ErrNoLoader if the
+// cache has no loader.
+func (c *Cache) Get(ctx context.Context, key interface{}) (interface{}, error) {
+	l := c.config.loader
+	if l == nil {
+		return nil, ErrNoLoader
+	}
+	return c.LoadOrCallCtx(ctx, key, func(ctx context.Context) (interface{}, error) {
+		return l(ctx, key)
+	})
+}
+
+// Get is like Cache.Get.
+func (r *RRCache) Get(ctx context.Context, key interface{}) (interface{}, error) {
+	l := r.config.loader
+	if l == nil {
+		return nil, ErrNoLoader
+	}
+	return r.LoadOrCallCtx(ctx, key, func(ctx context.Context) (interface{}, error) {
+		return l(ctx, key)
+	})
+}
+
+// Get returns the value for the key, loading it with the loader of WithLoader
+// if it's missing. See Cache.Get. It returns ErrNoLoader if the underlying
+// cache doesn't support Get.
+func (c *CacheOf[K, V]) Get(ctx context.Context, key K) (V, error) {
+	g, ok := c.c.(getter)
+	if !ok {
+		var zero V
+		return zero, ErrNoLoader
+	}
+	value, err := g.Get(ctx, key)
+	return valueOf[V](value), err
+}
@@ -0,0 +1,83 @@
+package memocache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func ExampleWithLoader() {
+	users := New(WithMaxSize(100), WithLoader(func(ctx context.Context, key interface{}) (interface{}, error) {
+		fmt.Println("loading", key)
+		return strings.ToUpper(key.(string)), nil
+	}))
+	for _, id := range []string{"ann", "bob", "ann"} {
+		name, _ := users.(*Cache).Get(context.Background(), id)
+		fmt.Println(name)
+	}
+	// Output:
+	// loading ann
+	// ANN
+	// loading bob
+	// BOB
+	// ANN
+}
+
+func TestGet(t *testing.T) {
+	errFail := errors.New("fail")
+	calls := 0
+	loader := WithLoader(func(ctx context.Context, key interface{}) (interface{}, error) {
+		calls++
+		if key == "bad" {
+			return nil, errFail
+		}
+		return key.(int) * 2, nil
+	})
+	ctx := context.Background()
+	for name, c := range map[string]getter{
+		"Cache":   NewCache(NewSampledLRUMap(10, 5), loader),
+		"RRCache": NewRRCacheLocal(10, 5, nil, loader),
+	} {
+		calls = 0
+		for i := 0; i < 2; i++ {
+			if got, err := c.Get(ctx, 21); got != 42 || err != nil {
+				t.Errorf("%s: Get(21) = %v, %v, want 42, nil", name, got, err)
+			}
+		}
+		if _, err := c.Get(ctx, "bad"); !errors.Is(err, errFail) {
+			t.Errorf("%s: Get(bad) error = %v, want %v", name, err, errFail)
+		}
+		if calls != 2 {
+			t.Errorf("%s: loader called %d times, want 2", name, calls)
+		}
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, err := c.Get(canceled, 1); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: Get of a canceled context error = %v, want %v", name, err, context.Canceled)
+		}
+	}
+}
+
+func TestGet_NoLoader(t *testing.T) {
+	if _, err := NewSampledLRUCache(10, 5).Get(context.Background(), 1); err != ErrNoLoader {
+		t.Errorf("Get() error = %v, want %v", err, ErrNoLoader)
+	}
+	if _, err := NewRRCacheLocal(10, 5, nil).Get(context.Background(), 1); err != ErrNoLoader {
+		t.Errorf("RRCache Get() error = %v, want %v", err, ErrNoLoader)
+	}
+}
+
+func TestCacheOf_Get(t *testing.T) {
+	c := NewLRUCacheOf[int, string](10, WithLoader(func(ctx context.Context, key interface{}) (interface{}, error) {
+		return fmt.Sprint(key), nil
+	}))
+	if got, err := c.Get(context.Background(), 7); got != "7" || err != nil {
+		t.Errorf("Get(7) = %q, %v, want \"7\", nil", got, err)
+	}
+	wrapped := struct{ ExtendedCache }{New()}
+	if _, err := WrapCacheOf[int, string](wrapped).Get(context.Background(), 7); err != ErrNoLoader {
+		t.Errorf("Get() of a cache without Get error = %v, want %v", err, ErrNoLoader)
+	}
+}
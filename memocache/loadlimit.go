@@ -0,0 +1,172 @@
+package memocache
+
+import "sync"
+
+// WithMaxConcurrentLoads limits the loaders running at once to n, e.g. so
+// that a cold cache after a restart doesn't overwhelm its backend with
+// thousands of simultaneous loads. The loads over the limit wait for a slot
+// before calling their loaders, and the calls of the same key still share one
+// load. A batch of LoadOrCallMany takes one slot. The caches given the same
+// Option share the limit, so a single option given to all the levels of a
+// MultiLevelMap, or to the MultiLevelMap itself but not to both, limits the
+// loads of the whole tree. A non-positive n means no limit.
+func WithMaxConcurrentLoads(n int) Option {
+	l := newLoadLimiter(n)
+	return func(c *config) {
+		c.loadLimiter = l
+	}
+}
+
+// WithMaxConcurrentLoadsPerPrefix makes a MultiLevelMap limit the loaders
+// running at once to n per prefix of depth elements of the paths, e.g. 1 to
+// limit the loads per tenant of paths like (tenant, object), so that a tenant
+// warming up doesn't take all the capacity of the backend. The paths shorter
+// than depth are limited as their own prefixes. It may be combined with
+// WithMaxConcurrentLoads, in which case the slot of the prefix is taken
+// first.
+func WithMaxConcurrentLoadsPerPrefix(depth, n int) Option {
+	return func(c *config) {
+		c.prefixLoadDepth = depth
+		c.prefixLoadLimit = n
+	}
+}
+
+// loadLimiter is a semaphore of the loads enabled by WithMaxConcurrentLoads.
+type loadLimiter chan struct{}
+
+// newLoadLimiter returns a limiter of n loads, or nil for no limit.
+func newLoadLimiter(n int) loadLimiter {
+	if n <= 0 {
+		return nil
+	}
+	return make(loadLimiter, n)
+}
+
+// acquire waits for a slot. It's a no-op on a nil limiter.
+func (l loadLimiter) acquire() {
+	if l != nil {
+		l <- struct{}{}
+	}
+}
+
+// release frees the slot taken by acquire. It's a no-op on a nil limiter.
+func (l loadLimiter) release() {
+	if l != nil {
+		<-l
+	}
+}
+
+// wrap returns getValue called in a slot of the limiter, or getValue itself
+// on a nil limiter.
+func (l loadLimiter) wrap(getValue func() (interface{}, error)) func() (interface{}, error) {
+	if l == nil {
+		return getValue
+	}
+	return func() (interface{}, error) {
+		l.acquire()
+		defer l.release()
+		return getValue()
+	}
+}
+
+// prefixLimiters holds the limiters of the prefixes with loads running, for
+// WithMaxConcurrentLoadsPerPrefix. A limiter is dropped once its prefix has
+// no loads running or waiting, so there are limiters only for the prefixes
+// being loaded.
+type prefixLimiters struct {
+	mu   sync.Mutex
+	root prefixNode
+}
+
+// prefixNode is a node of the tree of the prefixes of prefixLimiters.
+type prefixNode struct {
+	children map[interface{}]*prefixNode
+	limiter  loadLimiter // Set on the nodes of the prefixes
+	users    int         // Loads running or waiting in the subtree
+}
+
+// limitsLoads returns whether the map limits its loads.
+func (m *MultiLevelMap) limitsLoads() bool {
+	return m.config.loadLimiter != nil || (m.config.prefixLoadDepth > 0 && m.config.prefixLoadLimit > 0)
+}
+
+// wrapLoader returns getValue of the path counted in the loads in flight and
+// called in the slots of the limits of the map.
+func (m *MultiLevelMap) wrapLoader(path []interface{}, getValue func() (interface{}, error)) func() (interface{}, error) {
+	if !m.limitsLoads() {
+		return m.loads.wrap(getValue)
+	}
+	return m.loads.wrap(m.wrapPrefix(path, m.config.loadLimiter.wrap(getValue)))
+}
+
+// wrapValueLoader is like wrapLoader for a getValue that can't fail.
+func (m *MultiLevelMap) wrapValueLoader(path []interface{}, getValue func() interface{}) func() interface{} {
+	load := m.wrapLoader(path, func() (interface{}, error) {
+		return getValue(), nil
+	})
+	return func() interface{} {
+		value, _ := load()
+		return value
+	}
+}
+
+// wrapPrefix returns getValue called in a slot of the prefix of the path if
+// WithMaxConcurrentLoadsPerPrefix is given, or getValue itself otherwise.
+func (m *MultiLevelMap) wrapPrefix(path []interface{}, getValue func() (interface{}, error)) func() (interface{}, error) {
+	depth, n := m.config.prefixLoadDepth, m.config.prefixLoadLimit
+	if depth <= 0 || n <= 0 {
+		return getValue
+	}
+	if depth > len(path) {
+		depth = len(path)
+	}
+	// Copied so that the path doesn't escape on the hit path of the callers.
+	prefix := append([]interface{}(nil), path[:depth]...)
+	return func() (interface{}, error) {
+		l := m.prefixes.get(prefix, n)
+		defer m.prefixes.put(prefix)
+		l.acquire()
+		defer l.release()
+		return getValue()
+	}
+}
+
+// get returns the limiter of n loads of the prefix, adding it if needed, and
+// counts a user of it that should call put when done.
+func (p *prefixLimiters) get(prefix []interface{}, n int) loadLimiter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	node := &p.root
+	for _, key := range prefix {
+		child, ok := node.children[key]
+		if !ok {
+			if node.children == nil {
+				node.children = make(map[interface{}]*prefixNode)
+			}
+			child = &prefixNode{}
+			node.children[key] = child
+		}
+		child.users++
+		node = child
+	}
+	if node.limiter == nil {
+		node.limiter = newLoadLimiter(n)
+	}
+	return node.limiter
+}
+
+// put uncounts a user of the limiter of the prefix counted by get, dropping
+// the nodes left without users.
+func (p *prefixLimiters) put(prefix []interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	node := &p.root
+	for _, key := range prefix {
+		child := node.children[key]
+		if child.users--; child.users == 0 {
+			delete(node.children, key)
+			return
+		}
+		node = child
+	}
+}
@@ -0,0 +1,132 @@
+package memocache
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// concurrency counts the loads running at once and the most of them.
+type concurrency struct {
+	running, max atomic.Int32
+}
+
+// load runs a load taking a little time.
+func (c *concurrency) load() {
+	n := c.running.Add(1)
+	defer c.running.Add(-1)
+	for m := c.max.Load(); n > m && !c.max.CompareAndSwap(m, n); m = c.max.Load() {
+	}
+	time.Sleep(time.Millisecond)
+}
+
+func TestWithMaxConcurrentLoads(t *testing.T) {
+	limit := WithMaxConcurrentLoads(3)
+	caches := []ExtendedCache{
+		NewCache(&sync.Map{}, limit),
+		NewRRCacheLocal(1000, 500, nil, limit),
+	}
+	var conc concurrency
+	var wg sync.WaitGroup
+	for i := 0; i < 40; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			caches[i%2].LoadOrCall(i/4, func() interface{} {
+				conc.load()
+				return i
+			})
+		}(i)
+	}
+	wg.Wait()
+	if n := conc.max.Load(); n > 3 {
+		t.Errorf("%d loads at once of the caches sharing the option, want at most 3", n)
+	}
+}
+
+func TestWithMaxConcurrentLoads_Batch(t *testing.T) {
+	c := NewCache(&sync.Map{}, WithMaxConcurrentLoads(1))
+	var conc concurrency
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			c.LoadOrCallMany([]interface{}{i, i + 100}, func(missing []interface{}) map[interface{}]interface{} {
+				conc.load()
+				values := make(map[interface{}]interface{})
+				for _, key := range missing {
+					values[key] = key
+				}
+				return values
+			})
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			c.LoadOrCall(i+200, func() interface{} {
+				conc.load()
+				return i
+			})
+		}(i)
+	}
+	wg.Wait()
+	if n := conc.max.Load(); n > 1 {
+		t.Errorf("%d loads at once, want 1", n)
+	}
+}
+
+func TestWithMaxConcurrentLoadsPerPrefix(t *testing.T) {
+	m := NewMultiLevelMap(nil, WithMaxConcurrentLoadsPerPrefix(1, 2))
+	var all concurrency
+	tenants := map[string]*concurrency{"a": {}, "b": {}}
+	var wg sync.WaitGroup
+	for i := 0; i < 40; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tenant := []string{"a", "b"}[i%2]
+			m.LoadOrCallErr(func() (interface{}, error) {
+				all.running.Add(1)
+				defer all.running.Add(-1)
+				for n, max := all.running.Load(), all.max.Load(); n > max && !all.max.CompareAndSwap(max, n); max = all.max.Load() {
+				}
+				tenants[tenant].load()
+				return i, nil
+			}, tenant, "object", i)
+		}(i)
+	}
+	wg.Wait()
+	for name, conc := range tenants {
+		if n := conc.max.Load(); n > 2 {
+			t.Errorf("%d loads at once of tenant %s, want at most 2", n, name)
+		}
+	}
+	if n := all.max.Load(); n > 4 {
+		t.Errorf("%d loads at once of all the tenants, want at most 2 per tenant", n)
+	}
+	if n := len(m.prefixes.root.children); n != 0 {
+		t.Errorf("%d prefixes left after the loads, want 0", n)
+	}
+}
+
+func TestWithMaxConcurrentLoadsPerPrefix_Combined(t *testing.T) {
+	m := NewMultiLevelMap(nil, WithMaxConcurrentLoads(1), WithMaxConcurrentLoadsPerPrefix(2, 5))
+	var conc concurrency
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			m.LoadOrCall(func() interface{} {
+				conc.load()
+				return i
+			}, fmt.Sprint(i%3), i)
+		}(i)
+	}
+	wg.Wait()
+	if n := conc.max.Load(); n > 1 {
+		t.Errorf("%d loads at once, want 1 of the map", n)
+	}
+}
@@ -0,0 +1,175 @@
+// Package memcached provides a memocache.Store backed by memcached, so that
+// the processes of a service share the values loaded by any of them through a
+// memocache.TieredCache. The memcached client is behind the Client interface,
+// so that any client can be plugged in, e.g. github.com/bradfitz/gomemcache
+// with an adapter like:
+//
+//	type gomemcacheClient struct{ c *memcache.Client }
+//
+//	func (g gomemcacheClient) Get(ctx context.Context, key string) ([]byte, bool, error) {
+//		item, err := g.c.Get(key)
+//		if errors.Is(err, memcache.ErrCacheMiss) {
+//			return nil, false, nil
+//		}
+//		if err != nil {
+//			return nil, false, err
+//		}
+//		return item.Value, true, nil
+//	}
+//
+//	func (g gomemcacheClient) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+//		return g.c.Set(&memcache.Item{Key: key, Value: value, Expiration: memcached.Expiration(ttl)})
+//	}
+//
+//	func (g gomemcacheClient) Delete(ctx context.Context, key string) error {
+//		if err := g.c.Delete(key); !errors.Is(err, memcache.ErrCacheMiss) {
+//			return err
+//		}
+//		return nil
+//	}
+//
+// Then the store is used as the second level of a cache:
+//
+//	store := memcached.New(gomemcacheClient{memcache.New("localhost:11211")},
+//		memocache.GobValueCodec,
+//		memcached.WithPrefix("users:"),
+//		memcached.WithTTL(time.Hour))
+//	l1 := memocache.NewCache(memocache.NewLRUMap(list.New(), 1000))
+//	c := memocache.NewTieredCache(l1, store, nil)
+//
+// Keys are formatted with fmt.Sprint, so they should format uniquely like
+// strings and integers do. Values are encoded with a memocache.Codec.
+package memcached
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/jaeyeom/gomemocache/memocache"
+)
+
+// maxKeyLen is the longest key memcached accepts.
+const maxKeyLen = 250
+
+// relativeExpirationLimit is the longest expiration memcached takes as
+// relative to now. Longer ones are taken as Unix times.
+const relativeExpirationLimit = 30 * 24 * time.Hour
+
+// Client is the memcached client of a Store.
+type Client interface {
+	// Get returns the value of the key, or false if it's not cached.
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	// Set sets the value of the key expiring after ttl, or never if ttl is
+	// zero. See Expiration for converting ttl to memcached.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete deletes the key. It should return nil if the key isn't cached.
+	Delete(ctx context.Context, key string) error
+}
+
+// Store is a memocache.Store backed by memcached. Store should be created
+// with New.
+type Store struct {
+	client Client
+	codec  memocache.Codec
+	prefix string
+	ttl    time.Duration
+}
+
+var _ memocache.Store = (*Store)(nil)
+
+// Option is an option of a Store.
+type Option func(s *Store)
+
+// WithPrefix prepends the prefix to the memcached keys, e.g. to share a
+// memcached cluster between caches.
+func WithPrefix(prefix string) Option {
+	return func(s *Store) {
+		s.prefix = prefix
+	}
+}
+
+// WithTTL makes the values expire in memcached after ttl, rounded up to a
+// second. Without it, they never expire, but memcached may still evict them.
+func WithTTL(ttl time.Duration) Option {
+	return func(s *Store) {
+		s.ttl = ttl
+	}
+}
+
+// New returns a new Store of the client whose values are encoded with the
+// codec.
+func New(client Client, codec memocache.Codec, opts ...Option) *Store {
+	s := &Store{client: client, codec: codec}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Expiration converts ttl to the expiration of a memcached item: zero for no
+// expiration, seconds for up to 30 days and a Unix time beyond, since
+// memcached takes longer expirations as Unix times.
+func Expiration(ttl time.Duration) int32 {
+	if ttl <= 0 {
+		return 0
+	}
+	if ttl > relativeExpirationLimit {
+		return int32(time.Now().Add(ttl).Unix())
+	}
+	return int32((ttl + time.Second - 1) / time.Second)
+}
+
+// memcachedKey returns the memcached key of the key. Keys that memcached
+// doesn't accept, i.e. longer than 250 bytes or with spaces or control
+// characters, are replaced by the prefix and the SHA-256 hash of the key.
+func (s *Store) memcachedKey(key interface{}) string {
+	k := s.prefix + fmt.Sprint(key)
+	if validKey(k) {
+		return k
+	}
+	sum := sha256.Sum256([]byte(k))
+	return s.prefix + hex.EncodeToString(sum[:])
+}
+
+// validKey returns whether memcached accepts the key.
+func validKey(k string) bool {
+	if len(k) == 0 || len(k) > maxKeyLen {
+		return false
+	}
+	for i := 0; i < len(k); i++ {
+		if k[i] <= ' ' || k[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// Get returns the decoded value of the key if it's in memcached.
+func (s *Store) Get(ctx context.Context, key interface{}) (interface{}, bool, error) {
+	data, ok, err := s.client.Get(ctx, s.memcachedKey(key))
+	if !ok || err != nil {
+		return nil, false, err
+	}
+	value, err := s.codec.Decode(data)
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Set encodes and sets the value of the key in memcached.
+func (s *Store) Set(ctx context.Context, key, value interface{}) error {
+	data, err := s.codec.Encode(value)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, s.memcachedKey(key), data, s.ttl)
+}
+
+// Delete deletes the value of the key from memcached.
+func (s *Store) Delete(ctx context.Context, key interface{}) error {
+	return s.client.Delete(ctx, s.memcachedKey(key))
+}
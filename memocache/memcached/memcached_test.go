@@ -0,0 +1,113 @@
+package memcached
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jaeyeom/gomemocache/memocache"
+)
+
+// fakeClient is an in-memory Client recording the TTLs of the keys.
+type fakeClient struct {
+	mu     sync.Mutex
+	values map[string][]byte
+	ttls   map[string]time.Duration
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{values: make(map[string][]byte), ttls: make(map[string]time.Duration)}
+}
+
+func (f *fakeClient) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	value, ok := f.values[key]
+	return value, ok, nil
+}
+
+func (f *fakeClient) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.values[key] = value
+	f.ttls[key] = ttl
+	return nil
+}
+
+func (f *fakeClient) Delete(ctx context.Context, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.values, key)
+	return nil
+}
+
+func TestStore(t *testing.T) {
+	client := newFakeClient()
+	store := New(client, memocache.GobValueCodec, WithPrefix("p:"), WithTTL(time.Minute))
+	var calls int32
+	load := func() interface{} {
+		atomic.AddInt32(&calls, 1)
+		return "v"
+	}
+	a := memocache.NewTieredCache(memocache.NewCache(&sync.Map{}), store, nil)
+	b := memocache.NewTieredCache(memocache.NewCache(&sync.Map{}), store, nil)
+	if got := a.LoadOrCall("k", load); got != "v" {
+		t.Errorf("LoadOrCall(k) on a = %v, want v", got)
+	}
+	if got := b.LoadOrCall("k", load); got != "v" {
+		t.Errorf("LoadOrCall(k) on b = %v, want v", got)
+	}
+	if calls != 1 {
+		t.Errorf("getValue was called %d times, want 1", calls)
+	}
+	if ttl := client.ttls["p:k"]; ttl != time.Minute {
+		t.Errorf("TTL of p:k = %v, want 1m", ttl)
+	}
+	a.Delete("k")
+	if _, ok, _ := client.Get(context.Background(), "p:k"); ok {
+		t.Error("p:k survived Delete(k)")
+	}
+}
+
+func TestStore_InvalidKeys(t *testing.T) {
+	client := newFakeClient()
+	store := New(client, memocache.GobValueCodec, WithPrefix("p:"))
+	ctx := context.Background()
+	for _, key := range []string{"with space", "new\nline", strings.Repeat("x", 300)} {
+		if err := store.Set(ctx, key, key); err != nil {
+			t.Fatalf("Set(%q) = %v", key, err)
+		}
+		if got, ok, err := store.Get(ctx, key); err != nil || !ok || got != key {
+			t.Errorf("Get(%q) = %v, %v, %v, want the value", key, got, ok, err)
+		}
+	}
+	for k := range client.values {
+		if !validKey(k) || !strings.HasPrefix(k, "p:") {
+			t.Errorf("memcached key %q is invalid or unprefixed", k)
+		}
+	}
+}
+
+func TestExpiration(t *testing.T) {
+	for _, tc := range []struct {
+		ttl  time.Duration
+		want int32
+	}{
+		{0, 0},
+		{-time.Second, 0},
+		{time.Millisecond, 1},
+		{time.Hour, 3600},
+		{30 * 24 * time.Hour, 30 * 24 * 3600},
+	} {
+		if got := Expiration(tc.ttl); got != tc.want {
+			t.Errorf("Expiration(%v) = %d, want %d", tc.ttl, got, tc.want)
+		}
+	}
+	ttl := 60 * 24 * time.Hour
+	if got, want := Expiration(ttl), time.Now().Add(ttl).Unix(); int64(got) < want-1 || int64(got) > want+1 {
+		t.Errorf("Expiration(%v) = %d, want the Unix time %d", ttl, got, want)
+	}
+}
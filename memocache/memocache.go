@@ -11,25 +11,254 @@ package memocache
 
 import (
 	"container/list"
+	"context"
+	"errors"
+	"reflect"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 // Value is a single value that is initialized once by calling the given
 // function only once. Value should not be copied after first use.
 type Value struct {
-	once  sync.Once
-	value interface{}
+	state   atomic.Pointer[valueState]
+	mu      sync.Mutex // Lock for call
+	call    *valueCall
+	version uint64        // Version of the cache when the entry was created
+	ttl     time.Duration // Time to live of the value once it's set
+	clock   Clock         // Clock of the cache, or nil for the real clock
+	hits    atomic.Uint64 // Number of cache hits, counted by Cache
+	// Time of the last hit in Unix nanoseconds, set with WithAccessTracking
+	accessed atomic.Int64
+}
+
+// valueState is the published state of a Value. It's never modified after it's
+// published, so a single atomic load gives a consistent snapshot of the value
+// and its flags. Changes are made by swapping in a new valueState.
+type valueState struct {
+	value      interface{}
+	err        *CachedError
+	created    int64 // Time when the value was set in Unix nanoseconds
+	expires    int64 // Expiry time in Unix nanoseconds or zero
+	clock      Clock // Clock the expiry time is checked against
+	stale      bool
+	refreshing bool
+}
+
+// valueCall is a call of the function of a Value in flight. The done channel is
+// closed when the call returns, after the result has been published.
+type valueCall struct {
+	done chan struct{}
+	err  error // Error of a failed call handed to the waiters
 }
 
 // LoadOrCall gets the value. If the value isn't ready it calls getValue to get
-// the value.
+// the value. Once the value is ready, LoadOrCall is a single atomic load.
 func (e *Value) LoadOrCall(getValue func() interface{}) interface{} {
-	e.once.Do(func() {
-		e.value = getValue()
+	if s := e.state.Load(); s != nil {
+		return s.value
+	}
+	value, _ := e.loadOrCallSlow(context.Background(), func() (interface{}, error) {
+		return getValue(), nil
+	})
+	return value
+}
+
+// LoadOrCallErr gets the value. If the value isn't ready it calls getValue to
+// get the value. If getValue returns an error, the value is not set and the
+// error is returned, also to the calls waiting for getValue, so a later call
+// will call getValue again. The exception is a *CachedError, which is
+// memoized and returned by later calls without calling getValue. LoadOrCall
+// and Load see a memoized *CachedError as the value.
+func (e *Value) LoadOrCallErr(getValue func() (interface{}, error)) (interface{}, error) {
+	if s := e.state.Load(); s != nil {
+		return s.result()
+	}
+	return e.loadOrCallSlow(context.Background(), getValue)
+}
+
+// LoadOrCallCtx is like LoadOrCallErr but getValue is called with ctx. If ctx
+// is done while waiting for a call made by another goroutine, it returns
+// ctx.Err() without waiting any longer. The call made by the other goroutine
+// isn't affected.
+func (e *Value) LoadOrCallCtx(ctx context.Context, getValue func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	if s := e.state.Load(); s != nil {
+		return s.result()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return e.loadOrCallSlow(ctx, func() (interface{}, error) {
+		return getValue(ctx)
 	})
+}
 
-	return e.value
+// Load returns the value if it's ready. It never calls a function.
+func (e *Value) Load() (value interface{}, ok bool) {
+	if s := e.state.Load(); s != nil {
+		return s.value, true
+	}
+	return nil, false
+}
+
+// loadOrCallSlow calls getValue unless another goroutine is already calling
+// its function, in which case it waits for the call to finish or ctx to be
+// done. If the other call fails, its error is returned; if it panics or fails
+// with the error of its own context, it tries again with getValue.
+func (e *Value) loadOrCallSlow(ctx context.Context, getValue func() (interface{}, error)) (interface{}, error) {
+	return e.loadOrWait(ctx, 0, getValue)
+}
+
+// loadOrWait is like loadOrCallSlow but it gives up waiting for another
+// goroutine's call after the timeout and returns ErrWaitTimeout. A zero timeout
+// waits indefinitely.
+func (e *Value) loadOrWait(ctx context.Context, timeout time.Duration, getValue func() (interface{}, error)) (interface{}, error) {
+	var expired <-chan time.Time
+	for {
+		if s := e.state.Load(); s != nil {
+			return s.result()
+		}
+		e.mu.Lock()
+		if s := e.state.Load(); s != nil {
+			e.mu.Unlock()
+			return s.result()
+		}
+		if c := e.call; c != nil {
+			e.mu.Unlock()
+			if timeout > 0 && expired == nil {
+				t := newTimer(e.clock, timeout)
+				defer t.Stop()
+				expired = t.C()
+			}
+			select {
+			case <-c.done:
+				if c.err != nil {
+					return nil, c.err
+				}
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-expired:
+				return nil, ErrWaitTimeout
+			}
+		}
+		c := &valueCall{done: make(chan struct{})}
+		e.call = c
+		e.mu.Unlock()
+		return e.doCall(c, getValue)
+	}
+}
+
+// doCall calls getValue for the call c and publishes the result. If getValue
+// fails, nothing is published and the error is handed to the waiters, unless
+// it's a context error, which is likely the cancellation of the caller alone.
+// If getValue panics, the waiters will try again.
+func (e *Value) doCall(c *valueCall, getValue func() (interface{}, error)) (interface{}, error) {
+	defer func() {
+		e.mu.Lock()
+		e.call = nil
+		e.mu.Unlock()
+		close(c.done)
+	}()
+	value, err := getValue()
+	if sf, ok := err.(*staleFallback); ok {
+		e.state.Store(sf.state)
+		return sf.state.value, nil
+	}
+	if tv, ok := value.(ttlValue); ok {
+		e.state.Store(e.newStateTTL(tv.value, nil, tv.ttl))
+		return tv.value, nil
+	}
+	if err != nil {
+		var ce *CachedError
+		if errors.As(err, &ce) {
+			ce.stamp(e.clock)
+			e.state.Store(e.newState(ce, ce))
+		} else if !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+			c.err = err
+		}
+		return value, err
+	}
+	if ce, ok := value.(*CachedError); ok {
+		ce.stamp(e.clock)
+	}
+	e.state.Store(e.newState(value, nil))
+	return value, nil
+}
+
+// newState returns a new state of the value that expires after e.ttl.
+func (e *Value) newState(value interface{}, err *CachedError) *valueState {
+	return e.newStateTTL(value, err, e.ttl)
+}
+
+// newStateTTL returns a new state of the value that expires after ttl.
+func (e *Value) newStateTTL(value interface{}, err *CachedError, ttl time.Duration) *valueState {
+	now := now(e.clock)
+	s := &valueState{value: value, err: err, created: now.UnixNano(), clock: e.clock}
+	if ttl > 0 {
+		s.expires = now.Add(ttl).UnixNano()
+	}
+	return s
+}
+
+// expired returns true if the value has expired.
+func (s *valueState) expired() bool {
+	return s.expires != 0 && now(s.clock).UnixNano() >= s.expires
+}
+
+// fresh returns true if the value can be served as is, i.e. it's neither stale
+// nor expired.
+func (s *valueState) fresh() bool {
+	return !s.stale && !s.expired()
+}
+
+// result returns the value and the error to be returned by LoadOrCallErr.
+func (s *valueState) result() (interface{}, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.value, nil
+}
+
+// newReadyValue returns a Value that is already set to the value.
+func newReadyValue(value interface{}) *Value {
+	e := &Value{}
+	e.state.Store(e.newState(value, nil))
+	return e
+}
+
+// startRefresh flags a ready value as being refreshed. It returns false if
+// another goroutine has already started the refresh, or if the value is fresh
+// and staleOnly is true.
+func (e *Value) startRefresh(staleOnly bool) bool {
+	for {
+		s := e.state.Load()
+		if s == nil || (staleOnly && s.fresh()) || s.refreshing {
+			return false
+		}
+		ns := *s
+		ns.refreshing = true
+		if e.state.CompareAndSwap(s, &ns) {
+			return true
+		}
+	}
+}
+
+// endRefresh clears the refreshing flag so that a failed refresh can be tried
+// again.
+func (e *Value) endRefresh() {
+	for {
+		s := e.state.Load()
+		if s == nil || !s.refreshing {
+			return
+		}
+		ns := *s
+		ns.refreshing = false
+		if e.state.CompareAndSwap(s, &ns) {
+			return
+		}
+	}
 }
 
 // Map is a kind of key value cache map but it is safe for concurrent use by
@@ -41,7 +270,8 @@ func (e *Value) LoadOrCall(getValue func() interface{}) interface{} {
 //
 // Deprecated: Use NewCache(&sync.Map{}).
 type Map struct {
-	m sync.Map
+	m     sync.Map
+	stats counters
 }
 
 // LoadOrCall gets pre-cached value associated with the given key or calls
@@ -49,8 +279,93 @@ type Map struct {
 // once for the given key. Even if different getValue is given for the same key,
 // only one function is called. The key should be hashable.
 func (m *Map) LoadOrCall(key interface{}, getValue func() interface{}) interface{} {
-	e, _ := m.m.LoadOrStore(key, &Value{})
-	return e.(*Value).LoadOrCall(getValue)
+	v := m.entry(key)
+	if s := v.state.Load(); s != nil {
+		m.stats.record(false, nil)
+		return s.value
+	}
+	value, _ := m.loadOrCallSlow(context.Background(), v, func() (interface{}, error) {
+		return getValue(), nil
+	})
+	return value
+}
+
+// LoadOrCallErr is like LoadOrCall but getValue may fail. If getValue returns
+// an error other than *CachedError, nothing is cached for the key and the error
+// is returned, so the next call for the key calls its getValue again.
+func (m *Map) LoadOrCallErr(key interface{}, getValue func() (interface{}, error)) (interface{}, error) {
+	v := m.entry(key)
+	if s := v.state.Load(); s != nil {
+		m.stats.record(false, nil)
+		return s.result()
+	}
+	return m.loadOrCallSlow(context.Background(), v, getValue)
+}
+
+// LoadOrCallCtx is like LoadOrCallErr but getValue is called with ctx. If ctx
+// is done while waiting for a load started by another goroutine, ctx.Err() is
+// returned without waiting any longer.
+func (m *Map) LoadOrCallCtx(ctx context.Context, key interface{}, getValue func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	v := m.entry(key)
+	if s := v.state.Load(); s != nil {
+		m.stats.record(false, nil)
+		return s.result()
+	}
+	return m.loadOrCallSlow(ctx, v, func() (interface{}, error) {
+		return getValue(ctx)
+	})
+}
+
+// entry returns the entry for the key, adding an empty one if it's missing.
+func (m *Map) entry(key interface{}) *Value {
+	e, ok := m.m.Load(key)
+	if !ok {
+		e, _ = m.m.LoadOrStore(key, &Value{})
+	}
+	return e.(*Value)
+}
+
+// loadOrCallSlow gets the value of the entry v that isn't ready, calling
+// getValue if needed, and counts the call in the statistics.
+func (m *Map) loadOrCallSlow(ctx context.Context, v *Value, getValue func() (interface{}, error)) (interface{}, error) {
+	called := false
+	value, err := v.loadOrCallSlow(ctx, func() (interface{}, error) {
+		called = true
+		return getValue()
+	})
+	m.stats.record(called, err)
+	return value, err
+}
+
+// Load returns the cached value for the key if it's present and ready. It
+// never calls a loader.
+func (m *Map) Load(key interface{}) (value interface{}, ok bool) {
+	e, ok := m.m.Load(key)
+	if !ok {
+		return nil, false
+	}
+	return e.(*Value).Load()
+}
+
+// Range calls f sequentially for each key and ready value in the map. If f
+// returns false, range stops the iteration.
+func (m *Map) Range(f func(key, value interface{}) bool) {
+	m.m.Range(func(key, e interface{}) bool {
+		value, ok := e.(*Value).Load()
+		if !ok {
+			return true
+		}
+		return f(key, value)
+	})
+}
+
+// Store sets the value for the key, overwriting the existing value. Prior
+// LoadOrCall() with the same key won't be affected.
+func (m *Map) Store(key, value interface{}) {
+	m.m.Store(key, newReadyValue(value))
 }
 
 // Delete deletes the cache value for the key. Prior LoadOrCall() with the same
@@ -61,8 +376,8 @@ func (m *Map) Delete(key interface{}) {
 	m.m.Delete(key)
 }
 
-// CacheInterface is an interface that provides map interface which is safe to use
-// in multiple goroutines.
+// CacheInterface is an interface that provides map interface which is safe to
+// use in multiple goroutines.
 type CacheInterface interface {
 	LoadOrCall(key interface{}, getValue func() interface{}) interface{}
 	Delete(key interface{})
@@ -71,95 +386,549 @@ type CacheInterface interface {
 // MultiLevelMap is an expansion of a Map that can manage tree like structure.
 // It's possible to prune a subtree. There shouldn't be any conflicts between a
 // subtree and the leaf node. For example, if a path ("a", "b", "c") has a
-// value, path ("a", "b") cannot have a value. Each elemnt of path should be
+// value, path ("a", "b") cannot have a value; the calls on conflicting paths
+// fail with a *PathConflictError. Each elemnt of path should be
 // hashable. MultiLevelMap should not be copied after first use. MultiLevelMap
 // uses a single level cache that implements CacheInterface such as *sync.Map as
 // a backend. For some cache with replacement policies, cache maps on a
 // different levels may need to share some stats like the current size of the
 // cache.
 type MultiLevelMap struct {
-	v      Value
-	newMap func() CacheInterface
+	v        Value
+	newLevel func(level int) CacheInterface
+	config   config
+	stats    counters
+	subtrees subtreeNode    // Enabled by WithSubtreeStats
+	prefixes prefixLimiters // Enabled by WithMaxConcurrentLoadsPerPrefix
+	loads    loadTracker
+
+	levelTypes sync.Map // Depth to the reflect.Type of the levels made for it
+
+	expMu       sync.Mutex
+	expiries    []pathExpiry
+	numExpiries int32
+
+	depMu     sync.Mutex
+	deps      []pathDependency
+	depsSwept int // Length of deps after the last sweep
+
+	bus *busClient // Enabled by WithInvalidationBus
 }
 
 // NewMultiLevelMap returns a new MultiLevelMap with the given newMap factory.
-// For LRU cache, you may call:
+// For LRU cache of 10000 entries over all the levels, you may call:
 //
-// 	const maxSize = 10000
-// 	sharedList := list.New()
-// 	m := NewMultiLevelMap(func() memocache.CacheInterface {
-// 		return NewCache(NewLRUMap(sharedList, maxSize))
-// 	})
+// 	m := NewMultiLevelMap(NewLRUBudget(10000).NewLevel)
 //
 // For random replacement cache, you may call:
 //
-// 	const maxSize = 10000
-// 	var currentSize int32
-// 	m := NewMultiLevelMap(func() memocache.CacheInterface {
-// 		return NewRRCache(&currentSize, maxSize, maxSize/2, rand.Intn)
-// 	})
-func NewMultiLevelMap(newMap func() CacheInterface) *MultiLevelMap {
-	return &MultiLevelMap{
-		newMap: newMap,
+// 	m := NewMultiLevelMap(NewRRBudget(10000, 5000).NewLevel)
+//
+// Builder.MultiLevelMap wires the shared state of such caches too.
+//
+// Of the options, only WithRecorder, WithInvalidationBus,
+// WithPathCanonicalizer, WithSubtreeStats, WithMaxConcurrentLoads,
+// WithMaxConcurrentLoadsPerPrefix and WithClock apply to the MultiLevelMap
+// itself. Give the other options to the caches made by newMap.
+func NewMultiLevelMap(newMap func() CacheInterface, opts ...Option) *MultiLevelMap {
+	var newLevel func(level int) CacheInterface
+	if newMap != nil {
+		newLevel = func(level int) CacheInterface {
+			return newMap()
+		}
 	}
+	return NewMultiLevelMapByLevel(newLevel, opts...)
 }
 
-// findLeafNode finds a leaf node from the given non-nil root node.
-func findLeafNode(root CacheInterface, newMap func() CacheInterface, path ...interface{}) CacheInterface {
-	if len(path) == 0 {
-		return root
+// NewMultiLevelMapByLevel is like NewMultiLevelMap but the factory is given the
+// level of the cache to make, so that the levels can have different policies,
+// e.g. an unbounded map of tenants at the root and LRU caches of their objects
+// below. The root is level 0, and the values of the paths of n elements are
+// kept in the caches of level n-1:
+//
+//	m := NewMultiLevelMapByLevel(func(level int) memocache.CacheInterface {
+//		if level == 0 {
+//			return NewCache(&sync.Map{})
+//		}
+//		return NewCache(NewLRUMap(list.New(), 1000))
+//	})
+func NewMultiLevelMapByLevel(newLevel func(level int) CacheInterface, opts ...Option) *MultiLevelMap {
+	m := &MultiLevelMap{
+		newLevel: newLevel,
+		config:   newConfig(opts),
 	}
+	m.bus = newBusClient(&m.config, m.prune)
+	return m
+}
 
-	newRoot := root.LoadOrCall(path[0], func() interface{} {
-		return newMap()
-	}).(CacheInterface)
-
-	return findLeafNode(newRoot, newMap, path[1:]...)
+// findLeafNode finds a leaf node from the given non-nil root node. Existing
+// levels are looked up with the Load methods of their parents if they have one
+// like Cache has, so that walking down existing levels doesn't allocate.
+// Missing levels are added with LoadOrCall. If a level holds a value rather
+// than the next level, it returns the index of the key of the value, and -1
+// otherwise.
+func (m *MultiLevelMap) findLeafNode(root CacheInterface, path ...interface{}) (CacheInterface, int) {
+	node := root
+	for i, key := range path {
+		var next interface{}
+		loaded := false
+		if l, ok := node.(mapLoader); ok {
+			next, loaded = l.Load(key)
+		}
+		if !loaded {
+			depth := i + 1
+			next = node.LoadOrCall(key, func() interface{} {
+				return m.makeLevel(depth)
+			})
+		}
+		level, ok := next.(CacheInterface)
+		if !ok {
+			return nil, i
+		}
+		node = level
+	}
+	return node, -1
 }
 
 // getRoot returns a root of the tree. If the map multi map is not used before,
 // a new root is created in a multi-goroutine-safe way.
 func (m *MultiLevelMap) getRoot() CacheInterface {
 	return m.v.LoadOrCall(func() interface{} {
-		if m.newMap == nil {
-			m.newMap = func() CacheInterface {
+		if m.newLevel == nil {
+			m.newLevel = func(level int) CacheInterface {
 				return &Map{}
 			}
 		}
-		return m.newMap()
+		return m.makeLevel(0)
 	}).(CacheInterface)
 }
 
+// makeLevel returns a new level of the depth and remembers its type, so that
+// the leaf values can be told from the levels when walking the tree.
+func (m *MultiLevelMap) makeLevel(depth int) CacheInterface {
+	level := m.newLevel(depth)
+	if _, ok := m.levelTypes.Load(depth); !ok {
+		m.levelTypes.Store(depth, reflect.TypeOf(level))
+	}
+	return level
+}
+
+// isLevel returns true if the value at the depth is of the type of the levels
+// made for the depth rather than a leaf value.
+func (m *MultiLevelMap) isLevel(depth int, value interface{}) bool {
+	t, ok := m.levelTypes.Load(depth)
+	return ok && t == reflect.TypeOf(value)
+}
+
 // LoadOrCall loads the value in path. If the value doesn't exist, it calls
 // getValue only once. All concurrent calls to the same path will block until
 // the value is available. Calls to other paths are not blocked. Each path
 // element should be hashable.
 func (m *MultiLevelMap) LoadOrCall(getValue func() interface{}, path ...interface{}) interface{} {
+	path = m.canonical(path)
+	n := len(path)
+	if n == 0 {
+		panic("path was not given")
+	}
+	m.pruneExpired(path)
+
+	leaf, err := m.leaf(path)
+	if err != nil {
+		panic(err)
+	}
+	if s := readyState(leaf, path[n-1]); s != nil {
+		if err := checkLeafValue(path, s.value); err != nil {
+			panic(err)
+		}
+		m.stats.record(false, nil)
+		m.recordSubtrees(path, false, nil)
+		m.config.record(OpLoad, true, path...)
+		return s.value
+	}
+	load := m.wrapValueLoader(path, getValue)
+	called := false
+	value := leaf.LoadOrCall(path[n-1], func() interface{} {
+		called = true
+		return load()
+	})
+	if _, failed := value.(*loadFailure); failed {
+		// A concurrent LoadOrCallErr failed; call getValue like a Value
+		// does when the call it waited for fails.
+		value = load()
+		called = true
+	}
+	if !called {
+		if err := checkLeafValue(path, value); err != nil {
+			panic(err)
+		}
+	}
+	m.stats.record(called, nil)
+	m.recordSubtrees(path, called, nil)
+	m.config.record(OpLoad, !called, path...)
+	return value
+}
+
+// LoadOrCallErr is like LoadOrCall but getValue may fail. If getValue returns
+// an error other than *CachedError, nothing is cached in the path and the
+// error is returned, so the next call for the path calls its getValue again.
+// If the leaf level doesn't have a LoadOrCallErr method, the failure is kept
+// in it until the callers waiting for the call get the error, and then
+// deleted.
+func (m *MultiLevelMap) LoadOrCallErr(getValue func() (interface{}, error), path ...interface{}) (interface{}, error) {
+	return m.loadOrCallCtx(context.Background(), getValue, path)
+}
+
+// LoadOrCallCtx is like LoadOrCallErr but getValue is called with ctx. If ctx
+// is already done, ctx.Err() is returned without loading. If ctx is done while
+// waiting for a load started by another goroutine, ctx.Err() is returned
+// without waiting any longer, provided that the leaf level has a LoadOrCallCtx
+// method like Cache has.
+func (m *MultiLevelMap) LoadOrCallCtx(ctx context.Context, getValue func(ctx context.Context) (interface{}, error), path ...interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.loadOrCallCtx(ctx, func() (interface{}, error) {
+		return getValue(ctx)
+	}, path)
+}
+
+// loadOrCallCtx is LoadOrCallErr waiting for the loads of other goroutines
+// until ctx is done if the leaf supports it.
+func (m *MultiLevelMap) loadOrCallCtx(ctx context.Context, getValue func() (interface{}, error), path []interface{}) (interface{}, error) {
+	path = m.canonical(path)
 	n := len(path)
 	if n == 0 {
 		panic("path was not given")
 	}
+	m.pruneExpired(path)
+
+	leaf, err := m.leaf(path)
+	if err != nil {
+		return nil, err
+	}
+	if s := readyState(leaf, path[n-1]); s != nil {
+		if err := checkLeafValue(path, s.value); err != nil {
+			return nil, err
+		}
+		m.stats.record(false, nil)
+		m.recordSubtrees(path, false, nil)
+		m.config.record(OpLoad, true, path...)
+		return s.result()
+	}
+	load := m.wrapLoader(path, getValue)
+	called := false
+	value, err := loadOrCallErr(ctx, leaf, path[n-1], func() (interface{}, error) {
+		called = true
+		return load()
+	})
+	if !called && err == nil {
+		if err := checkLeafValue(path, value); err != nil {
+			return nil, err
+		}
+	}
+	m.stats.record(called, err)
+	m.recordSubtrees(path, called, err)
+	m.config.record(OpLoad, !called, path...)
+	return value, err
+}
 
-	root := m.getRoot()
-	return findLeafNode(root, m.newMap, path[:n-1]...).LoadOrCall(path[n-1], getValue)
+// readyState returns the state of the value of the key in the leaf if it can
+// be served as is, or nil if the leaf's LoadOrCall should be called. Only the
+// leaves of this package that can be looked up without allocating are
+// checked; a hit on a Cache is counted in its statistics.
+func readyState(leaf CacheInterface, key interface{}) *valueState {
+	switch l := leaf.(type) {
+	case *Cache:
+		return l.freshState(key)
+	case *Map:
+		if e, ok := l.m.Load(key); ok {
+			return e.(*Value).state.Load()
+		}
+	}
+	return nil
+}
+
+// loadOrCallErr calls LoadOrCallErr of the leaf, or LoadOrCallCtx if ctx can be
+// done, or emulates it with LoadOrCall if the leaf has neither. A failure is
+// kept in the leaf as a *loadFailure while the callers waiting for the call
+// take their error from it, and then only that entry is deleted, see
+// deleteValue. A *CachedError is kept as the value like Cache does.
+func loadOrCallErr(ctx context.Context, leaf CacheInterface, key interface{}, getValue func() (interface{}, error)) (interface{}, error) {
+	if l, ok := leaf.(ctxLoader); ok && ctx.Done() != nil {
+		return l.LoadOrCallCtx(ctx, key, func(context.Context) (interface{}, error) {
+			return getValue()
+		})
+	}
+	if l, ok := leaf.(errLoader); ok {
+		return l.LoadOrCallErr(key, getValue)
+	}
+	var failure *loadFailure
+	value := leaf.LoadOrCall(key, func() interface{} {
+		value, err := getValue()
+		if err == nil {
+			return value
+		}
+		var ce *CachedError
+		if errors.As(err, &ce) {
+			return ce
+		}
+		failure = &loadFailure{err: err}
+		return failure
+	})
+	if failure != nil {
+		deleteValue(leaf, key, failure)
+	}
+	switch v := value.(type) {
+	case *loadFailure:
+		return nil, v.err
+	case *CachedError:
+		return nil, v
+	}
+	return value, nil
+}
+
+// loadFailure is the value kept in a leaf without LoadOrCallErr while the
+// error of its loader is handed to the callers waiting for it.
+type loadFailure struct {
+	err error
+}
+
+// pruneAll replaces the root with a new one and clears the old tree.
+func (m *MultiLevelMap) pruneAll() {
+	s := m.v.state.Load()
+	if s == nil {
+		return
+	}
+	if m.v.state.CompareAndSwap(s, m.v.newState(m.makeLevel(0), nil)) {
+		clearLevel(s.value)
+	}
+}
+
+// Clear deletes all the values in the tree. Each level is cleared after the
+// levels below it with its Clear method if it has one like Cache has, or by
+// deleting its keys one by one otherwise, so the size counters shared by the
+// levels, e.g. of RRCache, are decremented for every value. Levels without a
+// Range method are cleared without visiting the levels below them. Values
+// loaded concurrently may survive; use Prune without a path to replace the
+// whole tree at once.
+func (m *MultiLevelMap) Clear() {
+	m.dropPathDependencies()
+	m.dropSubtreeStats(nil)
+	m.dropPathExpiries(nil)
+	defer m.bus.publish()
+	root, ok := m.v.Load()
+	if !ok {
+		return
+	}
+	clearLevel(root)
+}
+
+// clearLevel deletes all the values under the level.
+func clearLevel(level interface{}) {
+	var keys []interface{}
+	if r, ok := level.(mapRanger); ok {
+		r.Range(func(key, value interface{}) bool {
+			if _, ok := value.(CacheInterface); ok {
+				clearLevel(value)
+			}
+			keys = append(keys, key)
+			return true
+		})
+	}
+	if c, ok := level.(mapClearer); ok {
+		c.Clear()
+		return
+	}
+	if c, ok := level.(CacheInterface); ok {
+		for _, key := range keys {
+			c.Delete(key)
+		}
+	}
+}
+
+// Size returns the total number of leaf values in the tree. Values still being
+// loaded and the values of levels without a Range method like Cache has are
+// not counted. A leaf value that is a CacheInterface counts as one value
+// unless it's of the type of the levels made by the factory for its depth.
+func (m *MultiLevelMap) Size() int {
+	n := 0
+	m.walk(func(path []interface{}, value interface{}) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// Walk calls f sequentially for each path and ready value of the leaves in
+// the tree, e.g. to snapshot the contents for debugging or to select paths to
+// prune. If f returns false, Walk stops the iteration. The levels are
+// iterated with their Range methods, so levels without one like Cache has are
+// skipped, and a leaf value of the type of the levels made by the factory for
+// its depth is taken as a level. f may call other methods of the map and may
+// retain path.
+func (m *MultiLevelMap) Walk(f func(path []interface{}, value interface{}) bool) {
+	m.walk(func(path []interface{}, value interface{}) bool {
+		return f(append([]interface{}(nil), path...), value)
+	})
+}
+
+// walk is like Walk but the path is reused between calls.
+func (m *MultiLevelMap) walk(f func(path []interface{}, value interface{}) bool) {
+	root, ok := m.v.Load()
+	if !ok {
+		return
+	}
+	m.walkLevel(root, nil, f)
+}
+
+// walkLevel calls f for each leaf under the level at path until f returns
+// false, and returns false if f did.
+func (m *MultiLevelMap) walkLevel(level interface{}, path []interface{}, f func(path []interface{}, value interface{}) bool) bool {
+	r, ok := level.(mapRanger)
+	if !ok {
+		return true
+	}
+	cont := true
+	r.Range(func(key, value interface{}) bool {
+		path := append(path, key)
+		if m.isLevel(len(path), value) {
+			cont = m.walkLevel(value, path, f)
+		} else {
+			cont = f(path, value)
+		}
+		return cont
+	})
+	return cont
+}
+
+// Stats returns a snapshot of the statistics of the LoadOrCall calls made on
+// the MultiLevelMap. Only the leaf level of each path is counted.
+func (m *MultiLevelMap) Stats() Stats {
+	return m.stats.snapshot()
+}
+
+// Load returns the cached value in path if it's present and ready. It never
+// calls a loader and never adds the levels of the path. A level that doesn't
+// have a Load method like Cache has is treated as empty.
+func (m *MultiLevelMap) Load(path ...interface{}) (value interface{}, ok bool) {
+	return m.load(false, path...)
+}
+
+// Peek is like Load but uses the Peek method of the levels if they have one,
+// so it doesn't mark the path as recently used in LRU backed levels.
+func (m *MultiLevelMap) Peek(path ...interface{}) (value interface{}, ok bool) {
+	return m.load(true, path...)
+}
+
+// load looks up the value in path with Peek methods if peek is true, otherwise
+// with Load methods.
+func (m *MultiLevelMap) load(peek bool, path ...interface{}) (value interface{}, ok bool) {
+	if len(path) == 0 {
+		panic("path was not given")
+	}
+	path = m.canonical(path)
+	m.pruneExpired(path)
+	return m.lookup(peek, path)
+}
+
+// lookup is like load but path should be canonical and expired paths are not
+// pruned.
+func (m *MultiLevelMap) lookup(peek bool, path []interface{}) (value interface{}, ok bool) {
+	value, ok = m.v.Load()
+	for _, key := range path {
+		if !ok {
+			return nil, false
+		}
+		if p, isPeeker := value.(mapPeeker); peek && isPeeker {
+			value, ok = p.Peek(key)
+		} else if l, isLoader := value.(mapLoader); isLoader {
+			value, ok = l.Load(key)
+		} else {
+			return nil, false
+		}
+	}
+	if _, failed := value.(*loadFailure); failed {
+		return nil, false
+	}
+	return value, ok
+}
+
+// errLoader is implemented by the caches that support LoadOrCallErr.
+type errLoader interface {
+	LoadOrCallErr(key interface{}, getValue func() (interface{}, error)) (interface{}, error)
+}
+
+// ctxLoader is implemented by the caches that support LoadOrCallCtx.
+type ctxLoader interface {
+	LoadOrCallCtx(ctx context.Context, key interface{}, getValue func(ctx context.Context) (interface{}, error)) (interface{}, error)
+}
+
+// StorePath sets the value in path, overwriting the existing value, without
+// calling a loader. Prior LoadOrCall() with the same path won't be affected. If
+// the leaf level doesn't have a Store method, the existing value is deleted
+// and the value is loaded in its place, so a concurrent LoadOrCall may win.
+func (m *MultiLevelMap) StorePath(value interface{}, path ...interface{}) {
+	path = m.canonical(path)
+	n := len(path)
+	if n == 0 {
+		panic("path was not given")
+	}
+	m.pruneExpired(path)
+
+	leaf, err := m.leaf(path)
+	if err != nil {
+		panic(err)
+	}
+	defer m.pruneDependents(path)
+	if s, ok := leaf.(mapStorer); ok {
+		s.Store(path[n-1], value)
+		return
+	}
+	leaf.Delete(path[n-1])
+	leaf.LoadOrCall(path[n-1], func() interface{} {
+		return value
+	})
 }
 
 // Prune removes a subtree of the path. It may or may not affect other
 // LoadOrCall calls made at the same time. But subsequent LoadOrCall calls in
 // the same goroutine are affected by the Prune call, so newly updated value
 // will be cached again.
+//
+// Without a path, Prune replaces the whole tree with a new root at once, so
+// concurrent LoadOrCall calls either see the old tree or the new one. The old
+// tree is then cleared like Clear does, so the size counters shared by the
+// levels are decremented for its values.
 func (m *MultiLevelMap) Prune(path ...interface{}) {
+	path = m.canonical(path)
+	m.config.record(OpPrune, false, path...)
+	m.prune(path)
+	m.bus.publish(path...)
+}
+
+// prune removes a subtree of the path without publishing it.
+func (m *MultiLevelMap) prune(path []interface{}) {
+	m.dropSubtreeStats(path)
+	m.dropPathExpiries(path)
 	n := len(path)
 	if n == 0 {
-		panic("pruning the whole tree is not supported yet")
+		m.dropPathDependencies()
+		m.pruneAll()
+		return
 	}
 
-	root := m.getRoot()
-	findLeafNode(root, m.newMap, path[:n-1]...).Delete(path[n-1])
+	defer m.pruneDependents(path)
+	// A prefix of the path holding a value has no subtree to prune.
+	if leaf, err := m.leaf(path); err == nil {
+		leaf.Delete(path[n-1])
+	}
 }
 
 // MapInterface implements a map safe for concurrent use by multiple goroutines.
-// For example, *sync.Map implements MapInterface.
+// For example, *sync.Map implements MapInterface. A Cache replaces expired and
+// stale entries with the optional CompareAndDelete and CompareAndSwap methods
+// that *sync.Map has, so that it never drops an entry written concurrently;
+// with a map without them, such entries are served until they are deleted.
 type MapInterface interface {
 	LoadOrStore(key, value interface{}) (actual interface{}, loaded bool)
 	Delete(key interface{})
@@ -172,71 +941,448 @@ type MapInterface interface {
 // same key waits until the function returns, but calls to a different key are
 // not blocked. Map should not be copied after first use.
 type Cache struct {
-	m MapInterface
+	m       MapInterface
+	config  config
+	latency *latencyMonitor
+	stats   counters
+	loads   loadTracker
+
+	leaseMu     sync.Mutex
+	leases      map[interface{}]*leaseEntry
+	leasesSwept int // Leases left by the last sweep, guarded by leaseMu
+	numLeases   int32
+
+	version atomic.Uint64 // Bumped by every write with WithReadYourWrites
+
+	invalidations *invalidations // Enabled by WithInvalidationVersions
+
+	mapNotifies bool // The map notifies of its removals
+
+	report *reporter // Enabled by WithReport
+	hot    *hotKeys  // Enabled by WithHotKeys
+
+	bus    *busClient // Enabled by WithInvalidationBus
+	writer *writer    // Enabled by WithWriteThrough or WithWriteBehind
+
+	workerMu   sync.Mutex
+	workers    map[interface{}]*keyWorker
+	numWorkers int32
+
+	depMu       sync.Mutex
+	dependents  map[interface{}]map[interface{}]bool // Keys derived from a key
+	derivedFrom map[interface{}]map[interface{}]bool // Keys a key is derived from
+	numDeps     int32
 }
 
 // NewCache returns a new cache backed by the given m which should be safe for
-// concurrent use by multiple goroutines.
-func NewCache(m MapInterface) *Cache {
-	return &Cache{m: m}
+// concurrent use by multiple goroutines. Optional behavior can be configured
+// with opts.
+func NewCache(m MapInterface, opts ...Option) *Cache {
+	c := &Cache{
+		m:      m,
+		config: newConfig(opts),
+	}
+	c.latency = newLatencyMonitor(&c.config)
+	c.invalidations = newInvalidations(&c.config)
+	c.hot = newHotKeys(&c.config)
+	if c.report = newReporter(&c.config); c.report != nil {
+		c.config.onEvictedEntry = c.report.evicted
+		if c.config.onEvict == nil {
+			c.config.onEvict = func(key, value interface{}, reason EvictionReason) {}
+		}
+	}
+	if m, ok := m.(evictNotifier); ok {
+		m.SetOnEvict(chainOnEvict(c.mapRemoved, m))
+		c.mapNotifies = true
+	}
+	c.bus = newBusClient(&c.config, c.applyInvalidation)
+	c.writer = newWriter(&c.config)
+	return c
 }
 
 // LoadOrCall gets pre-cached value associated with the given key or calls
 // getValue to get the value for the key. The function getValue is called only
 // once for the given key. Even if different getValue is given for the same key,
-// only one function is called. The key should be hashable.
+// only one function is called. The key should be hashable. If getValue panics,
+// the panic propagates to the caller and the key is removed, so that later
+// calls try again.
 func (c *Cache) LoadOrCall(key interface{}, getValue func() interface{}) interface{} {
-	e, _ := c.m.LoadOrStore(key, &Value{})
-	return e.(*Value).LoadOrCall(getValue)
+	v := c.entry(key)
+	if s := v.state.Load(); s != nil && s.fresh() {
+		c.hit(key, v)
+		return s.value
+	}
+	value, _ := c.loadOrCallSlow(context.Background(), key, v, func() (interface{}, error) {
+		return getValue(), nil
+	})
+	return value
+}
+
+// entry returns the entry for the key, adding an empty one if it's missing. If
+// the map has a Load method, the key is looked up first so that hits don't
+// allocate an empty entry.
+func (c *Cache) entry(key interface{}) *Value {
+	if m, ok := c.m.(mapLoader); ok {
+		if e, ok := m.Load(key); ok {
+			return e.(*Value)
+		}
+	}
+	e, _ := c.m.LoadOrStore(key, c.newValue())
+	return e.(*Value)
+}
+
+// freshState returns the state of the entry for the key and counts a hit if
+// the entry has a fresh value. Otherwise it returns nil without adding an
+// entry.
+func (c *Cache) freshState(key interface{}) *valueState {
+	m, ok := c.m.(mapLoader)
+	if !ok {
+		return nil
+	}
+	e, ok := m.Load(key)
+	if !ok {
+		return nil
+	}
+	v := e.(*Value)
+	s := v.state.Load()
+	if s == nil || !s.fresh() {
+		return nil
+	}
+	c.hit(key, v)
+	return s
+}
+
+// hit counts a call for the key served by the entry v without calling a
+// loader.
+func (c *Cache) hit(key interface{}, v *Value) {
+	c.stats.hits.Add(1)
+	v.hits.Add(1)
+	if c.config.trackAccess {
+		v.accessed.Store(now(c.config.clock).UnixNano())
+	}
+	c.report.record(true)
+	c.hot.record(key)
+	c.config.record(OpLoad, true, key)
+	c.config.observe(true, key)
+}
+
+// newValue returns an empty entry stamped with the current version.
+func (c *Cache) newValue() *Value {
+	v := &Value{ttl: c.config.jitterTTL(c.config.ttl), clock: c.config.clock}
+	if c.config.readYourWrites {
+		v.version = c.version.Load()
+	}
+	return v
+}
+
+// loadOrCallSlow gets the value of the entry v for the key, calling getValue if
+// needed. Waiting for another goroutine's call stops when ctx is done. Stale or
+// expired entries are served and refreshed in the background in the degraded
+// mode, otherwise they are replaced by a new entry. The value of a leased key
+// is loaded without caching it. If getValue panics, the entry is removed
+// before the panic propagates.
+func (c *Cache) loadOrCallSlow(ctx context.Context, key interface{}, v *Value, getValue func() (interface{}, error)) (interface{}, error) {
+	if c.leased(key) {
+		return c.loadLeased(ctx, key, getValue)
+	}
+	var stale *valueState
+	if s := v.state.Load(); s != nil && !s.fresh() {
+		if c.latency.isDegraded() {
+			c.refreshStale(key, v, getValue)
+			c.hit(key, v)
+			return s.result()
+		}
+		stale = c.staleState(s)
+		c.compareAndDelete(key, v, EvictionDeleted)
+		v = c.entry(key)
+	}
+	getValue = onPanic(c.loader(ctx, key, getValue), func() {
+		c.compareAndDelete(key, v, EvictionDeleted)
+	})
+	if stale != nil {
+		getValue = c.serveStale(key, stale, getValue)
+	}
+	called := false
+	value, err := v.loadOrWait(ctx, c.config.waitTimeout, func() (interface{}, error) {
+		called = true
+		return getValue()
+	})
+	if err == ErrWaitTimeout && !called {
+		called = true
+		if c.config.waitFallback != nil {
+			value, err = c.config.waitFallback(key)
+		}
+	}
+	c.stats.record(called, err)
+	c.report.record(!called)
+	c.hot.record(key)
+	if !called {
+		v.hits.Add(1)
+	} else if m, ok := c.m.(mapReweigher); ok {
+		m.Reweigh(key, v)
+	}
+	c.config.record(OpLoad, !called, key)
+	c.config.observe(!called, key)
+	return value, err
+}
+
+// loader returns getValue decorated with the hooks, the latency monitor and
+// the count of the loads in flight.
+func (c *Cache) loader(ctx context.Context, key interface{}, getValue func() (interface{}, error)) func() (interface{}, error) {
+	getValue = c.config.wrapLoader(ctx, key, getValue)
+	if c.latency == nil {
+		return c.loads.wrap(getValue)
+	}
+	return c.loads.wrap(func() (interface{}, error) {
+		start := now(c.config.clock)
+		defer func() {
+			c.latency.record(now(c.config.clock).Sub(start))
+		}()
+		return getValue()
+	})
+}
+
+// refreshStale loads a new value for the stale or expired entry v in the
+// background unless it's already being refreshed. The new value replaces v once
+// ready. If the load fails, v is kept and the refresh is tried again later. The
+// new value is discarded if the key is invalidated meanwhile with
+// WithInvalidationVersions.
+func (c *Cache) refreshStale(key interface{}, v *Value, getValue func() (interface{}, error)) {
+	if v.startRefresh(true) {
+		c.refresh(key, v, getValue)
+	}
+}
+
+// refresh loads a new value for the entry v flagged as being refreshed in the
+// background, and swaps it in for v once ready.
+func (c *Cache) refresh(key interface{}, v *Value, getValue func() (interface{}, error)) {
+	getValue = c.loader(context.Background(), key, getValue)
+	started := c.invalidations.now()
+	go func() {
+		nv := c.newValue()
+		if _, err := nv.LoadOrCallErr(getValue); err != nil || c.leased(key) || c.invalidations.invalidatedSince(key, started) {
+			v.endRefresh()
+			return
+		}
+		if !c.compareAndSwap(key, v, nv) {
+			v.endRefresh()
+			return
+		}
+		// The dependencies of the key were declared again by getValue.
+		c.invalidateDependents(key, false)
+		// An invalidation racing with the swap drops the value loaded before
+		// it.
+		if c.invalidations.invalidatedSince(key, started) {
+			c.compareAndDelete(key, nv, EvictionDeleted)
+		}
+	}()
+}
+
+// compareAndSwap replaces the entry for the key with nv if it's still v, and
+// returns true if it's replaced. It always returns false if the backing map
+// can't compare.
+func (c *Cache) compareAndSwap(key interface{}, v, nv *Value) bool {
+	m, ok := c.m.(mapCompareSwapper)
+	if !ok || !m.CompareAndSwap(key, v, nv) {
+		return false
+	}
+	if c.notifiesEvictions() {
+		c.config.evicted(key, v, EvictionReplaced)
+	}
+	return true
+}
+
+// compareAndDelete deletes the entry for the key if it's still v, and returns
+// true if it's deleted. The removal is notified for the reason. It always
+// returns false if the backing map can't compare.
+func (c *Cache) compareAndDelete(key interface{}, v *Value, reason EvictionReason) bool {
+	m, ok := c.m.(mapCompareDeleter)
+	if !ok || !m.CompareAndDelete(key, v) {
+		return false
+	}
+	if c.notifiesEvictions() {
+		c.config.evicted(key, v, reason)
+	}
+	if reason != EvictionReplaced {
+		c.stopRemovedWorkers(key, v)
+	}
+	c.deleteDependents(key)
+	return true
+}
+
+// canCompare returns whether the backing map can delete entries conditionally.
+func (c *Cache) canCompare() bool {
+	_, ok := c.m.(mapCompareDeleter)
+	return ok
+}
+
+// deleteKey deletes the entry for the key and notifies the removal for the
+// reason.
+func (c *Cache) deleteKey(key interface{}, reason EvictionReason) {
+	if !c.notifiesEvictions() || !c.canCompare() {
+		c.m.Delete(key)
+		return
+	}
+	m, ok := c.m.(mapLoader)
+	if !ok {
+		c.m.Delete(key)
+		return
+	}
+	for {
+		e, ok := m.Load(key)
+		if !ok || c.compareAndDelete(key, e.(*Value), reason) {
+			return
+		}
+	}
 }
 
 // Delete deletes the cache value for the key. Prior LoadOrCall() with the same
 // key won't be affected by the delete calls. Later LoadOrCall() with the same
 // key will have to call getValue, since the cache is cleared for the key. The
-// key should be hashable.
+// key should be hashable. Delete revokes the lease of the key if any.
 func (c *Cache) Delete(key interface{}) {
-	c.m.Delete(key)
+	c.config.record(OpDelete, false, key)
+	c.writer.delete(key)
+	c.RevokeLease(key)
+	c.delete(key)
+	c.bus.publish(key)
+}
+
+// delete deletes the cache value for the key without revoking the lease. It
+// isn't recorded, since the deletes of the dependents and of the invalidation
+// bus follow a recorded Delete.
+func (c *Cache) delete(key interface{}) {
+	defer c.deleteDependents(key)
+	c.stopWorker(key)
+	if m, ok := c.m.(mapLoader); ok {
+		if e, ok := m.Load(key); ok {
+			if s := e.(*Value).state.Load(); s != nil {
+				if sub, ok := s.value.(*Cache); ok {
+					defer sub.stopWorkers()
+				}
+			}
+		}
+	}
+	c.invalidations.invalidate(key)
+	if c.config.readYourWrites {
+		c.version.Add(1)
+	}
+	c.deleteKey(key, EvictionDeleted)
 }
 
 // RRCache implements the random replacement cache. It removes about a half
 // (random) of cached items when it goes over the max size. RRCache has smaller
 // memory overhead than LRUCache has.
 type RRCache struct {
-	m           sync.Map
-	currentSize *int32
-	maxSize     int32
-	targetNum   int32
-	intn        func(n int) int
-	mu          sync.Mutex // Lock for delete
+	m         sync.Map
+	size      sizeTracker
+	maxSize   int32
+	targetNum int32
+	intn      func(n int) int
+	mu        sync.Mutex // Lock for the entries and the counts below
+	own       int32      // Entries of m counted in size
+	detached  bool       // Removed from the tree, so nothing is counted
+	children  map[*RRCache]bool
+	config    config
+	stats     counters
+	loads     loadTracker
+	pinned    sync.Map // Keys never evicted, set by Pin
+	budget    *Budget  // Capacity shared by the levels made by a Budget
+	writer    *writer  // Enabled by WithWriteThrough or WithWriteBehind
+
+	evictOnce  sync.Once
+	closeOnce  sync.Once
+	evictCh    chan struct{} // Wakes up the worker of WithAsyncEviction
+	evictClose chan struct{} // Stops the worker of WithAsyncEviction
 }
 
 // NewRRCache creates a new random replacement cache. If the maxSize is reached,
 // items are evicted approximately until the given targetNum (like half of
 // maxSize) items are remaining. The evicted items may not be truly random. A
 // pointer to currentSize is used to share the counter for the number of items
-// for multi level maps. Pass rand.Intn as intn or any random number generator
-// that is safe for concurrent use.
-func NewRRCache(currentSize *int32, maxSize, targetNum int32, intn func(n int) int) *RRCache {
-	return &RRCache{
-		currentSize: currentSize,
-		maxSize:     maxSize,
-		targetNum:   targetNum,
-		intn:        intn,
+// for multi level maps; if it's nil, the cache counts its own items. The nodes
+// of a multi level map count as items, and removing a node uncounts the items
+// of its whole subtree, including those written to it concurrently. Pass
+// rand.Intn as intn or any random number generator that is safe for concurrent
+// use. NewRRCacheLocal and NewRRCacheLevels are easier to use correctly. With
+// WithEvictionSeed, the evictions are reproducible and intn is unused.
+func NewRRCache(currentSize *int32, maxSize, targetNum int32, intn func(n int) int, opts ...Option) *RRCache {
+	if currentSize == nil {
+		currentSize = new(int32)
+	}
+	r := &RRCache{
+		size:      sizeTracker{n: currentSize},
+		maxSize:   maxSize,
+		targetNum: targetNum,
+		intn:      intn,
+		config:    newConfig(opts),
+	}
+	if r.config.deterministic {
+		r.intn = seededIntn(r.config.evictionSeed)
 	}
+	r.writer = newWriter(&r.config)
+	return r
 }
 
 // LoadOrCall loads the value in path. If the value doesn't exist, it calls
 // getValue only once. All concurrent calls to the same path will block until
 // the value is available. Calls to other paths are not blocked. Each path
 // element should be hashable. If the number of items exceeds the maxSize, it
-// will evict random items.
+// will evict random items. If getValue panics, the panic propagates to the
+// caller and the key is removed, so that later calls try again.
 func (r *RRCache) LoadOrCall(key interface{}, getValue func() interface{}) interface{} {
-	e, _ := r.m.LoadOrStore(key, &Value{})
-	return e.(*Value).LoadOrCall(func() interface{} {
-		atomic.AddInt32(r.currentSize, 1)
+	value, _ := r.LoadOrCallErr(key, func() (interface{}, error) {
+		return getValue(), nil
+	})
+	return value
+}
+
+// LoadOrCallErr is like LoadOrCall but getValue may fail. If getValue returns
+// an error other than *CachedError, nothing is cached for the key and the error
+// is returned, so the next call for the key calls its getValue again.
+func (r *RRCache) LoadOrCallErr(key interface{}, getValue func() (interface{}, error)) (interface{}, error) {
+	return r.loadOrCall(context.Background(), key, getValue)
+}
+
+// loadOrCall gets the value for the key, calling getValue if needed. Waiting
+// for another goroutine's call stops when ctx is done.
+func (r *RRCache) loadOrCall(ctx context.Context, key interface{}, getValue func() (interface{}, error)) (interface{}, error) {
+	e, ok := r.m.Load(key)
+	if !ok {
+		r.mu.Lock()
+		var loaded bool
+		if e, loaded = r.m.LoadOrStore(key, &Value{}); !loaded {
+			r.countAdded()
+		}
+		r.mu.Unlock()
+	}
+	v := e.(*Value)
+	if s := v.state.Load(); s != nil {
+		r.stats.hits.Add(1)
+		r.config.record(OpLoad, true, key)
+		r.config.observe(true, key)
+		return s.result()
+	}
+	getValue = onPanic(r.loads.wrap(r.config.wrapLoader(ctx, key, getValue)), func() {
+		r.remove(key, v)
+	})
+	called := false
+	value, err := v.loadOrCallSlow(ctx, func() (interface{}, error) {
+		called = true
 		r.maybeEvict()
-		return getValue()
+		value, err := getValue()
+		var ce *CachedError
+		if err != nil && !errors.As(err, &ce) {
+			r.remove(key, v)
+		} else if child, ok := value.(*RRCache); ok {
+			r.adopt(key, v, child)
+		}
+		return value, err
 	})
+	r.stats.record(called, err)
+	r.config.record(OpLoad, !called, key)
+	r.config.observe(!called, key)
+	return value, err
 }
 
 // Delete deletes the cache value for the key. Prior LoadOrCall() with the same
@@ -244,44 +1390,106 @@ func (r *RRCache) LoadOrCall(key interface{}, getValue func() interface{}) inter
 // key will have to call getValue, since the cache is cleared for the key. The
 // key should be hashable.
 func (r *RRCache) Delete(key interface{}) {
+	r.writer.delete(key)
+	r.config.record(OpDelete, false, key)
+	r.delete(key, EvictionDeleted)
+}
+
+// delete deletes the cache value for the key and notifies the removal for the
+// reason.
+func (r *RRCache) delete(key interface{}, reason EvictionReason) {
+	r.compareAndDelete(key, nil, reason)
+}
+
+// compareAndDelete deletes the entry for the key if it's old, or whatever it
+// is if old is nil, and notifies the removal for the reason. It returns true if
+// an entry is deleted.
+func (r *RRCache) compareAndDelete(key, old interface{}, reason EvictionReason) bool {
 	r.mu.Lock()
-	defer r.mu.Unlock()
-	if value, ok := r.m.Load(key); ok {
-		if child, ok := value.(*RRCache); ok {
-			child.clear()
-		}
-		atomic.AddInt32(r.currentSize, -1)
+	value, ok := r.m.Load(key)
+	if ok && old != nil && value != old {
+		ok = false
+	}
+	var child *RRCache
+	if ok {
 		r.m.Delete(key)
+		r.countRemoved()
+		child = r.dropChild(value)
+	}
+	r.mu.Unlock()
+	if child != nil {
+		child.detach()
+	}
+	if ok {
+		stopEntryWorkers(value)
+	}
+	if ok && r.config.onEvict != nil {
+		r.config.evicted(key, value, reason)
 	}
+	return ok
+}
+
+// Clear deletes all the entries of this cache one by one, decrementing the
+// size counter shared with other caches by the entries of the levels below
+// too. Values loaded concurrently may survive.
+func (r *RRCache) Clear() {
+	r.clear()
 }
 
 func (r *RRCache) clear() {
 	r.m.Range(func(key, value interface{}) bool {
-		if child, ok := value.(*RRCache); ok {
-			child.clear()
-		}
-		r.Delete(key)
+		r.delete(key, EvictionCleared)
 		return true
 	})
 }
 
+// rrChild returns the cache of the level below held by the entry e of a
+// RRCache of a MultiLevelMap.
+func rrChild(e interface{}) (*RRCache, bool) {
+	if v, ok := e.(*Value); ok {
+		if s := v.state.Load(); s != nil {
+			e = s.value
+		}
+	}
+	child, ok := e.(*RRCache)
+	return child, ok
+}
+
+// maybeEvict evicts items if there are more than maxSize items, or makes the
+// eviction worker do it with WithAsyncEviction.
 func (r *RRCache) maybeEvict() {
+	if r.config.asyncEviction {
+		r.evictAsync()
+		return
+	}
+	r.evict(r.limits())
+}
+
+// evict evicts random items approximately until targetNum items are remaining
+// if there are more than limit items.
+func (r *RRCache) evict(limit, targetNum int32) {
 	count := 0
-	for atomic.LoadInt32(r.currentSize) > r.maxSize {
+	for r.size.len() > limit {
 		count++
 		if count > 5 {
 			break
 		}
-		r.m.Range(func(key, value interface{}) bool {
-			if child, ok := value.(*RRCache); ok {
-				child.maybeEvict()
+		r.rangeEntries(func(key, value interface{}) bool {
+			if child, ok := rrChild(value); ok {
+				child.evict(limit, targetNum)
+			}
+			currentSize := r.size.len()
+			if currentSize <= 0 {
+				return false
+			}
+			numToEvict := currentSize - targetNum
+			if _, pinned := r.pinned.Load(key); pinned {
+				return true
 			}
-			currentSize := atomic.LoadInt32(r.currentSize)
-			numToEvict := currentSize - r.targetNum
 			randResult := int32(r.intn(int(currentSize)))
 			if randResult < numToEvict {
-				r.Delete(key)
-
+				r.delete(key, EvictionCapacity)
+				r.budget.evicted()
 			}
 			return true
 		})
@@ -290,10 +1498,17 @@ func (r *RRCache) maybeEvict() {
 
 type keyValue struct {
 	M     map[interface{}]*list.Element
+	Owner *LRUMap
 	Key   interface{}
 	Value interface{}
 }
 
+// eviction is a removed value to notify the listener of its owner of.
+type eviction struct {
+	kv     keyValue
+	reason EvictionReason
+}
+
 // LRUMap implements the least recently used map with manual deletion. LRUMap
 // needs a linked list and has some overhead on the memory space.
 type LRUMap struct {
@@ -301,6 +1516,13 @@ type LRUMap struct {
 	list    *list.List
 	m       map[interface{}]*list.Element
 	maxSize int
+
+	onEvict func(key, value interface{}, reason EvictionReason)
+	pending []eviction // Removed values to notify of, guarded by mu
+
+	pinned map[interface{}]bool // Keys kept out of the list by Pin
+
+	budget *Budget // Capacity shared by the levels made by a Budget
 }
 
 // NewLRUMap returns a new LRU cache.
@@ -312,27 +1534,239 @@ func NewLRUMap(l *list.List, maxSize int) *LRUMap {
 	}
 }
 
+// SetOnEvict sets a function that is called with every value removed from the
+// map, e.g. to close a connection stored as a value, and the reason of the
+// removal. It's called after the lock of the map is released, so it may call
+// the methods of the map. A value evicted to make room for a value of another
+// map sharing the list is notified to the listener of its own map.
+// SetOnEvict should be called before the map is used. A Cache wrapping the
+// map installs its own listener, which calls the one set before.
+func (l *LRUMap) SetOnEvict(f func(key, value interface{}, reason EvictionReason)) {
+	l.onEvict = f
+}
+
+// evictListener returns the listener set by SetOnEvict.
+func (l *LRUMap) evictListener() func(key, value interface{}, reason EvictionReason) {
+	return l.onEvict
+}
+
+// unlock releases the lock and notifies the listeners of the values removed
+// while it was held.
+func (l *LRUMap) unlock() {
+	pending := l.pending
+	l.pending = nil
+	l.mu.Unlock()
+	for _, ev := range pending {
+		ev.kv.Owner.onEvict(ev.kv.Key, ev.kv.Value, ev.reason)
+	}
+}
+
+// removed records the removal of kv to notify its owner of. It should be
+// called with l.mu held.
+func (l *LRUMap) removed(kv keyValue, reason EvictionReason) {
+	if kv.Owner != nil && kv.Owner.onEvict != nil {
+		l.pending = append(l.pending, eviction{kv: kv, reason: reason})
+	}
+}
+
 // LoadOrStore returns the existing value for the key if present. Otherwise, it
 // stores and returns the given value. The loaded result is true if the value
 // was loaded, false if stored. If the cache size exceeds the maxSize, it
 // removes the value from the map.
 func (l *LRUMap) LoadOrStore(key, value interface{}) (actual interface{}, loaded bool) {
 	l.mu.Lock()
-	defer l.mu.Unlock()
+	defer l.unlock()
 	e, ok := l.m[key]
 	if ok {
 		l.list.MoveToFront(e)
 		return e.Value.(*keyValue).Value, true
 	}
-	e = l.list.PushFront(&keyValue{M: l.m, Key: key, Value: value})
+	e = l.insert(key, value)
+	l.evict()
+	return e.Value.(*keyValue).Value, false
+}
+
+// loadOrStoreAdmit is like LoadOrStore but if the list is full, the value is
+// only stored if admit returns true for the least recently used key that would
+// be evicted for it. The stored result is false if the value was rejected.
+func (l *LRUMap) loadOrStoreAdmit(key, value interface{}, admit func(victim interface{}) bool) (actual interface{}, loaded, stored bool) {
+	l.mu.Lock()
+	defer l.unlock()
+	e, ok := l.m[key]
+	if ok {
+		l.list.MoveToFront(e)
+		return e.Value.(*keyValue).Value, true, false
+	}
+	if l.list.Len() >= l.capacity() && l.list.Len() > 0 {
+		if !admit(l.list.Back().Value.(*keyValue).Key) {
+			return value, false, false
+		}
+	}
+	l.insert(key, value)
+	l.evict()
+	return value, false, true
+}
+
+// Load returns the value stored in the map for a key, or nil if no value is
+// present. The ok result indicates whether value was found in the map. A found
+// key becomes the most recently used one.
+func (l *LRUMap) Load(key interface{}) (value interface{}, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.m[key]
+	if !ok {
+		return nil, false
+	}
+	l.list.MoveToFront(e)
+	return e.Value.(*keyValue).Value, true
+}
+
+// Peek is like Load but doesn't mark the key as recently used.
+func (l *LRUMap) Peek(key interface{}) (value interface{}, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.m[key]
+	if !ok {
+		return nil, false
+	}
+	return e.Value.(*keyValue).Value, true
+}
+
+// Store sets the value for a key, overwriting the existing value if any. The
+// key becomes the most recently used one.
+func (l *LRUMap) Store(key, value interface{}) {
+	l.mu.Lock()
+	defer l.unlock()
+	if e, ok := l.m[key]; ok {
+		kv := e.Value.(*keyValue)
+		l.removed(*kv, EvictionReplaced)
+		kv.Value = value
+		l.list.MoveToFront(e)
+		return
+	}
+	l.insert(key, value)
+	l.evict()
+}
+
+// insert adds the value of the key as the most recently used one, or out of
+// the list if the key is pinned. It should be called with l.mu held.
+func (l *LRUMap) insert(key, value interface{}) *list.Element {
+	kv := &keyValue{M: l.m, Owner: l, Key: key, Value: value}
+	var e *list.Element
+	if l.pinned[key] {
+		e = &list.Element{Value: kv}
+	} else {
+		e = l.list.PushFront(kv)
+	}
 	l.m[key] = e
-	for l.list.Len() > l.maxSize {
+	return e
+}
+
+// Range calls f sequentially for each key and value present in the map. If f
+// returns false, range stops the iteration. Range iterates over a snapshot, so
+// f may call other methods of the map. Range doesn't change the recency of the
+// keys.
+func (l *LRUMap) Range(f func(key, value interface{}) bool) {
+	l.mu.Lock()
+	kvs := make([]keyValue, 0, len(l.m))
+	for _, e := range l.m {
+		kvs = append(kvs, *e.Value.(*keyValue))
+	}
+	l.mu.Unlock()
+	for _, kv := range kvs {
+		if !f(kv.Key, kv.Value) {
+			return
+		}
+	}
+}
+
+// Cap returns the maximum number of keys in the list shared by the maps.
+func (l *LRUMap) Cap() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.capacity()
+}
+
+// Len returns the number of keys in this map. Maps sharing the same list are
+// not counted.
+func (l *LRUMap) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.m)
+}
+
+// CompareAndSwap swaps the old and new values for key if the value stored in
+// the map is equal to old. The swapped key becomes the most recently used one.
+func (l *LRUMap) CompareAndSwap(key, old, new interface{}) (swapped bool) {
+	l.mu.Lock()
+	defer l.unlock()
+	e, ok := l.m[key]
+	if !ok || e.Value.(*keyValue).Value != old {
+		return false
+	}
+	kv := e.Value.(*keyValue)
+	l.removed(*kv, EvictionReplaced)
+	kv.Value = new
+	l.list.MoveToFront(e)
+	return true
+}
+
+// CompareAndDelete deletes the entry for key if its value is equal to old.
+func (l *LRUMap) CompareAndDelete(key, old interface{}) (deleted bool) {
+	l.mu.Lock()
+	defer l.unlock()
+	e, ok := l.m[key]
+	if !ok || e.Value.(*keyValue).Value != old {
+		return false
+	}
+	l.deleteElement(e, EvictionDeleted)
+	return true
+}
+
+// evict removes the least recently used items until the list fits in maxSize.
+// It should be called with l.mu held.
+func (l *LRUMap) evict() {
+	l.evictTo(l.capacity())
+}
+
+// evictTo removes the least recently used items until the list has at most
+// size items. It should be called with l.mu held.
+func (l *LRUMap) evictTo(size int) {
+	for l.list.Len() > size {
 		oldest := l.list.Back()
 		kv := oldest.Value.(*keyValue)
 		delete(kv.M, kv.Key)
 		l.list.Remove(oldest)
+		l.removed(*kv, EvictionCapacity)
+		l.budget.evicted()
+	}
+}
+
+// Clear deletes all the values. If the list isn't shared with other LRUMaps, it
+// takes constant time under the lock regardless of the number of values: the
+// old map and list elements are dropped at once and left to the garbage
+// collector, and the listener set by SetOnEvict is notified of them in a new
+// goroutine. Otherwise the values are removed from the shared list one by one.
+func (l *LRUMap) Clear() {
+	l.mu.Lock()
+	defer l.unlock()
+	if len(l.pinned) == 0 && l.list.Len() == len(l.m) {
+		old := l.m
+		l.list.Init()
+		l.m = make(map[interface{}]*list.Element)
+		if l.onEvict != nil {
+			go func() {
+				for _, e := range old {
+					kv := e.Value.(*keyValue)
+					l.onEvict(kv.Key, kv.Value, EvictionCleared)
+				}
+			}()
+		}
+		return
+	}
+	for _, e := range l.m {
+		l.deleteElement(e, EvictionCleared)
 	}
-	return e.Value.(*keyValue).Value, false
 }
 
 // clear removes all values in this LRUMap.
@@ -345,14 +1779,22 @@ func (l *LRUMap) clear() {
 // Delete deletes the value for a key.
 func (l *LRUMap) Delete(key interface{}) {
 	l.mu.Lock()
-	defer l.mu.Unlock()
+	defer l.unlock()
 	e, ok := l.m[key]
 	if !ok {
 		return
 	}
-	if ll, ok := e.Value.(*keyValue).Value.(*LRUMap); ok {
+	l.deleteElement(e, EvictionDeleted)
+}
+
+// deleteElement removes the element e of the list from the map for the reason.
+// It should be called with l.mu held.
+func (l *LRUMap) deleteElement(e *list.Element, reason EvictionReason) {
+	kv := e.Value.(*keyValue)
+	if ll, ok := kv.Value.(*LRUMap); ok {
 		ll.clear()
 	}
 	l.list.Remove(e)
-	delete(l.m, key)
+	delete(l.m, kv.Key)
+	l.removed(*kv, reason)
 }
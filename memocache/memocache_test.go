@@ -2,11 +2,17 @@ package memocache
 
 import (
 	"container/list"
+	"context"
+	"errors"
 	"fmt"
 	"math/rand"
+	"os"
+	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"testing"
 
 	"github.com/jaeyeom/sugo/par"
 )
@@ -129,7 +135,7 @@ func ExampleCache_differentKeysNotBlocked() {
 
 func ExampleRRCache() {
 	var currentSize int32
-	m := NewRRCache(&currentSize, 6, 3, rand.Intn)
+	m := NewRRCache(&currentSize, 6, 3, rand.Intn, WithEvictionSeed(1))
 	names := []string{
 		"John", "Mary", "Linda", "Oscar", "Yang",
 		"Yoshi", "Carlos", "Samantha"}
@@ -148,7 +154,7 @@ func ExampleRRCache() {
 	}
 	lookupAll("== First Calls ==")
 	lookupAll("== Call again ==")
-	// Example Output:
+	// Output:
 	// == First Calls ==
 	// 0 John called
 	// John
@@ -168,13 +174,12 @@ func ExampleRRCache() {
 	// Samantha
 	// == Call again ==
 	// John
+	// 1 Mary called
 	// Mary
-	// 2 Linda called
 	// Linda
 	// Oscar
 	// 4 Yang called
 	// Yang
-	// 5 Yoshi called
 	// Yoshi
 	// 6 Carlos called
 	// Carlos
@@ -384,3 +389,534 @@ func ExampleMultiLevelMap_withRRCache() {
 	// LINDA
 	// Oscar
 }
+
+func BenchmarkValue_LoadOrCall(b *testing.B) {
+	var v Value
+	v.LoadOrCall(func() interface{} { return 1 })
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			v.LoadOrCall(nil)
+		}
+	})
+}
+
+func ExampleMultiLevelMap_LoadOrCallErr() {
+	var m MultiLevelMap
+
+	var numCalls int
+	lookup := func(id int) (interface{}, error) {
+		return m.LoadOrCallErr(func() (interface{}, error) {
+			numCalls++
+			switch {
+			case numCalls == 1:
+				return nil, errors.New("connection reset")
+			case id < 0:
+				return nil, NewCachedError(id, os.ErrNotExist)
+			}
+			return fmt.Sprint("user", id), nil
+		}, "users", id)
+	}
+
+	fmt.Println(lookup(1))
+	fmt.Println(lookup(1))
+	fmt.Println(lookup(1))
+	_, err := lookup(-1)
+	fmt.Println(errors.Is(err, os.ErrNotExist))
+	_, err = lookup(-1)
+	fmt.Println(errors.Is(err, os.ErrNotExist))
+	fmt.Println("calls:", numCalls)
+	// Output:
+	// <nil> connection reset
+	// user1 <nil>
+	// user1 <nil>
+	// true
+	// true
+	// calls: 3
+}
+
+// plainCache is a CacheInterface without the optional methods.
+type plainCache struct {
+	c *Cache
+}
+
+func (p plainCache) LoadOrCall(key interface{}, getValue func() interface{}) interface{} {
+	return p.c.LoadOrCall(key, getValue)
+}
+
+func (p plainCache) Delete(key interface{}) {
+	p.c.Delete(key)
+}
+
+func TestMultiLevelMap_LoadOrCallErrPlainCache(t *testing.T) {
+	m := NewMultiLevelMap(func() CacheInterface {
+		return plainCache{NewCache(&sync.Map{})}
+	})
+
+	wantErr := errors.New("failed")
+	if _, err := m.LoadOrCallErr(func() (interface{}, error) {
+		return nil, wantErr
+	}, "a", "b"); err != wantErr {
+		t.Errorf("LoadOrCallErr() error = %v, want %v", err, wantErr)
+	}
+	if v, err := m.LoadOrCallErr(func() (interface{}, error) {
+		return 1, nil
+	}, "a", "b"); err != nil || v != 1 {
+		t.Errorf("LoadOrCallErr() = %v, %v, want 1, nil", v, err)
+	}
+	if v, err := m.LoadOrCallErr(func() (interface{}, error) {
+		return 2, nil
+	}, "a", "b"); err != nil || v != 1 {
+		t.Errorf("LoadOrCallErr() = %v, %v, want cached 1, nil", v, err)
+	}
+}
+
+// countingCache is a plainCache that counts the calls of LoadOrCall.
+type countingCache struct {
+	plainCache
+	calls *int32
+}
+
+func (c countingCache) LoadOrCall(key interface{}, getValue func() interface{}) interface{} {
+	atomic.AddInt32(c.calls, 1)
+	return c.plainCache.LoadOrCall(key, getValue)
+}
+
+func TestMultiLevelMap_LoadOrCallErrPlainCacheWaiters(t *testing.T) {
+	var calls int32
+	m := NewMultiLevelMap(func() CacheInterface {
+		return countingCache{plainCache{NewCache(&sync.Map{})}, &calls}
+	})
+
+	wantErr := errors.New("failed")
+	release := make(chan struct{})
+	leader := make(chan error)
+	go func() {
+		_, err := m.LoadOrCallErr(func() (interface{}, error) {
+			<-release
+			return nil, wantErr
+		}, "a")
+		leader <- err
+	}()
+	waitFor(func() bool { return atomic.LoadInt32(&calls) == 1 })
+	waiter := make(chan error)
+	go func() {
+		v, err := m.LoadOrCallErr(func() (interface{}, error) {
+			// Called only if the failure was already deleted.
+			return nil, wantErr
+		}, "a")
+		if v != nil {
+			t.Errorf("LoadOrCallErr() = %v, want nil", v)
+		}
+		waiter <- err
+	}()
+	waitFor(func() bool { return atomic.LoadInt32(&calls) == 2 })
+	close(release)
+	if err := <-leader; err != wantErr {
+		t.Errorf("leader error = %v, want %v", err, wantErr)
+	}
+	if err := <-waiter; err != wantErr {
+		t.Errorf("waiter error = %v, want %v", err, wantErr)
+	}
+	if v, ok := m.Load("a"); ok {
+		t.Errorf("Load() = %v, want no value", v)
+	}
+}
+
+// waitingContext is a context signaling each call of Done, which a caller
+// makes right before it waits for the call of another goroutine.
+type waitingContext struct {
+	context.Context
+	waiting chan<- struct{}
+}
+
+func (c waitingContext) Done() <-chan struct{} {
+	c.waiting <- struct{}{}
+	return nil
+}
+
+func TestLoadOrCallCtx_SharedFailure(t *testing.T) {
+	const numWaiters = 9
+	for name, c := range map[string]interface {
+		LoadOrCallCtx(ctx context.Context, key interface{}, getValue func(ctx context.Context) (interface{}, error)) (interface{}, error)
+	}{
+		"Cache":   NewCache(&sync.Map{}),
+		"Map":     &Map{},
+		"RRCache": NewRRCacheLocal(10, 5, nil),
+	} {
+		var calls atomic.Int32
+		wantErr := errors.New("failed")
+		started := make(chan struct{})
+		release := make(chan struct{})
+		load := func(ctx context.Context) (interface{}, error) {
+			if calls.Add(1) == 1 {
+				close(started)
+				<-release
+			}
+			return nil, wantErr
+		}
+		errs := make(chan error, numWaiters+1)
+		go func() {
+			_, err := c.LoadOrCallCtx(context.Background(), "k", load)
+			errs <- err
+		}()
+		<-started
+		waiting := make(chan struct{}, numWaiters)
+		for i := 0; i < numWaiters; i++ {
+			go func() {
+				_, err := c.LoadOrCallCtx(waitingContext{context.Background(), waiting}, "k", load)
+				errs <- err
+			}()
+		}
+		for i := 0; i < numWaiters; i++ {
+			<-waiting
+		}
+		close(release)
+		for i := 0; i < numWaiters+1; i++ {
+			if err := <-errs; err != wantErr {
+				t.Errorf("%s: LoadOrCallCtx() error = %v, want %v", name, err, wantErr)
+			}
+		}
+		if n := calls.Load(); n != 1 {
+			t.Errorf("%s: loader called %d times, want once for the leader and its waiters", name, n)
+		}
+	}
+}
+
+// replacingLeaf stores "replaced" for the key after each call of getValue as
+// if another goroutine stored a value right after the call.
+type replacingLeaf struct {
+	m *sync.Map
+}
+
+func (r replacingLeaf) LoadOrCall(key interface{}, getValue func() interface{}) interface{} {
+	value := getValue()
+	r.m.Store(key, "replaced")
+	return value
+}
+
+func (r replacingLeaf) Load(key interface{}) (interface{}, bool) {
+	return r.m.Load(key)
+}
+
+func (r replacingLeaf) Delete(key interface{}) {
+	r.m.Delete(key)
+}
+
+func TestMultiLevelMap_LoadOrCallErrKeepsReplacedValue(t *testing.T) {
+	m := NewMultiLevelMap(func() CacheInterface {
+		return replacingLeaf{&sync.Map{}}
+	})
+
+	wantErr := errors.New("failed")
+	if _, err := m.LoadOrCallErr(func() (interface{}, error) {
+		return nil, wantErr
+	}, "a"); err != wantErr {
+		t.Errorf("LoadOrCallErr() error = %v, want %v", err, wantErr)
+	}
+	if v, ok := m.Load("a"); !ok || v != "replaced" {
+		t.Errorf("Load() = %v, %v, want replaced, true", v, ok)
+	}
+}
+
+func ExampleMultiLevelMap_StorePath() {
+	var m MultiLevelMap
+
+	// Warm the cache, e.g. from a batch job.
+	m.StorePath("alice", "users", 1)
+	fmt.Println(m.LoadOrCall(func() interface{} { return "loaded" }, "users", 1))
+
+	m.StorePath("alice2", "users", 1)
+	fmt.Println(m.LoadOrCall(func() interface{} { return "loaded" }, "users", 1))
+	// Output:
+	// alice
+	// alice2
+}
+
+func TestMultiLevelMap_StorePathPlainCache(t *testing.T) {
+	m := NewMultiLevelMap(func() CacheInterface {
+		return plainCache{NewCache(&sync.Map{})}
+	})
+
+	m.StorePath(1, "a", "b")
+	m.StorePath(2, "a", "b")
+	if v := m.LoadOrCall(func() interface{} { return 3 }, "a", "b"); v != 2 {
+		t.Errorf("LoadOrCall() = %v, want stored 2", v)
+	}
+}
+
+func ExampleMultiLevelMap_Load() {
+	var m MultiLevelMap
+	m.LoadOrCall(func() interface{} { return "alice" }, "users", 1)
+
+	fmt.Println(m.Load("users", 1))
+	fmt.Println(m.Load("users", 2))
+	fmt.Println(m.Load("groups", 1))
+	// Output:
+	// alice true
+	// <nil> false
+	// <nil> false
+}
+
+func TestCache_PeekKeepsRecency(t *testing.T) {
+	c := NewCache(NewLRUMap(list.New(), 2))
+	c.Store("a", 1)
+	c.Store("b", 2)
+	if v, ok := c.Peek("a"); !ok || v != 1 {
+		t.Errorf("Peek(a) = %v, %v, want 1, true", v, ok)
+	}
+	c.Store("c", 3)
+	if _, ok := c.Load("a"); ok {
+		t.Error("Peek(a) marked a as recently used, so b was evicted instead")
+	}
+
+	m := NewMultiLevelMap(func() CacheInterface {
+		return NewCache(NewLRUMap(list.New(), 2))
+	})
+	m.StorePath(1, "x", "a")
+	m.StorePath(2, "x", "b")
+	if v, ok := m.Peek("x", "a"); !ok || v != 1 {
+		t.Errorf("MultiLevelMap.Peek(x, a) = %v, %v, want 1, true", v, ok)
+	}
+	m.StorePath(3, "x", "c")
+	if _, ok := m.Load("x", "a"); ok {
+		t.Error("MultiLevelMap.Peek(x, a) marked the path as recently used")
+	}
+}
+
+func ExampleMultiLevelMap_Size() {
+	m := NewMultiLevelMap(func() CacheInterface {
+		return NewCache(NewLRUMap(list.New(), 100))
+	})
+	m.LoadOrCall(func() interface{} { return "alice" }, "users", 1)
+	m.LoadOrCall(func() interface{} { return "bob" }, "users", 2)
+	m.LoadOrCall(func() interface{} { return "admins" }, "groups", 1)
+	fmt.Println(m.Size())
+	// Output:
+	// 3
+}
+
+func TestCapAndLen(t *testing.T) {
+	var currentSize int32
+	lru := NewCache(NewLRUMap(list.New(), 10))
+	rr := NewRRCache(&currentSize, 10, 5, rand.Intn)
+	other := NewRRCache(&currentSize, 10, 5, rand.Intn)
+	unbounded := NewCache(&sync.Map{})
+	for i := 0; i < 3; i++ {
+		lru.Store(i, i)
+		rr.Store(i, i)
+		unbounded.Store(i, i)
+	}
+	other.Store("x", 1)
+
+	for _, tc := range []struct {
+		name string
+		got  int
+		want int
+	}{
+		{"LRU Cap", lru.Cap(), 10},
+		{"LRU Len", lru.Len(), 3},
+		{"RR Cap", rr.Cap(), 10},
+		{"RR Len", rr.Len(), 3},
+		{"RR Size", rr.Size(), 4},
+		{"unbounded Cap", unbounded.Cap(), 0},
+		{"unbounded Len", unbounded.Len(), 3},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("%s = %d, want %d", tc.name, tc.got, tc.want)
+		}
+	}
+
+	var m MultiLevelMap
+	if n := m.Size(); n != 0 {
+		t.Errorf("Size() = %d for an empty map, want 0", n)
+	}
+	m.LoadOrCall(func() interface{} { return 1 }, "a", "b", "c")
+	m.LoadOrCall(func() interface{} { return 2 }, "a", "d")
+	if n := m.Size(); n != 2 {
+		t.Errorf("Size() = %d, want 2", n)
+	}
+	// A leaf value that is a cache but not a level of the map is one value.
+	m.LoadOrCall(func() interface{} { return lru }, "a", "e")
+	if n := m.Size(); n != 3 {
+		t.Errorf("Size() = %d with a cache as a value, want 3", n)
+	}
+	if n := m.SubtreeLen("a", "e"); n != 1 {
+		t.Errorf("SubtreeLen(a, e) = %d for a cache as a value, want 1", n)
+	}
+}
+
+func ExampleMultiLevelMap_Walk() {
+	var m MultiLevelMap
+	m.LoadOrCall(func() interface{} { return "alice" }, "users", 1)
+	m.LoadOrCall(func() interface{} { return "bob" }, "users", 2)
+	m.LoadOrCall(func() interface{} { return "admins" }, "groups", 1)
+
+	var entries []string
+	m.Walk(func(path []interface{}, value interface{}) bool {
+		entries = append(entries, fmt.Sprint(path, "=", value))
+		return true
+	})
+	sort.Strings(entries)
+	fmt.Println(strings.Join(entries, "\n"))
+	// Output:
+	// [groups 1]=admins
+	// [users 1]=alice
+	// [users 2]=bob
+}
+
+func TestMultiLevelMap_WalkStops(t *testing.T) {
+	var m MultiLevelMap
+	for i := 0; i < 10; i++ {
+		m.LoadOrCall(func() interface{} { return i }, i%3, i)
+	}
+	var paths [][]interface{}
+	m.Walk(func(path []interface{}, value interface{}) bool {
+		paths = append(paths, path)
+		return len(paths) < 4
+	})
+	if len(paths) != 4 {
+		t.Fatalf("Walk() visited %d leaves, want 4", len(paths))
+	}
+	seen := map[string]bool{}
+	for _, path := range paths {
+		if seen[fmt.Sprint(path)] {
+			t.Errorf("Walk() visited %v twice or reused the path", path)
+		}
+		seen[fmt.Sprint(path)] = true
+	}
+}
+
+func TestMultiLevelMap_Clear(t *testing.T) {
+	var currentSize int32
+	rr := NewMultiLevelMap(func() CacheInterface {
+		return NewRRCache(&currentSize, 100, 50, rand.Intn)
+	})
+	lru := NewMultiLevelMap(func() CacheInterface {
+		return NewCache(NewLRUMap(list.New(), 100))
+	})
+	var plain MultiLevelMap
+	for name, m := range map[string]*MultiLevelMap{"RRCache": rr, "LRUMap": lru, "Map": &plain} {
+		t.Run(name, func(t *testing.T) {
+			m.Clear()
+			for i := 0; i < 5; i++ {
+				m.LoadOrCall(func() interface{} { return i }, "a", i)
+				m.LoadOrCall(func() interface{} { return i }, "b", "c", i)
+			}
+			m.Clear()
+			if n := m.Size(); n != 0 {
+				t.Errorf("Size() = %d after Clear(), want 0", n)
+			}
+			if got := m.LoadOrCall(func() interface{} { return "new" }, "a", 1); got != "new" {
+				t.Errorf("LoadOrCall() = %v after Clear(), want new", got)
+			}
+		})
+	}
+	// Only the value loaded after the Clear should remain counted.
+	if n := atomic.LoadInt32(&currentSize); n != 2 {
+		t.Errorf("shared RRCache size = %d after Clear(), want 2 for a and 1", n)
+	}
+}
+
+func TestMultiLevelMap_PruneAll(t *testing.T) {
+	var currentSize int32
+	m := NewMultiLevelMap(func() CacheInterface {
+		return NewRRCache(&currentSize, 100, 50, rand.Intn)
+	})
+	m.Prune()
+	for i := 0; i < 5; i++ {
+		m.LoadOrCall(func() interface{} { return i }, "a", i)
+	}
+	m.Prune()
+	if n := m.Size(); n != 0 {
+		t.Errorf("Size() = %d after Prune(), want 0", n)
+	}
+	if n := atomic.LoadInt32(&currentSize); n != 0 {
+		t.Errorf("shared RRCache size = %d after Prune(), want 0", n)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			m.LoadOrCall(func() interface{} { return i }, "b", i)
+		}(i)
+		go func() {
+			defer wg.Done()
+			m.Prune()
+		}()
+	}
+	wg.Wait()
+	if got := m.LoadOrCall(func() interface{} { return "new" }, "c"); got != "new" {
+		t.Errorf("LoadOrCall() = %v after concurrent Prune(), want new", got)
+	}
+}
+
+func BenchmarkCache_LoadOrCall(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		m    MapInterface
+	}{
+		{"SyncMap", &sync.Map{}},
+		{"LRUMap", NewLRUMap(list.New(), 1000)},
+	} {
+		c := NewCache(bc.m)
+		for i := 0; i < 100; i++ {
+			c.LoadOrCall(i, func() interface{} { return i })
+		}
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					c.LoadOrCall(i%100, nil)
+					i++
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkMultiLevelMap_LoadOrCall(b *testing.B) {
+	for _, bc := range []struct {
+		name   string
+		newMap func() CacheInterface
+	}{
+		{"Map", nil},
+		{"LRUCache", func() CacheInterface {
+			return NewCache(NewLRUMap(list.New(), 1000))
+		}},
+	} {
+		m := NewMultiLevelMap(bc.newMap)
+		m.LoadOrCall(func() interface{} { return 1 }, "a", "b", "c", "d", "e")
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				m.LoadOrCall(nil, "a", "b", "c", "d", "e")
+			}
+		})
+	}
+}
+
+func TestNewMultiLevelMapByLevel(t *testing.T) {
+	var levels []int
+	m := NewMultiLevelMapByLevel(func(level int) CacheInterface {
+		levels = append(levels, level)
+		if level == 0 {
+			return NewCache(&sync.Map{})
+		}
+		return NewCache(NewLRUMap(list.New(), 1))
+	})
+	m.LoadOrCall(func() interface{} { return 1 }, "tenant", "a")
+	m.LoadOrCall(func() interface{} { return 2 }, "tenant", "b")
+	m.LoadOrCall(func() interface{} { return 3 }, "other", "c", "d")
+	if want := []int{0, 1, 1, 2}; !reflect.DeepEqual(levels, want) {
+		t.Errorf("levels made = %v, want %v", levels, want)
+	}
+	if _, ok := m.Load("tenant", "a"); ok {
+		t.Error("the bounded leaf level kept a, want it evicted by b")
+	}
+	if v, ok := m.Load("tenant", "b"); !ok || v != 2 {
+		t.Errorf("Load(tenant, b) = %v, %v, want 2, true", v, ok)
+	}
+}
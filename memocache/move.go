@@ -0,0 +1,29 @@
+package memocache
+
+// Move moves the value or the subtree in oldPath to newPath without calling
+// any loader, e.g. to rename a session whose values are expensive to load
+// again. What's in newPath is replaced. The subtree is stored in newPath
+// before it's removed from oldPath, so concurrent readers find it in one of
+// the paths, or in both for a moment, but never in neither. A value still
+// being loaded in oldPath isn't moved. It returns false if oldPath has nothing
+// to move. The caches of the subtree are moved as they are, so with
+// NewMultiLevelMapByLevel the paths should be of the same length. Move panics
+// if newPath is in the subtree of oldPath.
+func (m *MultiLevelMap) Move(oldPath, newPath []interface{}) bool {
+	if len(oldPath) == 0 || len(newPath) == 0 {
+		panic("path was not given")
+	}
+	oldPath, newPath = m.canonical(oldPath), m.canonical(newPath)
+	if hasPathPrefix(newPath, oldPath) {
+		panic("memocache: can't move a path into its own subtree")
+	}
+	node, ok := m.load(false, oldPath...)
+	if !ok {
+		return false
+	}
+	m.StorePath(node, newPath...)
+	m.prune(oldPath)
+	m.bus.publish(oldPath...)
+	m.bus.publish(newPath...)
+	return true
+}
@@ -0,0 +1,45 @@
+package memocache
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestMultiLevelMapMove(t *testing.T) {
+	m := NewMultiLevelMap(func() CacheInterface { return NewCache(&sync.Map{}) })
+	calls := 0
+	load := func() interface{} { calls++; return calls }
+	m.LoadOrCall(load, "sessions", "old", "a")
+	m.LoadOrCall(load, "sessions", "old", "b")
+	m.LoadOrCall(load, "sessions", "new", "stale")
+
+	if !m.Move(P("sessions", "old"), P("sessions", "new")) {
+		t.Fatal("Move() = false, want true")
+	}
+	for key, want := range map[string]interface{}{"a": 1, "b": 2} {
+		if v := m.LoadOrCall(load, "sessions", "new", key); v != want {
+			t.Errorf("LoadOrCall(sessions, new, %s) = %v, want %v", key, v, want)
+		}
+	}
+	if _, ok := m.Load("sessions", "new", "stale"); ok {
+		t.Error("the subtree replaced in newPath is still there")
+	}
+	if _, ok := m.Load("sessions", "old", "a"); ok {
+		t.Error("the subtree is still in oldPath")
+	}
+	if calls != 3 {
+		t.Errorf("getValue was called %d times, want 3", calls)
+	}
+
+	// A leaf moves alike.
+	if !m.Move(P("sessions", "new", "a"), P("archive", "a")) {
+		t.Error("Move() of a leaf = false, want true")
+	}
+	if v, ok := m.Load("archive", "a"); !ok || v != 1 {
+		t.Errorf("Load(archive, a) = %v, %v, want 1, true", v, ok)
+	}
+	if m.Move(P("missing"), P("elsewhere")) {
+		t.Error("Move() of a missing path = true, want false")
+	}
+	mustPanic(t, func() { m.Move(P("sessions"), P("sessions", "new")) })
+}
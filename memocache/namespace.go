@@ -0,0 +1,98 @@
+package memocache
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Namespace is a MultiLevelMap whose paths are kept under a generation number,
+// so that Invalidate makes all the values unreachable at once by bumping the
+// generation instead of walking and deleting them, e.g. to flush everything on
+// a deploy. Namespace should be created with NewNamespace.
+type Namespace struct {
+	m   *MultiLevelMap
+	gen atomic.Uint64
+
+	pruneMu sync.Mutex
+	live    uint64 // Lowest generation not pruned since it was invalidated, guarded by pruneMu
+}
+
+// NewNamespace returns a new Namespace whose levels are created by newMap. See
+// NewMultiLevelMap.
+func NewNamespace(newMap func() CacheInterface, opts ...Option) *Namespace {
+	return &Namespace{m: NewMultiLevelMap(newMap, opts...)}
+}
+
+// Generation returns the current generation, which starts at zero and is
+// incremented by every Invalidate.
+func (n *Namespace) Generation() uint64 {
+	return n.gen.Load()
+}
+
+// Invalidate makes all the values unreachable immediately by starting a new
+// generation. The values of the previous generations are pruned in the
+// background. Loads of them still in flight store their values there, where
+// they are never seen by later calls, and they are pruned by the next
+// Invalidate.
+func (n *Namespace) Invalidate() {
+	old := n.gen.Add(1) - 1
+	go n.prune(old)
+}
+
+// prune prunes the generations up to old that are left since the last prune,
+// including the last one pruned for the loads that were in flight then.
+func (n *Namespace) prune(old uint64) {
+	n.pruneMu.Lock()
+	defer n.pruneMu.Unlock()
+	for gen := n.live; gen <= old; gen++ {
+		n.m.Prune(gen)
+	}
+	if old > n.live {
+		n.live = old
+	}
+}
+
+// path returns the path of the current generation.
+func (n *Namespace) path(path []interface{}) []interface{} {
+	if len(path) == 0 {
+		panic("path was not given")
+	}
+	return append([]interface{}{n.gen.Load()}, path...)
+}
+
+// LoadOrCall loads the value in path of the current generation, calling
+// getValue only once if it doesn't exist. See MultiLevelMap.LoadOrCall.
+func (n *Namespace) LoadOrCall(getValue func() interface{}, path ...interface{}) interface{} {
+	return n.m.LoadOrCall(getValue, n.path(path)...)
+}
+
+// LoadOrCallErr is like LoadOrCall but getValue may fail. See
+// MultiLevelMap.LoadOrCallErr.
+func (n *Namespace) LoadOrCallErr(getValue func() (interface{}, error), path ...interface{}) (interface{}, error) {
+	return n.m.LoadOrCallErr(getValue, n.path(path)...)
+}
+
+// Load returns the value in path of the current generation if it's ready.
+func (n *Namespace) Load(path ...interface{}) (value interface{}, ok bool) {
+	return n.m.Load(n.path(path)...)
+}
+
+// StorePath sets the value in path of the current generation.
+func (n *Namespace) StorePath(value interface{}, path ...interface{}) {
+	n.m.StorePath(value, n.path(path)...)
+}
+
+// Prune removes a subtree of the path of the current generation. Without a
+// path, it's the same as Invalidate.
+func (n *Namespace) Prune(path ...interface{}) {
+	if len(path) == 0 {
+		n.Invalidate()
+		return
+	}
+	n.m.Prune(n.path(path)...)
+}
+
+// Stats returns a snapshot of the statistics of the underlying MultiLevelMap.
+func (n *Namespace) Stats() Stats {
+	return n.m.Stats()
+}
@@ -0,0 +1,54 @@
+package memocache
+
+import (
+	"fmt"
+	"testing"
+)
+
+func ExampleNamespace() {
+	n := NewNamespace(nil)
+	n.LoadOrCall(func() interface{} { return "v1" }, "config", "flags")
+	n.Invalidate()
+	fmt.Println(n.LoadOrCall(func() interface{} { return "v2" }, "config", "flags"))
+	// Output: v2
+}
+
+func TestNamespace(t *testing.T) {
+	n := NewNamespace(nil)
+	n.LoadOrCall(func() interface{} { return 1 }, "a", "b")
+	n.StorePath(2, "a", "c")
+	if v, ok := n.Load("a", "c"); !ok || v != 2 {
+		t.Errorf("Load(a, c) = %v, %v, want 2, true", v, ok)
+	}
+	n.Invalidate()
+	if g := n.Generation(); g != 1 {
+		t.Errorf("Generation() = %d, want 1", g)
+	}
+	if _, ok := n.Load("a", "b"); ok {
+		t.Error("value of the previous generation is reachable")
+	}
+	if got := n.LoadOrCall(func() interface{} { return 3 }, "a", "b"); got != 3 {
+		t.Errorf("LoadOrCall(a, b) = %v, want 3", got)
+	}
+	if !waitFor(func() bool { _, ok := n.m.Load(uint64(0), "a", "b"); return !ok }) {
+		t.Error("the previous generation wasn't pruned")
+	}
+}
+
+func TestNamespace_InvalidateConcurrently(t *testing.T) {
+	n := NewNamespace(nil)
+	for i := 0; i < 10; i++ {
+		n.StorePath(i, "a")
+		n.Invalidate()
+	}
+	if !waitFor(func() bool {
+		for gen := uint64(0); gen < 10; gen++ {
+			if _, ok := n.m.Load(gen, "a"); ok {
+				return false
+			}
+		}
+		return true
+	}) {
+		t.Error("some previous generations weren't pruned")
+	}
+}
@@ -0,0 +1,87 @@
+package memocache
+
+import "time"
+
+// Observer is notified of the calls, the loads and the removals of a Cache or
+// a RRCache, e.g. to log them with log/slog, sampled to keep the volume low.
+// The methods are called synchronously from the goroutines making the calls
+// and should be fast and safe for concurrent use. Embed NopObserver to
+// implement only some of them.
+type Observer interface {
+	// OnHit is called with the key of a call served without calling a
+	// loader, including a call that waited for another goroutine's load.
+	OnHit(key interface{})
+	// OnMiss is called with the key of a call that called a loader, after
+	// the load.
+	OnMiss(key interface{})
+	// OnLoadStart is called with the key when a load starts.
+	OnLoadStart(key interface{})
+	// OnLoadEnd is called with the key, the duration and the error of a load
+	// when it ends. It's not called if the loader panics.
+	OnLoadEnd(key interface{}, d time.Duration, err error)
+	// OnEvict is called with a removed ready value like the function of
+	// WithOnEvict is.
+	OnEvict(key, value interface{}, reason EvictionReason)
+}
+
+// NopObserver is an Observer that does nothing.
+type NopObserver struct{}
+
+var _ Observer = NopObserver{}
+
+// OnHit does nothing.
+func (NopObserver) OnHit(key interface{}) {}
+
+// OnMiss does nothing.
+func (NopObserver) OnMiss(key interface{}) {}
+
+// OnLoadStart does nothing.
+func (NopObserver) OnLoadStart(key interface{}) {}
+
+// OnLoadEnd does nothing.
+func (NopObserver) OnLoadEnd(key interface{}, d time.Duration, err error) {}
+
+// OnEvict does nothing.
+func (NopObserver) OnEvict(key, value interface{}, reason EvictionReason) {}
+
+// WithObserver sets the observer of a Cache or a RRCache. It works alongside
+// WithLoadHook and WithOnEvict rather than replacing them.
+func WithObserver(o Observer) Option {
+	return func(c *config) {
+		c.observer = o
+	}
+}
+
+// observe notifies the observer if any of a call of the key.
+func (c *config) observe(hit bool, key interface{}) {
+	if c.observer == nil {
+		return
+	}
+	if hit {
+		c.observer.OnHit(key)
+	} else {
+		c.observer.OnMiss(key)
+	}
+}
+
+// observeLoader returns getValue notifying the observer of its start and end.
+func (c *config) observeLoader(key interface{}, getValue func() (interface{}, error)) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		c.observer.OnLoadStart(key)
+		start := now(c.clock)
+		value, err := getValue()
+		c.observer.OnLoadEnd(key, now(c.clock).Sub(start), err)
+		return value, err
+	}
+}
+
+// observeEvictions makes the listener of the removals notify the observer too.
+func (c *config) observeEvictions() {
+	onEvict := c.onEvict
+	c.onEvict = func(key, value interface{}, reason EvictionReason) {
+		if onEvict != nil {
+			onEvict(key, value, reason)
+		}
+		c.observer.OnEvict(key, value, reason)
+	}
+}
@@ -0,0 +1,114 @@
+package memocache
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// logObserver logs the notifications of an Observer.
+type logObserver struct {
+	mu  sync.Mutex
+	log []string
+}
+
+func (o *logObserver) add(format string, args ...interface{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.log = append(o.log, fmt.Sprintf(format, args...))
+}
+
+func (o *logObserver) OnHit(key interface{})       { o.add("hit %v", key) }
+func (o *logObserver) OnMiss(key interface{})      { o.add("miss %v", key) }
+func (o *logObserver) OnLoadStart(key interface{}) { o.add("start %v", key) }
+
+func (o *logObserver) OnLoadEnd(key interface{}, d time.Duration, err error) {
+	o.add("end %v %v %v", key, d, err)
+}
+
+func (o *logObserver) OnEvict(key, value interface{}, reason EvictionReason) {
+	o.add("evict %v %v %v", key, value, reason)
+}
+
+func TestWithObserver(t *testing.T) {
+	clock := newFakeClock()
+	o := &logObserver{}
+	var evicted []interface{}
+	c := NewCache(&sync.Map{}, WithClock(clock), WithObserver(o), WithOnEvict(func(key, value interface{}, reason EvictionReason) {
+		evicted = append(evicted, key)
+	}))
+
+	c.LoadOrCall("a", func() interface{} {
+		clock.Advance(time.Second)
+		return 1
+	})
+	c.LoadOrCall("a", func() interface{} { return 2 })
+	c.LoadOrCallErr("b", func() (interface{}, error) { return nil, errors.New("fail") })
+	c.Delete("a")
+
+	want := []string{
+		"start a", "end a 1s <nil>", "miss a",
+		"hit a",
+		"start b", "end b 0s fail", "miss b",
+		"evict a 1 deleted",
+	}
+	if fmt.Sprint(o.log) != fmt.Sprint(want) {
+		t.Errorf("notifications = %q, want %q", o.log, want)
+	}
+	if len(evicted) != 1 {
+		t.Errorf("WithOnEvict got %v, want a", evicted)
+	}
+}
+
+func TestWithObserver_RRCache(t *testing.T) {
+	o := &logObserver{}
+	c := NewRRCache(new(int32), 10, 5, rand.Intn, WithClock(newFakeClock()), WithObserver(o))
+	c.LoadOrCall("a", func() interface{} { return 1 })
+	c.LoadOrCall("a", func() interface{} { return 1 })
+
+	want := []string{"start a", "end a 0s <nil>", "miss a", "hit a"}
+	if fmt.Sprint(o.log) != fmt.Sprint(want) {
+		t.Errorf("notifications = %q, want %q", o.log, want)
+	}
+}
+
+func TestWithObserver_Builder(t *testing.T) {
+	for _, policy := range []Policy{PolicyUnbounded, PolicyLRU, PolicyRandom, PolicyFIFO} {
+		t.Run(string(policy), func(t *testing.T) {
+			o := &logObserver{}
+			opts := []Option{WithObserver(o), WithEvictionPolicy(policy)}
+			if policy != PolicyUnbounded {
+				opts = append(opts, WithMaxSize(10))
+			}
+			c := New(opts...)
+			c.Store("a", 1)
+			c.Delete("a")
+
+			want := []string{"evict a 1 deleted"}
+			if fmt.Sprint(o.log) != fmt.Sprint(want) {
+				t.Errorf("notifications = %q, want %q", o.log, want)
+			}
+		})
+	}
+}
+
+func TestNopObserver(t *testing.T) {
+	var hits int
+	c := NewCache(&sync.Map{}, WithObserver(hitCounter{count: &hits}))
+	c.LoadOrCall("a", func() interface{} { return 1 })
+	c.LoadOrCall("a", func() interface{} { return 1 })
+	if hits != 1 {
+		t.Errorf("hits = %d, want 1", hits)
+	}
+}
+
+// hitCounter is an Observer counting only the hits.
+type hitCounter struct {
+	NopObserver
+	count *int
+}
+
+func (h hitCounter) OnHit(key interface{}) { *h.count++ }
@@ -0,0 +1,145 @@
+package memocache
+
+import (
+	"context"
+	"time"
+)
+
+// Option configures optional behavior of a cache. Options are passed to the
+// constructors such as NewCache and NewRRCache.
+type Option func(*config)
+
+// config holds the optional settings shared by the caches in this package.
+type config struct {
+	onLoadStart  func(key interface{})
+	onLoadFinish func(key interface{})
+
+	latencyBudget   time.Duration
+	onLatencyChange func(LatencyEvent)
+
+	readYourWrites       bool
+	invalidationVersions bool
+
+	ttl       time.Duration
+	ttlJitter float64
+
+	loader Loader
+	retry  *RetryPolicy
+
+	leaseDuration time.Duration
+
+	serveStale bool
+	maxStale   time.Duration
+	onStale    func(key interface{}, err error)
+
+	loadLimiter     loadLimiter // Shared by the caches given the same option
+	prefixLoadDepth int         // Used by MultiLevelMap
+	prefixLoadLimit int         // Used by MultiLevelMap
+
+	writeStore    Store
+	writeInterval time.Duration // Zero for write-through
+	onWriteError  func(key interface{}, err error)
+
+	waitTimeout  time.Duration
+	waitFallback func(key interface{}) (interface{}, error)
+
+	asyncEviction bool
+	deterministic bool  // Set by WithEvictionSeed
+	evictionSeed  int64 // Used if deterministic
+
+	sizer       func(value interface{}) int64
+	trackAccess bool
+
+	recorder func(op Op)
+	observer Observer
+
+	onEvict        func(key, value interface{}, reason EvictionReason)
+	onEvictedEntry func(reason EvictionReason, hits uint64) // Set by NewCache
+
+	reportBucket  time.Duration
+	reportBuckets int
+
+	hotKeys       int
+	hotKeysWindow time.Duration
+
+	bus        InvalidationBus
+	onBusError func(err error)
+
+	clock Clock // Nil for the real clock
+
+	canonicalize func(key interface{}) interface{} // Used by MultiLevelMap
+	subtreeDepth int                               // Used by MultiLevelMap
+
+	policy  Policy // Used by New and Builder
+	maxSize int    // Used by New and Builder
+}
+
+// newConfig returns the config of a new cache with the given options applied.
+// The listener of the removals is wrapped to notify the observer, so it should
+// be called once per cache.
+func newConfig(opts []Option) config {
+	c := applyOptions(opts)
+	if c.observer != nil {
+		c.observeEvictions()
+	}
+	return c
+}
+
+// applyOptions returns a config with the given options applied, e.g. for a
+// Builder to pass to the caches it makes.
+func applyOptions(opts []Option) config {
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// WithLoadHook registers functions that are called with the key when a load
+// for the key starts and when it finishes. They are called from the goroutine
+// running the loader, so applications can coordinate with external systems,
+// e.g. mark a row as being materialized in a database, without wrapping every
+// loader themselves. The finish function is called even if the loader panics.
+// Either function may be nil.
+func WithLoadHook(start, finish func(key interface{})) Option {
+	return func(c *config) {
+		c.onLoadStart = start
+		c.onLoadFinish = finish
+	}
+}
+
+// WithReadYourWrites guarantees that a Store or Delete followed by a
+// LoadOrCall of the same key in the same goroutine observes the write, even
+// while a competing load of the key is in flight. Entries are versioned by the
+// writes, so an entry older than a write is never put back in place of it, and
+// Delete always drops the entry instead of keeping it as stale in the
+// degraded mode of WithLatencyBudget. For a MultiLevelMap, give the option to
+// the caches of all levels to make Prune take effect immediately for the
+// pruning goroutine.
+func WithReadYourWrites() Option {
+	return func(c *config) {
+		c.readYourWrites = true
+	}
+}
+
+// wrapLoader returns getValue decorated with the configured hooks for the key.
+// The retries wait until ctx is done, out of the slot of the limiter.
+func (c *config) wrapLoader(ctx context.Context, key interface{}, getValue func() (interface{}, error)) func() (interface{}, error) {
+	getValue = c.retry.wrap(ctx, c.clock, c.loadLimiter.wrap(getValue))
+	if c.observer != nil {
+		getValue = c.observeLoader(key, getValue)
+	}
+	if c.onLoadStart != nil || c.onLoadFinish != nil {
+		hooked := getValue
+		getValue = func() (interface{}, error) {
+			if c.onLoadStart != nil {
+				c.onLoadStart(key)
+			}
+			if c.onLoadFinish != nil {
+				defer c.onLoadFinish(key)
+			}
+			return hooked()
+		}
+	}
+	return getValue
+}
@@ -0,0 +1,132 @@
+package memocache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func ExampleWithLoadHook() {
+	m := NewCache(&sync.Map{}, WithLoadHook(
+		func(key interface{}) { fmt.Printf("materializing %v\n", key) },
+		func(key interface{}) { fmt.Printf("materialized %v\n", key) },
+	))
+
+	fmt.Println(m.LoadOrCall("row-1", func() interface{} { return "one" }))
+	fmt.Println(m.LoadOrCall("row-1", func() interface{} { return "not one" }))
+	// Output:
+	// materializing row-1
+	// materialized row-1
+	// one
+	// one
+}
+
+// minimalMap is a MapInterface without any optional methods.
+type minimalMap struct {
+	m sync.Map
+}
+
+func (m *minimalMap) LoadOrStore(key, value interface{}) (interface{}, bool) {
+	return m.m.LoadOrStore(key, value)
+}
+
+func (m *minimalMap) Delete(key interface{}) {
+	m.m.Delete(key)
+}
+
+func TestWithReadYourWrites(t *testing.T) {
+	c := NewCache(&minimalMap{}, WithReadYourWrites())
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.LoadOrCall("k", func() interface{} {
+			close(started)
+			<-release
+			return "loaded"
+		})
+	}()
+	<-started
+
+	c.Store("k", "stored")
+	if got := c.LoadOrCall("k", func() interface{} { return "reloaded" }); got != "stored" {
+		t.Errorf("LoadOrCall() after Store() = %v, want stored", got)
+	}
+	close(release)
+	<-done
+	if got := c.LoadOrCall("k", func() interface{} { return "reloaded" }); got != "stored" {
+		t.Errorf("LoadOrCall() after the competing load = %v, want stored", got)
+	}
+
+	c.Delete("k")
+	if got := c.LoadOrCall("k", func() interface{} { return "reloaded" }); got != "reloaded" {
+		t.Errorf("LoadOrCall() after Delete() = %v, want reloaded", got)
+	}
+}
+
+func TestWithReadYourWrites_degraded(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCache(&sync.Map{}, WithClock(clock), WithReadYourWrites(), WithLatencyBudget(time.Nanosecond, nil))
+	for i := 0; i < minLatencySamples; i++ {
+		c.LoadOrCall(i, func() interface{} {
+			clock.Advance(time.Microsecond)
+			return i
+		})
+	}
+	if !c.latency.isDegraded() {
+		t.Fatal("cache is not in the degraded mode")
+	}
+
+	c.Delete(0)
+	if got := c.LoadOrCall(0, func() interface{} { return "reloaded" }); got != "reloaded" {
+		t.Errorf("LoadOrCall() after Delete() = %v, want reloaded", got)
+	}
+}
+
+func TestWithInvalidationVersions(t *testing.T) {
+	m := &minimalMap{}
+	clock := newFakeClock()
+	c := NewCache(m, WithClock(clock), WithInvalidationVersions(), WithTTL(time.Millisecond), WithLatencyBudget(time.Nanosecond, nil))
+	for i := 0; i < minLatencySamples; i++ {
+		c.LoadOrCall(i, func() interface{} {
+			clock.Advance(time.Microsecond)
+			return i
+		})
+	}
+	if !c.latency.isDegraded() {
+		t.Fatal("cache is not in the degraded mode")
+	}
+	c.LoadOrCall("k", func() interface{} { return "old" })
+	clock.Advance(2 * time.Millisecond)
+	old, _ := m.m.Load("k")
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	finished := make(chan struct{})
+	if got := c.LoadOrCall("k", func() interface{} {
+		defer close(finished)
+		close(started)
+		<-release
+		return "refreshed before Delete"
+	}); got != "old" {
+		t.Fatalf("LoadOrCall() of an expired value = %v, want old", got)
+	}
+	<-started
+
+	c.Delete("k")
+	if got := c.LoadOrCall("k", func() interface{} { return "reloaded" }); got != "reloaded" {
+		t.Errorf("LoadOrCall() after Delete() = %v, want reloaded", got)
+	}
+	close(release)
+	<-finished
+	if !waitFor(func() bool { return !old.(*Value).state.Load().refreshing }) {
+		t.Fatal("the refresh started before Delete() didn't finish")
+	}
+	e, _ := m.m.Load("k")
+	if got, _ := e.(*Value).Load(); got != "reloaded" {
+		t.Errorf("value after the refresh started before Delete() = %v, want reloaded", got)
+	}
+}
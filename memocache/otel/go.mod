@@ -0,0 +1,20 @@
+module github.com/jaeyeom/gomemocache/memocache/otel
+
+go 1.20
+
+replace github.com/jaeyeom/gomemocache => ../..
+
+require (
+	github.com/jaeyeom/gomemocache v0.0.0-00010101000000-000000000000
+	go.opentelemetry.io/otel v1.19.0
+	go.opentelemetry.io/otel/metric v1.19.0
+	go.opentelemetry.io/otel/sdk v1.19.0
+	go.opentelemetry.io/otel/sdk/metric v1.19.0
+	go.opentelemetry.io/otel/trace v1.19.0
+)
+
+require (
+	github.com/go-logr/logr v1.2.4 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	golang.org/x/sys v0.12.0 // indirect
+)
@@ -0,0 +1,153 @@
+// Package otel instruments caches with OpenTelemetry, so that cache loads show
+// up in the traces of the requests that made them instead of as gaps:
+//
+//	c, err := otel.New("users", memocache.New(), nil, nil)
+//	...
+//	user, err := c.LoadOrCallCtx(ctx, id, loadUser)
+//
+// Every call adds a memocache.hit or memocache.miss event to the span of its
+// context, and every load runs in a memocache.load child span. The hits, the
+// misses, the hit ratio and the load latency are exported as metrics labeled
+// with the name of the cache.
+package otel
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/jaeyeom/gomemocache/memocache"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName is the name of the tracer and the meter.
+const instrumentationName = "github.com/jaeyeom/gomemocache/memocache/otel"
+
+// Cache is an ExtendedCache that traces and measures the loads of the cache it
+// wraps. The other methods are those of the wrapped cache. Cache should be
+// created with New.
+type Cache struct {
+	memocache.ExtendedCache
+
+	tracer  trace.Tracer
+	attrs   attribute.Set
+	hits    metric.Int64Counter
+	misses  metric.Int64Counter
+	latency metric.Float64Histogram
+	reg     metric.Registration
+}
+
+var _ memocache.ExtendedCache = (*Cache)(nil)
+
+// New returns a new Cache instrumenting the cache under the name with the
+// providers. Nil providers mean the global providers of the otel package. It
+// returns the error of the meter if the instruments can't be created.
+func New(name string, cache memocache.ExtendedCache, tp trace.TracerProvider, mp metric.MeterProvider) (*Cache, error) {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	if mp == nil {
+		mp = otel.GetMeterProvider()
+	}
+	meter := mp.Meter(instrumentationName)
+	c := &Cache{
+		ExtendedCache: cache,
+		tracer:        tp.Tracer(instrumentationName),
+		attrs:         attribute.NewSet(attribute.String("memocache.cache", name)),
+	}
+	var err error
+	if c.hits, err = meter.Int64Counter("memocache.hits",
+		metric.WithDescription("Number of calls served without calling a loader.")); err != nil {
+		return nil, err
+	}
+	if c.misses, err = meter.Int64Counter("memocache.misses",
+		metric.WithDescription("Number of calls that called a loader.")); err != nil {
+		return nil, err
+	}
+	if c.latency, err = meter.Float64Histogram("memocache.load.duration",
+		metric.WithDescription("Latency of the loader calls."),
+		metric.WithUnit("s")); err != nil {
+		return nil, err
+	}
+	ratio, err := meter.Float64ObservableGauge("memocache.hit_ratio",
+		metric.WithDescription("Ratio of hits to all calls since the cache was created."))
+	if err != nil {
+		return nil, err
+	}
+	c.reg, err = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		s := cache.Stats()
+		if s.Hits+s.Misses > 0 {
+			o.ObserveFloat64(ratio, float64(s.Hits)/float64(s.Hits+s.Misses), metric.WithAttributeSet(c.attrs))
+		}
+		return nil
+	}, ratio)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// LoadOrCall is like LoadOrCall of the wrapped cache but instrumented. The
+// call isn't a part of any trace since it has no context.
+func (c *Cache) LoadOrCall(key interface{}, getValue func() interface{}) interface{} {
+	value, _ := c.LoadOrCallCtx(context.Background(), key, func(ctx context.Context) (interface{}, error) {
+		return getValue(), nil
+	})
+	return value
+}
+
+// LoadOrCallErr is like LoadOrCallErr of the wrapped cache but instrumented.
+// The call isn't a part of any trace since it has no context.
+func (c *Cache) LoadOrCallErr(key interface{}, getValue func() (interface{}, error)) (interface{}, error) {
+	return c.LoadOrCallCtx(context.Background(), key, func(ctx context.Context) (interface{}, error) {
+		return getValue()
+	})
+}
+
+// LoadOrCallCtx is like LoadOrCallCtx of the wrapped cache but adds a hit or
+// miss event with the key to the span of ctx, and calls getValue in a child
+// span of it which records the error of getValue. The calls that waited for
+// another call's load are hits.
+func (c *Cache) LoadOrCallCtx(ctx context.Context, key interface{}, getValue func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	var called atomic.Bool
+	value, err := c.ExtendedCache.LoadOrCallCtx(ctx, key, func(ctx context.Context) (interface{}, error) {
+		called.Store(true)
+		ctx, span := c.tracer.Start(ctx, "memocache.load", trace.WithAttributes(c.keyAttrs(key)...))
+		defer span.End()
+		start := time.Now()
+		value, err := getValue(ctx)
+		c.latency.Record(ctx, time.Since(start).Seconds(), metric.WithAttributeSet(c.attrs))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return value, err
+	})
+	event, counter := "memocache.hit", c.hits
+	if called.Load() {
+		event, counter = "memocache.miss", c.misses
+	}
+	counter.Add(ctx, 1, metric.WithAttributeSet(c.attrs))
+	trace.SpanFromContext(ctx).AddEvent(event, trace.WithAttributes(c.keyAttrs(key)...))
+	return value, err
+}
+
+// keyAttrs returns the span attributes of a call of the key. The key is
+// formatted with fmt.Sprint. It's only put on spans, not on metrics, to keep
+// the cardinality of the metrics low.
+func (c *Cache) keyAttrs(key interface{}) []attribute.KeyValue {
+	return append(c.attrs.ToSlice(), attribute.String("memocache.key", fmt.Sprint(key)))
+}
+
+// Close stops reporting the hit ratio and closes the wrapped cache.
+func (c *Cache) Close() error {
+	if err := c.reg.Unregister(); err != nil {
+		return err
+	}
+	return c.ExtendedCache.Close()
+}
@@ -0,0 +1,85 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jaeyeom/gomemocache/memocache"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestCache(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	c, err := New("users", memocache.New(), tp, mp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "request")
+	for i := 0; i < 2; i++ {
+		c.LoadOrCallCtx(ctx, "a", func(ctx context.Context) (interface{}, error) {
+			return 1, nil
+		})
+	}
+	c.LoadOrCallCtx(ctx, "b", func(ctx context.Context) (interface{}, error) {
+		return nil, errors.New("fail")
+	})
+	parent.End()
+
+	ended := spans.Ended()
+	if len(ended) != 3 {
+		t.Fatalf("got %d spans, want 2 loads and the request", len(ended))
+	}
+	if ended[0].Name() != "memocache.load" || ended[0].Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("span = %s of parent %v, want memocache.load of the request", ended[0].Name(), ended[0].Parent().SpanID())
+	}
+	if got := ended[1].Status().Code; got != codes.Error {
+		t.Errorf("status of the failed load = %v, want %v", got, codes.Error)
+	}
+	var events []string
+	for _, e := range ended[2].Events() {
+		events = append(events, e.Name)
+	}
+	if want := []string{"memocache.miss", "memocache.hit", "memocache.miss"}; len(events) != len(want) ||
+		events[0] != want[0] || events[1] != want[1] || events[2] != want[2] {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]float64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				got[m.Name] = float64(data.DataPoints[0].Value)
+			case metricdata.Gauge[float64]:
+				got[m.Name] = data.DataPoints[0].Value
+			case metricdata.Histogram[float64]:
+				got[m.Name] = float64(data.DataPoints[0].Count)
+			}
+		}
+	}
+	want := map[string]float64{
+		"memocache.hits":          1,
+		"memocache.misses":        2,
+		"memocache.hit_ratio":     1.0 / 3,
+		"memocache.load.duration": 2,
+	}
+	for name, v := range want {
+		if got[name] != v {
+			t.Errorf("%s = %v, want %v", name, got[name], v)
+		}
+	}
+}
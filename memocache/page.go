@@ -0,0 +1,92 @@
+package memocache
+
+import "fmt"
+
+// Page is a page of a paginated response.
+type Page[T any] struct {
+	// Items are the items of the page.
+	Items []T
+	// NextPageToken is the token of the next page. It's empty on the last
+	// page.
+	NextPageToken string
+}
+
+// PageCache memoizes the pages of paginated queries under (query, page) paths
+// of a MultiLevelMap, so that all the pages of a query can be invalidated at
+// once. Pages are requested either by page tokens with LoadOrCall or by
+// offsets with Slice, which stitches adjacent pages together.
+type PageCache[T any] struct {
+	m *MultiLevelMap
+}
+
+// pageIndex is the key of a page of an offset-based query. The page size is a
+// part of the key so that pages of different sizes don't mix.
+type pageIndex struct {
+	size, index int
+}
+
+// NewPageCache returns a new PageCache whose levels are created by newMap. See
+// NewMultiLevelMap.
+func NewPageCache[T any](newMap func() CacheInterface) *PageCache[T] {
+	return &PageCache[T]{m: NewMultiLevelMap(newMap)}
+}
+
+// LoadOrCall returns the page of the query at the pageToken, calling getPage
+// only once for concurrent calls if it's not cached. The query and the
+// pageToken are compared as they are, so the query should be hashable, e.g. a
+// string or a struct of the filter and the sort order. Errors are not cached
+// unless they are *CachedError.
+func (p *PageCache[T]) LoadOrCall(query interface{}, pageToken string, getPage func() (Page[T], error)) (Page[T], error) {
+	value, err := p.m.LoadOrCallErr(func() (interface{}, error) {
+		return getPage()
+	}, query, pageToken)
+	return valueOf[Page[T]](value), err
+}
+
+// Slice returns up to limit items of the query from the offset, loading the
+// pages of pageSize items covering the range with getPage and stitching them
+// together. The pages are cached, so a later read of an overlapping range only
+// loads the pages that are missing. A page shorter than pageSize is taken as
+// the last page. It returns an error without loading anything if pageSize
+// isn't positive or offset or limit is negative.
+func (p *PageCache[T]) Slice(query interface{}, offset, limit, pageSize int, getPage func(offset, limit int) ([]T, error)) ([]T, error) {
+	if pageSize <= 0 || offset < 0 || limit < 0 {
+		return nil, fmt.Errorf("memocache: invalid slice of %d items from offset %d in pages of %d items", limit, offset, pageSize)
+	}
+	if limit == 0 {
+		return nil, nil
+	}
+	var items []T
+	first, last := offset/pageSize, (offset+limit-1)/pageSize
+	for i := first; i <= last; i++ {
+		value, err := p.m.LoadOrCallErr(func() (interface{}, error) {
+			return getPage(i*pageSize, pageSize)
+		}, query, pageIndex{size: pageSize, index: i})
+		if err != nil {
+			return nil, err
+		}
+		page := valueOf[[]T](value)
+		start, end := 0, len(page)
+		if i == first {
+			start = offset - i*pageSize
+		}
+		if i == last {
+			end = offset + limit - i*pageSize
+		}
+		if end > len(page) {
+			end = len(page)
+		}
+		if start < end {
+			items = append(items, page[start:end]...)
+		}
+		if len(page) < pageSize {
+			break
+		}
+	}
+	return items, nil
+}
+
+// Invalidate removes all the cached pages of the query.
+func (p *PageCache[T]) Invalidate(query interface{}) {
+	p.m.Prune(query)
+}
@@ -0,0 +1,97 @@
+package memocache
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func ExamplePageCache() {
+	pages := NewPageCache[string](func() CacheInterface {
+		return NewCache(&sync.Map{})
+	})
+	list := func(pageToken string) (Page[string], error) {
+		return pages.LoadOrCall("color", pageToken, func() (Page[string], error) {
+			fmt.Println("listing page", strconv.Quote(pageToken))
+			if pageToken == "" {
+				return Page[string]{Items: []string{"red", "green"}, NextPageToken: "2"}, nil
+			}
+			return Page[string]{Items: []string{"blue"}}, nil
+		})
+	}
+
+	page, _ := list("")
+	fmt.Println(page.Items)
+	page, _ = list(page.NextPageToken)
+	fmt.Println(page.Items)
+	page, _ = list("")
+	fmt.Println(page.Items)
+
+	pages.Invalidate("color")
+	page, _ = list("")
+	fmt.Println(page.Items)
+	// Output:
+	// listing page ""
+	// [red green]
+	// listing page "2"
+	// [blue]
+	// [red green]
+	// listing page ""
+	// [red green]
+}
+
+func TestPageCache_Slice(t *testing.T) {
+	pages := NewPageCache[int](nil)
+	var loaded []int
+	getPage := func(offset, limit int) ([]int, error) {
+		loaded = append(loaded, offset)
+		var items []int
+		for i := offset; i < offset+limit && i < 23; i++ {
+			items = append(items, i)
+		}
+		return items, nil
+	}
+
+	for _, tc := range []struct {
+		offset, limit int
+		want          []int
+		wantLoaded    []int
+	}{
+		{3, 4, []int{3, 4, 5, 6}, []int{0}},
+		{8, 5, []int{8, 9, 10, 11, 12}, []int{10}},
+		{0, 12, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}, nil},
+		{19, 10, []int{19, 20, 21, 22}, []int{20}},
+		{30, 5, nil, []int{30}},
+	} {
+		loaded = nil
+		got, err := pages.Slice("q", tc.offset, tc.limit, 10, getPage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tc.want) || !reflect.DeepEqual(loaded, tc.wantLoaded) {
+			t.Errorf("Slice(%d, %d) = %v loading %v, want %v loading %v", tc.offset, tc.limit, got, loaded, tc.want, tc.wantLoaded)
+		}
+	}
+}
+
+func TestPageCache_SliceInvalid(t *testing.T) {
+	pages := NewPageCache[int](nil)
+	getPage := func(offset, limit int) ([]int, error) {
+		t.Errorf("getPage(%d, %d) called for an invalid slice", offset, limit)
+		return nil, nil
+	}
+	for _, tc := range []struct {
+		offset, limit, pageSize int
+	}{
+		{0, 10, 0},
+		{0, 10, -1},
+		{-1, 10, 10},
+		{0, -1, 10},
+	} {
+		if _, err := pages.Slice("q", tc.offset, tc.limit, tc.pageSize, getPage); err == nil {
+			t.Errorf("Slice(%d, %d, %d) error = nil", tc.offset, tc.limit, tc.pageSize)
+		}
+	}
+}
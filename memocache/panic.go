@@ -0,0 +1,18 @@
+package memocache
+
+// onPanic returns a loader that calls getValue and then cleanup if getValue
+// panics, before the panic propagates to the caller. The panic isn't recovered
+// and re-raised, so it keeps the stack trace of the loader.
+func onPanic(getValue func() (interface{}, error), cleanup func()) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		returned := false
+		defer func() {
+			if !returned {
+				cleanup()
+			}
+		}()
+		value, err := getValue()
+		returned = true
+		return value, err
+	}
+}
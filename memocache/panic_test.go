@@ -0,0 +1,77 @@
+package memocache
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// mustPanic calls f and reports an error if it doesn't panic.
+func mustPanic(t *testing.T, f func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Error("getValue didn't panic")
+		}
+	}()
+	f()
+}
+
+func TestCache_LoadOrCallPanic(t *testing.T) {
+	c := NewCache(&sync.Map{})
+	mustPanic(t, func() {
+		c.LoadOrCall("a", func() interface{} { panic("failed") })
+	})
+	if n := c.Len(); n != 0 {
+		t.Errorf("Len() after panic = %d, want 0", n)
+	}
+	if got := c.LoadOrCall("a", func() interface{} { return 1 }); got != 1 {
+		t.Errorf("LoadOrCall(a) = %v, want 1", got)
+	}
+}
+
+func TestCache_LoadOrCallPanicWaiters(t *testing.T) {
+	c := NewCache(&sync.Map{})
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mustPanic(t, func() {
+			c.LoadOrCall("a", func() interface{} {
+				close(started)
+				<-release
+				panic("failed")
+			})
+		})
+	}()
+	<-started
+	got := make(chan interface{})
+	go func() {
+		got <- c.LoadOrCall("a", func() interface{} { return 1 })
+	}()
+	close(release)
+	if v := <-got; v != 1 {
+		t.Errorf("LoadOrCall(a) of the waiter = %v, want 1", v)
+	}
+	<-done
+}
+
+func TestRRCache_LoadOrCallPanic(t *testing.T) {
+	var size int32
+	r := NewRRCache(&size, 10, 5, rand.Intn)
+	for i := 0; i < 3; i++ {
+		mustPanic(t, func() {
+			r.LoadOrCall("a", func() interface{} { panic("failed") })
+		})
+	}
+	if size != 0 {
+		t.Errorf("size after panics = %d, want 0", size)
+	}
+	if got := r.LoadOrCall("a", func() interface{} { return 1 }); got != 1 {
+		t.Errorf("LoadOrCall(a) = %v, want 1", got)
+	}
+	if size != 1 {
+		t.Errorf("size = %d, want 1", size)
+	}
+}
@@ -0,0 +1,115 @@
+package memocache
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Path is a path of a MultiLevelMap, so that a path can be built once and
+// reused, compared and logged. It's passed to the methods of MultiLevelMap
+// like any other path:
+//
+//	p := P("users", id, "profile")
+//	m.LoadOrCall(getProfile, p...)
+type Path []interface{}
+
+// P returns the path of the elements.
+func P(elems ...interface{}) Path {
+	return Path(elems)
+}
+
+// Append returns a new path of the elements of p followed by elems. It never
+// modifies p.
+func (p Path) Append(elems ...interface{}) Path {
+	q := make(Path, 0, len(p)+len(elems))
+	return append(append(q, p...), elems...)
+}
+
+// Equal returns true if p and q have equal elements. Elements that aren't
+// comparable, e.g. slices, are never equal, as they can't be keys anyway.
+func (p Path) Equal(q Path) bool {
+	if len(p) != len(q) {
+		return false
+	}
+	for i := range p {
+		if !isComparable(p[i]) || p[i] != q[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats the path like "users/42/profile".
+func (p Path) String() string {
+	elems := make([]string, len(p))
+	for i, elem := range p {
+		elems[i] = fmt.Sprint(elem)
+	}
+	return strings.Join(elems, "/")
+}
+
+// WithPathCanonicalizer makes a MultiLevelMap replace each element of the
+// paths given to its methods with its canonical form by canonicalize, so that
+// elements of different types naming the same key, e.g. int and int64, share a
+// branch instead of silently making two. If canonicalize is nil, CanonicalKey
+// is used. The paths are copied once per call to be canonicalized.
+func WithPathCanonicalizer(canonicalize func(key interface{}) interface{}) Option {
+	if canonicalize == nil {
+		canonicalize = CanonicalKey
+	}
+	return func(c *config) {
+		c.canonicalize = canonicalize
+	}
+}
+
+// CanonicalKey returns the canonical form of the key: a []byte is converted to
+// a string, and an integer of any type is converted to an int64, or to a
+// uint64 if it doesn't fit. Other keys are returned as they are.
+func CanonicalKey(key interface{}) interface{} {
+	switch k := key.(type) {
+	case []byte:
+		return string(k)
+	case int:
+		return int64(k)
+	case int8:
+		return int64(k)
+	case int16:
+		return int64(k)
+	case int32:
+		return int64(k)
+	case uint:
+		return canonicalUint(uint64(k))
+	case uint8:
+		return int64(k)
+	case uint16:
+		return int64(k)
+	case uint32:
+		return int64(k)
+	case uint64:
+		return canonicalUint(k)
+	case uintptr:
+		return canonicalUint(uint64(k))
+	}
+	return key
+}
+
+// canonicalUint returns u as an int64 if it fits, and as it is otherwise.
+func canonicalUint(u uint64) interface{} {
+	if u > 1<<63-1 {
+		return u
+	}
+	return int64(u)
+}
+
+// canonical returns the path canonicalized by the canonicalizer of
+// WithPathCanonicalizer, or the path as it is if there is none.
+func (m *MultiLevelMap) canonical(path []interface{}) []interface{} {
+	if m.config.canonicalize == nil {
+		return path
+	}
+	q := make([]interface{}, len(path))
+	for i, key := range path {
+		q[i] = m.config.canonicalize(key)
+	}
+	return q
+}
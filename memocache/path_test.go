@@ -0,0 +1,60 @@
+package memocache
+
+import (
+	"fmt"
+	"testing"
+)
+
+func ExampleP() {
+	users := P("users")
+	p := users.Append(42, "profile")
+	fmt.Println(p, p.Equal(P("users", 42, "profile")), users)
+	// Output:
+	// users/42/profile true users
+}
+
+func TestWithPathCanonicalizer(t *testing.T) {
+	m := NewMultiLevelMap(nil, WithPathCanonicalizer(nil))
+	calls := 0
+	load := func() interface{} { calls++; return calls }
+	m.LoadOrCall(load, P("users", 42)...)
+	for _, p := range []Path{P("users", int32(42)), P([]byte("users"), uint8(42))} {
+		if v := m.LoadOrCall(load, p...); v != 1 {
+			t.Errorf("LoadOrCall(%v) = %v, want 1", p, v)
+		}
+		if v, ok := m.Load(p...); !ok || v != 1 {
+			t.Errorf("Load(%v) = %v, %v, want 1, true", p, v, ok)
+		}
+	}
+	m.Prune([]byte("users"), uint(42))
+	if _, ok := m.Load("users", 42); ok {
+		t.Error("Load() found a path pruned with other types")
+	}
+	if got := CanonicalKey(uint64(1 << 63)); got != uint64(1<<63) {
+		t.Errorf("CanonicalKey(1<<63) = %T, want uint64", got)
+	}
+
+	// Without the option, the types tell the paths apart.
+	m = NewMultiLevelMap(nil)
+	m.LoadOrCall(load, 1)
+	if _, ok := m.Load(int64(1)); ok {
+		t.Error("Load(int64(1)) found the path of int 1 without the option")
+	}
+}
+
+func TestPath_Equal(t *testing.T) {
+	for _, tc := range []struct {
+		p, q Path
+		want bool
+	}{
+		{P("users", 42), P("users", 42), true},
+		{P("users", 42), P("users", int64(42)), false},
+		{P("users"), P("users", 42), false},
+		{P("users", []byte("42")), P("users", []byte("42")), false},
+		{P([]int{1}), P(1), false},
+	} {
+		if got := tc.p.Equal(tc.q); got != tc.want {
+			t.Errorf("%v.Equal(%v) = %v, want %v", tc.p, tc.q, got, tc.want)
+		}
+	}
+}
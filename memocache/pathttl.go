@@ -0,0 +1,98 @@
+package memocache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// pathExpiry is the expiration of a path set by ExpirePath.
+type pathExpiry struct {
+	path    []interface{}
+	expires time.Time
+}
+
+// ExpirePath makes the value or the subtree in path expire after ttl, e.g. to
+// drop the whole branch of a tenant after 10 minutes. An expired path is
+// pruned lazily by the next call that uses a path in it, e.g. LoadOrCall or
+// Load, before the call goes on, so the call never sees the expired subtree,
+// or by PruneExpired. Calling ExpirePath again replaces the expiration of the
+// path. The expiration is dropped when the path or a subtree containing it is
+// pruned, so call ExpirePath again for the branch made anew after that. The
+// expirations are kept in a list scanned by every call while there are any,
+// so they suit a moderate number of branches.
+func (m *MultiLevelMap) ExpirePath(ttl time.Duration, path ...interface{}) {
+	if len(path) == 0 {
+		panic("path was not given")
+	}
+	path = m.canonical(path)
+	e := pathExpiry{
+		path:    append([]interface{}(nil), path...),
+		expires: now(m.config.clock).Add(ttl),
+	}
+	m.expMu.Lock()
+	defer m.expMu.Unlock()
+	for i := range m.expiries {
+		if Path(m.expiries[i].path).Equal(path) {
+			m.expiries[i] = e
+			return
+		}
+	}
+	m.expiries = append(m.expiries, e)
+	atomic.StoreInt32(&m.numExpiries, int32(len(m.expiries)))
+}
+
+// PruneExpired prunes all the expired paths, e.g. periodically to release the
+// memory of the branches that are no longer used.
+func (m *MultiLevelMap) PruneExpired() {
+	m.pruneExpired(nil)
+}
+
+// pruneExpired prunes the expired paths that are prefixes of the path, or all
+// the expired paths if path is nil.
+func (m *MultiLevelMap) pruneExpired(path []interface{}) {
+	if atomic.LoadInt32(&m.numExpiries) == 0 {
+		return
+	}
+	t := now(m.config.clock)
+	var expired [][]interface{}
+	m.expMu.Lock()
+	kept := m.expiries[:0]
+	for _, e := range m.expiries {
+		if (path == nil || hasPathPrefix(path, e.path)) && !t.Before(e.expires) {
+			expired = append(expired, e.path)
+		} else {
+			kept = append(kept, e)
+		}
+	}
+	for i := len(kept); i < len(m.expiries); i++ {
+		m.expiries[i] = pathExpiry{}
+	}
+	m.expiries = kept
+	atomic.StoreInt32(&m.numExpiries, int32(len(m.expiries)))
+	m.expMu.Unlock()
+	// The expired paths were taken out of the list, so a concurrent call
+	// doesn't prune them again after they are loaded anew.
+	for _, p := range expired {
+		m.prune(p)
+	}
+}
+
+// dropPathExpiries drops the expirations of the paths under prefix.
+func (m *MultiLevelMap) dropPathExpiries(prefix []interface{}) {
+	if atomic.LoadInt32(&m.numExpiries) == 0 {
+		return
+	}
+	m.expMu.Lock()
+	defer m.expMu.Unlock()
+	kept := m.expiries[:0]
+	for _, e := range m.expiries {
+		if !hasPathPrefix(e.path, prefix) {
+			kept = append(kept, e)
+		}
+	}
+	for i := len(kept); i < len(m.expiries); i++ {
+		m.expiries[i] = pathExpiry{}
+	}
+	m.expiries = kept
+	atomic.StoreInt32(&m.numExpiries, int32(len(m.expiries)))
+}
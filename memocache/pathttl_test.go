@@ -0,0 +1,49 @@
+package memocache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpirePath(t *testing.T) {
+	clock := newFakeClock()
+	m := NewMultiLevelMap(nil, WithClock(clock))
+	calls := 0
+	load := func() interface{} { calls++; return calls }
+	m.LoadOrCall(load, "tenant", 42, "a")
+	m.LoadOrCall(load, "tenant", 43, "a")
+	m.ExpirePath(10*time.Minute, "tenant", 42)
+
+	clock.Advance(9 * time.Minute)
+	if v := m.LoadOrCall(load, "tenant", 42, "a"); v != 1 {
+		t.Errorf("LoadOrCall() before the expiry = %v, want 1", v)
+	}
+	clock.Advance(time.Minute)
+	if _, ok := m.Load("tenant", 42, "a"); ok {
+		t.Error("Load() found a value of an expired branch")
+	}
+	if v := m.LoadOrCall(load, "tenant", 42, "a"); v != 3 {
+		t.Errorf("LoadOrCall() after the expiry = %v, want 3", v)
+	}
+	if v, ok := m.Load("tenant", 43, "a"); !ok || v != 2 {
+		t.Errorf("Load() of another branch = %v, %v, want 2, true", v, ok)
+	}
+
+	// The expiration doesn't carry over to the branch made anew.
+	clock.Advance(time.Hour)
+	if v := m.LoadOrCall(load, "tenant", 42, "a"); v != 3 {
+		t.Errorf("LoadOrCall() of the new branch = %v, want 3", v)
+	}
+
+	m.ExpirePath(time.Minute, "tenant", 43)
+	m.ExpirePath(time.Minute, "tenant", 42)
+	m.Prune("tenant", 42)
+	clock.Advance(time.Minute)
+	m.PruneExpired()
+	if n := m.Size(); n != 0 {
+		t.Errorf("Size() after PruneExpired() = %d, want 0", n)
+	}
+	if n := len(m.expiries); n != 0 {
+		t.Errorf("%d expirations are left, want 0", n)
+	}
+}
@@ -0,0 +1,31 @@
+package peering
+
+import (
+	"bytes"
+	"encoding/gob"
+
+	"google.golang.org/grpc/encoding"
+)
+
+func init() {
+	encoding.RegisterCodec(codec{})
+}
+
+// codec is the gRPC codec of the messages of the service.
+type codec struct{}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+func (codec) Name() string {
+	return "memocache-peering-gob"
+}
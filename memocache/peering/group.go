@@ -0,0 +1,215 @@
+package peering
+
+import (
+	"context"
+	"errors"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/jaeyeom/gomemocache/memocache"
+	"google.golang.org/grpc"
+)
+
+// GroupsServiceName is the full name of the gRPC service of the groups.
+const GroupsServiceName = "memocache.peering.Groups"
+
+// Number of points of a peer on the hash ring.
+const ringReplicas = 50
+
+// Getter loads the value of a key on the peer owning the key.
+type Getter func(ctx context.Context, key string) (interface{}, error)
+
+// Pool is the set of the peers of a cluster sharing the loads of the keys of
+// its groups, like groupcache does. A consistent hash of a key decides the
+// peer that owns it, and the other peers forward their loads of the key to
+// the owner, so that an expensive value is loaded once per cluster rather than
+// once per process. Pool should be created with NewPool, and its service
+// should be registered with the gRPC server of the peer with Register.
+type Pool struct {
+	self string
+
+	mu     sync.RWMutex
+	groups map[string]*Group
+	ring   []ringPoint
+	conns  map[string]grpc.ClientConnInterface
+}
+
+// ringPoint is a point of a peer on the hash ring.
+type ringPoint struct {
+	hash uint32
+	peer string
+}
+
+// NewPool returns a new Pool of the peer named self, e.g. its address.
+func NewPool(self string) *Pool {
+	p := &Pool{self: self, groups: make(map[string]*Group)}
+	p.SetPeers(nil)
+	return p
+}
+
+// Register registers the service serving the loads forwarded by the other
+// peers with s.
+func (p *Pool) Register(s grpc.ServiceRegistrar) {
+	s.RegisterService(&groupsServiceDesc, p)
+}
+
+// SetPeers sets the other peers of the cluster and the connections to them by
+// their names. The peer itself is always a part of the cluster. SetPeers may
+// be called again when the cluster changes, which moves the ownership of a
+// small part of the keys.
+func (p *Pool) SetPeers(peers map[string]grpc.ClientConnInterface) {
+	names := []string{p.self}
+	for name := range peers {
+		if name != p.self {
+			names = append(names, name)
+		}
+	}
+	ring := make([]ringPoint, 0, len(names)*ringReplicas)
+	for _, name := range names {
+		for i := 0; i < ringReplicas; i++ {
+			ring = append(ring, ringPoint{
+				hash: crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + name)),
+				peer: name,
+			})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ring = ring
+	p.conns = peers
+}
+
+// owner returns the connection to the owner of the key, or nil if the peer
+// itself owns it.
+func (p *Pool) owner(key string) grpc.ClientConnInterface {
+	h := crc32.ChecksumIEEE([]byte(key))
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	i := sort.Search(len(p.ring), func(i int) bool { return p.ring[i].hash >= h })
+	if i == len(p.ring) {
+		i = 0
+	}
+	if peer := p.ring[i].peer; peer != p.self {
+		return p.conns[peer]
+	}
+	return nil
+}
+
+// Group is a named cache of the keys loaded by the same Getter on all the peers
+// of a Pool. Group should be created with Pool.NewGroup.
+type Group struct {
+	name   string
+	pool   *Pool
+	cache  memocache.ExtendedCache
+	getter Getter
+}
+
+// NewGroup returns a new Group of the name, which should be created with the
+// same getter on all the peers. The values of the keys the peer owns are kept
+// in the cache, and so are the values of the other keys fetched from their
+// owners, so that hot keys are served locally.
+func (p *Pool) NewGroup(name string, cache memocache.ExtendedCache, getter Getter) *Group {
+	g := &Group{name: name, pool: p, cache: cache, getter: getter}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.groups[name] = g
+	return g
+}
+
+// Get gets the value of the key from the cache, or from its owner, or from the
+// getter if the peer owns the key. Loads of a key are deduplicated in the
+// process by the cache and in the cluster by its owner. If the owner can't be
+// reached, the value is loaded by the getter of this peer instead. Errors of
+// the getter of the owner are returned as they are reported by the owner.
+func (g *Group) Get(ctx context.Context, key string) (interface{}, error) {
+	return g.cache.LoadOrCallCtx(ctx, key, func(ctx context.Context) (interface{}, error) {
+		conn := g.pool.owner(key)
+		if conn == nil {
+			return g.getter(ctx, key)
+		}
+		var resp getResponse
+		err := conn.Invoke(ctx, "/"+GroupsServiceName+"/Get", &getRequest{Group: g.name, Key: key}, &resp, grpc.ForceCodec(codec{}))
+		if err != nil {
+			return g.getter(ctx, key)
+		}
+		if resp.Err != "" {
+			return nil, errors.New(resp.Err)
+		}
+		return decode(resp.Value)
+	})
+}
+
+// getRequest is the request of the Get method.
+type getRequest struct {
+	Group string
+	Key   string
+}
+
+// getResponse is the response of the Get method. The error of the getter is
+// sent as Err rather than as an error of the call, so that the caller can tell
+// it from a failure to reach the owner.
+type getResponse struct {
+	Value []byte
+	Err   string
+}
+
+// groupsServer is the handler type of the service of the groups.
+type groupsServer interface {
+	get(ctx context.Context, req *getRequest) (*getResponse, error)
+}
+
+var groupsServiceDesc = grpc.ServiceDesc{
+	ServiceName: GroupsServiceName,
+	HandlerType: (*groupsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Get",
+			Handler:    getHandler,
+		},
+	},
+	Metadata: "memocache/peering",
+}
+
+func getHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(getRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(groupsServer).get(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/" + GroupsServiceName + "/Get",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(groupsServer).get(ctx, req.(*getRequest))
+	}
+	return interceptor(ctx, req, info, handler)
+}
+
+// get serves a load forwarded by another peer. The key is loaded locally even
+// if this peer doesn't think it owns the key, so that peers with different
+// views of the cluster don't forward a load back and forth.
+func (p *Pool) get(ctx context.Context, req *getRequest) (*getResponse, error) {
+	p.mu.RLock()
+	g, ok := p.groups[req.Group]
+	p.mu.RUnlock()
+	if !ok {
+		return nil, errors.New("memocache/peering: unknown group " + req.Group)
+	}
+	value, err := g.cache.LoadOrCallCtx(ctx, req.Key, func(ctx context.Context) (interface{}, error) {
+		return g.getter(ctx, req.Key)
+	})
+	if err != nil {
+		return &getResponse{Err: err.Error()}, nil
+	}
+	data, err := encode(value)
+	if err != nil {
+		return nil, err
+	}
+	return &getResponse{Value: data}, nil
+}
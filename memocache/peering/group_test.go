@@ -0,0 +1,122 @@
+package peering
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jaeyeom/gomemocache/memocache"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// servePool serves the pool on a new in-memory listener and returns a
+// connection to it.
+func servePool(t *testing.T, p *Pool, opts ...grpc.ServerOption) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(opts...)
+	p.Register(s)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestGroup(t *testing.T) {
+	var calls atomic.Int32
+	getter := func(ctx context.Context, key string) (interface{}, error) {
+		calls.Add(1)
+		if key == "bad" {
+			return nil, errors.New("bad key")
+		}
+		return "value of " + key, nil
+	}
+	a, b := NewPool("a"), NewPool("b")
+	ga := a.NewGroup("g", memocache.NewCache(&sync.Map{}), getter)
+	gb := b.NewGroup("g", memocache.NewCache(&sync.Map{}), getter)
+	a.SetPeers(map[string]grpc.ClientConnInterface{"b": servePool(t, b)})
+	b.SetPeers(map[string]grpc.ClientConnInterface{"a": servePool(t, a)})
+
+	const n = 20
+	for _, g := range []*Group{ga, gb} {
+		for i := 0; i < n; i++ {
+			key := fmt.Sprint("k", i)
+			got, err := g.Get(context.Background(), key)
+			if err != nil || got != "value of "+key {
+				t.Errorf("Get(%s) = %v, %v, want value of %s", key, got, err, key)
+			}
+		}
+	}
+	if c := calls.Load(); c != n {
+		t.Errorf("getter was called %d times, want %d for the cluster", c, n)
+	}
+	if _, err := ga.Get(context.Background(), "bad"); err == nil || err.Error() != "bad key" {
+		t.Errorf("Get(bad) error = %v, want bad key", err)
+	}
+}
+
+func TestGroup_Interceptor(t *testing.T) {
+	getter := func(ctx context.Context, key string) (interface{}, error) {
+		return key, nil
+	}
+	a, b := NewPool("a"), NewPool("b")
+	ga := a.NewGroup("g", memocache.NewCache(&sync.Map{}), getter)
+	b.NewGroup("g", memocache.NewCache(&sync.Map{}), getter)
+	var methods []string
+	var mu sync.Mutex
+	interceptor := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		mu.Lock()
+		methods = append(methods, info.FullMethod)
+		mu.Unlock()
+		return handler(ctx, req)
+	}
+	a.SetPeers(map[string]grpc.ClientConnInterface{"b": servePool(t, b, grpc.UnaryInterceptor(interceptor))})
+	b.SetPeers(map[string]grpc.ClientConnInterface{"a": servePool(t, a)})
+
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprint("k", i)
+		if got, err := ga.Get(context.Background(), key); err != nil || got != key {
+			t.Errorf("Get(%s) = %v, %v, want %s", key, got, err, key)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(methods) == 0 {
+		t.Fatal("the interceptor of the owner was never called")
+	}
+	for _, m := range methods {
+		if m != "/"+GroupsServiceName+"/Get" {
+			t.Errorf("FullMethod = %s, want /%s/Get", m, GroupsServiceName)
+		}
+	}
+}
+
+func TestGroup_OwnerDown(t *testing.T) {
+	a, b := NewPool("a"), NewPool("b")
+	ga := a.NewGroup("g", memocache.NewCache(&sync.Map{}), func(ctx context.Context, key string) (interface{}, error) {
+		return key, nil
+	})
+	// b serves no groups, so the loads forwarded to it fail.
+	a.SetPeers(map[string]grpc.ClientConnInterface{"b": servePool(t, b)})
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprint("k", i)
+		if got, err := ga.Get(context.Background(), key); err != nil || got != key {
+			t.Errorf("Get(%s) = %v, %v, want %s", key, got, err, key)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package peering
+
+import (
+	"context"
+	"time"
+
+	"github.com/jaeyeom/gomemocache/memocache"
+	"google.golang.org/grpc"
+)
+
+// Method is the caching configuration of a unary method for
+// UnaryServerInterceptor.
+type Method struct {
+	// Key returns the key of the response to the request, e.g. the ID of
+	// the requested resource. Requests of the method with equal keys share
+	// their responses, so the key should cover everything the response
+	// depends on, including the caller if the response is personal. The key
+	// should be comparable. Key must not be nil.
+	Key func(req interface{}) interface{}
+	// TTL is how long a response is served from the cache. Zero means until
+	// it's evicted.
+	TTL time.Duration
+	// Cacheable reports whether the request may be served from the cache,
+	// e.g. false for a request asking for fresh data. A request that isn't
+	// cacheable calls the handler and leaves the cache alone. If nil, all
+	// the requests are cacheable.
+	Cacheable func(req interface{}) bool
+}
+
+// methodKey is the key of a response in the cache, so that the methods
+// sharing a cache don't conflict.
+type methodKey struct {
+	method string
+	key    interface{}
+}
+
+// unaryResponse is a cached response of a unary method.
+type unaryResponse struct {
+	resp    interface{}
+	expires time.Time // Zero if the response doesn't expire
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor caching the responses of
+// the unary methods in cache, so that concurrent requests with the same key
+// are served by a single call of the handler. The methods are configured by
+// their full names, e.g. "/package.Service/Method", so one interceptor can
+// serve many services; the calls of the other methods go to the handler as
+// they are. Errors of the handler are returned to the requests that waited
+// for the call but aren't cached. The cached responses are shared by the
+// requests, so they must not be modified. The methods are copied, and it
+// panics if one of them has no Key.
+func UnaryServerInterceptor(cache *memocache.Cache, methods map[string]Method) grpc.UnaryServerInterceptor {
+	registry := make(map[string]Method, len(methods))
+	for name, m := range methods {
+		if m.Key == nil {
+			panic("memocache/peering: no key of method " + name)
+		}
+		registry[name] = m
+	}
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		m, ok := registry[info.FullMethod]
+		if !ok || (m.Cacheable != nil && !m.Cacheable(req)) {
+			return handler(ctx, req)
+		}
+		key := methodKey{method: info.FullMethod, key: m.Key(req)}
+		load := func(ctx context.Context) (interface{}, error) {
+			resp, err := handler(ctx, req)
+			if err != nil {
+				return nil, err
+			}
+			r := &unaryResponse{resp: resp}
+			if m.TTL > 0 {
+				r.expires = time.Now().Add(m.TTL)
+			}
+			return r, nil
+		}
+		value, err := cache.LoadOrCallCtx(ctx, key, load)
+		if err == nil && value.(*unaryResponse).expired() {
+			cache.CompareAndDelete(key, value)
+			value, err = cache.LoadOrCallCtx(ctx, key, load)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return value.(*unaryResponse).resp, nil
+	}
+}
+
+// expired returns true if the response has expired.
+func (r *unaryResponse) expired() bool {
+	return !r.expires.IsZero() && time.Now().After(r.expires)
+}
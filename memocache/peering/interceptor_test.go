@@ -0,0 +1,116 @@
+package peering
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jaeyeom/gomemocache/memocache"
+	"google.golang.org/grpc"
+)
+
+// lookupRequest is a request of the methods of the tests.
+type lookupRequest struct {
+	ID    string
+	Fresh bool
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	cache := memocache.NewCache(&sync.Map{})
+	key := func(req interface{}) interface{} { return req.(*lookupRequest).ID }
+	interceptor := UnaryServerInterceptor(cache, map[string]Method{
+		"/users.Users/Get": {
+			Key:       key,
+			TTL:       time.Minute,
+			Cacheable: func(req interface{}) bool { return !req.(*lookupRequest).Fresh },
+		},
+		"/groups.Groups/Get": {Key: key},
+	})
+	var calls atomic.Int32
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		n := calls.Add(1)
+		if req.(*lookupRequest).ID == "bad" {
+			return nil, errors.New("bad id")
+		}
+		return n, nil
+	}
+	call := func(method string, req *lookupRequest) (interface{}, error) {
+		return interceptor(context.Background(), req, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+	}
+
+	for _, tc := range []struct {
+		method string
+		req    *lookupRequest
+		want   int32
+	}{
+		{"/users.Users/Get", &lookupRequest{ID: "1"}, 1},
+		{"/users.Users/Get", &lookupRequest{ID: "1"}, 1},
+		{"/users.Users/Get", &lookupRequest{ID: "2"}, 2},
+		{"/groups.Groups/Get", &lookupRequest{ID: "1"}, 3},
+		{"/groups.Groups/Get", &lookupRequest{ID: "1"}, 3},
+		{"/users.Users/Get", &lookupRequest{ID: "1", Fresh: true}, 4},
+		{"/users.Users/Get", &lookupRequest{ID: "1"}, 1},
+		{"/users.Users/List", &lookupRequest{ID: "1"}, 5},
+		{"/users.Users/List", &lookupRequest{ID: "1"}, 6},
+	} {
+		if got, err := call(tc.method, tc.req); err != nil || got != tc.want {
+			t.Errorf("%s(%+v) = %v, %v, want %v", tc.method, tc.req, got, err, tc.want)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := call("/users.Users/Get", &lookupRequest{ID: "bad"}); err == nil || err.Error() != "bad id" {
+			t.Errorf("Get(bad) error = %v, want bad id", err)
+		}
+	}
+	if n := calls.Load(); n != 8 {
+		t.Errorf("handler called %d times, want 8 with the errors not cached", n)
+	}
+
+	expired := &unaryResponse{resp: "expired", expires: time.Now().Add(-time.Second)}
+	cache.Store(methodKey{method: "/users.Users/Get", key: "3"}, expired)
+	if got, err := call("/users.Users/Get", &lookupRequest{ID: "3"}); err != nil || got != int32(9) {
+		t.Errorf("Get(3) of an expired response = %v, %v, want 9", got, err)
+	}
+}
+
+func TestUnaryServerInterceptor_NoKey(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("UnaryServerInterceptor() of a method without Key didn't panic")
+		}
+	}()
+	UnaryServerInterceptor(memocache.NewCache(&sync.Map{}), map[string]Method{"/users.Users/Get": {}})
+}
+
+func TestUnaryServerInterceptor_Server(t *testing.T) {
+	getter := func(ctx context.Context, key string) (interface{}, error) {
+		return key, nil
+	}
+	b := NewPool("b")
+	b.NewGroup("g", memocache.NewCache(&sync.Map{}), getter)
+	cache := memocache.NewCache(&sync.Map{})
+	interceptor := UnaryServerInterceptor(cache, map[string]Method{
+		"/" + GroupsServiceName + "/Get": {
+			Key: func(req interface{}) interface{} { return *req.(*getRequest) },
+		},
+	})
+	conn := servePool(t, b, grpc.UnaryInterceptor(interceptor))
+
+	for _, key := range []string{"k1", "k1", "k2"} {
+		var resp getResponse
+		err := conn.Invoke(context.Background(), "/"+GroupsServiceName+"/Get", &getRequest{Group: "g", Key: key}, &resp, grpc.ForceCodec(codec{}))
+		if err != nil {
+			t.Fatalf("Get(%s) error = %v", key, err)
+		}
+		if got, err := decode(resp.Value); err != nil || got != key {
+			t.Errorf("Get(%s) = %v, %v, want %s", key, got, err, key)
+		}
+	}
+	if n := cache.Len(); n != 2 {
+		t.Errorf("%d responses cached, want 2", n)
+	}
+}
@@ -0,0 +1,81 @@
+package peering
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/jaeyeom/gomemocache/memocache"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// unencodable is a value type that gob can't encode.
+type unencodable struct {
+	ch chan int
+}
+
+func dialPeer(t *testing.T, src Source) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	Register(s, src)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestWarm(t *testing.T) {
+	healthy := memocache.NewCache(&sync.Map{})
+	healthy.Store("a", 1)
+	healthy.Store(2, "two")
+	healthy.Store("bad", unencodable{})
+	conn := dialPeer(t, healthy)
+
+	fresh := memocache.NewCache(&sync.Map{})
+	n, err := Warm(context.Background(), conn, fresh, 0)
+	if err != nil {
+		t.Fatalf("Warm() error = %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Warm() = %d, want 2", n)
+	}
+	if v, ok := fresh.Load("a"); !ok || v != 1 {
+		t.Errorf(`Load("a") = %v, %v, want 1, true`, v, ok)
+	}
+	if v, ok := fresh.Load(2); !ok || v != "two" {
+		t.Errorf("Load(2) = %v, %v, want two, true", v, ok)
+	}
+	if _, ok := fresh.Load("bad"); ok {
+		t.Error(`Load("bad") found an entry that can't be encoded`)
+	}
+}
+
+func TestWarm_limit(t *testing.T) {
+	healthy := memocache.NewCache(&sync.Map{})
+	for i := 0; i < 10; i++ {
+		healthy.Store(i, i)
+	}
+	conn := dialPeer(t, healthy)
+
+	fresh := memocache.NewCache(&sync.Map{})
+	n, err := Warm(context.Background(), conn, fresh, 3)
+	if err != nil {
+		t.Fatalf("Warm() error = %v", err)
+	}
+	if n != 3 || fresh.Len() != 3 {
+		t.Errorf("Warm() = %d with %d entries, want 3", n, fresh.Len())
+	}
+}
@@ -0,0 +1,145 @@
+package memocache
+
+// mapPinner is implemented by maps that can keep keys from being evicted.
+type mapPinner interface {
+	Pin(key interface{})
+	Unpin(key interface{})
+}
+
+var (
+	_ mapPinner = (*LRUMap)(nil)
+	_ mapPinner = (*ShardedMap)(nil)
+)
+
+// Pin keeps the key from being evicted for capacity, e.g. for a global
+// configuration that must stay cached however many other keys are used. The
+// key is kept out of the list, so it doesn't count against maxSize and doesn't
+// make other keys of a shared list evicted. The pin holds across deletions and
+// reloads of the key until Unpin. The key may be pinned before it's stored.
+func (l *LRUMap) Pin(key interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.pinned == nil {
+		l.pinned = make(map[interface{}]bool)
+	}
+	l.pinned[key] = true
+	if e, ok := l.m[key]; ok {
+		l.list.Remove(e)
+	}
+}
+
+// Unpin makes the key evictable again as the most recently used one.
+func (l *LRUMap) Unpin(key interface{}) {
+	l.mu.Lock()
+	defer l.unlock()
+	if !l.pinned[key] {
+		return
+	}
+	delete(l.pinned, key)
+	if e, ok := l.m[key]; ok {
+		l.m[key] = l.list.PushFront(e.Value)
+		l.evict()
+	}
+}
+
+// Pin pins the key in its shard if the shards can pin keys like *LRUMap can.
+func (s *ShardedMap) Pin(key interface{}) {
+	if m, ok := s.shard(key).(mapPinner); ok {
+		m.Pin(key)
+	}
+}
+
+// Unpin unpins the key in its shard if the shards can pin keys.
+func (s *ShardedMap) Unpin(key interface{}) {
+	if m, ok := s.shard(key).(mapPinner); ok {
+		m.Unpin(key)
+	}
+}
+
+// Pin keeps the key from being evicted for capacity by the backing map, e.g.
+// for a global configuration that must stay cached regardless of the pressure.
+// Deleted and expired values of the key are still removed, but the pin holds
+// for the values loaded again until Unpin. It returns false if the backing map
+// can't pin keys; *LRUMap, *TinyLFUMap and sharded LRU maps can.
+func (c *Cache) Pin(key interface{}) bool {
+	m, ok := c.m.(mapPinner)
+	if ok {
+		m.Pin(key)
+	}
+	return ok
+}
+
+// Unpin makes the key pinned by Pin evictable again.
+func (c *Cache) Unpin(key interface{}) {
+	if m, ok := c.m.(mapPinner); ok {
+		m.Unpin(key)
+	}
+}
+
+// Pin keeps the key from being evicted by the random replacement. The pinned
+// values still count in the size shared by the caches. The pin holds across
+// deletions and reloads of the key until Unpin.
+func (r *RRCache) Pin(key interface{}) bool {
+	r.pinned.Store(key, true)
+	return true
+}
+
+// Unpin makes the key pinned by Pin evictable again.
+func (r *RRCache) Unpin(key interface{}) {
+	r.pinned.Delete(key)
+}
+
+// pinner is implemented by the caches of the levels that can pin keys.
+type pinner interface {
+	Pin(key interface{}) bool
+	Unpin(key interface{})
+}
+
+// PinPath pins the path, so that neither its value nor the nodes above it are
+// evicted for capacity by the caches of the levels, see Cache.Pin. The path
+// should have been loaded, since its elements can only be pinned in the levels
+// that exist. It returns false if the path isn't cached or a level can't pin
+// keys, in which case the levels above that one are still pinned.
+func (m *MultiLevelMap) PinPath(path ...interface{}) bool {
+	if len(path) == 0 {
+		panic("path was not given")
+	}
+	path = m.canonical(path)
+	node, ok := m.v.Load()
+	for _, key := range path {
+		if !ok {
+			return false
+		}
+		p, isPinner := node.(pinner)
+		if !isPinner || !p.Pin(key) {
+			return false
+		}
+		l, isLoader := node.(mapLoader)
+		if !isLoader {
+			return false
+		}
+		node, ok = l.Load(key)
+	}
+	return ok
+}
+
+// UnpinPath unpins the value of the path pinned by PinPath. The nodes above it
+// stay pinned, since other pinned paths may go through them.
+func (m *MultiLevelMap) UnpinPath(path ...interface{}) {
+	if len(path) == 0 {
+		panic("path was not given")
+	}
+	path = m.canonical(path)
+	n := len(path)
+	value, ok := m.v.Load()
+	for _, key := range path[:n-1] {
+		l, isLoader := value.(mapLoader)
+		if !ok || !isLoader {
+			return
+		}
+		value, ok = l.Load(key)
+	}
+	if p, isPinner := value.(pinner); ok && isPinner {
+		p.Unpin(path[n-1])
+	}
+}
@@ -0,0 +1,106 @@
+package memocache
+
+import (
+	"container/list"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestLRUMap_Pin(t *testing.T) {
+	l := NewLRUMap(list.New(), 2)
+	l.Store("config", 0)
+	l.Pin("config")
+	for i := 0; i < 10; i++ {
+		l.Store(i, i)
+	}
+	if _, ok := l.Load("config"); !ok {
+		t.Error("pinned key was evicted")
+	}
+	if got := l.Len(); got != 3 {
+		t.Errorf("Len() = %d, want the pinned key and 2 others", got)
+	}
+
+	l.Delete("config")
+	l.Store("config", 1)
+	l.Store(10, 10)
+	if _, ok := l.Load("config"); !ok {
+		t.Error("pinned key stored again was evicted")
+	}
+
+	l.Unpin("config")
+	l.Store(11, 11)
+	l.Store(12, 12)
+	if _, ok := l.Load("config"); ok {
+		t.Error("unpinned key wasn't evicted")
+	}
+}
+
+func TestLRUMap_PinClearSharedList(t *testing.T) {
+	shared := list.New()
+	l1 := NewLRUMap(shared, 10)
+	l2 := NewLRUMap(shared, 10)
+	l1.Store("a", 1)
+	l1.Store("b", 2)
+	l1.Pin("a")
+	l2.Store("c", 3)
+
+	l1.Clear()
+	if _, ok := l2.Load("c"); !ok {
+		t.Error("Clear() dropped a key of another map sharing the list")
+	}
+}
+
+func TestCache_Pin(t *testing.T) {
+	c := NewCache(NewLRUMap(list.New(), 2))
+	c.LoadOrCall("config", func() interface{} { return "v1" })
+	if !c.Pin("config") {
+		t.Fatal("Pin() = false, want true")
+	}
+	for i := 0; i < 10; i++ {
+		c.LoadOrCall(i, func() interface{} { return i })
+	}
+	if got := c.LoadOrCall("config", func() interface{} { return "v2" }); got != "v1" {
+		t.Errorf("pinned value = %v, want v1", got)
+	}
+	if NewCache(&sync.Map{}).Pin("config") {
+		t.Error("Pin() on a sync.Map = true, want false")
+	}
+}
+
+func TestRRCache_Pin(t *testing.T) {
+	r := NewRRCache(new(int32), 4, 1, rand.Intn)
+	r.LoadOrCall("config", func() interface{} { return "v1" })
+	r.Pin("config")
+	for i := 0; i < 100; i++ {
+		r.LoadOrCall(i, func() interface{} { return i })
+	}
+	if got := r.LoadOrCall("config", func() interface{} { return "v2" }); got != "v1" {
+		t.Errorf("pinned value = %v, want v1", got)
+	}
+}
+
+func TestMultiLevelMap_PinPath(t *testing.T) {
+	m := NewMultiLevelMap(func() CacheInterface { return NewCache(NewLRUMap(list.New(), 2)) })
+	if m.PinPath("global", "config") {
+		t.Error("PinPath() of a missing path = true, want false")
+	}
+	m.LoadOrCall(func() interface{} { return "v1" }, "global", "config")
+	if !m.PinPath("global", "config") {
+		t.Fatal("PinPath() = false, want true")
+	}
+	for i := 0; i < 10; i++ {
+		m.LoadOrCall(func() interface{} { return i }, i, "x")
+		m.LoadOrCall(func() interface{} { return i }, "global", i)
+	}
+	if got, ok := m.Load("global", "config"); !ok || got != "v1" {
+		t.Errorf("Load() of the pinned path = %v, %v, want v1", got, ok)
+	}
+
+	m.UnpinPath("global", "config")
+	m.LoadOrCall(func() interface{} { return 0 }, "global", "a")
+	m.LoadOrCall(func() interface{} { return 0 }, "global", "b")
+	if _, ok := m.Load("global", "config"); ok {
+		t.Error("unpinned path wasn't evicted")
+	}
+}
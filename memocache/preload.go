@@ -0,0 +1,89 @@
+package memocache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// Preload loads the keys that aren't cached yet with loader, e.g. to warm the
+// cache at startup, running up to parallelism loads at once. A non-positive
+// parallelism means one load at a time. A nil loader means the loader of
+// WithLoader. The keys already cached or being loaded aren't loaded again, and
+// checking them doesn't count as hits. The loads are made as by LoadOrCallCtx,
+// so their errors are cached or not in the same way. Preload carries on after
+// a failed load and returns the first error, or ErrNoLoader without a loader.
+// It stops starting loads when ctx is done and then returns ctx.Err().
+func (c *Cache) Preload(ctx context.Context, keys []interface{}, parallelism int, loader Loader) error {
+	if loader == nil {
+		loader = c.config.loader
+	}
+	if loader == nil {
+		return ErrNoLoader
+	}
+	return preload(ctx, len(keys), parallelism, func(i int) error {
+		key := keys[i]
+		if _, ok := c.Peek(key); ok {
+			return nil
+		}
+		_, err := c.LoadOrCallCtx(ctx, key, func(ctx context.Context) (interface{}, error) {
+			return loader(ctx, key)
+		})
+		return err
+	})
+}
+
+// PreloadPaths is like Cache.Preload for the paths of the map. The loader is
+// called with the path to load.
+func (m *MultiLevelMap) PreloadPaths(ctx context.Context, paths [][]interface{}, parallelism int, loader func(ctx context.Context, path []interface{}) (interface{}, error)) error {
+	return preload(ctx, len(paths), parallelism, func(i int) error {
+		path := paths[i]
+		if _, ok := m.Peek(path...); ok {
+			return nil
+		}
+		_, err := m.LoadOrCallErr(func() (interface{}, error) {
+			return loader(ctx, path)
+		}, path...)
+		return err
+	})
+}
+
+// preload calls load with the indexes from 0 to n-1 in up to parallelism
+// goroutines, and returns the first error of load, or ctx.Err() if ctx is done
+// before all the indexes are taken.
+func preload(ctx context.Context, n, parallelism int, load func(i int) error) error {
+	if parallelism <= 0 {
+		parallelism = 1
+	}
+	if parallelism > n {
+		parallelism = n
+	}
+	var (
+		next     atomic.Int64
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for w := 0; w < parallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				i := int(next.Add(1) - 1)
+				if i >= n {
+					return
+				}
+				if err := load(i); err != nil {
+					errOnce.Do(func() { firstErr = err })
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if int(next.Load()) < n {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+	return firstErr
+}
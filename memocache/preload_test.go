@@ -0,0 +1,98 @@
+package memocache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCache_Preload(t *testing.T) {
+	c := NewCache(&sync.Map{})
+	c.Store(0, "cached")
+	var running, maxRunning, calls atomic.Int32
+	keys := make([]interface{}, 100)
+	for i := range keys {
+		keys[i] = i
+	}
+	err := c.Preload(context.Background(), keys, 4, func(ctx context.Context, key interface{}) (interface{}, error) {
+		calls.Add(1)
+		n := running.Add(1)
+		defer running.Add(-1)
+		for m := maxRunning.Load(); n > m && !maxRunning.CompareAndSwap(m, n); m = maxRunning.Load() {
+		}
+		return fmt.Sprint(key), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 99 {
+		t.Errorf("loader called %d times, want 99 for the keys not cached", n)
+	}
+	if n := maxRunning.Load(); n > 4 {
+		t.Errorf("%d loads at once, want at most 4", n)
+	}
+	if got, _ := c.Load(0); got != "cached" {
+		t.Errorf("Load(0) = %v, want the cached value", got)
+	}
+	if got, _ := c.Load(99); got != "99" {
+		t.Errorf("Load(99) = %v, want 99", got)
+	}
+	if s := c.Stats(); s.Hits != 0 || s.Misses != 99 {
+		t.Errorf("Stats() = %+v, want 99 misses and no hits for the cached key", s)
+	}
+}
+
+func TestCache_PreloadErrors(t *testing.T) {
+	errFail := errors.New("fail")
+	c := NewCache(&sync.Map{}, WithLoader(func(ctx context.Context, key interface{}) (interface{}, error) {
+		if key == 1 {
+			return nil, errFail
+		}
+		return key, nil
+	}))
+	if err := c.Preload(context.Background(), []interface{}{0, 1, 2}, 2, nil); !errors.Is(err, errFail) {
+		t.Errorf("Preload() = %v, want %v", err, errFail)
+	}
+	if _, ok := c.Load(2); !ok {
+		t.Error("key after the failed key not preloaded")
+	}
+	if err := NewCache(&sync.Map{}).Preload(context.Background(), []interface{}{0}, 1, nil); err != ErrNoLoader {
+		t.Errorf("Preload() without a loader = %v, want %v", err, ErrNoLoader)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls atomic.Int32
+	err := NewCache(&sync.Map{}).Preload(ctx, []interface{}{0, 1, 2, 3}, 1, func(ctx context.Context, key interface{}) (interface{}, error) {
+		calls.Add(1)
+		cancel()
+		return key, nil
+	})
+	if !errors.Is(err, context.Canceled) || calls.Load() != 1 {
+		t.Errorf("Preload() = %v after %d loads, want %v after 1", err, calls.Load(), context.Canceled)
+	}
+}
+
+func TestMultiLevelMap_PreloadPaths(t *testing.T) {
+	var m MultiLevelMap
+	m.LoadOrCall(func() interface{} { return "cached" }, "a", 1)
+	var calls atomic.Int32
+	paths := [][]interface{}{{"a", 1}, {"a", 2}, {"b", 1}}
+	err := m.PreloadPaths(context.Background(), paths, 8, func(ctx context.Context, path []interface{}) (interface{}, error) {
+		calls.Add(1)
+		return fmt.Sprint(path...), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("loader called %d times, want 2", n)
+	}
+	for _, path := range paths[1:] {
+		if got, _ := m.Load(path...); got != fmt.Sprint(path...) {
+			t.Errorf("Load(%v) = %v, want %v", path, got, fmt.Sprint(path...))
+		}
+	}
+}
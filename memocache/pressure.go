@@ -0,0 +1,116 @@
+package memocache
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+// Shrinker is a cache that can evict entries on demand, e.g. when the process
+// is running out of memory. Shrink evicts entries until the cache holds about
+// lowWater of its capacity, a fraction between 0 and 1. *Cache, *RRCache,
+// *LRUMap, *WeightedLRUMap and *ShardedMap implement Shrinker.
+type Shrinker interface {
+	Shrink(lowWater float64)
+}
+
+var (
+	_ Shrinker = (*Cache)(nil)
+	_ Shrinker = (*RRCache)(nil)
+	_ Shrinker = (*LRUMap)(nil)
+	_ Shrinker = (*WeightedLRUMap)(nil)
+	_ Shrinker = (*ShardedMap)(nil)
+)
+
+// Shrink evicts the least recently used keys of the list shared by the maps
+// until it holds at most lowWater of maxSize keys.
+func (l *LRUMap) Shrink(lowWater float64) {
+	l.mu.Lock()
+	defer l.unlock()
+	l.evictTo(int(lowWater * float64(l.capacity())))
+}
+
+// Shrink evicts the least recently used values until their total weight is at
+// most lowWater of maxWeight.
+func (w *WeightedLRUMap) Shrink(lowWater float64) {
+	w.mu.Lock()
+	defer w.unlock()
+	target := int64(lowWater * float64(w.maxWeight))
+	for w.weight > target && w.list.Len() > 0 {
+		w.deleteElement(w.list.Back(), EvictionCapacity)
+	}
+}
+
+// Shrink evicts random items approximately until lowWater of maxSize items are
+// remaining in the caches sharing the size counter.
+func (r *RRCache) Shrink(lowWater float64) {
+	maxSize, _ := r.limits()
+	target := int32(lowWater * float64(maxSize))
+	r.evict(target, target)
+}
+
+// Shrink shrinks the backing map if it's a Shrinker like *LRUMap is. Otherwise
+// it does nothing.
+func (c *Cache) Shrink(lowWater float64) {
+	if m, ok := c.m.(Shrinker); ok {
+		m.Shrink(lowWater)
+	}
+}
+
+// ShrinkOnPressure shrinks the caches to lowWater every time a signal is
+// received from pressure, e.g. from a container runtime's memory pressure
+// notification, until pressure is closed. It blocks, so it's usually run in a
+// new goroutine.
+func ShrinkOnPressure(pressure <-chan struct{}, lowWater float64, caches ...Shrinker) {
+	for range pressure {
+		for _, c := range caches {
+			c.Shrink(lowWater)
+		}
+	}
+}
+
+// WatchMemory starts a goroutine that checks the memory obtained from the OS
+// by the Go runtime and not yet returned to it every interval, and shrinks the
+// caches to lowWater when it's over limit. A zero limit means the soft memory
+// limit of the runtime set by GOMEMLIMIT or debug.SetMemoryLimit, and nothing
+// is ever shrunk if there is no such limit. The check reads runtime.MemStats,
+// which briefly stops the world, so the interval shouldn't be too short. Call
+// the returned stop function to stop watching.
+func WatchMemory(limit uint64, interval time.Duration, lowWater float64, caches ...Shrinker) (stop func()) {
+	if limit == 0 {
+		if l := debug.SetMemoryLimit(-1); l > 0 {
+			limit = uint64(l)
+		}
+	}
+	pressure := make(chan struct{})
+	done := make(chan struct{})
+	var stopped atomic.Bool
+	go ShrinkOnPressure(pressure, lowWater, caches...)
+	go func() {
+		defer close(pressure)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var ms runtime.MemStats
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			runtime.ReadMemStats(&ms)
+			if ms.Sys-ms.HeapReleased > limit {
+				select {
+				case pressure <- struct{}{}:
+				case <-done:
+					return
+				}
+			}
+		}
+	}()
+	return func() {
+		if stopped.CompareAndSwap(false, true) {
+			close(done)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package memocache
+
+import (
+	"container/list"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestShrink(t *testing.T) {
+	lru := NewCache(NewLRUMap(list.New(), 100))
+	var size int32
+	rr := NewRRCache(&size, 100, 50, rand.Intn)
+	for i := 0; i < 100; i++ {
+		lru.LoadOrCall(i, func() interface{} { return i })
+		rr.LoadOrCall(i, func() interface{} { return i })
+	}
+	pressure := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		ShrinkOnPressure(pressure, 0.2, lru, rr)
+		close(done)
+	}()
+	pressure <- struct{}{}
+	close(pressure)
+	<-done
+	if n := lru.Len(); n != 20 {
+		t.Errorf("Len() of LRU cache = %d, want 20", n)
+	}
+	if _, ok := lru.Load(99); !ok {
+		t.Error("the most recently used key 99 was evicted")
+	}
+	if n := rr.Len(); n > 30 || int32(n) != size {
+		t.Errorf("Len() of RRCache = %d with size %d, want about 20", n, size)
+	}
+}
+
+func TestWatchMemory(t *testing.T) {
+	c := NewCache(NewLRUMap(list.New(), 100))
+	for i := 0; i < 100; i++ {
+		c.LoadOrCall(i, func() interface{} { return i })
+	}
+	stop := WatchMemory(1, time.Millisecond, 0.5, c)
+	defer stop()
+	deadline := time.Now().Add(time.Second)
+	for c.Len() > 50 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := c.Len(); n != 50 {
+		t.Errorf("Len() = %d, want 50", n)
+	}
+	stop()
+}
@@ -0,0 +1,163 @@
+// Package prometheus exports the statistics of named caches as Prometheus
+// metrics, so that many caches can be put on dashboards without hand-rolled
+// instrumentation.
+//
+// Register the caches with a Collector and the Collector with a Prometheus
+// registry:
+//
+//	c := prometheus.NewCollector("myservice")
+//	c.Register("users", usersCache)
+//	c.Register("permissions", permissionsMultiLevelMap)
+//	registry.MustRegister(c)
+//
+// To export the load latency and the evictions too, give the option returned by
+// LoadHook to the cache when it's created:
+//
+//	usersCache := memocache.NewCache(&sync.Map{}, c.LoadHook("users"))
+package prometheus
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jaeyeom/gomemocache/memocache"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// Source is a cache whose statistics are exported. *memocache.Cache,
+// *memocache.RRCache and *memocache.MultiLevelMap implement Source. If the
+// cache also has a Len() int method, its size is exported too.
+type Source interface {
+	Stats() memocache.Stats
+}
+
+// Collector is a prometheus.Collector that reports the statistics of the
+// registered caches labeled by their names. A Collector is safe for concurrent
+// use.
+type Collector struct {
+	mu     sync.Mutex
+	caches map[string]Source
+
+	hits       *prom.Desc
+	misses     *prom.Desc
+	loadErrors *prom.Desc
+	size       *prom.Desc
+	latency    *prom.HistogramVec
+	evictions  *prom.CounterVec
+}
+
+// NewCollector returns a new Collector whose metric names are prefixed with
+// the namespace, e.g. "myservice_memocache_hits_total".
+func NewCollector(namespace string) *Collector {
+	name := func(name string) string {
+		return prom.BuildFQName(namespace, "memocache", name)
+	}
+	labels := []string{"cache"}
+	return &Collector{
+		caches:     make(map[string]Source),
+		hits:       prom.NewDesc(name("hits_total"), "Number of calls served without calling a loader.", labels, nil),
+		misses:     prom.NewDesc(name("misses_total"), "Number of calls that called a loader.", labels, nil),
+		loadErrors: prom.NewDesc(name("load_errors_total"), "Number of loader calls that returned an error.", labels, nil),
+		size:       prom.NewDesc(name("entries"), "Number of cached entries.", labels, nil),
+		latency: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "memocache",
+			Name:      "load_duration_seconds",
+			Help:      "Latency of the loader calls.",
+			Buckets:   prom.DefBuckets,
+		}, labels),
+		evictions: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "memocache",
+			Name:      "evictions_total",
+			Help:      "Number of values removed from the cache by reason.",
+		}, []string{"cache", "reason"}),
+	}
+}
+
+// Register adds the cache under the name, replacing the cache registered
+// under the same name if any.
+func (c *Collector) Register(name string, cache Source) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.caches[name] = cache
+}
+
+// Unregister removes the cache of the name.
+func (c *Collector) Unregister(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.caches, name)
+	c.latency.DeleteLabelValues(name)
+	c.evictions.DeletePartialMatch(prom.Labels{"cache": name})
+}
+
+// LoadHook returns an option that observes the latency of the loaders and the
+// removals of the values of a cache under the name. It's implemented with
+// memocache.WithObserver, so it works alongside the hooks of
+// memocache.WithLoadHook and memocache.WithOnEvict given to the same cache,
+// but replaces another observer.
+func (c *Collector) LoadHook(name string) memocache.Option {
+	return memocache.WithObserver(&observer{
+		latency:   c.latency.WithLabelValues(name),
+		evictions: c.evictions.MustCurryWith(prom.Labels{"cache": name}),
+	})
+}
+
+// observer is the memocache.Observer of a cache given LoadHook.
+type observer struct {
+	memocache.NopObserver
+	latency   prom.Observer
+	evictions *prom.CounterVec
+}
+
+// OnLoadEnd observes the latency of the load.
+func (o *observer) OnLoadEnd(key interface{}, d time.Duration, err error) {
+	o.latency.Observe(d.Seconds())
+}
+
+// OnEvict counts the removal by its reason.
+func (o *observer) OnEvict(key, value interface{}, reason memocache.EvictionReason) {
+	o.evictions.WithLabelValues(reason.String()).Inc()
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prom.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.loadErrors
+	ch <- c.size
+	c.latency.Describe(ch)
+	c.evictions.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prom.Metric) {
+	type namedCache struct {
+		name  string
+		cache Source
+	}
+	c.mu.Lock()
+	caches := make([]namedCache, 0, len(c.caches))
+	for name, cache := range c.caches {
+		caches = append(caches, namedCache{name, cache})
+	}
+	c.mu.Unlock()
+	sort.Slice(caches, func(i, j int) bool {
+		return caches[i].name < caches[j].name
+	})
+
+	for _, nc := range caches {
+		name, cache := nc.name, nc.cache
+		stats := cache.Stats()
+		ch <- prom.MustNewConstMetric(c.hits, prom.CounterValue, float64(stats.Hits), name)
+		ch <- prom.MustNewConstMetric(c.misses, prom.CounterValue, float64(stats.Misses), name)
+		ch <- prom.MustNewConstMetric(c.loadErrors, prom.CounterValue, float64(stats.LoadErrors), name)
+		if l, ok := cache.(interface{ Len() int }); ok {
+			ch <- prom.MustNewConstMetric(c.size, prom.GaugeValue, float64(l.Len()), name)
+		}
+	}
+	c.latency.Collect(ch)
+	c.evictions.Collect(ch)
+}
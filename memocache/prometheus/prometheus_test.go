@@ -0,0 +1,62 @@
+package prometheus
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jaeyeom/gomemocache/memocache"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	c := NewCollector("test")
+	var started []interface{}
+	cache := memocache.NewCache(&sync.Map{}, c.LoadHook("flat"), memocache.WithLoadHook(func(key interface{}) {
+		started = append(started, key)
+	}, nil))
+	var tree memocache.MultiLevelMap
+	c.Register("flat", cache)
+	c.Register("tree", &tree)
+
+	cache.LoadOrCall("a", func() interface{} { return 1 })
+	cache.LoadOrCall("a", func() interface{} { return 1 })
+	tree.LoadOrCall(func() interface{} { return 1 }, "x", "y")
+	cache.LoadOrCall("b", func() interface{} { return 2 })
+	cache.Delete("b")
+	if len(started) != 2 {
+		t.Errorf("load hook given with LoadHook() called for %v, want a and b", started)
+	}
+
+	reg := prom.NewPedanticRegistry()
+	reg.MustRegister(c)
+	want := `
+# HELP test_memocache_entries Number of cached entries.
+# TYPE test_memocache_entries gauge
+test_memocache_entries{cache="flat"} 1
+# HELP test_memocache_evictions_total Number of values removed from the cache by reason.
+# TYPE test_memocache_evictions_total counter
+test_memocache_evictions_total{cache="flat",reason="deleted"} 1
+# HELP test_memocache_hits_total Number of calls served without calling a loader.
+# TYPE test_memocache_hits_total counter
+test_memocache_hits_total{cache="flat"} 1
+test_memocache_hits_total{cache="tree"} 0
+# HELP test_memocache_misses_total Number of calls that called a loader.
+# TYPE test_memocache_misses_total counter
+test_memocache_misses_total{cache="flat"} 2
+test_memocache_misses_total{cache="tree"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want),
+		"test_memocache_entries", "test_memocache_evictions_total", "test_memocache_hits_total", "test_memocache_misses_total"); err != nil {
+		t.Error(err)
+	}
+	if n, err := testutil.GatherAndCount(reg, "test_memocache_load_duration_seconds"); err != nil || n != 1 {
+		t.Errorf("GatherAndCount(load_duration_seconds) = %d, %v, want 1 series", n, err)
+	}
+
+	c.Unregister("tree")
+	if n, err := testutil.GatherAndCount(reg, "test_memocache_misses_total"); err != nil || n != 1 {
+		t.Errorf("GatherAndCount(misses_total) = %d, %v after Unregister(), want 1 series", n, err)
+	}
+}
@@ -0,0 +1,165 @@
+package memocache
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// OpKind is the kind of a recorded cache operation.
+type OpKind uint8
+
+// Kinds of recorded cache operations.
+const (
+	// OpLoad is a LoadOrCall or its variants.
+	OpLoad OpKind = iota
+	// OpDelete is a Delete.
+	OpDelete
+	// OpPrune is a Prune of a MultiLevelMap.
+	OpPrune
+)
+
+// String returns the name of the kind.
+func (k OpKind) String() string {
+	switch k {
+	case OpLoad:
+		return "load"
+	case OpDelete:
+		return "delete"
+	case OpPrune:
+		return "prune"
+	}
+	return fmt.Sprintf("OpKind(%d)", uint8(k))
+}
+
+// Op is a recorded cache operation. Keys are recorded as hashes so that a
+// recording of production traffic doesn't hold the keys themselves.
+type Op struct {
+	Time time.Time
+	Kind OpKind
+	// Path has the hashes of the path elements of a MultiLevelMap operation
+	// or the hash of the key of a single level cache operation. It's empty
+	// for a Prune of the whole tree.
+	Path []uint64
+	// Hit tells whether an OpLoad was served without calling a loader.
+	Hit bool
+}
+
+// WithRecorder sets a function that is called with every LoadOrCall and
+// Delete made on the cache, so that the access pattern can be replayed later
+// with Replay against alternative configurations, e.g. by BenchmarkReplay of
+// this package. Each call is recorded once; the deletes it causes, like those
+// of the dependents, aren't. Give the option to NewMultiLevelMap, and not to
+// the caches of its levels, to record the operations with the full paths
+// including Prune calls. The function is called synchronously and should be
+// safe for concurrent use; Recording is a ready-made one.
+func WithRecorder(record func(op Op)) Option {
+	return func(c *config) {
+		c.recorder = record
+	}
+}
+
+// record calls the recorder if any with an operation of the path.
+func (c *config) record(kind OpKind, hit bool, path ...interface{}) {
+	if c.recorder == nil {
+		return
+	}
+	op := Op{
+		Time: now(c.clock),
+		Kind: kind,
+		Path: make([]uint64, len(path)),
+		Hit:  hit,
+	}
+	for i, key := range path {
+		op.Path[i] = hashKey(key)
+	}
+	c.recorder(op)
+}
+
+// hashKey returns a hash of the key that is stable across processes.
+func hashKey(key interface{}) uint64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%T:%v", key, key)
+	return h.Sum64()
+}
+
+// Recording collects recorded operations in memory. Pass its Record method to
+// WithRecorder. A Recording is safe for concurrent use.
+type Recording struct {
+	mu  sync.Mutex
+	ops []Op
+}
+
+// Record appends the op to the recording.
+func (r *Recording) Record(op Op) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ops = append(r.ops, op)
+}
+
+// Ops returns the recorded operations in the order they were recorded.
+func (r *Recording) Ops() []Op {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Op(nil), r.ops...)
+}
+
+// Replay replays the ops against the cache c as fast as possible and returns
+// the hits and misses observed in c. The hashes of the keys are used as the
+// keys and the loaders return immediately, so only the policy and the size of
+// c matter. Operations with paths of more than one element are replayed
+// against the last element, since c has a single level; use
+// ReplayMultiLevel to keep the tree structure. A Prune of the whole tree
+// clears c if it has a Clear method like Cache has.
+func Replay(ops []Op, c CacheInterface) Stats {
+	var stats counters
+	for _, op := range ops {
+		if len(op.Path) == 0 {
+			if cl, ok := c.(mapClearer); ok && op.Kind == OpPrune {
+				cl.Clear()
+			}
+			continue
+		}
+		key := op.Path[len(op.Path)-1]
+		switch op.Kind {
+		case OpLoad:
+			called := false
+			c.LoadOrCall(key, func() interface{} {
+				called = true
+				return struct{}{}
+			})
+			stats.record(called, nil)
+		case OpDelete, OpPrune:
+			c.Delete(key)
+		}
+	}
+	return stats.snapshot()
+}
+
+// ReplayMultiLevel is like Replay but replays the ops against the
+// MultiLevelMap m with their full paths.
+func ReplayMultiLevel(ops []Op, m *MultiLevelMap) Stats {
+	var stats counters
+	for _, op := range ops {
+		if len(op.Path) == 0 && op.Kind != OpPrune {
+			continue
+		}
+		path := make([]interface{}, len(op.Path))
+		for i, h := range op.Path {
+			path[i] = h
+		}
+		switch op.Kind {
+		case OpLoad:
+			called := false
+			m.LoadOrCall(func() interface{} {
+				called = true
+				return struct{}{}
+			}, path...)
+			stats.record(called, nil)
+		case OpDelete, OpPrune:
+			m.Prune(path...)
+		}
+	}
+	return stats.snapshot()
+}
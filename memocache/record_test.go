@@ -0,0 +1,121 @@
+package memocache
+
+import (
+	"container/list"
+	"encoding/gob"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"testing"
+)
+
+func ExampleReplay() {
+	var rec Recording
+	c := NewCache(&sync.Map{}, WithRecorder(rec.Record))
+	for _, key := range []string{"a", "b", "c", "a", "b", "c", "a"} {
+		c.LoadOrCall(key, func() interface{} { return key })
+	}
+	fmt.Printf("%+v\n", c.Stats())
+
+	// Would a smaller LRU cache do?
+	lru := NewCache(NewLRUMap(list.New(), 2))
+	fmt.Printf("%+v\n", Replay(rec.Ops(), lru))
+	// Output:
+	// {Hits:4 Misses:3 LoadErrors:0}
+	// {Hits:0 Misses:7 LoadErrors:0}
+}
+
+func TestReplayMultiLevel(t *testing.T) {
+	var rec Recording
+	m := NewMultiLevelMap(nil, WithRecorder(rec.Record))
+	m.LoadOrCall(func() interface{} { return 1 }, "a", "x")
+	m.LoadOrCall(func() interface{} { return 1 }, "a", "x")
+	m.Prune("a")
+	m.LoadOrCall(func() interface{} { return 1 }, "a", "x")
+
+	ops := rec.Ops()
+	var kinds []string
+	for _, op := range ops {
+		kinds = append(kinds, fmt.Sprint(op.Kind, len(op.Path), op.Hit))
+	}
+	if got, want := fmt.Sprint(kinds), "[load 2 false load 2 true prune 1 false load 2 false]"; got != want {
+		t.Errorf("recorded %v, want %v", got, want)
+	}
+
+	if got := ReplayMultiLevel(ops, NewMultiLevelMap(nil)); got.Hits != 1 || got.Misses != 2 {
+		t.Errorf("ReplayMultiLevel() = %+v, want 1 hit and 2 misses", got)
+	}
+}
+
+func TestWithRecorder_OncePerCall(t *testing.T) {
+	var rec Recording
+	c := NewCache(&sync.Map{}, WithRecorder(rec.Record))
+	c.LoadOrCall("a", func() interface{} { return 1 })
+	c.LoadOrCall("b", func() interface{} { return 1 })
+	c.AddDependency("b", "a")
+	c.LoadOrCall("a", func() interface{} { return 1 })
+	c.LoadOrCallErr("b", func() (interface{}, error) { return 1, nil })
+	c.Delete("a")
+	lease, err := c.Lease("a")
+	if err != nil {
+		t.Fatalf("Lease() error = %v", err)
+	}
+	if err := lease.Delete(); err != nil {
+		t.Fatalf("Lease.Delete() error = %v", err)
+	}
+
+	var kinds []string
+	for _, op := range rec.Ops() {
+		kinds = append(kinds, fmt.Sprint(op.Kind, op.Hit))
+	}
+	if got, want := fmt.Sprint(kinds), "[load false load false load true load true delete false delete false]"; got != want {
+		t.Errorf("recorded %v, want %v", got, want)
+	}
+}
+
+var replayFile = flag.String("replay", "", "gob file of the []Op of a Recording to replay in BenchmarkReplay instead of a synthetic one")
+
+// BenchmarkReplay replays a recording against caches of each policy and size,
+// and reports their hit ratios. Record production traffic with WithRecorder,
+// save it with gob.NewEncoder(f).Encode(rec.Ops()) and run:
+//
+//	go test -run xxx -bench Replay -replay ops.gob
+func BenchmarkReplay(b *testing.B) {
+	ops := syntheticOps(100000, 10000)
+	if *replayFile != "" {
+		f, err := os.Open(*replayFile)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer f.Close()
+		ops = nil
+		if err := gob.NewDecoder(f).Decode(&ops); err != nil {
+			b.Fatalf("decoding %s: %v", *replayFile, err)
+		}
+	}
+	for _, policy := range []Policy{PolicyLRU, PolicyRandom, PolicyFIFO, PolicyLFU} {
+		for _, size := range []int{100, 1000, 10000} {
+			b.Run(fmt.Sprintf("%s/%d", policy, size), func(b *testing.B) {
+				var stats Stats
+				for i := 0; i < b.N; i++ {
+					stats = Replay(ops, New(WithEvictionPolicy(policy), WithMaxSize(size)))
+				}
+				b.ReportMetric(100*float64(stats.Hits)/float64(stats.Hits+stats.Misses), "hit%")
+				b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*len(ops)), "ns/op-replayed")
+			})
+		}
+	}
+}
+
+// syntheticOps returns n loads of numKeys keys with a skewed distribution.
+func syntheticOps(n, numKeys int) []Op {
+	r := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(r, 1.1, 1, uint64(numKeys-1))
+	ops := make([]Op, n)
+	for i := range ops {
+		ops[i] = Op{Kind: OpLoad, Path: []uint64{zipf.Uint64()}}
+	}
+	return ops
+}
@@ -0,0 +1,196 @@
+// Package redis provides a memocache.Store backed by Redis, so that the
+// processes of a service share the values loaded by any of them through a
+// memocache.TieredCache with the same LoadOrCall API as an in-memory cache:
+//
+//	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+//	store := memoredis.New(client, memocache.GobValueCodec,
+//		memoredis.WithPrefix("users:"),
+//		memoredis.WithTTL(time.Hour),
+//		memoredis.WithLock(10*time.Second, 50*time.Millisecond))
+//	l1 := memocache.NewCache(memocache.NewLRUMap(list.New(), 1000))
+//	c := memocache.NewTieredCache(l1, store, nil)
+//
+// Keys are formatted with fmt.Sprint, so they should format uniquely like
+// strings and integers do. Values are encoded with a memocache.Codec.
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jaeyeom/gomemocache/memocache"
+	"github.com/redis/go-redis/v9"
+)
+
+// lockSuffix is appended to the Redis key of a key to get the key of its lock.
+const lockSuffix = ":lock"
+
+// unlockScript deletes a lock only if it still holds the token of its owner, so
+// that an owner whose lock expired doesn't release the lock of another.
+var unlockScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0
+`)
+
+// Store is a memocache.Store backed by Redis. Store should be created with
+// New.
+type Store struct {
+	client redis.UniversalClient
+	codec  memocache.Codec
+	prefix string
+	ttl    time.Duration
+
+	lockTTL time.Duration
+	poll    time.Duration
+	mu      sync.Mutex
+	tokens  map[string]string // Tokens of the locks held by the Redis keys
+}
+
+var _ memocache.Store = (*Store)(nil)
+
+// Option is an option of a Store.
+type Option func(s *Store)
+
+// WithPrefix prepends the prefix to the Redis keys, e.g. to share a Redis
+// database between caches.
+func WithPrefix(prefix string) Option {
+	return func(s *Store) {
+		s.prefix = prefix
+	}
+}
+
+// WithTTL makes the values expire in Redis after ttl. Without it, they never
+// expire.
+func WithTTL(ttl time.Duration) Option {
+	return func(s *Store) {
+		s.ttl = ttl
+	}
+}
+
+// WithLock deduplicates the loads of a key across processes. A Get that misses
+// takes a lock of the key in Redis for up to ttl and reports the miss, so that
+// the caller loads the value, and the lock is released by the Set of the
+// value. A Get that misses while another process holds the lock polls Redis
+// every poll interval until the value is set, the lock is released or expires,
+// or ctx is done. If the loader fails, the lock is held until it expires, so
+// ttl should be a bit longer than a load usually takes.
+func WithLock(ttl, poll time.Duration) Option {
+	return func(s *Store) {
+		s.lockTTL = ttl
+		s.poll = poll
+	}
+}
+
+// New returns a new Store of the client whose values are encoded with the
+// codec.
+func New(client redis.UniversalClient, codec memocache.Codec, opts ...Option) *Store {
+	s := &Store{
+		client: client,
+		codec:  codec,
+		tokens: make(map[string]string),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// redisKey returns the Redis key of the key.
+func (s *Store) redisKey(key interface{}) string {
+	return s.prefix + fmt.Sprint(key)
+}
+
+// Get returns the decoded value of the key if it's in Redis. See WithLock for
+// the wait for a load of another process.
+func (s *Store) Get(ctx context.Context, key interface{}) (interface{}, bool, error) {
+	k := s.redisKey(key)
+	for {
+		value, ok, err := s.get(ctx, k)
+		if ok || err != nil || s.lockTTL <= 0 {
+			return value, ok, err
+		}
+		locked, err := s.lock(ctx, k)
+		if locked || err != nil {
+			return nil, false, err
+		}
+		t := time.NewTimer(s.poll)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, false, ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// get returns the decoded value of the Redis key k.
+func (s *Store) get(ctx context.Context, k string) (interface{}, bool, error) {
+	data, err := s.client.Get(ctx, k).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	value, err := s.codec.Decode(data)
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// lock takes the lock of the Redis key k, and returns false if another holds
+// it.
+func (s *Store) lock(ctx context.Context, k string) (bool, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return false, err
+	}
+	token := hex.EncodeToString(b[:])
+	ok, err := s.client.SetNX(ctx, k+lockSuffix, token, s.lockTTL).Result()
+	if err != nil || !ok {
+		return false, err
+	}
+	s.mu.Lock()
+	s.tokens[k] = token
+	s.mu.Unlock()
+	return true, nil
+}
+
+// unlock releases the lock of the Redis key k if this store holds it.
+func (s *Store) unlock(ctx context.Context, k string) error {
+	s.mu.Lock()
+	token, ok := s.tokens[k]
+	delete(s.tokens, k)
+	s.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return unlockScript.Run(ctx, s.client, []string{k + lockSuffix}, token).Err()
+}
+
+// Set encodes and sets the value of the key in Redis, and releases the lock of
+// the key taken by Get if any.
+func (s *Store) Set(ctx context.Context, key, value interface{}) error {
+	data, err := s.codec.Encode(value)
+	if err != nil {
+		return err
+	}
+	k := s.redisKey(key)
+	if err := s.client.Set(ctx, k, data, s.ttl).Err(); err != nil {
+		return err
+	}
+	return s.unlock(ctx, k)
+}
+
+// Delete deletes the value of the key from Redis.
+func (s *Store) Delete(ctx context.Context, key interface{}) error {
+	return s.client.Del(ctx, s.redisKey(key)).Err()
+}
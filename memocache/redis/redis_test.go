@@ -0,0 +1,75 @@
+package redis
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jaeyeom/gomemocache/memocache"
+	"github.com/redis/go-redis/v9"
+)
+
+func newClient(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return mr, client
+}
+
+func TestStore(t *testing.T) {
+	mr, client := newClient(t)
+	store := New(client, memocache.GobValueCodec, WithPrefix("p:"), WithTTL(time.Minute))
+	var calls int32
+	load := func() interface{} {
+		atomic.AddInt32(&calls, 1)
+		return "v"
+	}
+	a := memocache.NewTieredCache(memocache.NewCache(&sync.Map{}), store, nil)
+	b := memocache.NewTieredCache(memocache.NewCache(&sync.Map{}), store, nil)
+	if got := a.LoadOrCall("k", load); got != "v" {
+		t.Errorf("LoadOrCall(k) on a = %v, want v", got)
+	}
+	if got := b.LoadOrCall("k", load); got != "v" {
+		t.Errorf("LoadOrCall(k) on b = %v, want v", got)
+	}
+	if calls != 1 {
+		t.Errorf("getValue was called %d times, want 1", calls)
+	}
+	if ttl := mr.TTL("p:k"); ttl != time.Minute {
+		t.Errorf("TTL of p:k = %v, want 1m", ttl)
+	}
+	a.Delete("k")
+	if mr.Exists("p:k") {
+		t.Error("p:k survived Delete(k)")
+	}
+}
+
+func TestStore_Lock(t *testing.T) {
+	_, client := newClient(t)
+	var calls int32
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		// Each tiered cache stands for a process with its own store.
+		store := New(client, memocache.GobValueCodec, WithLock(time.Second, time.Millisecond))
+		c := memocache.NewTieredCache(memocache.NewCache(&sync.Map{}), store, nil)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got := c.LoadOrCall("k", func() interface{} {
+				atomic.AddInt32(&calls, 1)
+				time.Sleep(10 * time.Millisecond)
+				return 1
+			})
+			if got != 1 {
+				t.Errorf("LoadOrCall(k) = %v, want 1", got)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("getValue was called %d times, want 1 across the stores", calls)
+	}
+}
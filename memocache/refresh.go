@@ -0,0 +1,51 @@
+package memocache
+
+import "context"
+
+// Refresh recomputes the value of the key with getValue in the background and
+// swaps it in once it's ready, so that readers keep getting the current value
+// meanwhile instead of stampeding the loader as they would after a Delete. If
+// getValue fails, the current value is kept. Refresh does nothing if the key
+// is already being refreshed, and loads the key in the background as
+// LoadOrCallErr does if it's not cached yet. Like a refresh of a stale value in
+// the degraded mode of WithLatencyBudget, the new value is discarded if the key
+// is invalidated or leased meanwhile.
+func (c *Cache) Refresh(key interface{}, getValue func() (interface{}, error)) {
+	v := c.entry(key)
+	if v.state.Load() == nil {
+		go c.loadOrCallSlow(context.Background(), key, v, getValue)
+		return
+	}
+	if v.startRefresh(false) {
+		c.refresh(key, v, getValue)
+	}
+}
+
+// RefreshPath recomputes the value in path with getValue in the background and
+// swaps it in once it's ready. See Cache.Refresh. If the leaf level doesn't
+// have a Refresh method like Cache has, the new value is stored with StorePath
+// once it's ready, and concurrent refreshes of the path aren't deduplicated.
+func (m *MultiLevelMap) RefreshPath(getValue func() (interface{}, error), path ...interface{}) {
+	path = m.canonical(path)
+	n := len(path)
+	if n == 0 {
+		panic("path was not given")
+	}
+	m.pruneExpired(path)
+
+	leaf, err := m.leaf(path)
+	if err != nil {
+		panic(err)
+	}
+	if r, ok := leaf.(interface {
+		Refresh(key interface{}, getValue func() (interface{}, error))
+	}); ok {
+		r.Refresh(path[n-1], getValue)
+		return
+	}
+	go func() {
+		if value, err := getValue(); err == nil {
+			m.StorePath(value, path...)
+		}
+	}()
+}
@@ -0,0 +1,66 @@
+package memocache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it's true or a second passes, and returns cond.
+func waitFor(cond func() bool) bool {
+	deadline := time.Now().Add(time.Second)
+	for !cond() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	return cond()
+}
+
+func TestCache_Refresh(t *testing.T) {
+	c := NewCache(&sync.Map{})
+	c.LoadOrCall("a", func() interface{} { return 1 })
+	release := make(chan struct{})
+	c.Refresh("a", func() (interface{}, error) {
+		<-release
+		return 2, nil
+	})
+	c.Refresh("a", func() (interface{}, error) {
+		t.Error("concurrent Refresh() called getValue")
+		return 3, nil
+	})
+	if got := c.LoadOrCall("a", func() interface{} { return 4 }); got != 1 {
+		t.Errorf("LoadOrCall(a) during refresh = %v, want 1", got)
+	}
+	close(release)
+	if !waitFor(func() bool { v, _ := c.Load("a"); return v == 2 }) {
+		t.Error("the refreshed value 2 wasn't swapped in")
+	}
+
+	c.Refresh("a", func() (interface{}, error) { return nil, errors.New("failed") })
+	c.Refresh("b", func() (interface{}, error) { return 5, nil })
+	if !waitFor(func() bool { v, _ := c.Load("b"); return v == 5 }) {
+		t.Error("the missing key b wasn't loaded")
+	}
+	if v, _ := c.Load("a"); v != 2 {
+		t.Errorf("Load(a) after failed refresh = %v, want 2", v)
+	}
+}
+
+func TestMultiLevelMap_RefreshPath(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		newMap func() CacheInterface
+	}{
+		{"Map", nil},
+		{"Cache", func() CacheInterface { return NewCache(&sync.Map{}) }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewMultiLevelMap(tc.newMap)
+			m.LoadOrCall(func() interface{} { return 1 }, "a", "b")
+			m.RefreshPath(func() (interface{}, error) { return 2, nil }, "a", "b")
+			if !waitFor(func() bool { v, _ := m.Load("a", "b"); return v == 2 }) {
+				t.Error("the refreshed value 2 wasn't swapped in")
+			}
+		})
+	}
+}
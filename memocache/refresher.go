@@ -0,0 +1,77 @@
+package memocache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Refresher keeps registered keys warm by refreshing them on a schedule, e.g.
+// configuration entries that must never be loaded on the request path. Each
+// refresh is made with Cache.Refresh or MultiLevelMap.RefreshPath, so readers
+// keep getting the current value while the new one is loaded and a failed
+// load keeps the current value. Refresher should be created with
+// NewRefresher.
+type Refresher struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	clock  Clock
+}
+
+// NewRefresher returns a new Refresher. Its refreshes stop when ctx is done or
+// Close is called. Of the options, only WithClock applies to a Refresher.
+func NewRefresher(ctx context.Context, opts ...Option) *Refresher {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Refresher{ctx: ctx, cancel: cancel, clock: newConfig(opts).clock}
+}
+
+// Register refreshes the key of the cache with getValue right away and then
+// every interval, until the returned unregister function is called or the
+// refresher is stopped.
+func (r *Refresher) Register(c *Cache, key interface{}, interval time.Duration, getValue func() (interface{}, error)) (unregister func()) {
+	return r.every(interval, func() {
+		c.Refresh(key, getValue)
+	})
+}
+
+// RegisterPath refreshes the path of the map with getValue right away and then
+// every interval, until the returned unregister function is called or the
+// refresher is stopped.
+func (r *Refresher) RegisterPath(m *MultiLevelMap, interval time.Duration, getValue func() (interface{}, error), path ...interface{}) (unregister func()) {
+	if len(path) == 0 {
+		panic("path was not given")
+	}
+	return r.every(interval, func() {
+		m.RefreshPath(getValue, path...)
+	})
+}
+
+// every calls refresh now and every interval in a new goroutine until the
+// returned function is called or the refresher is stopped.
+func (r *Refresher) every(interval time.Duration, refresh func()) func() {
+	ctx, cancel := context.WithCancel(r.ctx)
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		for ctx.Err() == nil {
+			refresh()
+			t := newTimer(r.clock, interval)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return
+			case <-t.C():
+			}
+		}
+	}()
+	return cancel
+}
+
+// Close stops all the refreshes and waits until no more refreshes are
+// started. Refreshes already started may still finish in the background.
+func (r *Refresher) Close() error {
+	r.cancel()
+	r.wg.Wait()
+	return nil
+}
@@ -0,0 +1,41 @@
+package memocache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRefresher(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCache(&sync.Map{})
+	m := NewMultiLevelMap(nil)
+	r := NewRefresher(context.Background(), WithClock(clock))
+	var n, pathN atomic.Int32
+	unregister := r.Register(c, "config", time.Minute, func() (interface{}, error) {
+		return n.Add(1), nil
+	})
+	r.RegisterPath(m, time.Minute, func() (interface{}, error) {
+		return pathN.Add(1), nil
+	}, "a", "b")
+	for want := int32(1); want <= 3; want++ {
+		if !waitFor(func() bool { v, _ := c.Load("config"); return v == want }) {
+			t.Fatalf("the key wasn't refreshed %d times", want)
+		}
+		if !waitFor(func() bool { v, _ := m.Load("a", "b"); return v == want && clock.numTimers() == 2 }) {
+			t.Fatalf("the path wasn't refreshed %d times", want)
+		}
+		clock.Advance(time.Minute)
+	}
+	unregister()
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	stopped, pathStopped := n.Load(), pathN.Load()
+	clock.Advance(time.Minute)
+	if n.Load() != stopped || pathN.Load() != pathStopped {
+		t.Error("refreshed after Close()")
+	}
+}
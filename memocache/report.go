@@ -0,0 +1,197 @@
+package memocache
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Report is a summary of the efficiency of a Cache, e.g. to be attached to a
+// nightly performance report. It can be serialized to JSON.
+type Report struct {
+	// Generated is when the report was generated.
+	Generated time.Time `json:"generated"`
+	// Stats are the statistics since the cache was created.
+	Stats Stats `json:"stats"`
+	// HitRatio is the ratio of hits to all calls since the cache was
+	// created.
+	HitRatio float64 `json:"hitRatio"`
+	// Buckets are the hits and misses of the recent time buckets, from the
+	// oldest to the newest.
+	Buckets []ReportBucket `json:"buckets,omitempty"`
+	// TopKeys are the most hit keys among the cached entries.
+	TopKeys []KeyHits `json:"topKeys,omitempty"`
+	// Evictions are the numbers of removed values by the reasons.
+	Evictions map[string]uint64 `json:"evictions,omitempty"`
+	// WastedLoads is the number of loaded values that were removed without
+	// ever being hit.
+	WastedLoads uint64 `json:"wastedLoads"`
+	// NeverHitEntries is the number of cached values that haven't been hit
+	// yet.
+	NeverHitEntries int `json:"neverHitEntries"`
+}
+
+// ReportBucket is the hits and misses of a time bucket of a Report.
+type ReportBucket struct {
+	Start    time.Time `json:"start"`
+	Hits     uint64    `json:"hits"`
+	Misses   uint64    `json:"misses"`
+	HitRatio float64   `json:"hitRatio"`
+}
+
+// KeyHits is the number of hits of a key of a Report. The key is formatted
+// with fmt.Sprint so that the report can be serialized.
+type KeyHits struct {
+	Key  string `json:"key"`
+	Hits uint64 `json:"hits"`
+}
+
+// WithReport makes a Cache keep the hits and misses of the last n time buckets
+// of the given length and count the removed values by the reasons, so that
+// Cache.Report can summarize them. Without the option, the report only has
+// the statistics and the cached entries.
+func WithReport(bucket time.Duration, n int) Option {
+	return func(c *config) {
+		c.reportBucket = bucket
+		c.reportBuckets = n
+	}
+}
+
+// reporter keeps the data of Cache.Report enabled by WithReport.
+type reporter struct {
+	mu      sync.Mutex
+	bucket  time.Duration
+	buckets []reportCounts // Ring indexed by the bucket number
+	clock   Clock
+
+	evictions   [EvictionCleared + 1]atomic.Uint64
+	wastedLoads atomic.Uint64
+}
+
+// reportCounts are the counts of a time bucket.
+type reportCounts struct {
+	num          int64 // Bucket number since the Unix epoch
+	hits, misses uint64
+}
+
+// newReporter returns a reporter for the configured buckets, or nil if the
+// report is not enabled.
+func newReporter(c *config) *reporter {
+	if c.reportBucket <= 0 || c.reportBuckets <= 0 {
+		return nil
+	}
+	return &reporter{
+		bucket:  c.reportBucket,
+		buckets: make([]reportCounts, c.reportBuckets),
+		clock:   c.clock,
+	}
+}
+
+// record counts a call in the current bucket. It's a no-op on a nil reporter.
+func (r *reporter) record(hit bool) {
+	if r == nil {
+		return
+	}
+	num := now(r.clock).UnixNano() / int64(r.bucket)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b := &r.buckets[num%int64(len(r.buckets))]
+	if b.num != num {
+		*b = reportCounts{num: num}
+	}
+	if hit {
+		b.hits++
+	} else {
+		b.misses++
+	}
+}
+
+// evicted counts a removed value that was hit the given times.
+func (r *reporter) evicted(reason EvictionReason, hits uint64) {
+	if int(reason) < len(r.evictions) {
+		r.evictions[reason].Add(1)
+	}
+	if hits == 0 {
+		r.wastedLoads.Add(1)
+	}
+}
+
+// Report returns a summary of the efficiency of the cache with up to topN most
+// hit keys. The time buckets, the evictions and the wasted loads are reported
+// only with WithReport. The entries are visited only if the backing map has a
+// Range method like *sync.Map has.
+func (c *Cache) Report(topN int) Report {
+	now := now(c.config.clock)
+	rep := Report{
+		Generated: now,
+		Stats:     c.Stats(),
+	}
+	rep.HitRatio = hitRatio(rep.Stats.Hits, rep.Stats.Misses)
+
+	if m, ok := c.m.(mapRanger); ok {
+		var top []KeyHits
+		m.Range(func(key, e interface{}) bool {
+			v := e.(*Value)
+			if s := v.state.Load(); s == nil || s.expired() {
+				return true
+			}
+			hits := v.hits.Load()
+			if hits == 0 {
+				rep.NeverHitEntries++
+				return true
+			}
+			if topN > 0 {
+				top = append(top, KeyHits{Key: fmt.Sprint(key), Hits: hits})
+			}
+			return true
+		})
+		sort.Slice(top, func(i, j int) bool {
+			if top[i].Hits != top[j].Hits {
+				return top[i].Hits > top[j].Hits
+			}
+			return top[i].Key < top[j].Key
+		})
+		if len(top) > topN {
+			top = top[:topN]
+		}
+		rep.TopKeys = top
+	}
+
+	r := c.report
+	if r == nil {
+		return rep
+	}
+	current := now.UnixNano() / int64(r.bucket)
+	r.mu.Lock()
+	for num := current - int64(len(r.buckets)) + 1; num <= current; num++ {
+		b := r.buckets[num%int64(len(r.buckets))]
+		if b.num != num {
+			b = reportCounts{num: num}
+		}
+		rep.Buckets = append(rep.Buckets, ReportBucket{
+			Start:    time.Unix(0, num*int64(r.bucket)),
+			Hits:     b.hits,
+			Misses:   b.misses,
+			HitRatio: hitRatio(b.hits, b.misses),
+		})
+	}
+	r.mu.Unlock()
+	rep.Evictions = make(map[string]uint64)
+	for reason := range r.evictions {
+		if n := r.evictions[reason].Load(); n > 0 {
+			rep.Evictions[EvictionReason(reason).String()] = n
+		}
+	}
+	rep.WastedLoads = r.wastedLoads.Load()
+	return rep
+}
+
+// hitRatio returns the ratio of hits to all calls, or zero without calls.
+func hitRatio(hits, misses uint64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
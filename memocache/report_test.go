@@ -0,0 +1,60 @@
+package memocache
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func ExampleCache_Report() {
+	c := NewCache(NewLRUMap(list.New(), 2), WithReport(time.Hour, 24))
+	for _, key := range []string{"a", "a", "b", "c", "c", "c", "d"} {
+		c.LoadOrCall(key, func() interface{} { return key })
+	}
+
+	rep := c.Report(1)
+	fmt.Printf("hit ratio %.2f\n", rep.HitRatio)
+	fmt.Printf("top keys %+v\n", rep.TopKeys)
+	fmt.Println("evictions", rep.Evictions)
+	fmt.Println("wasted loads", rep.WastedLoads)
+	fmt.Println("never hit entries", rep.NeverHitEntries)
+	// Output:
+	// hit ratio 0.43
+	// top keys [{Key:c Hits:2}]
+	// evictions map[capacity:2]
+	// wasted loads 1
+	// never hit entries 1
+}
+
+func TestCache_Report(t *testing.T) {
+	c := NewCache(NewLRUMap(list.New(), 10), WithReport(time.Hour, 3))
+	for i := 0; i < 3; i++ {
+		c.LoadOrCall("hot", func() interface{} { return 1 })
+	}
+	c.LoadOrCall("warm", func() interface{} { return 2 })
+	c.LoadOrCall("warm", func() interface{} { return 2 })
+	c.LoadOrCall("cold", func() interface{} { return 3 })
+	c.Delete("cold")
+
+	rep := c.Report(5)
+	if got, want := fmt.Sprint(rep.TopKeys), "[{hot 2} {warm 1}]"; got != want {
+		t.Errorf("TopKeys = %v, want %v", got, want)
+	}
+	if got, want := fmt.Sprint(rep.Evictions), "map[deleted:1]"; got != want || rep.WastedLoads != 1 {
+		t.Errorf("Evictions = %v, WastedLoads = %d, want %v, 1", got, rep.WastedLoads, want)
+	}
+	if len(rep.Buckets) != 3 {
+		t.Fatalf("len(Buckets) = %d, want 3", len(rep.Buckets))
+	}
+	if b := rep.Buckets[2]; b.Hits != 3 || b.Misses != 3 {
+		t.Errorf("current bucket = %+v, want 3 hits and 3 misses", b)
+	}
+	if b := rep.Buckets[0]; b.Hits != 0 || b.Misses != 0 {
+		t.Errorf("oldest bucket = %+v, want empty", b)
+	}
+	if _, err := json.Marshal(rep); err != nil {
+		t.Errorf("json.Marshal() error = %v", err)
+	}
+}
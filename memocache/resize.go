@@ -0,0 +1,46 @@
+package memocache
+
+import "sync/atomic"
+
+// mapResizer is implemented by maps whose capacity can be changed.
+type mapResizer interface {
+	SetMaxSize(n int)
+}
+
+var _ mapResizer = (*LRUMap)(nil)
+
+// SetMaxSize changes the maximum number of keys in the list, evicting the least
+// recently used keys at once if the list holds more. The maps sharing the list
+// keep their own maximum sizes, so they should be resized together.
+func (l *LRUMap) SetMaxSize(n int) {
+	l.mu.Lock()
+	defer l.unlock()
+	l.maxSize = n
+	l.evict()
+}
+
+// SetMaxSize changes the maximum number of entries of the caches sharing the
+// size counter, evicting random entries down to the target at once if they
+// hold more. Like the sizes given to NewRRCache, it should be the same for all
+// the caches sharing the counter.
+func (r *RRCache) SetMaxSize(n int32) {
+	atomic.StoreInt32(&r.maxSize, n)
+	r.evict(r.limits())
+}
+
+// SetTarget changes the number of entries the evictions leave in the caches
+// sharing the size counter. It takes effect on the next eviction.
+func (r *RRCache) SetTarget(n int32) {
+	atomic.StoreInt32(&r.targetNum, n)
+}
+
+// SetMaxSize changes the capacity of the backing map if it can be changed like
+// that of *LRUMap can, evicting entries at once if needed. It returns false
+// otherwise.
+func (c *Cache) SetMaxSize(n int) bool {
+	m, ok := c.m.(mapResizer)
+	if ok {
+		m.SetMaxSize(n)
+	}
+	return ok
+}
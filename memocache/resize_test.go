@@ -0,0 +1,70 @@
+package memocache
+
+import (
+	"container/list"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestLRUMap_SetMaxSize(t *testing.T) {
+	l := NewLRUMap(list.New(), 10)
+	for i := 0; i < 10; i++ {
+		l.Store(i, i)
+	}
+	l.SetMaxSize(3)
+	if got := l.Len(); got != 3 {
+		t.Errorf("Len() after SetMaxSize(3) = %d, want 3", got)
+	}
+	for i := 7; i < 10; i++ {
+		if _, ok := l.Peek(i); !ok {
+			t.Errorf("recently used key %d was evicted", i)
+		}
+	}
+	l.SetMaxSize(5)
+	for i := 10; i < 20; i++ {
+		l.Store(i, i)
+	}
+	if got, cap := l.Len(), l.Cap(); got != 5 || cap != 5 {
+		t.Errorf("Len(), Cap() after SetMaxSize(5) = %d, %d, want 5, 5", got, cap)
+	}
+}
+
+func TestLRUMap_SetMaxSizeConcurrent(t *testing.T) {
+	c := NewCache(NewLRUMap(list.New(), 100))
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				c.LoadOrCall(j, func() interface{} { return j })
+				if j%100 == 0 {
+					c.SetMaxSize(10 + i*10)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if got, cap := c.Len(), c.Cap(); got > cap {
+		t.Errorf("Len() = %d, want at most Cap() = %d", got, cap)
+	}
+}
+
+func TestRRCache_SetMaxSize(t *testing.T) {
+	r := NewRRCache(new(int32), 100, 50, rand.Intn)
+	for i := 0; i < 100; i++ {
+		r.LoadOrCall(i, func() interface{} { return i })
+	}
+	r.SetTarget(5)
+	r.SetMaxSize(10)
+	if got := r.Size(); got > 10 {
+		t.Errorf("Size() after SetMaxSize(10) = %d, want at most 10", got)
+	}
+	if got := r.Cap(); got != 10 {
+		t.Errorf("Cap() = %d, want 10", got)
+	}
+	if NewCache(&sync.Map{}).SetMaxSize(10) {
+		t.Error("SetMaxSize() on a sync.Map = true, want false")
+	}
+}
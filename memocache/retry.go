@@ -0,0 +1,110 @@
+package memocache
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy describes how the failed loads are retried by WithRetry. The
+// n-th retry waits InitialBackoff multiplied by Multiplier n-1 times, up to
+// MaxBackoff, reduced by a random fraction of up to Jitter of it so that the
+// retries of many keys spread out.
+type RetryPolicy struct {
+	// MaxAttempts is the number of calls of the loader including the first
+	// one. Less than 2 means no retries.
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait before a retry. Zero means no cap.
+	MaxBackoff time.Duration
+	// Multiplier is the growth of the wait from a retry to the next. Zero
+	// means 2.
+	Multiplier float64
+	// Jitter is the fraction of the wait, from 0 to 1, that is randomly cut
+	// off it.
+	Jitter float64
+	// Retryable tells whether a failed load should be retried. Nil means all
+	// the errors but *CachedError, which asks for the error to be cached, and
+	// the errors of contexts.
+	Retryable func(err error) bool
+}
+
+// WithRetry retries the failed loads of a Cache or an RRCache according to
+// the policy inside the load, so that a transient failure is retried once for
+// all the calls waiting for the key instead of by each caller. The calls keep
+// waiting during the retries, and the load fails for all of them with the
+// error of the last attempt, or with the error of the context of the load if
+// it's done while waiting for a retry, in which case the waiting calls load
+// again. The slot of WithMaxConcurrentLoads is released while waiting for a
+// retry. A panic of the loader isn't retried. If the loader returns a
+// *CachedError after retries, its Attempts is set to the number of calls.
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *config) {
+		c.retry = &policy
+	}
+}
+
+// wrap returns getValue retried according to the policy, waiting on the
+// clock until ctx is done. It returns getValue itself on a nil policy.
+func (p *RetryPolicy) wrap(ctx context.Context, clock Clock, getValue func() (interface{}, error)) func() (interface{}, error) {
+	if p == nil || p.MaxAttempts < 2 {
+		return getValue
+	}
+	return func() (interface{}, error) {
+		for attempt := 1; ; attempt++ {
+			value, err := getValue()
+			if err == nil {
+				return value, nil
+			}
+			var ce *CachedError
+			if errors.As(err, &ce) && attempt > 1 {
+				ce.Attempts = attempt
+			}
+			if attempt >= p.MaxAttempts || !p.retryable(err) {
+				return value, err
+			}
+			if d := p.backoff(attempt); d > 0 {
+				t := newTimer(clock, d)
+				select {
+				case <-t.C():
+				case <-ctx.Done():
+					t.Stop()
+					return nil, ctx.Err()
+				}
+			}
+		}
+	}
+}
+
+// retryable tells whether the error should be retried.
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	var ce *CachedError
+	return !errors.As(err, &ce) && !isContextErr(err)
+}
+
+// backoff returns the wait after the failed attempt.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier == 0 {
+		multiplier = 2
+	}
+	d := float64(p.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		d *= multiplier
+		if p.MaxBackoff > 0 && d >= float64(p.MaxBackoff) {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		d -= d * p.Jitter * rand.Float64()
+	}
+	return time.Duration(d)
+}
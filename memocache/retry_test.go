@@ -0,0 +1,252 @@
+package memocache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithRetry(t *testing.T) {
+	errFail := errors.New("transient")
+	for name, c := range map[string]ExtendedCache{
+		"Cache":   NewCache(&sync.Map{}, WithRetry(RetryPolicy{MaxAttempts: 3})),
+		"RRCache": NewRRCacheLocal(10, 5, nil, WithRetry(RetryPolicy{MaxAttempts: 3})),
+	} {
+		var calls atomic.Int32
+		release := make(chan struct{})
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				value, err := c.LoadOrCallErr("k", func() (interface{}, error) {
+					<-release
+					if calls.Add(1) < 3 {
+						return nil, errFail
+					}
+					return "v", nil
+				})
+				if value != "v" || err != nil {
+					t.Errorf("%s: LoadOrCallErr() = %v, %v, want v, nil", name, value, err)
+				}
+			}()
+		}
+		close(release)
+		wg.Wait()
+		if n := calls.Load(); n != 3 {
+			t.Errorf("%s: loader called %d times, want 3 for all the callers", name, n)
+		}
+	}
+}
+
+func TestWithRetry_GiveUp(t *testing.T) {
+	errFail := errors.New("down")
+	c := NewCache(&sync.Map{}, WithRetry(RetryPolicy{MaxAttempts: 3}))
+	calls := 0
+	load := func() (interface{}, error) {
+		calls++
+		return nil, errFail
+	}
+	if _, err := c.LoadOrCallErr("k", load); err != errFail {
+		t.Errorf("LoadOrCallErr() error = %v, want %v", err, errFail)
+	}
+	if calls != 3 {
+		t.Errorf("loader called %d times, want 3", calls)
+	}
+	c.LoadOrCallErr("k", load)
+	if calls != 6 {
+		t.Errorf("loader called %d times after another call, want 6 since the error isn't cached", calls)
+	}
+}
+
+func TestWithRetry_SharedFailure(t *testing.T) {
+	const numWaiters = 4
+	errFail := errors.New("down")
+	for name, c := range map[string]ExtendedCache{
+		"Cache":   NewCache(&sync.Map{}, WithRetry(RetryPolicy{MaxAttempts: 3})),
+		"RRCache": NewRRCacheLocal(10, 5, nil, WithRetry(RetryPolicy{MaxAttempts: 3})),
+	} {
+		var calls atomic.Int32
+		started := make(chan struct{})
+		release := make(chan struct{})
+		load := func(ctx context.Context) (interface{}, error) {
+			if calls.Add(1) == 1 {
+				close(started)
+				<-release
+			}
+			return nil, errFail
+		}
+		errs := make(chan error, numWaiters+1)
+		go func() {
+			_, err := c.LoadOrCallCtx(context.Background(), "k", load)
+			errs <- err
+		}()
+		<-started
+		waiting := make(chan struct{}, numWaiters)
+		for i := 0; i < numWaiters; i++ {
+			go func() {
+				_, err := c.LoadOrCallCtx(waitingContext{context.Background(), waiting}, "k", load)
+				errs <- err
+			}()
+		}
+		for i := 0; i < numWaiters; i++ {
+			<-waiting
+		}
+		close(release)
+		for i := 0; i < numWaiters+1; i++ {
+			if err := <-errs; err != errFail {
+				t.Errorf("%s: LoadOrCallCtx() error = %v, want %v", name, err, errFail)
+			}
+		}
+		if n := calls.Load(); n != 3 {
+			t.Errorf("%s: loader called %d times, want 3 for all the callers", name, n)
+		}
+	}
+}
+
+func TestWithRetry_NotRetryable(t *testing.T) {
+	c := NewCache(&sync.Map{}, WithRetry(RetryPolicy{MaxAttempts: 5}))
+	calls := 0
+	_, err := c.LoadOrCallErr("missing", func() (interface{}, error) {
+		calls++
+		return nil, NewCachedError("missing", errors.New("not found"))
+	})
+	var ce *CachedError
+	if !errors.As(err, &ce) || calls != 1 || ce.Attempts != 1 {
+		t.Errorf("LoadOrCallErr() = %v after %d calls, want a cached error after 1", err, calls)
+	}
+
+	calls = 0
+	_, err = c.LoadOrCallCtx(context.Background(), "ctx", func(ctx context.Context) (interface{}, error) {
+		calls++
+		return nil, context.DeadlineExceeded
+	})
+	if err != context.DeadlineExceeded || calls != 1 {
+		t.Errorf("LoadOrCallCtx() = %v after %d calls, want %v after 1", err, calls, context.DeadlineExceeded)
+	}
+
+	c = NewCache(&sync.Map{}, WithRetry(RetryPolicy{MaxAttempts: 5, Retryable: func(err error) bool {
+		return !errors.Is(err, errNotLoaded)
+	}}))
+	calls = 0
+	_, err = c.LoadOrCallErr("k", func() (interface{}, error) {
+		if calls++; calls < 3 {
+			return nil, errors.New("retryable")
+		}
+		return nil, NewCachedError("k", errNotLoaded)
+	})
+	if !errors.As(err, &ce) || calls != 3 || ce.Attempts != 3 {
+		t.Errorf("LoadOrCallErr() = %v after %d calls with %d attempts, want a cached error after 3", err, calls, ce.Attempts)
+	}
+}
+
+func TestWithRetry_Backoff(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCache(&sync.Map{}, WithClock(clock), WithRetry(RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Second,
+	}))
+	var calls atomic.Int32
+	done := make(chan interface{})
+	go func() {
+		value, _ := c.LoadOrCallErr("k", func() (interface{}, error) {
+			if calls.Add(1) < 3 {
+				return nil, errors.New("transient")
+			}
+			return "v", nil
+		})
+		done <- value
+	}()
+	for _, d := range []time.Duration{time.Second, 2 * time.Second} {
+		if !waitFor(func() bool { return clock.numTimers() == 1 }) {
+			t.Fatalf("no backoff after %d calls", calls.Load())
+		}
+		clock.Advance(d - time.Nanosecond)
+		if clock.numTimers() != 1 {
+			t.Fatalf("retried before the backoff of %v", d)
+		}
+		clock.Advance(time.Nanosecond)
+	}
+	if got := <-done; got != "v" {
+		t.Errorf("LoadOrCallErr() = %v, want v", got)
+	}
+}
+
+func TestWithRetry_BackoffCanceled(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCache(&sync.Map{}, WithClock(clock), WithRetry(RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Hour,
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := c.LoadOrCallCtx(ctx, "k", func(ctx context.Context) (interface{}, error) {
+			return nil, errors.New("transient")
+		})
+		done <- err
+	}()
+	if !waitFor(func() bool { return clock.numTimers() == 1 }) {
+		t.Fatal("no backoff after the failed call")
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("LoadOrCallCtx() error = %v, want %v", err, context.Canceled)
+	}
+	if n := clock.numTimers(); n != 0 {
+		t.Errorf("%d timers left, want the backoff stopped", n)
+	}
+}
+
+func TestWithRetry_BackoffReleasesSlot(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCache(&sync.Map{}, WithClock(clock), WithMaxConcurrentLoads(1), WithRetry(RetryPolicy{
+		MaxAttempts:    2,
+		InitialBackoff: time.Hour,
+	}))
+	done := make(chan interface{})
+	var calls atomic.Int32
+	go func() {
+		value, _ := c.LoadOrCallErr("retried", func() (interface{}, error) {
+			if calls.Add(1) == 1 {
+				return nil, errors.New("transient")
+			}
+			return "v", nil
+		})
+		done <- value
+	}()
+	if !waitFor(func() bool { return clock.numTimers() == 1 }) {
+		t.Fatal("no backoff after the failed call")
+	}
+	if got := c.LoadOrCall("other", func() interface{} { return 1 }); got != 1 {
+		t.Errorf("LoadOrCall() during the backoff = %v, want 1", got)
+	}
+	clock.Advance(time.Hour)
+	if got := <-done; got != "v" {
+		t.Errorf("LoadOrCallErr() = %v, want v", got)
+	}
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 3}
+	for attempt, want := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 300 * time.Millisecond,
+		3: 900 * time.Millisecond,
+		4: time.Second,
+		9: time.Second,
+	} {
+		if got := p.backoff(attempt); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := p.backoff(2); got < 150*time.Millisecond || got > 300*time.Millisecond {
+			t.Fatalf("backoff(2) with jitter = %v, want between 150ms and 300ms", got)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Package ristretto adapts a github.com/dgraph-io/ristretto cache as the
+// backing map of a memocache.Cache, so that the once-per-key LoadOrCall and
+// the MultiLevelMap of memocache can be layered on top of an existing
+// ristretto cache without migrating the storage.
+//
+//	r, err := ristretto.NewCache(&ristretto.Config{
+//		NumCounters: 1e5,
+//		MaxCost:     1e4,
+//		BufferItems: 64,
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	c := memocache.NewCache(memoristretto.New(r))
+//
+// Ristretto only accepts keys of the types its KeyToHash function supports,
+// e.g. strings and integers, and every entry costs 1. Since ristretto may drop
+// a new entry at admission, concurrent calls of a key that was just dropped
+// may call their loaders more than once.
+package ristretto
+
+import (
+	"sync"
+
+	"github.com/dgraph-io/ristretto"
+	"github.com/jaeyeom/gomemocache/memocache"
+)
+
+// Map is a memocache.MapInterface backed by a ristretto cache. Besides
+// LoadOrStore and Delete, it has the optional Load, Store, Clear,
+// CompareAndSwap and CompareAndDelete methods that memocache.Cache uses if
+// present.
+type Map struct {
+	c  *ristretto.Cache
+	mu sync.Mutex // Serializes writes so that LoadOrStore and the comparisons are atomic
+
+	// pending has the values set but maybe not yet applied by ristretto, so
+	// that they are visible while their sets are waited for out of mu.
+	pending map[interface{}]*pendingSet
+}
+
+// pendingSet is a value set in ristretto that isn't known to be applied yet.
+type pendingSet struct {
+	value interface{}
+}
+
+var _ memocache.MapInterface = (*Map)(nil)
+
+// New returns a new Map backed by c. The entries in c should only be added
+// through the Map.
+func New(c *ristretto.Cache) *Map {
+	return &Map{c: c, pending: map[interface{}]*pendingSet{}}
+}
+
+// LoadOrStore returns the existing value for the key if present. Otherwise, it
+// stores and returns the given value. The loaded result is true if the value
+// was loaded, false if stored. A store waits until ristretto has applied it,
+// but other writes don't wait for it, so a stored value is visible to the
+// next LoadOrStore unless ristretto dropped it.
+func (m *Map) LoadOrStore(key, value interface{}) (actual interface{}, loaded bool) {
+	if v, ok := m.c.Get(key); ok {
+		return v, true
+	}
+	m.mu.Lock()
+	if v, ok := m.getLocked(key); ok {
+		m.mu.Unlock()
+		return v, true
+	}
+	p := m.setLocked(key, value)
+	m.mu.Unlock()
+	m.wait(key, p)
+	return value, false
+}
+
+// Load returns the value for the key if present.
+func (m *Map) Load(key interface{}) (value interface{}, ok bool) {
+	if v, ok := m.c.Get(key); ok {
+		return v, true
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.getLocked(key)
+}
+
+// Store sets the value for the key.
+func (m *Map) Store(key, value interface{}) {
+	m.mu.Lock()
+	p := m.setLocked(key, value)
+	m.mu.Unlock()
+	m.wait(key, p)
+}
+
+// getLocked returns the value for the key, including a pending one. It should
+// be called with m.mu held.
+func (m *Map) getLocked(key interface{}) (value interface{}, ok bool) {
+	if p, ok := m.pending[key]; ok {
+		return p.value, true
+	}
+	return m.c.Get(key)
+}
+
+// setLocked sets the value for the key and returns its pending set to wait
+// for, or nil if ristretto dropped it. It should be called with m.mu held.
+func (m *Map) setLocked(key, value interface{}) *pendingSet {
+	if !m.c.Set(key, value, 1) {
+		delete(m.pending, key)
+		return nil
+	}
+	p := &pendingSet{value: value}
+	m.pending[key] = p
+	return p
+}
+
+// deleteLocked deletes the value for the key. It should be called with m.mu
+// held.
+func (m *Map) deleteLocked(key interface{}) {
+	delete(m.pending, key)
+	m.c.Del(key)
+}
+
+// wait waits until ristretto has applied the set p of the key if any, and
+// removes it from the pending sets unless it has been replaced.
+func (m *Map) wait(key interface{}, p *pendingSet) {
+	if p == nil {
+		return
+	}
+	m.c.Wait()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.pending[key] == p {
+		delete(m.pending, key)
+	}
+}
+
+// Delete deletes the value for the key.
+func (m *Map) Delete(key interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deleteLocked(key)
+}
+
+// CompareAndSwap swaps the old and new values for the key if the value stored
+// in the map is equal to old.
+func (m *Map) CompareAndSwap(key, old, new interface{}) (swapped bool) {
+	m.mu.Lock()
+	if v, ok := m.getLocked(key); !ok || v != old {
+		m.mu.Unlock()
+		return false
+	}
+	p := m.setLocked(key, new)
+	m.mu.Unlock()
+	m.wait(key, p)
+	return true
+}
+
+// CompareAndDelete deletes the entry for the key if its value is equal to old.
+func (m *Map) CompareAndDelete(key, old interface{}) (deleted bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if v, ok := m.getLocked(key); !ok || v != old {
+		return false
+	}
+	m.deleteLocked(key)
+	return true
+}
+
+// Clear deletes all the values.
+func (m *Map) Clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pending = map[interface{}]*pendingSet{}
+	m.c.Clear()
+}
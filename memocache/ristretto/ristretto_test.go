@@ -0,0 +1,148 @@
+package ristretto
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/ristretto"
+	"github.com/jaeyeom/gomemocache/memocache"
+)
+
+func TestMap(t *testing.T) {
+	r, err := ristretto.NewCache(&ristretto.Config{
+		NumCounters: 1000,
+		MaxCost:     100,
+		BufferItems: 64,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	c := memocache.NewCache(New(r))
+
+	var calls int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.LoadOrCall("k", func() interface{} {
+				atomic.AddInt32(&calls, 1)
+				return 1
+			})
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("loader called %d times, want 1", calls)
+	}
+
+	c.Store("k", 2)
+	if v, ok := c.Load("k"); !ok || v != 2 {
+		t.Errorf("Load(k) = %v, %v, want 2, true", v, ok)
+	}
+	c.Delete("k")
+	if v := c.LoadOrCall("k", func() interface{} { return 3 }); v != 3 {
+		t.Errorf("LoadOrCall(k) = %v after Delete(), want 3", v)
+	}
+}
+
+func TestMap_Concurrent(t *testing.T) {
+	r, err := ristretto.NewCache(&ristretto.Config{
+		NumCounters:        10000,
+		MaxCost:            1000,
+		IgnoreInternalCost: true,
+		BufferItems:        64,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	m := New(r)
+	c := memocache.NewCache(m)
+	var calls [100]int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := range calls {
+				k := k
+				c.LoadOrCall(k, func() interface{} {
+					atomic.AddInt32(&calls[k], 1)
+					return k
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	for k, n := range calls {
+		if n != 1 {
+			t.Errorf("loader of %d called %d times, want 1", k, n)
+		}
+	}
+	if n := len(m.pending); n != 0 {
+		t.Errorf("%d sets still pending after they were waited for", n)
+	}
+}
+
+func TestMap_Pending(t *testing.T) {
+	r, err := ristretto.NewCache(&ristretto.Config{
+		NumCounters: 1000,
+		MaxCost:     100,
+		BufferItems: 64,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	m := New(r)
+	// A set that ristretto hasn't applied yet.
+	m.pending["k"] = &pendingSet{value: 1}
+	if v, ok := m.Load("k"); !ok || v != 1 {
+		t.Errorf("Load(k) = %v, %v, want the pending 1, true", v, ok)
+	}
+	if v, loaded := m.LoadOrStore("k", 2); !loaded || v != 1 {
+		t.Errorf("LoadOrStore(k, 2) = %v, %v, want the pending 1, true", v, loaded)
+	}
+	if m.CompareAndSwap("k", 2, 3) {
+		t.Error("CompareAndSwap(k, 2, 3) = true, want false for the pending 1")
+	}
+	if !m.CompareAndDelete("k", 1) {
+		t.Error("CompareAndDelete(k, 1) = false, want true for the pending 1")
+	}
+	if v, ok := m.Load("k"); ok {
+		t.Errorf("Load(k) = %v, true after CompareAndDelete(), want false", v)
+	}
+}
+
+func TestMap_TTL(t *testing.T) {
+	r, err := ristretto.NewCache(&ristretto.Config{
+		NumCounters: 1000,
+		MaxCost:     100,
+		BufferItems: 64,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	c := memocache.NewCache(New(r), memocache.WithClock(clock), memocache.WithTTL(time.Minute))
+	c.LoadOrCall("a", func() interface{} { return 1 })
+	clock.now = clock.now.Add(time.Minute)
+	if v := c.LoadOrCall("a", func() interface{} { return 2 }); v != 2 {
+		t.Errorf("LoadOrCall(a) = %v after expiry, want 2", v)
+	}
+}
+
+// fakeClock is a memocache.Clock that only moves when told to.
+type fakeClock struct {
+	memocache.Clock
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
@@ -0,0 +1,41 @@
+package memocache
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// NewRRCacheLocal returns a new random replacement cache that counts its own
+// entries, for use on its own rather than as a level of a MultiLevelMap. See
+// NewRRCache for maxSize and targetNum. The evicted entries are picked with r,
+// which is guarded by a lock since *rand.Rand isn't safe for concurrent use,
+// or with the global source of math/rand if r is nil.
+func NewRRCacheLocal(maxSize, targetNum int32, r *rand.Rand, opts ...Option) *RRCache {
+	return NewRRCache(new(int32), maxSize, targetNum, lockedIntn(r), opts...)
+}
+
+// NewRRCacheLevels returns a newMap function for NewMultiLevelMap making random
+// replacement caches that share a size counter, so that maxSize bounds the
+// entries of all the levels together. The arguments are as for
+// NewRRCacheLocal, and opts are given to every level.
+func NewRRCacheLevels(maxSize, targetNum int32, r *rand.Rand, opts ...Option) func() CacheInterface {
+	currentSize := new(int32)
+	intn := lockedIntn(r)
+	return func() CacheInterface {
+		return NewRRCache(currentSize, maxSize, targetNum, intn, opts...)
+	}
+}
+
+// lockedIntn returns the Intn of r guarded by a lock, or rand.Intn if r is
+// nil.
+func lockedIntn(r *rand.Rand) func(n int) int {
+	if r == nil {
+		return rand.Intn
+	}
+	var mu sync.Mutex
+	return func(n int) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return r.Intn(n)
+	}
+}
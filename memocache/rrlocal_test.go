@@ -0,0 +1,40 @@
+package memocache
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestNewRRCacheLocal(t *testing.T) {
+	c := NewRRCacheLocal(10, 5, rand.New(rand.NewSource(1)))
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				key := i*100 + j
+				c.LoadOrCall(key, func() interface{} { return key })
+			}
+		}(i)
+	}
+	wg.Wait()
+	if got := c.Size(); got > 10 {
+		t.Errorf("Size() = %d, want at most 10", got)
+	}
+	if NewRRCacheLocal(10, 5, nil).Size() != 0 {
+		t.Error("caches made by NewRRCacheLocal share their size")
+	}
+}
+
+func TestNewRRCacheLevels(t *testing.T) {
+	m := NewMultiLevelMap(NewRRCacheLevels(20, 10, nil))
+	for i := 0; i < 100; i++ {
+		m.LoadOrCall(func() interface{} { return i }, i%5, i)
+	}
+	root, _ := m.v.Load()
+	if got := root.(*RRCache).Size(); got > 20 {
+		t.Errorf("Size() = %d, want at most 20 entries in all the levels", got)
+	}
+}
@@ -0,0 +1,52 @@
+package memocache
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// WithEvictionSeed makes the evictions of an RRCache reproducible, e.g. for
+// tests asserting which entries survive. The evicted entries are picked with a
+// source of math/rand seeded with seed, which replaces the intn given to
+// NewRRCache, and the entries are visited in the order of their keys
+// formatted with fmt.Sprintf("%T:%v") rather than in the random order of a
+// sync.Map. The same operations on caches with the same seed then evict the
+// same keys, provided that the keys format distinctly. The evictions sort the
+// keys, so the option is meant for tests rather than for large caches.
+func WithEvictionSeed(seed int64) Option {
+	return func(c *config) {
+		c.deterministic = true
+		c.evictionSeed = seed
+	}
+}
+
+// seededIntn returns the Intn of a source of math/rand seeded with seed,
+// guarded by a lock.
+func seededIntn(seed int64) func(n int) int {
+	return lockedIntn(rand.New(rand.NewSource(seed)))
+}
+
+// rangeEntries calls f for the entries of the cache like Range of sync.Map,
+// in the order of their keys if the evictions are deterministic.
+func (r *RRCache) rangeEntries(f func(key, value interface{}) bool) {
+	if !r.config.deterministic {
+		r.m.Range(f)
+		return
+	}
+	type entry struct {
+		order      string
+		key, value interface{}
+	}
+	var entries []entry
+	r.m.Range(func(key, value interface{}) bool {
+		entries = append(entries, entry{fmt.Sprintf("%T:%v", key, key), key, value})
+		return true
+	})
+	sort.Slice(entries, func(i, j int) bool { return entries[i].order < entries[j].order })
+	for _, e := range entries {
+		if !f(e.key, e.value) {
+			return
+		}
+	}
+}
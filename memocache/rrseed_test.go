@@ -0,0 +1,61 @@
+package memocache
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestWithEvictionSeed(t *testing.T) {
+	survivors := func(seed int64) []int {
+		r := NewRRCacheLocal(20, 10, nil, WithEvictionSeed(seed))
+		for i := 0; i < 100; i++ {
+			r.LoadOrCall(i, func() interface{} { return i })
+		}
+		var keys []int
+		for i := 0; i < 100; i++ {
+			if _, ok := r.m.Load(i); ok {
+				keys = append(keys, i)
+			}
+		}
+		return keys
+	}
+	want := survivors(1)
+	if len(want) == 0 || len(want) > 20 {
+		t.Fatalf("survivors = %v, want 1 to 20 keys", want)
+	}
+	for i := 0; i < 10; i++ {
+		if got := survivors(1); !reflect.DeepEqual(got, want) {
+			t.Fatalf("survivors = %v, want %v of the same seed", got, want)
+		}
+	}
+	if got := survivors(2); reflect.DeepEqual(got, want) {
+		t.Errorf("survivors = %v of another seed, want different", got)
+	}
+}
+
+func TestWithEvictionSeed_Levels(t *testing.T) {
+	survivors := func() string {
+		m := NewMultiLevelMap(NewRRCacheLevels(20, 10, nil, WithEvictionSeed(7)))
+		for i := 0; i < 10; i++ {
+			for j := 0; j < 10; j++ {
+				m.LoadOrCall(func() interface{} { return j }, i, j)
+			}
+		}
+		var s string
+		for i := 0; i < 10; i++ {
+			for j := 0; j < 10; j++ {
+				if _, ok := m.Load(i, j); ok {
+					s += fmt.Sprint(i, j, " ")
+				}
+			}
+		}
+		return s
+	}
+	want := survivors()
+	for i := 0; i < 10; i++ {
+		if got := survivors(); got != want {
+			t.Fatalf("survivors = %q, want %q", got, want)
+		}
+	}
+}
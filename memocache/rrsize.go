@@ -0,0 +1,95 @@
+package memocache
+
+import "sync/atomic"
+
+// sizeTracker counts the entries of the RRCaches sharing a capacity, e.g. the
+// levels of a MultiLevelMap. An entry is counted from when it's added to the
+// map of a cache until it's removed. When a node is removed from the tree, the
+// caches of its subtree are detached: their entries are uncounted at once and
+// the entries added to them later aren't counted, so removing a branch
+// subtracts exactly the entries it holds, even while it's being written.
+type sizeTracker struct {
+	n *int32
+}
+
+// len returns the number of counted entries.
+func (t sizeTracker) len() int32 {
+	return atomic.LoadInt32(t.n)
+}
+
+// add adds delta to the number of counted entries.
+func (t sizeTracker) add(delta int32) {
+	atomic.AddInt32(t.n, delta)
+}
+
+// countAdded counts an entry added to r. It should be called with r.mu held.
+func (r *RRCache) countAdded() {
+	if !r.detached {
+		r.own++
+		r.size.add(1)
+	}
+}
+
+// countRemoved uncounts an entry removed from r. It should be called with r.mu
+// held.
+func (r *RRCache) countRemoved() {
+	if !r.detached {
+		r.own--
+		r.size.add(-1)
+	}
+}
+
+// remove removes the entry v of the key if it's still in r.
+func (r *RRCache) remove(key interface{}, v *Value) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.m.CompareAndDelete(key, v) {
+		r.countRemoved()
+	}
+}
+
+// adopt registers the cache child loaded as the entry v of the key as a level
+// below r, or detaches it if r or the entry has been removed meanwhile.
+func (r *RRCache) adopt(key interface{}, v *Value, child *RRCache) {
+	r.mu.Lock()
+	if e, ok := r.m.Load(key); r.detached || !ok || e != v {
+		r.mu.Unlock()
+		child.detach()
+		return
+	}
+	if r.children == nil {
+		r.children = make(map[*RRCache]bool)
+	}
+	r.children[child] = true
+	r.mu.Unlock()
+}
+
+// dropChild unregisters the level below held by the removed entry e if any
+// and returns it. It should be called with r.mu held.
+func (r *RRCache) dropChild(e interface{}) *RRCache {
+	child, ok := rrChild(e)
+	if !ok || !r.children[child] {
+		return nil
+	}
+	delete(r.children, child)
+	return child
+}
+
+// detach uncounts the entries of r and of the levels below it and stops
+// counting them.
+func (r *RRCache) detach() {
+	r.mu.Lock()
+	if r.detached {
+		r.mu.Unlock()
+		return
+	}
+	r.detached = true
+	r.size.add(-r.own)
+	r.own = 0
+	children := r.children
+	r.children = nil
+	r.mu.Unlock()
+	for child := range children {
+		child.detach()
+	}
+}
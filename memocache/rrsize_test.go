@@ -0,0 +1,73 @@
+package memocache
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRRCache_BranchSize(t *testing.T) {
+	var size int32
+	m := NewMultiLevelMap(func() CacheInterface {
+		return NewRRCache(&size, 1000, 500, rand.Intn)
+	})
+	for i := 0; i < 3; i++ {
+		m.LoadOrCall(func() interface{} { return i }, "a", "b", i)
+	}
+	m.LoadOrCall(func() interface{} { return 0 }, "c", 0)
+	// Nodes a, a/b and c, and the 4 values.
+	if size != 7 {
+		t.Fatalf("size = %d, want 7", size)
+	}
+	m.Prune("a")
+	if size != 2 {
+		t.Errorf("size after Prune(a) = %d, want the 2 entries of c", size)
+	}
+	m.LoadOrCall(func() interface{} { return 0 }, "a", "b", 0)
+	if size != 5 {
+		t.Errorf("size after reloading a/b/0 = %d, want 5", size)
+	}
+	m.Clear()
+	if size != 0 {
+		t.Errorf("size after Clear() = %d, want 0", size)
+	}
+}
+
+func TestRRCache_SizeFailedLoads(t *testing.T) {
+	r := NewRRCacheLocal(100, 50, nil)
+	r.LoadOrCallErr("a", func() (interface{}, error) { return nil, errors.New("fail") })
+	mustPanic(t, func() {
+		r.LoadOrCall("b", func() interface{} { panic("fail") })
+	})
+	r.LoadOrCallErr("c", func() (interface{}, error) { return nil, &CachedError{Key: "c", Err: errors.New("fail")} })
+	if got := r.Size(); got != 1 {
+		t.Errorf("Size() = %d, want only the cached error", got)
+	}
+}
+
+func TestRRCache_SizeConcurrentPrune(t *testing.T) {
+	var size int32
+	m := NewMultiLevelMap(func() CacheInterface {
+		return NewRRCache(&size, 1<<20, 1<<19, rand.Intn)
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				m.LoadOrCall(func() interface{} { return j }, j%3, i, j)
+				if j%100 == 0 {
+					m.Prune(j % 3)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	m.Clear()
+	if got := atomic.LoadInt32(&size); got != 0 {
+		t.Errorf("size after Clear() = %d, want 0", got)
+	}
+}
@@ -0,0 +1,125 @@
+package memocache
+
+import (
+	"math/rand"
+	"time"
+)
+
+// EntryInfo is the metadata of a cached entry.
+type EntryInfo struct {
+	// Key is the key of the entry.
+	Key interface{}
+	// Age is the time since the value was loaded or stored.
+	Age time.Duration
+	// Size is the size of the value in bytes estimated by the function
+	// given by WithSizer. It's zero without WithSizer.
+	Size int64
+	// Hits is the number of calls served by the entry without calling a
+	// loader.
+	Hits uint64
+	// LastAccess is the time of the last hit of the entry, or of its load if
+	// it hasn't been hit. Hits are timed only with WithAccessTracking, so
+	// it's the time of the load without the option.
+	LastAccess time.Time
+	// TTL is the time left until the value expires, or zero if it doesn't
+	// expire.
+	TTL time.Duration
+}
+
+// WithAccessTracking makes a Cache record the time of the last hit of every
+// entry for EntryInfo.LastAccess. It costs a reading of the clock per hit.
+func WithAccessTracking() Option {
+	return func(c *config) {
+		c.trackAccess = true
+	}
+}
+
+// WithSizer sets a function that estimates the size of a value in bytes. The
+// estimate is reported by Cache.Sample.
+func WithSizer(sizer func(value interface{}) int64) Option {
+	return func(c *config) {
+		c.sizer = sizer
+	}
+}
+
+// Sample returns a uniform random sample of up to n entries with their
+// metadata, e.g. for an audit of what fraction of cached bytes were never hit.
+// Entries whose values are still being loaded or have expired are not
+// sampled. It returns nil if the backing map doesn't have a Range method like
+// *sync.Map has.
+func (c *Cache) Sample(n int) []EntryInfo {
+	m, ok := c.m.(mapRanger)
+	if !ok || n <= 0 {
+		return nil
+	}
+	now := now(c.config.clock).UnixNano()
+	var sample []EntryInfo
+	seen := 0
+	m.Range(func(key, e interface{}) bool {
+		v := e.(*Value)
+		s := v.state.Load()
+		if s == nil || s.expired() {
+			return true
+		}
+		seen++
+		i := len(sample)
+		if i == n {
+			// Reservoir sampling: replace a random entry with
+			// probability n/seen.
+			i = rand.Intn(seen)
+			if i >= n {
+				return true
+			}
+		} else {
+			sample = append(sample, EntryInfo{})
+		}
+		sample[i] = c.entryInfo(key, v, s, now)
+		return true
+	})
+	return sample
+}
+
+// GetEntry returns the metadata of the entry of the key, e.g. to show the age
+// and the hits of an entry on a dashboard. It returns false if the key isn't
+// cached, its value is still being loaded or it has expired, or if the backing
+// map has neither a Peek nor a Load method. It doesn't count as a hit.
+func (c *Cache) GetEntry(key interface{}) (EntryInfo, bool) {
+	var e interface{}
+	var ok bool
+	if m, isPeeker := c.m.(mapPeeker); isPeeker {
+		e, ok = m.Peek(key)
+	} else if m, isLoader := c.m.(mapLoader); isLoader {
+		e, ok = m.Load(key)
+	}
+	if !ok {
+		return EntryInfo{}, false
+	}
+	v := e.(*Value)
+	s := v.state.Load()
+	if s == nil || s.expired() {
+		return EntryInfo{}, false
+	}
+	return c.entryInfo(key, v, s, now(c.config.clock).UnixNano()), true
+}
+
+// entryInfo returns the metadata of the entry v of the key in the state s at
+// the time now in Unix nanoseconds.
+func (c *Cache) entryInfo(key interface{}, v *Value, s *valueState, now int64) EntryInfo {
+	info := EntryInfo{
+		Key:  key,
+		Age:  time.Duration(now - s.created),
+		Hits: v.hits.Load(),
+	}
+	accessed := v.accessed.Load()
+	if accessed < s.created {
+		accessed = s.created
+	}
+	info.LastAccess = time.Unix(0, accessed)
+	if s.expires != 0 {
+		info.TTL = time.Duration(s.expires - now)
+	}
+	if c.config.sizer != nil {
+		info.Size = c.config.sizer(s.value)
+	}
+	return info
+}
@@ -0,0 +1,94 @@
+package memocache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func ExampleCache_Sample() {
+	c := NewCache(&sync.Map{}, WithSizer(func(value interface{}) int64 {
+		return int64(len(value.(string)))
+	}))
+	c.LoadOrCall("greeting", func() interface{} { return "hello" })
+	c.LoadOrCall("greeting", func() interface{} { return "hi" })
+	c.LoadOrCall("greeting", func() interface{} { return "hey" })
+
+	for _, info := range c.Sample(10) {
+		fmt.Printf("%v: %d bytes, %d hits\n", info.Key, info.Size, info.Hits)
+	}
+	// Output:
+	// greeting: 5 bytes, 2 hits
+}
+
+func TestCache_Sample(t *testing.T) {
+	c := NewCache(&sync.Map{})
+	for i := 0; i < 100; i++ {
+		c.Store(i, i)
+	}
+
+	sample := c.Sample(10)
+	if len(sample) != 10 {
+		t.Fatalf("len(Sample(10)) = %d, want 10", len(sample))
+	}
+	seen := map[interface{}]bool{}
+	for _, info := range sample {
+		if seen[info.Key] {
+			t.Errorf("Sample() has duplicate key %v", info.Key)
+		}
+		seen[info.Key] = true
+	}
+	if got := len(c.Sample(1000)); got != 100 {
+		t.Errorf("len(Sample(1000)) = %d, want all 100 entries", got)
+	}
+}
+
+func TestCache_GetEntry(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCache(&sync.Map{}, WithClock(clock), WithTTL(time.Minute), WithAccessTracking())
+	if _, ok := c.GetEntry("a"); ok {
+		t.Error("GetEntry() of a missing key = true, want false")
+	}
+	loaded := clock.Now()
+	c.LoadOrCall("a", func() interface{} { return 1 })
+	clock.Advance(10 * time.Second)
+	accessed := clock.Now()
+	c.LoadOrCall("a", func() interface{} { return 2 })
+	c.LoadOrCall("a", func() interface{} { return 2 })
+	clock.Advance(5 * time.Second)
+
+	info, ok := c.GetEntry("a")
+	if !ok {
+		t.Fatal("GetEntry() = false, want true")
+	}
+	want := EntryInfo{Key: "a", Age: 15 * time.Second, Hits: 2, LastAccess: accessed, TTL: 45 * time.Second}
+	if info != want {
+		t.Errorf("GetEntry() = %+v, want %+v", info, want)
+	}
+	if info.LastAccess.Equal(loaded) {
+		t.Errorf("LastAccess = %v, want the time of the last hit", info.LastAccess)
+	}
+
+	clock.Advance(time.Minute)
+	if _, ok := c.GetEntry("a"); ok {
+		t.Error("GetEntry() of an expired key = true, want false")
+	}
+}
+
+func TestCache_GetEntryWithoutAccessTracking(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCache(&sync.Map{}, WithClock(clock))
+	loaded := clock.Now()
+	c.LoadOrCall("a", func() interface{} { return 1 })
+	clock.Advance(time.Second)
+	c.LoadOrCall("a", func() interface{} { return 1 })
+
+	info, ok := c.GetEntry("a")
+	if !ok {
+		t.Fatal("GetEntry() = false, want true")
+	}
+	if !info.LastAccess.Equal(loaded) || info.TTL != 0 || info.Hits != 1 {
+		t.Errorf("GetEntry() = %+v, want LastAccess %v, no TTL and 1 hit", info, loaded)
+	}
+}
@@ -0,0 +1,271 @@
+package memocache
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+)
+
+// SampledLRUMap is a bounded map approximating LRU by sampling, like Redis:
+// when the map is full, it samples a few random keys and evicts the least
+// recently used of them. A hit only stores a logical timestamp in its entry
+// under a read lock, so concurrent hits don't contend on a list, and the
+// entries need no list elements. The more keys are sampled, the closer the
+// evictions are to LRU and the slower the inserts into a full map; 5 samples
+// evict nearly as well as LRU and much better than random replacement.
+// SampledLRUMap should be created with NewSampledLRUMap.
+type SampledLRUMap struct {
+	mu      sync.RWMutex
+	m       map[interface{}]*sampledEntry
+	entries []*sampledEntry // Entries in no particular order, for sampling
+	tick    atomic.Int64    // Logical clock of the accesses
+	rand    *rand.Rand      // Guarded by the write lock of mu
+	maxSize int
+	samples int
+
+	onEvict func(key, value interface{}, reason EvictionReason)
+	pending []removal // Removed values to notify of, guarded by mu
+}
+
+// sampledEntry is an entry of a SampledLRUMap.
+type sampledEntry struct {
+	key, value interface{}
+	index      int          // Index in entries
+	access     atomic.Int64 // Tick of the last access
+}
+
+// NewSampledLRUMap returns a new SampledLRUMap holding up to maxSize keys and
+// sampling the given number of keys per eviction. A non-positive number of
+// samples means 5.
+func NewSampledLRUMap(maxSize, samples int) *SampledLRUMap {
+	if samples <= 0 {
+		samples = 5
+	}
+	return &SampledLRUMap{
+		m:       make(map[interface{}]*sampledEntry),
+		rand:    rand.New(rand.NewSource(rand.Int63())),
+		maxSize: maxSize,
+		samples: samples,
+	}
+}
+
+// NewSampledLRUCache returns a new cache backed by a SampledLRUMap holding up
+// to maxSize keys and sampling the given number of keys per eviction.
+func NewSampledLRUCache(maxSize, samples int, opts ...Option) *Cache {
+	return NewCache(NewSampledLRUMap(maxSize, samples), opts...)
+}
+
+// SetOnEvict sets a function that is called with every value removed from the
+// map and the reason of the removal. It's called after the lock of the map is
+// released, so it may call the methods of the map. SetOnEvict should be called
+// before the map is used. A Cache wrapping the map installs its own listener,
+// which calls the one set before.
+func (s *SampledLRUMap) SetOnEvict(f func(key, value interface{}, reason EvictionReason)) {
+	s.onEvict = f
+}
+
+// evictListener returns the listener set by SetOnEvict.
+func (s *SampledLRUMap) evictListener() func(key, value interface{}, reason EvictionReason) {
+	return s.onEvict
+}
+
+// unlock releases the lock and notifies the listener of the values removed
+// while it was held.
+func (s *SampledLRUMap) unlock() {
+	pending := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+	for _, ev := range pending {
+		s.onEvict(ev.key, ev.value, ev.reason)
+	}
+}
+
+// removed records the removal of the entry to notify of. It should be called
+// with s.mu held.
+func (s *SampledLRUMap) removed(en *sampledEntry, reason EvictionReason) {
+	if s.onEvict != nil {
+		s.pending = append(s.pending, removal{key: en.key, value: en.value, reason: reason})
+	}
+}
+
+// touch marks the entry as used now.
+func (s *SampledLRUMap) touch(en *sampledEntry) {
+	en.access.Store(s.tick.Add(1))
+}
+
+// LoadOrStore returns the existing value for the key if present. Otherwise, it
+// stores and returns the given value. The loaded result is true if the value
+// was loaded, false if stored. If the map gets over maxSize, a key is evicted.
+func (s *SampledLRUMap) LoadOrStore(key, value interface{}) (actual interface{}, loaded bool) {
+	if value, ok := s.Load(key); ok {
+		return value, true
+	}
+	s.mu.Lock()
+	defer s.unlock()
+	if en, ok := s.m[key]; ok {
+		s.touch(en)
+		return en.value, true
+	}
+	s.add(key, value)
+	return value, false
+}
+
+// Load returns the value stored in the map for a key, or nil if no value is
+// present. The ok result indicates whether value was found in the map. A found
+// key is marked as used.
+func (s *SampledLRUMap) Load(key interface{}) (value interface{}, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	en, ok := s.m[key]
+	if !ok {
+		return nil, false
+	}
+	s.touch(en)
+	return en.value, true
+}
+
+// Peek is like Load but doesn't mark the key as used.
+func (s *SampledLRUMap) Peek(key interface{}) (value interface{}, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	en, ok := s.m[key]
+	if !ok {
+		return nil, false
+	}
+	return en.value, true
+}
+
+// Store sets the value for a key, overwriting the existing value if any. The
+// key is marked as used.
+func (s *SampledLRUMap) Store(key, value interface{}) {
+	s.mu.Lock()
+	defer s.unlock()
+	if en, ok := s.m[key]; ok {
+		s.removed(en, EvictionReplaced)
+		en.value = value
+		s.touch(en)
+		return
+	}
+	s.add(key, value)
+}
+
+// CompareAndSwap swaps the old and new values for key if the value stored in
+// the map is equal to old.
+func (s *SampledLRUMap) CompareAndSwap(key, old, new interface{}) (swapped bool) {
+	s.mu.Lock()
+	defer s.unlock()
+	en, ok := s.m[key]
+	if !ok || en.value != old {
+		return false
+	}
+	s.removed(en, EvictionReplaced)
+	en.value = new
+	return true
+}
+
+// CompareAndDelete deletes the entry for key if its value is equal to old.
+func (s *SampledLRUMap) CompareAndDelete(key, old interface{}) (deleted bool) {
+	s.mu.Lock()
+	defer s.unlock()
+	en, ok := s.m[key]
+	if !ok || en.value != old {
+		return false
+	}
+	s.delete(en, EvictionDeleted)
+	return true
+}
+
+// Delete deletes the value for a key.
+func (s *SampledLRUMap) Delete(key interface{}) {
+	s.mu.Lock()
+	defer s.unlock()
+	if en, ok := s.m[key]; ok {
+		s.delete(en, EvictionDeleted)
+	}
+}
+
+// Range calls f sequentially for each key and value present in the map. If f
+// returns false, range stops the iteration. Range iterates over a snapshot, so
+// f may call other methods of the map. Range doesn't mark the keys as used.
+func (s *SampledLRUMap) Range(f func(key, value interface{}) bool) {
+	s.mu.RLock()
+	kvs := make([]removal, 0, len(s.entries))
+	for _, en := range s.entries {
+		kvs = append(kvs, removal{key: en.key, value: en.value})
+	}
+	s.mu.RUnlock()
+	for _, kv := range kvs {
+		if !f(kv.key, kv.value) {
+			return
+		}
+	}
+}
+
+// Len returns the number of keys in the map.
+func (s *SampledLRUMap) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.entries)
+}
+
+// Cap returns the maximum number of keys in the map.
+func (s *SampledLRUMap) Cap() int {
+	return s.maxSize
+}
+
+// Clear deletes all the values.
+func (s *SampledLRUMap) Clear() {
+	s.mu.Lock()
+	defer s.unlock()
+	for _, en := range s.entries {
+		s.removed(en, EvictionCleared)
+	}
+	s.m = make(map[interface{}]*sampledEntry)
+	s.entries = nil
+}
+
+// add inserts a new key, evicting keys first until it fits in maxSize so that
+// the new key isn't evicted for itself. It should be called with s.mu held.
+func (s *SampledLRUMap) add(key, value interface{}) {
+	for len(s.entries) >= s.maxSize && len(s.entries) > 0 {
+		s.delete(s.sample(), EvictionCapacity)
+	}
+	en := &sampledEntry{key: key, value: value, index: len(s.entries)}
+	s.touch(en)
+	s.m[key] = en
+	s.entries = append(s.entries, en)
+}
+
+// sample returns the least recently used of randomly sampled entries. The
+// map shouldn't be empty. It should be called with s.mu held.
+func (s *SampledLRUMap) sample() *sampledEntry {
+	if len(s.entries) <= s.samples {
+		// Cheaper and exact for small maps.
+		oldest := s.entries[0]
+		for _, en := range s.entries[1:] {
+			if en.access.Load() < oldest.access.Load() {
+				oldest = en
+			}
+		}
+		return oldest
+	}
+	oldest := s.entries[s.rand.Intn(len(s.entries))]
+	for i := 1; i < s.samples; i++ {
+		if en := s.entries[s.rand.Intn(len(s.entries))]; en.access.Load() < oldest.access.Load() {
+			oldest = en
+		}
+	}
+	return oldest
+}
+
+// delete removes the entry from the map for the reason, moving the last entry
+// into its place. It should be called with s.mu held.
+func (s *SampledLRUMap) delete(en *sampledEntry, reason EvictionReason) {
+	last := s.entries[len(s.entries)-1]
+	s.entries[en.index] = last
+	last.index = en.index
+	s.entries[len(s.entries)-1] = nil
+	s.entries = s.entries[:len(s.entries)-1]
+	delete(s.m, en.key)
+	s.removed(en, reason)
+}
@@ -0,0 +1,113 @@
+package memocache
+
+import (
+	"container/list"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func ExampleSampledLRUMap() {
+	c := NewSampledLRUCache(3, 5)
+	load := func(key string) {
+		c.LoadOrCall(key, func() interface{} {
+			fmt.Println("loading", key)
+			return key
+		})
+	}
+	load("a")
+	load("b")
+	load("c")
+	load("a") // Uses a again.
+	load("d") // Evicts b, the least recently used.
+	load("a")
+	load("b")
+	// Output:
+	// loading a
+	// loading b
+	// loading c
+	// loading d
+	// loading b
+}
+
+func TestSampledLRUMap_Evict(t *testing.T) {
+	var evicted []interface{}
+	m := NewSampledLRUMap(3, 3)
+	m.SetOnEvict(func(key, value interface{}, reason EvictionReason) {
+		if reason == EvictionCapacity {
+			evicted = append(evicted, key)
+		}
+	})
+	for _, key := range []int{1, 2, 3} {
+		m.LoadOrStore(key, key)
+	}
+	m.Load(1)
+	m.Peek(2)
+	m.LoadOrStore(4, 4) // Evicts 2, not used since it was stored.
+	m.Store(3, 3)
+	m.LoadOrStore(5, 5) // Evicts 1.
+	if want := []interface{}{2, 1}; fmt.Sprint(evicted) != fmt.Sprint(want) {
+		t.Errorf("evicted %v, want %v", evicted, want)
+	}
+	if n := m.Len(); n != 3 {
+		t.Errorf("Len() = %d, want 3", n)
+	}
+	m.Delete(3)
+	if _, ok := m.Load(3); ok {
+		t.Error("Load(3) after Delete(3) found it")
+	}
+	for _, key := range []int{4, 5} {
+		if _, ok := m.Load(key); !ok {
+			t.Errorf("Load(%d) didn't find it", key)
+		}
+	}
+	m.Clear()
+	if n := m.Len(); n != 0 {
+		t.Errorf("Len() after Clear() = %d, want 0", n)
+	}
+}
+
+func TestSampledLRUMap_HitRatio(t *testing.T) {
+	hitRatio := func(m MapInterface) float64 {
+		r := rand.New(rand.NewSource(1))
+		zipf := rand.NewZipf(r, 1.1, 1, 10000)
+		hits := 0
+		const n = 100000
+		for i := 0; i < n; i++ {
+			if _, loaded := m.LoadOrStore(zipf.Uint64(), i); loaded {
+				hits++
+			}
+		}
+		return float64(hits) / n
+	}
+	lru := hitRatio(NewLRUMap(list.New(), 500))
+	sampled := hitRatio(NewSampledLRUMap(500, 5))
+	if sampled < 0.95*lru {
+		t.Errorf("hit ratio = %.3f, want close to %.3f of LRU", sampled, lru)
+	}
+}
+
+func TestSampledLRUMap_Concurrent(t *testing.T) {
+	c := NewSampledLRUCache(50, 5)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := (i * (g + 1)) % 100
+				if got := c.LoadOrCall(key, func() interface{} { return key }); got != key {
+					t.Errorf("LoadOrCall(%d) = %v", key, got)
+				}
+				if i%10 == 0 {
+					c.Delete(key)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if n := c.m.(*SampledLRUMap).Len(); n > 50 {
+		t.Errorf("Len() = %d, want at most 50", n)
+	}
+}
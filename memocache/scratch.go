@@ -0,0 +1,105 @@
+package memocache
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// ScratchMap is a MapInterface whose entries vanish at garbage collections,
+// like the items of a sync.Pool. It suits memoizing cheap but frequent
+// computations where strict retention isn't needed, since it has no
+// bookkeeping of a bounded policy and its memory is reclaimed by the garbage
+// collector. All entries are dropped together, after a garbage collection
+// cycle ends. ScratchMap should be created with NewScratchMap.
+type ScratchMap struct {
+	s *scratch
+}
+
+// scratch is the state of a ScratchMap referenced by its sentinel. It's
+// separate from ScratchMap so that the sentinel doesn't keep the ScratchMap
+// alive.
+type scratch struct {
+	m       atomic.Pointer[sync.Map]
+	stopped atomic.Bool
+}
+
+// scratchSentinel is an object whose finalizer runs once per garbage
+// collection cycle to drop the entries.
+type scratchSentinel struct {
+	s *scratch
+}
+
+// NewScratchMap returns a new empty ScratchMap.
+func NewScratchMap() *ScratchMap {
+	s := &scratch{}
+	s.m.Store(&sync.Map{})
+	s.arm()
+	m := &ScratchMap{s: s}
+	runtime.SetFinalizer(m, func(m *ScratchMap) {
+		m.s.stopped.Store(true)
+	})
+	return m
+}
+
+// NewScratchCache returns a new cache backed by a ScratchMap.
+func NewScratchCache(opts ...Option) *Cache {
+	return NewCache(NewScratchMap(), opts...)
+}
+
+// arm sets a finalizer on a new unreachable sentinel, which runs after the
+// next garbage collection.
+func (s *scratch) arm() {
+	runtime.SetFinalizer(&scratchSentinel{s: s}, func(ss *scratchSentinel) {
+		if ss.s.stopped.Load() {
+			return
+		}
+		ss.s.m.Store(&sync.Map{})
+		ss.s.arm()
+	})
+}
+
+// LoadOrStore returns the existing value for the key if present. Otherwise, it
+// stores and returns the given value. The loaded result is true if the value
+// was loaded, false if stored.
+func (m *ScratchMap) LoadOrStore(key, value interface{}) (actual interface{}, loaded bool) {
+	return m.s.m.Load().LoadOrStore(key, value)
+}
+
+// Load returns the value stored in the map for a key, or nil if no value is
+// present.
+func (m *ScratchMap) Load(key interface{}) (value interface{}, ok bool) {
+	return m.s.m.Load().Load(key)
+}
+
+// Store sets the value for a key.
+func (m *ScratchMap) Store(key, value interface{}) {
+	m.s.m.Load().Store(key, value)
+}
+
+// Delete deletes the value for a key.
+func (m *ScratchMap) Delete(key interface{}) {
+	m.s.m.Load().Delete(key)
+}
+
+// CompareAndSwap swaps the old and new values for key if the value stored in
+// the map is equal to old.
+func (m *ScratchMap) CompareAndSwap(key, old, new interface{}) (swapped bool) {
+	return m.s.m.Load().CompareAndSwap(key, old, new)
+}
+
+// CompareAndDelete deletes the entry for key if its value is equal to old.
+func (m *ScratchMap) CompareAndDelete(key, old interface{}) (deleted bool) {
+	return m.s.m.Load().CompareAndDelete(key, old)
+}
+
+// Range calls f sequentially for each key and value present in the map. If f
+// returns false, range stops the iteration.
+func (m *ScratchMap) Range(f func(key, value interface{}) bool) {
+	m.s.m.Load().Range(f)
+}
+
+// Clear deletes all the values at once.
+func (m *ScratchMap) Clear() {
+	m.s.m.Store(&sync.Map{})
+}
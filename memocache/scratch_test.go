@@ -0,0 +1,38 @@
+package memocache
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestScratchMap(t *testing.T) {
+	c := NewScratchCache()
+	calls := 0
+	square := func(n int) interface{} {
+		return c.LoadOrCall(n, func() interface{} {
+			calls++
+			return n * n
+		})
+	}
+	if got := square(3); got != 9 {
+		t.Fatalf("square(3) = %v, want 9", got)
+	}
+	square(3)
+	if calls != 1 {
+		t.Fatalf("getValue called %d times before GC, want 1", calls)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Len() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("entries survived garbage collections")
+		}
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+	square(3)
+	if calls != 2 {
+		t.Errorf("getValue called %d times after GC, want 2", calls)
+	}
+}
@@ -0,0 +1,547 @@
+package memocache
+
+import (
+	"container/list"
+	"hash/maphash"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ShardedMap is a map that splits its keys across a number of shards by their
+// hashes, so that operations on keys of different shards don't contend for the
+// same lock. Unlike *sync.Map, it doesn't degrade under frequent writes of new
+// keys. ShardedMap should be created with NewShardedMap, NewShardedLRUMap or
+// NewAdaptiveShardedMap.
+type ShardedMap struct {
+	table atomic.Pointer[shardTable]
+	hash  func(key interface{}) uint64
+
+	growMu    sync.Mutex    // Held while the shards are doubled
+	maxWait   time.Duration // Enabled by NewAdaptiveShardedMap
+	maxShards int
+	onEvict   func(key, value interface{}, reason EvictionReason)
+}
+
+// shardTable is the set of the shards of a ShardedMap. The shard of a key is
+// its hash modulo the number of shards, so the keys of a shard are spread to
+// the shards of the same index modulo the old number when the shards are
+// doubled.
+type shardTable struct {
+	shards []MapInterface
+}
+
+// NewShardedMap returns a new unbounded ShardedMap with numShards shards, each
+// of which is a map guarded by its own lock. If numShards isn't positive, four
+// shards per GOMAXPROCS are used. The hash function picks the shard of a key;
+// if it's nil, strings and integers are hashed directly, pointers and channels
+// by their addresses and other keys by their formatted values, so a custom
+// hash is faster for keys of other types.
+func NewShardedMap(numShards int, hash func(key interface{}) uint64) *ShardedMap {
+	return newShardedMap(numShards, hash, func() MapInterface {
+		return &lockedMap{m: make(map[interface{}]interface{})}
+	})
+}
+
+// NewAdaptiveShardedMap returns a new unbounded ShardedMap like NewShardedMap
+// with four shards per GOMAXPROCS, that doubles its shards online, up to 64 per
+// GOMAXPROCS, whenever the time spent waiting for the lock of a shard adds up
+// to more than maxWait per second. The waits are measured only when the lock
+// is already held, so uncontended operations don't pay for the measurement.
+// The operations are blocked while the shards are doubled.
+func NewAdaptiveShardedMap(hash func(key interface{}) uint64, maxWait time.Duration) *ShardedMap {
+	s := NewShardedMap(0, hash)
+	s.maxWait = maxWait
+	s.maxShards = maxShardsPerProc * runtime.GOMAXPROCS(0)
+	return s
+}
+
+// maxShardsPerProc is the number of shards per GOMAXPROCS up to which
+// NewAdaptiveShardedMap doubles its shards.
+const maxShardsPerProc = 64
+
+// NewShardedCache returns a new cache backed by a ShardedMap with numShards
+// shards.
+func NewShardedCache(numShards int, opts ...Option) *Cache {
+	return NewCache(NewShardedMap(numShards, nil), opts...)
+}
+
+// NewShardedLRUMap returns a new ShardedMap with numShards LRUMap shards, each
+// with its own list and lock, holding up to maxSize keys in total. Hits on keys
+// of different shards don't contend for a global list lock, but recency is
+// tracked per shard, so the key evicted for a new key is the least recently
+// used key of its shard rather than of the whole map. If numShards isn't
+// positive, four shards per GOMAXPROCS are used. Each shard holds up to
+// maxSize divided by numShards keys, rounded up.
+func NewShardedLRUMap(numShards, maxSize int) *ShardedMap {
+	if numShards <= 0 {
+		numShards = 4 * runtime.GOMAXPROCS(0)
+	}
+	shardSize := (maxSize + numShards - 1) / numShards
+	return newShardedMap(numShards, nil, func() MapInterface {
+		return NewLRUMap(list.New(), shardSize)
+	})
+}
+
+// NewShardedLRUCache returns a new cache backed by a ShardedMap with numShards
+// LRUMap shards holding up to maxSize keys in total.
+func NewShardedLRUCache(numShards, maxSize int, opts ...Option) *Cache {
+	return NewCache(NewShardedLRUMap(numShards, maxSize), opts...)
+}
+
+// newShardedMap returns a new ShardedMap with the shards made by newShard.
+func newShardedMap(numShards int, hash func(key interface{}) uint64, newShard func() MapInterface) *ShardedMap {
+	if numShards <= 0 {
+		numShards = 4 * runtime.GOMAXPROCS(0)
+	}
+	if hash == nil {
+		seed := maphash.MakeSeed()
+		hash = func(key interface{}) uint64 {
+			return maphashKey(seed, key)
+		}
+	}
+	s := &ShardedMap{hash: hash}
+	t := &shardTable{shards: make([]MapInterface, numShards)}
+	for i := range t.shards {
+		m := newShard()
+		if l, ok := m.(*lockedMap); ok {
+			l.owner = s
+			l.table = t
+			l.since.Store(time.Now().UnixNano())
+		}
+		t.shards[i] = m
+	}
+	s.table.Store(t)
+	return s
+}
+
+// shard returns the shard of the key.
+func (s *ShardedMap) shard(key interface{}) MapInterface {
+	shards := s.table.Load().shards
+	return shards[s.hash(key)%uint64(len(shards))]
+}
+
+// NumShards returns the current number of shards.
+func (s *ShardedMap) NumShards() int {
+	return len(s.table.Load().shards)
+}
+
+// grow doubles the shards of the table t unless they have already been
+// doubled or the map has as many shards as it may have. The shards of t are
+// locked while their entries are moved, and then forward the operations
+// waiting for them to the new shards.
+func (s *ShardedMap) grow(t *shardTable) {
+	s.growMu.Lock()
+	defer s.growMu.Unlock()
+	if s.table.Load() != t || len(t.shards) >= s.maxShards {
+		return
+	}
+	nt := &shardTable{shards: make([]MapInterface, 2*len(t.shards))}
+	now := time.Now().UnixNano()
+	for i := range nt.shards {
+		l := &lockedMap{
+			m:       make(map[interface{}]interface{}),
+			owner:   s,
+			table:   nt,
+			onEvict: s.onEvict,
+		}
+		l.since.Store(now)
+		nt.shards[i] = l
+	}
+	for _, m := range t.shards {
+		m.(*lockedMap).mu.Lock()
+	}
+	for _, m := range t.shards {
+		for key, value := range m.(*lockedMap).m {
+			nt.shards[s.hash(key)%uint64(len(nt.shards))].(*lockedMap).m[key] = value
+		}
+	}
+	s.table.Store(nt)
+	for _, m := range t.shards {
+		l := m.(*lockedMap)
+		l.m = nil
+		l.next = nt
+		l.mu.Unlock()
+	}
+}
+
+// visit calls f with the shards of the table t whose index is i modulo n, and
+// with the shards their keys moved to if they had been doubled before f could
+// visit them, which f reports with moved. It returns false as soon as f does.
+func (s *ShardedMap) visit(t *shardTable, i, n int, f func(m MapInterface) (cont, moved bool)) bool {
+	for j := i; j < len(t.shards); j += n {
+		cont, moved := f(t.shards[j])
+		if moved {
+			cont = s.visit(t.shards[j].(*lockedMap).next, j, len(t.shards), f)
+		}
+		if !cont {
+			return false
+		}
+	}
+	return true
+}
+
+// LoadOrStore returns the existing value for the key if present. Otherwise, it
+// stores and returns the given value. The loaded result is true if the value
+// was loaded, false if stored.
+func (s *ShardedMap) LoadOrStore(key, value interface{}) (actual interface{}, loaded bool) {
+	return s.shard(key).LoadOrStore(key, value)
+}
+
+// Load returns the value stored in the map for a key, or nil if no value is
+// present. The ok result indicates whether value was found in the map.
+func (s *ShardedMap) Load(key interface{}) (value interface{}, ok bool) {
+	if m, ok := s.shard(key).(mapLoader); ok {
+		return m.Load(key)
+	}
+	return nil, false
+}
+
+// Peek is like Load but uses the Peek method of the shard if it has one, so it
+// doesn't mark the key as recently used in LRU shards.
+func (s *ShardedMap) Peek(key interface{}) (value interface{}, ok bool) {
+	if m, ok := s.shard(key).(mapPeeker); ok {
+		return m.Peek(key)
+	}
+	return s.Load(key)
+}
+
+// Store sets the value for a key, overwriting the existing value if any.
+func (s *ShardedMap) Store(key, value interface{}) {
+	m := s.shard(key)
+	if m, ok := m.(mapStorer); ok {
+		m.Store(key, value)
+		return
+	}
+	m.Delete(key)
+	m.LoadOrStore(key, value)
+}
+
+// CompareAndSwap swaps the old and new values for key if the value stored in
+// the map is equal to old. It always returns false if the shard can't compare.
+func (s *ShardedMap) CompareAndSwap(key, old, new interface{}) (swapped bool) {
+	if m, ok := s.shard(key).(mapCompareSwapper); ok {
+		return m.CompareAndSwap(key, old, new)
+	}
+	return false
+}
+
+// CompareAndDelete deletes the entry for key if its value is equal to old. It
+// always returns false if the shard can't compare.
+func (s *ShardedMap) CompareAndDelete(key, old interface{}) (deleted bool) {
+	if m, ok := s.shard(key).(mapCompareDeleter); ok {
+		return m.CompareAndDelete(key, old)
+	}
+	return false
+}
+
+// Delete deletes the value for a key.
+func (s *ShardedMap) Delete(key interface{}) {
+	s.shard(key).Delete(key)
+}
+
+// Range calls f sequentially for each key and value present in the map, shard
+// by shard. If f returns false, range stops the iteration.
+func (s *ShardedMap) Range(f func(key, value interface{}) bool) {
+	s.visit(s.table.Load(), 0, 1, func(m MapInterface) (cont, moved bool) {
+		if l, ok := m.(*lockedMap); ok {
+			return l.rangeUnlessMoved(f)
+		}
+		r, ok := m.(mapRanger)
+		if !ok {
+			return true, false
+		}
+		cont = true
+		r.Range(func(key, value interface{}) bool {
+			cont = f(key, value)
+			return cont
+		})
+		return cont, false
+	})
+}
+
+// Len returns the number of keys in the map. The shards are counted one by
+// one, so the result may be off while the map is modified concurrently.
+func (s *ShardedMap) Len() int {
+	n := 0
+	s.visit(s.table.Load(), 0, 1, func(m MapInterface) (cont, moved bool) {
+		if l, ok := m.(*lockedMap); ok {
+			k, moved := l.lenUnlessMoved()
+			n += k
+			return true, moved
+		}
+		if m, ok := m.(mapLener); ok {
+			n += m.Len()
+		}
+		return true, false
+	})
+	return n
+}
+
+// Cap returns the total capacity of the shards, or zero if the shards are
+// unbounded.
+func (s *ShardedMap) Cap() int {
+	n := 0
+	for _, m := range s.table.Load().shards {
+		if m, ok := m.(mapCapper); ok {
+			n += m.Cap()
+		}
+	}
+	return n
+}
+
+// Clear deletes all the values, shard by shard.
+func (s *ShardedMap) Clear() {
+	s.visit(s.table.Load(), 0, 1, func(m MapInterface) (cont, moved bool) {
+		if l, ok := m.(*lockedMap); ok {
+			return true, l.clearUnlessMoved()
+		}
+		if m, ok := m.(mapClearer); ok {
+			m.Clear()
+		}
+		return true, false
+	})
+}
+
+// Shrink shrinks each shard that is a Shrinker, like LRUMap is, to lowWater of
+// its capacity.
+func (s *ShardedMap) Shrink(lowWater float64) {
+	for _, m := range s.table.Load().shards {
+		if m, ok := m.(Shrinker); ok {
+			m.Shrink(lowWater)
+		}
+	}
+}
+
+// SetOnEvict sets the listener of the removals made by the shards that notify
+// of their removals. SetOnEvict should be called before the map is used.
+func (s *ShardedMap) SetOnEvict(f func(key, value interface{}, reason EvictionReason)) {
+	s.onEvict = f
+	for _, m := range s.table.Load().shards {
+		if m, ok := m.(evictNotifier); ok {
+			m.SetOnEvict(f)
+		}
+	}
+}
+
+// evictListener returns the listener set by SetOnEvict.
+func (s *ShardedMap) evictListener() func(key, value interface{}, reason EvictionReason) {
+	return s.onEvict
+}
+
+// lockedMap is a map guarded by a lock. It's the shard of a ShardedMap made by
+// NewShardedMap. Once the shards of the map are doubled, the shard has moved:
+// its map is nil and its operations are forwarded to the map.
+type lockedMap struct {
+	mu sync.RWMutex
+	m  map[interface{}]interface{}
+
+	owner *ShardedMap
+	table *shardTable  // Table of the shard
+	next  *shardTable  // Table the entries moved to, guarded by mu
+	wait  atomic.Int64 // Nanoseconds waited for mu since the window started
+	since atomic.Int64 // Start of the window in Unix nanoseconds
+
+	onEvict func(key, value interface{}, reason EvictionReason)
+}
+
+func (l *lockedMap) SetOnEvict(f func(key, value interface{}, reason EvictionReason)) {
+	l.onEvict = f
+}
+
+// evictListener returns the listener set by SetOnEvict.
+func (l *lockedMap) evictListener() func(key, value interface{}, reason EvictionReason) {
+	return l.onEvict
+}
+
+// notify notifies the listener of the removal of the value of the key if the
+// value was removed.
+func (l *lockedMap) notify(key, value interface{}, removed bool, reason EvictionReason) {
+	if removed && l.onEvict != nil {
+		l.onEvict(key, value, reason)
+	}
+}
+
+// lock locks mu, measuring the wait if it's already locked and the owner
+// adapts its shards.
+func (l *lockedMap) lock() {
+	if l.mu.TryLock() {
+		return
+	}
+	if l.owner.maxWait == 0 {
+		l.mu.Lock()
+		return
+	}
+	start := time.Now()
+	l.mu.Lock()
+	l.waited(time.Since(start))
+}
+
+// rlock is like lock but read-locks mu.
+func (l *lockedMap) rlock() {
+	if l.mu.TryRLock() {
+		return
+	}
+	if l.owner.maxWait == 0 {
+		l.mu.RLock()
+		return
+	}
+	start := time.Now()
+	l.mu.RLock()
+	l.waited(time.Since(start))
+}
+
+// waited adds the wait for mu to the current window of at least a second. At
+// the end of the window, the shards of the owner are doubled in the background
+// if the waits add up to more than maxWait per second.
+func (l *lockedMap) waited(d time.Duration) {
+	wait := l.wait.Add(int64(d))
+	since := l.since.Load()
+	now := time.Now().UnixNano()
+	elapsed := now - since
+	if elapsed < int64(time.Second) || !l.since.CompareAndSwap(since, now) {
+		return
+	}
+	l.wait.Add(-wait)
+	if float64(wait) > float64(l.owner.maxWait)*float64(elapsed)/float64(time.Second) {
+		go l.owner.grow(l.table)
+	}
+}
+
+func (l *lockedMap) LoadOrStore(key, value interface{}) (actual interface{}, loaded bool) {
+	l.rlock()
+	if l.next != nil {
+		l.mu.RUnlock()
+		return l.owner.LoadOrStore(key, value)
+	}
+	actual, loaded = l.m[key]
+	l.mu.RUnlock()
+	if loaded {
+		return actual, true
+	}
+	l.lock()
+	if l.next != nil {
+		l.mu.Unlock()
+		return l.owner.LoadOrStore(key, value)
+	}
+	defer l.mu.Unlock()
+	if actual, loaded = l.m[key]; loaded {
+		return actual, true
+	}
+	l.m[key] = value
+	return value, false
+}
+
+func (l *lockedMap) Load(key interface{}) (value interface{}, ok bool) {
+	l.rlock()
+	if l.next != nil {
+		l.mu.RUnlock()
+		return l.owner.Load(key)
+	}
+	defer l.mu.RUnlock()
+	value, ok = l.m[key]
+	return value, ok
+}
+
+func (l *lockedMap) Store(key, value interface{}) {
+	l.lock()
+	if l.next != nil {
+		l.mu.Unlock()
+		l.owner.Store(key, value)
+		return
+	}
+	old, ok := l.m[key]
+	l.m[key] = value
+	l.mu.Unlock()
+	l.notify(key, old, ok, EvictionReplaced)
+}
+
+func (l *lockedMap) CompareAndSwap(key, old, new interface{}) (swapped bool) {
+	l.lock()
+	if l.next != nil {
+		l.mu.Unlock()
+		return l.owner.CompareAndSwap(key, old, new)
+	}
+	v, ok := l.m[key]
+	swapped = ok && v == old
+	if swapped {
+		l.m[key] = new
+	}
+	l.mu.Unlock()
+	l.notify(key, old, swapped, EvictionReplaced)
+	return swapped
+}
+
+func (l *lockedMap) CompareAndDelete(key, old interface{}) (deleted bool) {
+	l.lock()
+	if l.next != nil {
+		l.mu.Unlock()
+		return l.owner.CompareAndDelete(key, old)
+	}
+	v, ok := l.m[key]
+	deleted = ok && v == old
+	if deleted {
+		delete(l.m, key)
+	}
+	l.mu.Unlock()
+	l.notify(key, old, deleted, EvictionDeleted)
+	return deleted
+}
+
+func (l *lockedMap) Delete(key interface{}) {
+	l.lock()
+	if l.next != nil {
+		l.mu.Unlock()
+		l.owner.Delete(key)
+		return
+	}
+	old, ok := l.m[key]
+	delete(l.m, key)
+	l.mu.Unlock()
+	l.notify(key, old, ok, EvictionDeleted)
+}
+
+// rangeUnlessMoved calls f for each key and value of the shard unless it has
+// moved. It iterates over a snapshot, so f may call other methods of the map.
+func (l *lockedMap) rangeUnlessMoved(f func(key, value interface{}) bool) (cont, moved bool) {
+	l.rlock()
+	if l.next != nil {
+		l.mu.RUnlock()
+		return true, true
+	}
+	kvs := make([]removal, 0, len(l.m))
+	for key, value := range l.m {
+		kvs = append(kvs, removal{key: key, value: value})
+	}
+	l.mu.RUnlock()
+	for _, kv := range kvs {
+		if !f(kv.key, kv.value) {
+			return false, false
+		}
+	}
+	return true, false
+}
+
+// lenUnlessMoved returns the number of keys of the shard unless it has moved.
+func (l *lockedMap) lenUnlessMoved() (n int, moved bool) {
+	l.rlock()
+	defer l.mu.RUnlock()
+	return len(l.m), l.next != nil
+}
+
+// clearUnlessMoved deletes all the values of the shard unless it has moved.
+func (l *lockedMap) clearUnlessMoved() (moved bool) {
+	l.lock()
+	if l.next != nil {
+		l.mu.Unlock()
+		return true
+	}
+	old := l.m
+	l.m = make(map[interface{}]interface{})
+	l.mu.Unlock()
+	for key, value := range old {
+		l.notify(key, value, true, EvictionCleared)
+	}
+	return false
+}
@@ -0,0 +1,246 @@
+package memocache
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShardedMap(t *testing.T) {
+	m := NewShardedMap(4, nil)
+	for i := 0; i < 100; i++ {
+		if _, loaded := m.LoadOrStore(i, i); loaded {
+			t.Errorf("LoadOrStore(%d) loaded", i)
+		}
+	}
+	if v, loaded := m.LoadOrStore(3, "three"); !loaded || v != 3 {
+		t.Errorf("LoadOrStore(3) = %v, %v, want 3, true", v, loaded)
+	}
+	m.Store("a", 1)
+	if !m.CompareAndSwap("a", 1, 2) || m.CompareAndSwap("a", 1, 3) {
+		t.Error("CompareAndSwap() didn't compare")
+	}
+	if v, ok := m.Load("a"); !ok || v != 2 {
+		t.Errorf("Load(a) = %v, %v, want 2, true", v, ok)
+	}
+	if !m.CompareAndDelete("a", 2) {
+		t.Error("CompareAndDelete(a, 2) = false")
+	}
+	m.Delete(0)
+	if n := m.Len(); n != 99 {
+		t.Errorf("Len() = %d, want 99", n)
+	}
+	sum := 0
+	m.Range(func(key, value interface{}) bool {
+		sum += value.(int)
+		return true
+	})
+	if sum != 99*100/2 {
+		t.Errorf("sum of values = %d, want %d", sum, 99*100/2)
+	}
+	m.Clear()
+	if n := m.Len(); n != 0 {
+		t.Errorf("Len() after Clear() = %d, want 0", n)
+	}
+}
+
+func TestShardedMap_PointerKeys(t *testing.T) {
+	type key struct{ n int }
+	m := NewShardedMap(64, nil)
+	k := &key{1}
+	m.Store(k, "v")
+	k.n = 2
+	if v, ok := m.Load(k); !ok || v != "v" {
+		t.Errorf("Load() of the mutated pointer key = %v, %v, want v, true", v, ok)
+	}
+	if _, ok := m.Load(&key{2}); ok {
+		t.Error("Load() of another pointer to an equal value found it")
+	}
+	m.Delete(k)
+	if n := m.Len(); n != 0 {
+		t.Errorf("Len() after Delete() = %d, want 0", n)
+	}
+}
+
+func TestShardedMap_OnEvict(t *testing.T) {
+	var mu sync.Mutex
+	var removed []string
+	c := NewShardedCache(2, WithOnEvict(func(key, value interface{}, reason EvictionReason) {
+		mu.Lock()
+		defer mu.Unlock()
+		removed = append(removed, fmt.Sprint(key, ":", reason))
+	}))
+	c.LoadOrCall("a", func() interface{} { return 1 })
+	c.Store("a", 2)
+	c.Delete("a")
+	if want := "[a:replaced a:deleted]"; fmt.Sprint(removed) != want {
+		t.Errorf("removed %v, want %v", removed, want)
+	}
+}
+
+func TestShardedMap_Concurrent(t *testing.T) {
+	c := NewShardedCache(0)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := fmt.Sprint((i * (g + 1)) % 100)
+				if got := c.LoadOrCall(key, func() interface{} { return key }); got != key {
+					t.Errorf("LoadOrCall(%s) = %v", key, got)
+				}
+				if i%10 == 0 {
+					c.Delete(key)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if n := c.Len(); n > 100 {
+		t.Errorf("Len() = %d, want at most 100", n)
+	}
+}
+
+func TestShardedLRUMap(t *testing.T) {
+	var mu sync.Mutex
+	evicted := 0
+	m := NewShardedLRUMap(4, 40)
+	m.SetOnEvict(func(key, value interface{}, reason EvictionReason) {
+		mu.Lock()
+		defer mu.Unlock()
+		if reason == EvictionCapacity {
+			evicted++
+		}
+	})
+	if n := m.Cap(); n != 40 {
+		t.Errorf("Cap() = %d, want 40", n)
+	}
+	for i := 0; i < 100; i++ {
+		m.LoadOrStore(i, i)
+	}
+	if n := m.Len(); n > 40 || n+evicted != 100 {
+		t.Errorf("Len() = %d with %d evicted, want at most 40 and 100 in total", n, evicted)
+	}
+	if _, ok := m.Peek(99); !ok {
+		t.Error("the most recently used key 99 was evicted")
+	}
+	m.Shrink(0)
+	if n := m.Len(); n != 0 {
+		t.Errorf("Len() after Shrink(0) = %d, want 0", n)
+	}
+}
+
+func BenchmarkShardedLRUMap_Load(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		m    MapInterface
+	}{
+		{"LRUMap", NewLRUMap(list.New(), 2000)},
+		{"ShardedLRUMap", NewShardedLRUMap(0, 2000)},
+	} {
+		for i := 0; i < 1000; i++ {
+			bc.m.LoadOrStore(i, i)
+		}
+		b.Run(bc.name, func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					bc.m.LoadOrStore(i%1000, i)
+					i++
+				}
+			})
+		})
+	}
+}
+
+func TestShardedMap_Grow(t *testing.T) {
+	m := NewAdaptiveShardedMap(nil, time.Millisecond)
+	for i := 0; i < 100; i++ {
+		m.Store(i, i)
+	}
+	old := m.table.Load()
+	stale := m.shard(7)
+	n := m.NumShards()
+	m.grow(old)
+	if got := m.NumShards(); got != 2*n {
+		t.Fatalf("NumShards() = %d after grow, want %d", got, 2*n)
+	}
+	m.grow(old) // Already grown.
+	if got := m.NumShards(); got != 2*n {
+		t.Errorf("NumShards() = %d after a second grow of the old table, want %d", got, 2*n)
+	}
+	if v, ok := stale.(mapLoader).Load(7); !ok || v != 7 {
+		t.Errorf("Load(7) of the moved shard = %v, %v, want 7, true", v, ok)
+	}
+	stale.(mapStorer).Store(7, "seven")
+	if v, ok := m.Load(7); !ok || v != "seven" {
+		t.Errorf("Load(7) = %v, %v, want the value stored through the moved shard", v, ok)
+	}
+	if got := m.Len(); got != 100 {
+		t.Errorf("Len() = %d, want 100", got)
+	}
+	seen := make(map[interface{}]int)
+	s := &ShardedMap{hash: m.hash}
+	s.table.Store(old)
+	s.Range(func(key, value interface{}) bool {
+		seen[key]++
+		return true
+	})
+	if len(seen) != 100 {
+		t.Errorf("Range() over the old table visited %d keys, want 100", len(seen))
+	}
+	for key, n := range seen {
+		if n != 1 {
+			t.Errorf("Range() visited %v %d times", key, n)
+		}
+	}
+}
+
+func TestShardedMap_GrowOnContention(t *testing.T) {
+	m := NewAdaptiveShardedMap(nil, time.Millisecond)
+	n := m.NumShards()
+	l := m.shard(0).(*lockedMap)
+	l.since.Store(time.Now().Add(-time.Second).UnixNano())
+	l.waited(10 * time.Millisecond)
+	if !waitFor(func() bool { return m.NumShards() == 2*n }) {
+		t.Errorf("NumShards() = %d after contention, want %d", m.NumShards(), 2*n)
+	}
+
+	quiet := NewAdaptiveShardedMap(nil, time.Second)
+	l = quiet.shard(0).(*lockedMap)
+	l.since.Store(time.Now().Add(-time.Second).UnixNano())
+	l.waited(time.Millisecond)
+	quiet.growMu.Lock() // Waits for a grow started by waited if any.
+	quiet.growMu.Unlock()
+	if got := quiet.NumShards(); got != n {
+		t.Errorf("NumShards() = %d after little contention, want %d", got, n)
+	}
+}
+
+func TestShardedMap_GrowConcurrent(t *testing.T) {
+	m := NewAdaptiveShardedMap(nil, time.Millisecond)
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := g*1000 + i
+				m.LoadOrStore(key, i)
+				if v, ok := m.Load(key); !ok || v != i {
+					t.Errorf("Load(%d) = %v, %v, want %d, true", key, v, ok, i)
+				}
+			}
+		}(g)
+	}
+	for i := 0; i < 3; i++ {
+		m.grow(m.table.Load())
+	}
+	wg.Wait()
+	if n := m.Len(); n != 4000 {
+		t.Errorf("Len() = %d, want 4000", n)
+	}
+}
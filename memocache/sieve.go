@@ -0,0 +1,252 @@
+package memocache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+// SieveMap is a bounded map with the SIEVE eviction policy, which approximates
+// LRU with cheap hits: a hit only sets a visited flag of the key under a read
+// lock instead of moving the key to the front of a list under an exclusive
+// lock, so concurrent hits don't contend. When the map is full, a hand sweeps
+// the keys from the oldest, clearing the visited flags, and evicts the first
+// key that wasn't visited since the hand passed it. New keys are still added
+// under the exclusive lock. SieveMap should be created with NewSieveMap.
+type SieveMap struct {
+	mu      sync.RWMutex
+	list    *list.List // Keys from the newest to the oldest
+	m       map[interface{}]*list.Element
+	hand    *list.Element // Next key to examine for eviction, nil for the oldest
+	maxSize int
+
+	onEvict func(key, value interface{}, reason EvictionReason)
+	pending []removal // Removed values to notify of, guarded by mu
+}
+
+// sieveEntry is an entry of a SieveMap.
+type sieveEntry struct {
+	key, value interface{}
+	visited    atomic.Bool
+}
+
+// NewSieveMap returns a new SieveMap holding up to maxSize keys.
+func NewSieveMap(maxSize int) *SieveMap {
+	return &SieveMap{
+		list:    list.New(),
+		m:       make(map[interface{}]*list.Element),
+		maxSize: maxSize,
+	}
+}
+
+// NewSieveCache returns a new cache backed by a SieveMap holding up to maxSize
+// keys.
+func NewSieveCache(maxSize int, opts ...Option) *Cache {
+	return NewCache(NewSieveMap(maxSize), opts...)
+}
+
+// SetOnEvict sets a function that is called with every value removed from the
+// map and the reason of the removal. It's called after the lock of the map is
+// released, so it may call the methods of the map. SetOnEvict should be called
+// before the map is used. A Cache wrapping the map installs its own listener,
+// which calls the one set before.
+func (s *SieveMap) SetOnEvict(f func(key, value interface{}, reason EvictionReason)) {
+	s.onEvict = f
+}
+
+// evictListener returns the listener set by SetOnEvict.
+func (s *SieveMap) evictListener() func(key, value interface{}, reason EvictionReason) {
+	return s.onEvict
+}
+
+// unlock releases the lock and notifies the listener of the values removed
+// while it was held.
+func (s *SieveMap) unlock() {
+	pending := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+	for _, ev := range pending {
+		s.onEvict(ev.key, ev.value, ev.reason)
+	}
+}
+
+// removed records the removal of the entry to notify of. It should be called
+// with s.mu held.
+func (s *SieveMap) removed(en *sieveEntry, reason EvictionReason) {
+	if s.onEvict != nil {
+		s.pending = append(s.pending, removal{key: en.key, value: en.value, reason: reason})
+	}
+}
+
+// LoadOrStore returns the existing value for the key if present. Otherwise, it
+// stores and returns the given value. The loaded result is true if the value
+// was loaded, false if stored. If the map gets over maxSize, a key is evicted.
+func (s *SieveMap) LoadOrStore(key, value interface{}) (actual interface{}, loaded bool) {
+	if value, ok := s.Load(key); ok {
+		return value, true
+	}
+	s.mu.Lock()
+	defer s.unlock()
+	if e, ok := s.m[key]; ok {
+		en := e.Value.(*sieveEntry)
+		en.visited.Store(true)
+		return en.value, true
+	}
+	s.makeRoom()
+	s.m[key] = s.list.PushFront(&sieveEntry{key: key, value: value})
+	return value, false
+}
+
+// Load returns the value stored in the map for a key, or nil if no value is
+// present. The ok result indicates whether value was found in the map. A found
+// key is marked as visited.
+func (s *SieveMap) Load(key interface{}) (value interface{}, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.m[key]
+	if !ok {
+		return nil, false
+	}
+	en := e.Value.(*sieveEntry)
+	if !en.visited.Load() {
+		en.visited.Store(true)
+	}
+	return en.value, true
+}
+
+// Peek is like Load but doesn't mark the key as visited.
+func (s *SieveMap) Peek(key interface{}) (value interface{}, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.m[key]
+	if !ok {
+		return nil, false
+	}
+	return e.Value.(*sieveEntry).value, true
+}
+
+// Store sets the value for a key, overwriting the existing value if any. An
+// existing key is marked as visited.
+func (s *SieveMap) Store(key, value interface{}) {
+	s.mu.Lock()
+	defer s.unlock()
+	if e, ok := s.m[key]; ok {
+		en := e.Value.(*sieveEntry)
+		s.removed(en, EvictionReplaced)
+		en.value = value
+		en.visited.Store(true)
+		return
+	}
+	s.makeRoom()
+	s.m[key] = s.list.PushFront(&sieveEntry{key: key, value: value})
+}
+
+// CompareAndSwap swaps the old and new values for key if the value stored in
+// the map is equal to old.
+func (s *SieveMap) CompareAndSwap(key, old, new interface{}) (swapped bool) {
+	s.mu.Lock()
+	defer s.unlock()
+	e, ok := s.m[key]
+	if !ok || e.Value.(*sieveEntry).value != old {
+		return false
+	}
+	en := e.Value.(*sieveEntry)
+	s.removed(en, EvictionReplaced)
+	en.value = new
+	return true
+}
+
+// CompareAndDelete deletes the entry for key if its value is equal to old.
+func (s *SieveMap) CompareAndDelete(key, old interface{}) (deleted bool) {
+	s.mu.Lock()
+	defer s.unlock()
+	e, ok := s.m[key]
+	if !ok || e.Value.(*sieveEntry).value != old {
+		return false
+	}
+	s.deleteElement(e, EvictionDeleted)
+	return true
+}
+
+// Delete deletes the value for a key.
+func (s *SieveMap) Delete(key interface{}) {
+	s.mu.Lock()
+	defer s.unlock()
+	if e, ok := s.m[key]; ok {
+		s.deleteElement(e, EvictionDeleted)
+	}
+}
+
+// Range calls f sequentially for each key and value present in the map. If f
+// returns false, range stops the iteration. Range iterates over a snapshot, so
+// f may call other methods of the map. Range doesn't mark the keys as visited.
+func (s *SieveMap) Range(f func(key, value interface{}) bool) {
+	s.mu.RLock()
+	kvs := make([]removal, 0, len(s.m))
+	for _, e := range s.m {
+		en := e.Value.(*sieveEntry)
+		kvs = append(kvs, removal{key: en.key, value: en.value})
+	}
+	s.mu.RUnlock()
+	for _, kv := range kvs {
+		if !f(kv.key, kv.value) {
+			return
+		}
+	}
+}
+
+// Len returns the number of keys in the map.
+func (s *SieveMap) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.m)
+}
+
+// Cap returns the maximum number of keys in the map.
+func (s *SieveMap) Cap() int {
+	return s.maxSize
+}
+
+// Clear deletes all the values.
+func (s *SieveMap) Clear() {
+	s.mu.Lock()
+	defer s.unlock()
+	for e := s.list.Front(); e != nil; e = e.Next() {
+		s.removed(e.Value.(*sieveEntry), EvictionCleared)
+	}
+	s.list.Init()
+	s.m = make(map[interface{}]*list.Element)
+	s.hand = nil
+}
+
+// makeRoom evicts keys with the hand until a new key fits in maxSize. It's
+// called before the new key is added so that the new key isn't evicted for
+// itself. It should be called with s.mu held.
+func (s *SieveMap) makeRoom() {
+	for s.list.Len() >= s.maxSize && s.list.Len() > 0 {
+		e := s.hand
+		if e == nil {
+			e = s.list.Back()
+		}
+		for en := e.Value.(*sieveEntry); en.visited.Load(); en = e.Value.(*sieveEntry) {
+			en.visited.Store(false)
+			if e = e.Prev(); e == nil {
+				e = s.list.Back()
+			}
+		}
+		s.hand = e
+		s.deleteElement(e, EvictionCapacity)
+	}
+}
+
+// deleteElement removes the element e from the map for the reason. It should
+// be called with s.mu held.
+func (s *SieveMap) deleteElement(e *list.Element, reason EvictionReason) {
+	if s.hand == e {
+		s.hand = e.Prev()
+	}
+	en := e.Value.(*sieveEntry)
+	s.list.Remove(e)
+	delete(s.m, en.key)
+	s.removed(en, reason)
+}
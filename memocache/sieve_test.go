@@ -0,0 +1,84 @@
+package memocache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func ExampleSieveMap() {
+	c := NewSieveCache(3)
+	load := func(key string) {
+		c.LoadOrCall(key, func() interface{} {
+			fmt.Println("loading", key)
+			return key
+		})
+	}
+	load("a")
+	load("b")
+	load("c")
+	load("a") // Marks a as visited.
+	load("d") // Evicts b, the oldest key not visited.
+	load("a")
+	load("b")
+	// Output:
+	// loading a
+	// loading b
+	// loading c
+	// loading d
+	// loading b
+}
+
+func TestSieveMap_Evict(t *testing.T) {
+	var evicted []interface{}
+	m := NewSieveMap(3)
+	m.SetOnEvict(func(key, value interface{}, reason EvictionReason) {
+		if reason == EvictionCapacity {
+			evicted = append(evicted, key)
+		}
+	})
+	for _, key := range []int{1, 2, 3} {
+		m.LoadOrStore(key, key)
+	}
+	m.Load(1)
+	m.Load(2)
+	m.LoadOrStore(4, 4) // Clears 1 and 2, evicts 3.
+	m.Load(4)
+	m.LoadOrStore(5, 5) // Wraps around and evicts 1, cleared before.
+	m.LoadOrStore(6, 6) // Evicts 2, cleared before, but not 4.
+	if want := []interface{}{3, 1, 2}; fmt.Sprint(evicted) != fmt.Sprint(want) {
+		t.Errorf("evicted %v, want %v", evicted, want)
+	}
+	if n := m.Len(); n != 3 {
+		t.Errorf("Len() = %d, want 3", n)
+	}
+	m.Delete(2)
+	m.Clear()
+	if n := m.Len(); n != 0 {
+		t.Errorf("Len() after Clear() = %d, want 0", n)
+	}
+}
+
+func TestSieveMap_Concurrent(t *testing.T) {
+	c := NewSieveCache(50)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := (i * (g + 1)) % 100
+				if got := c.LoadOrCall(key, func() interface{} { return key }); got != key {
+					t.Errorf("LoadOrCall(%d) = %v", key, got)
+				}
+				if i%10 == 0 {
+					c.Delete(key)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if n := c.Len(); n > 50 {
+		t.Errorf("Len() = %d, want at most 50", n)
+	}
+}
@@ -0,0 +1,123 @@
+package memocache
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// SizeOf returns the approximate number of bytes of memory used by value and
+// everything reachable from it through pointers, slices, maps, strings and
+// interfaces. Memory shared by several references is counted once. Channels
+// and functions count only their headers. The overhead of maps is estimated,
+// so the result is a rough figure meant for budgets, not an exact measure.
+func SizeOf(value interface{}) int64 {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() {
+		return 0
+	}
+	s := &sizer{seen: make(map[uintptr]bool)}
+	return int64(v.Type().Size()) + s.referenced(v)
+}
+
+// SizeOfEntry is a Weigher returning the approximate memory usage of the key
+// and the value as measured by SizeOf.
+func SizeOfEntry(key, value interface{}) int64 {
+	return SizeOf(key) + SizeOf(value)
+}
+
+// NewMemoryBoundedCache returns a new LRU cache whose values, weighed by
+// SizeOfEntry, use up to about maxBytes of memory. To measure the values with
+// a hook instead of reflection, e.g. for values that know their encoded size,
+// use NewWeightedLRUCache with the hook as the Weigher.
+func NewMemoryBoundedCache(maxBytes int64, opts ...Option) *Cache {
+	return NewWeightedLRUCache(maxBytes, SizeOfEntry, opts...)
+}
+
+// MemoryUsage returns the total weight of the values if the backing map is
+// weighted like *WeightedLRUMap is, otherwise zero. It's the approximate
+// memory usage in bytes for a cache made by NewMemoryBoundedCache.
+func (c *Cache) MemoryUsage() int64 {
+	if m, ok := c.m.(mapWeighter); ok {
+		return m.Weight()
+	}
+	return 0
+}
+
+// Estimated overhead of a map entry besides the key and the value, and of a map
+// itself.
+const (
+	mapEntryOverhead = 8
+	mapOverhead      = 48
+)
+
+// sizer measures memory reachable from values, counting each pointed memory
+// once.
+type sizer struct {
+	seen map[uintptr]bool
+}
+
+// visit returns true if the memory at p hasn't been visited yet and marks it
+// as visited.
+func (s *sizer) visit(p uintptr) bool {
+	if s.seen[p] {
+		return false
+	}
+	s.seen[p] = true
+	return true
+}
+
+// referenced returns the size of the memory referenced by v, excluding v
+// itself.
+func (s *sizer) referenced(v reflect.Value) int64 {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() || !s.visit(v.Pointer()) {
+			return 0
+		}
+		e := v.Elem()
+		return int64(e.Type().Size()) + s.referenced(e)
+	case reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		e := v.Elem()
+		return int64(e.Type().Size()) + s.referenced(e)
+	case reflect.String:
+		if v.Len() == 0 || !s.visit(uintptr(unsafe.Pointer(unsafe.StringData(v.String())))) {
+			return 0
+		}
+		return int64(v.Len())
+	case reflect.Slice:
+		if v.IsNil() || v.Cap() == 0 || !s.visit(v.Pointer()) {
+			return 0
+		}
+		n := int64(v.Cap()) * int64(v.Type().Elem().Size())
+		for i := 0; i < v.Len(); i++ {
+			n += s.referenced(v.Index(i))
+		}
+		return n
+	case reflect.Array:
+		var n int64
+		for i := 0; i < v.Len(); i++ {
+			n += s.referenced(v.Index(i))
+		}
+		return n
+	case reflect.Struct:
+		var n int64
+		for i := 0; i < v.NumField(); i++ {
+			n += s.referenced(v.Field(i))
+		}
+		return n
+	case reflect.Map:
+		if v.IsNil() || !s.visit(v.Pointer()) {
+			return 0
+		}
+		t := v.Type()
+		n := int64(mapOverhead) + int64(v.Len())*(int64(t.Key().Size())+int64(t.Elem().Size())+mapEntryOverhead)
+		for it := v.MapRange(); it.Next(); {
+			n += s.referenced(it.Key()) + s.referenced(it.Value())
+		}
+		return n
+	}
+	return 0
+}
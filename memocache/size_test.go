@@ -0,0 +1,54 @@
+package memocache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSizeOf(t *testing.T) {
+	type node struct {
+		name string
+		next *node
+	}
+	cyclic := &node{name: "abcd"}
+	cyclic.next = cyclic
+	shared := strings.Repeat("x", 100)
+	for _, tc := range []struct {
+		name  string
+		value interface{}
+		want  int64
+	}{
+		{"nil", nil, 0},
+		{"int", 1, 8},
+		{"string", "hello", 16 + 5},
+		{"bytes", make([]byte, 10, 20), 24 + 20},
+		{"strings", []string{"ab", "cd"}, 24 + 2*16 + 2 + 2},
+		{"pointer", &node{name: "abcd"}, 8 + 24 + 4},
+		{"cycle", cyclic, 8 + 24 + 4},
+		{"shared", []string{shared, shared}, 24 + 2*16 + 100},
+	} {
+		if got := SizeOf(tc.value); got != tc.want {
+			t.Errorf("SizeOf(%s) = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+	m := map[string]int{"a": 1, "b": 2}
+	if got, min := SizeOf(m), int64(8+2*(16+8+1)); got < min {
+		t.Errorf("SizeOf(map) = %d, want at least %d", got, min)
+	}
+}
+
+func TestNewMemoryBoundedCache(t *testing.T) {
+	c := NewMemoryBoundedCache(1000)
+	for i := 0; i < 100; i++ {
+		c.LoadOrCall(i, func() interface{} { return make([]byte, 100) })
+	}
+	if u := c.MemoryUsage(); u <= 0 || u > 1000 {
+		t.Errorf("MemoryUsage() = %d, want in (0, 1000]", u)
+	}
+	if n := c.Len(); n == 0 || n > 10 {
+		t.Errorf("Len() = %d, want in [1, 10]", n)
+	}
+	if u := NewCache(NewLRUMap(nil, 10)).MemoryUsage(); u != 0 {
+		t.Errorf("MemoryUsage() of LRUMap = %d, want 0", u)
+	}
+}
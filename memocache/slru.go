@@ -0,0 +1,277 @@
+package memocache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// SLRUMap is a bounded map with the segmented LRU eviction policy. New keys
+// enter the probation segment, and a key hit again while on probation is
+// promoted to the protected segment. Keys are evicted from the least recently
+// used end of the probation segment, so keys accessed only once are evicted
+// quickly while repeatedly accessed keys stay resident. When the protected
+// segment is full, its least recently used key is demoted back to the most
+// recently used end of the probation segment. SLRUMap should be created with
+// NewSLRUMap.
+type SLRUMap struct {
+	mu            sync.Mutex
+	probation     *list.List // Keys on probation from the most recently used
+	protected     *list.List // Protected keys from the most recently used
+	m             map[interface{}]*list.Element
+	probationSize int
+	protectedSize int
+
+	onEvict func(key, value interface{}, reason EvictionReason)
+	pending []removal // Removed values to notify of, guarded by mu
+}
+
+// slruEntry is an entry of a SLRUMap.
+type slruEntry struct {
+	key, value interface{}
+	protected  bool
+}
+
+// NewSLRUMap returns a new SLRUMap holding up to probationSize +
+// protectedSize keys, of which up to protectedSize keys are protected.
+func NewSLRUMap(probationSize, protectedSize int) *SLRUMap {
+	return &SLRUMap{
+		probation:     list.New(),
+		protected:     list.New(),
+		m:             make(map[interface{}]*list.Element),
+		probationSize: probationSize,
+		protectedSize: protectedSize,
+	}
+}
+
+// NewSLRUCache returns a new cache backed by a SLRUMap with the given segment
+// sizes.
+func NewSLRUCache(probationSize, protectedSize int, opts ...Option) *Cache {
+	return NewCache(NewSLRUMap(probationSize, protectedSize), opts...)
+}
+
+// SetOnEvict sets a function that is called with every value removed from the
+// map and the reason of the removal. It's called after the lock of the map is
+// released, so it may call the methods of the map. SetOnEvict should be called
+// before the map is used. A Cache wrapping the map installs its own listener,
+// which calls the one set before.
+func (s *SLRUMap) SetOnEvict(f func(key, value interface{}, reason EvictionReason)) {
+	s.onEvict = f
+}
+
+// evictListener returns the listener set by SetOnEvict.
+func (s *SLRUMap) evictListener() func(key, value interface{}, reason EvictionReason) {
+	return s.onEvict
+}
+
+// unlock releases the lock and notifies the listener of the values removed
+// while it was held.
+func (s *SLRUMap) unlock() {
+	pending := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+	for _, ev := range pending {
+		s.onEvict(ev.key, ev.value, ev.reason)
+	}
+}
+
+// removed records the removal of the entry to notify of. It should be called
+// with s.mu held.
+func (s *SLRUMap) removed(en *slruEntry, reason EvictionReason) {
+	if s.onEvict != nil {
+		s.pending = append(s.pending, removal{key: en.key, value: en.value, reason: reason})
+	}
+}
+
+// LoadOrStore returns the existing value for the key if present. Otherwise, it
+// stores the given value on probation and returns it. The loaded result is
+// true if the value was loaded, false if stored. A loaded key is promoted as
+// if by Load.
+func (s *SLRUMap) LoadOrStore(key, value interface{}) (actual interface{}, loaded bool) {
+	s.mu.Lock()
+	defer s.unlock()
+	if e, ok := s.m[key]; ok {
+		s.touch(e)
+		return e.Value.(*slruEntry).value, true
+	}
+	s.add(key, value)
+	return value, false
+}
+
+// Load returns the value stored in the map for a key, or nil if no value is
+// present. The ok result indicates whether value was found in the map. A found
+// key on probation is promoted to the protected segment, and a found protected
+// key becomes the most recently used one.
+func (s *SLRUMap) Load(key interface{}) (value interface{}, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.m[key]
+	if !ok {
+		return nil, false
+	}
+	s.touch(e)
+	return e.Value.(*slruEntry).value, true
+}
+
+// Peek is like Load but doesn't promote the key or change its recency.
+func (s *SLRUMap) Peek(key interface{}) (value interface{}, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.m[key]
+	if !ok {
+		return nil, false
+	}
+	return e.Value.(*slruEntry).value, true
+}
+
+// Store sets the value for a key, overwriting the existing value if any. An
+// existing key is promoted as if by Load, and a new key is put on probation.
+func (s *SLRUMap) Store(key, value interface{}) {
+	s.mu.Lock()
+	defer s.unlock()
+	if e, ok := s.m[key]; ok {
+		en := e.Value.(*slruEntry)
+		s.removed(en, EvictionReplaced)
+		en.value = value
+		s.touch(e)
+		return
+	}
+	s.add(key, value)
+}
+
+// CompareAndSwap swaps the old and new values for key if the value stored in
+// the map is equal to old. The recency of the key isn't changed.
+func (s *SLRUMap) CompareAndSwap(key, old, new interface{}) (swapped bool) {
+	s.mu.Lock()
+	defer s.unlock()
+	e, ok := s.m[key]
+	if !ok || e.Value.(*slruEntry).value != old {
+		return false
+	}
+	en := e.Value.(*slruEntry)
+	s.removed(en, EvictionReplaced)
+	en.value = new
+	return true
+}
+
+// CompareAndDelete deletes the entry for key if its value is equal to old.
+func (s *SLRUMap) CompareAndDelete(key, old interface{}) (deleted bool) {
+	s.mu.Lock()
+	defer s.unlock()
+	e, ok := s.m[key]
+	if !ok || e.Value.(*slruEntry).value != old {
+		return false
+	}
+	s.deleteElement(e, EvictionDeleted)
+	return true
+}
+
+// Delete deletes the value for a key.
+func (s *SLRUMap) Delete(key interface{}) {
+	s.mu.Lock()
+	defer s.unlock()
+	if e, ok := s.m[key]; ok {
+		s.deleteElement(e, EvictionDeleted)
+	}
+}
+
+// Range calls f sequentially for each key and value present in the map. If f
+// returns false, range stops the iteration. Range iterates over a snapshot, so
+// f may call other methods of the map. Range doesn't change the segments or
+// the recency of the keys.
+func (s *SLRUMap) Range(f func(key, value interface{}) bool) {
+	s.mu.Lock()
+	kvs := make([]removal, 0, len(s.m))
+	for _, e := range s.m {
+		en := e.Value.(*slruEntry)
+		kvs = append(kvs, removal{key: en.key, value: en.value})
+	}
+	s.mu.Unlock()
+	for _, kv := range kvs {
+		if !f(kv.key, kv.value) {
+			return
+		}
+	}
+}
+
+// Len returns the number of keys in the map.
+func (s *SLRUMap) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.m)
+}
+
+// Cap returns the maximum number of keys in the map, which is the sum of the
+// segment sizes.
+func (s *SLRUMap) Cap() int {
+	return s.probationSize + s.protectedSize
+}
+
+// Clear deletes all the values.
+func (s *SLRUMap) Clear() {
+	s.mu.Lock()
+	defer s.unlock()
+	for _, l := range []*list.List{s.probation, s.protected} {
+		for e := l.Front(); e != nil; e = e.Next() {
+			s.removed(e.Value.(*slruEntry), EvictionCleared)
+		}
+		l.Init()
+	}
+	s.m = make(map[interface{}]*list.Element)
+}
+
+// add puts a new key on probation and evicts keys until the map fits in its
+// capacity. It should be called with s.mu held.
+func (s *SLRUMap) add(key, value interface{}) {
+	s.m[key] = s.probation.PushFront(&slruEntry{key: key, value: value})
+	s.evict()
+}
+
+// touch promotes the element e on probation to the protected segment, or
+// makes the protected element e the most recently used one. It should be
+// called with s.mu held.
+func (s *SLRUMap) touch(e *list.Element) {
+	en := e.Value.(*slruEntry)
+	if en.protected {
+		s.protected.MoveToFront(e)
+		return
+	}
+	if s.protectedSize <= 0 {
+		s.probation.MoveToFront(e)
+		return
+	}
+	s.probation.Remove(e)
+	en.protected = true
+	s.m[en.key] = s.protected.PushFront(en)
+	for s.protected.Len() > s.protectedSize {
+		oldest := s.protected.Back()
+		demoted := s.protected.Remove(oldest).(*slruEntry)
+		demoted.protected = false
+		s.m[demoted.key] = s.probation.PushFront(demoted)
+	}
+}
+
+// evict removes the least recently used keys on probation, or the protected
+// ones if the probation segment is empty, until the map fits in its capacity.
+// It should be called with s.mu held.
+func (s *SLRUMap) evict() {
+	for len(s.m) > s.Cap() {
+		oldest := s.probation.Back()
+		if oldest == nil {
+			oldest = s.protected.Back()
+		}
+		s.deleteElement(oldest, EvictionCapacity)
+	}
+}
+
+// deleteElement removes the element e from the map for the reason. It should
+// be called with s.mu held.
+func (s *SLRUMap) deleteElement(e *list.Element, reason EvictionReason) {
+	en := e.Value.(*slruEntry)
+	if en.protected {
+		s.protected.Remove(e)
+	} else {
+		s.probation.Remove(e)
+	}
+	delete(s.m, en.key)
+	s.removed(en, reason)
+}
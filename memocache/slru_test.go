@@ -0,0 +1,108 @@
+package memocache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func ExampleSLRUMap() {
+	c := NewSLRUCache(2, 2)
+	load := func(key string) {
+		c.LoadOrCall(key, func() interface{} {
+			fmt.Println("loading", key)
+			return key
+		})
+	}
+	load("a")
+	load("a") // Promotes a to the protected segment.
+	load("b")
+	load("c")
+	load("d")
+	load("e") // Evicts b, the oldest key on probation, but not a.
+	load("a")
+	load("b")
+	// Output:
+	// loading a
+	// loading b
+	// loading c
+	// loading d
+	// loading e
+	// loading b
+}
+
+func TestSLRUMap_Evict(t *testing.T) {
+	var evicted []interface{}
+	m := NewSLRUMap(2, 2)
+	m.SetOnEvict(func(key, value interface{}, reason EvictionReason) {
+		if reason == EvictionCapacity {
+			evicted = append(evicted, key)
+		}
+	})
+	for _, key := range []int{1, 2, 3} {
+		m.LoadOrStore(key, key)
+	}
+	m.Load(1)
+	m.Load(2)
+	m.Load(3)           // Demotes 1 to probation.
+	m.LoadOrStore(4, 4) // Fits.
+	m.LoadOrStore(5, 5) // Evicts 1, the oldest key on probation.
+	// Peek doesn't promote 5.
+	if _, ok := m.Peek(5); !ok {
+		t.Error("Peek(5) not found")
+	}
+	m.LoadOrStore(6, 6) // Evicts 4.
+	if want := []interface{}{1, 4}; fmt.Sprint(evicted) != fmt.Sprint(want) {
+		t.Errorf("evicted %v, want %v", evicted, want)
+	}
+	if n := m.Len(); n != 4 {
+		t.Errorf("Len() = %d, want 4", n)
+	}
+	if n := m.Cap(); n != 4 {
+		t.Errorf("Cap() = %d, want 4", n)
+	}
+	m.Delete(2)
+	m.Clear()
+	if n := m.Len(); n != 0 {
+		t.Errorf("Len() after Clear() = %d, want 0", n)
+	}
+}
+
+func TestSLRUMap_MultiLevelMap(t *testing.T) {
+	m := NewMultiLevelMap(func() CacheInterface {
+		return NewSLRUCache(10, 10)
+	})
+	for i := 0; i < 5; i++ {
+		m.LoadOrCall(func() interface{} { return i }, "a", i)
+	}
+	if n := m.Size(); n != 5 {
+		t.Errorf("Size() = %d, want 5", n)
+	}
+	if v, ok := m.Load("a", 3); !ok || v != 3 {
+		t.Errorf("Load(a, 3) = %v, %v, want 3, true", v, ok)
+	}
+}
+
+func TestSLRUMap_Concurrent(t *testing.T) {
+	c := NewSLRUCache(10, 40)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := (i * (g + 1)) % 100
+				if got := c.LoadOrCall(key, func() interface{} { return key }); got != key {
+					t.Errorf("LoadOrCall(%d) = %v", key, got)
+				}
+				if i%10 == 0 {
+					c.Delete(key)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if n := c.Len(); n > 50 {
+		t.Errorf("Len() = %d, want at most 50", n)
+	}
+}
@@ -0,0 +1,200 @@
+package memocache
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// ErrSnapshotKey is returned by Snapshot for a key or a path element that the
+// codec can't restore as it is, e.g. one that isn't of a basic type, i.e. a
+// boolean, a number or a string, and by Restore for a key decoded as a type
+// that can't be a key. Keys of other types don't survive every codec, e.g. a
+// struct is decoded by JSONCodec as a map, which can't be a key.
+var ErrSnapshotKey = errors.New("memocache: key can't be snapshotted")
+
+// Encoder encodes values to a stream, like *gob.Encoder and *json.Encoder do.
+type Encoder interface {
+	Encode(v interface{}) error
+}
+
+// Decoder decodes values from a stream, like *gob.Decoder and *json.Decoder do.
+// Decode should return io.EOF at the end of the stream.
+type Decoder interface {
+	Decode(v interface{}) error
+}
+
+// SnapshotCodec is the format of the snapshots written by Snapshot and read by
+// Restore.
+type SnapshotCodec struct {
+	NewEncoder func(w io.Writer) Encoder
+	NewDecoder func(r io.Reader) Decoder
+	// CheckKey returns an error wrapping ErrSnapshotKey if the key or path
+	// element isn't restored as the same key. If nil, the keys of the basic
+	// types are accepted.
+	CheckKey func(key interface{}) error
+}
+
+var (
+	// GobCodec writes snapshots with encoding/gob. The concrete types of the
+	// keys and values other than the basic types should be registered with
+	// gob.Register.
+	GobCodec = SnapshotCodec{
+		NewEncoder: func(w io.Writer) Encoder { return gob.NewEncoder(w) },
+		NewDecoder: func(r io.Reader) Decoder { return gob.NewDecoder(r) },
+	}
+	// JSONCodec writes snapshots with encoding/json, one entry per line. The
+	// keys and values are restored as the values encoding/json decodes into an
+	// interface{}, e.g. float64 for numbers and map[string]interface{} for
+	// structs, so it suits caches of such values. Only keys of types string,
+	// bool and float64 are snapshotted, since a key of another type, e.g. the
+	// int 1, would be restored as a different key.
+	JSONCodec = SnapshotCodec{
+		NewEncoder: func(w io.Writer) Encoder { return json.NewEncoder(w) },
+		NewDecoder: func(r io.Reader) Decoder { return json.NewDecoder(r) },
+		CheckKey:   checkJSONKey,
+	}
+)
+
+// snapshotEntry is an entry of a snapshot. Key is set by a Cache and Path by a
+// MultiLevelMap.
+type snapshotEntry struct {
+	Key   interface{}   `json:",omitempty"`
+	Path  []interface{} `json:",omitempty"`
+	Value interface{}
+}
+
+// Snapshot writes the keys and ready values of the cache to w with the codec,
+// e.g. on shutdown, so that they can be loaded with Restore on the next
+// startup instead of loading every key again at once. Values being loaded,
+// expired values and cached errors are skipped. It fails with ErrSnapshotKey
+// on a key that the codec can't restore. Nothing is written if the backing map
+// doesn't have a Range method like *sync.Map has.
+func (c *Cache) Snapshot(w io.Writer, codec SnapshotCodec) error {
+	m, ok := c.m.(mapRanger)
+	if !ok {
+		return nil
+	}
+	enc := codec.NewEncoder(w)
+	var err error
+	m.Range(func(key, e interface{}) bool {
+		s := e.(*Value).state.Load()
+		if s == nil || s.err != nil || s.expired() {
+			return true
+		}
+		if err = codec.checkKey(key); err != nil {
+			return false
+		}
+		err = enc.Encode(snapshotEntry{Key: key, Value: s.value})
+		return err == nil
+	})
+	return err
+}
+
+// Restore reads the entries written by Snapshot from r with the codec and
+// caches the values of the keys that aren't cached yet, as if they were loaded
+// by LoadOrCall now, so they are counted as loads in Stats and get a new TTL.
+func (c *Cache) Restore(r io.Reader, codec SnapshotCodec) error {
+	return restore(r, codec, func(e snapshotEntry) {
+		c.LoadOrCall(e.Key, func() interface{} {
+			return e.Value
+		})
+	})
+}
+
+// Snapshot writes the paths and ready values of the leaves of the tree to w
+// with the codec. The leaves are visited as by Walk. See Cache.Snapshot.
+func (m *MultiLevelMap) Snapshot(w io.Writer, codec SnapshotCodec) error {
+	enc := codec.NewEncoder(w)
+	var err error
+	m.walk(func(path []interface{}, value interface{}) bool {
+		switch value.(type) {
+		case *CachedError, *loadFailure:
+			return true
+		}
+		for _, key := range path {
+			if err = codec.checkKey(key); err != nil {
+				return false
+			}
+		}
+		err = enc.Encode(snapshotEntry{Path: path, Value: value})
+		return err == nil
+	})
+	return err
+}
+
+// Restore reads the entries written by Snapshot from r with the codec and
+// caches the values of the paths that aren't cached yet, as if they were
+// loaded by LoadOrCall now. See Cache.Restore.
+func (m *MultiLevelMap) Restore(r io.Reader, codec SnapshotCodec) error {
+	return restore(r, codec, func(e snapshotEntry) {
+		if len(e.Path) == 0 {
+			return
+		}
+		m.LoadOrCall(func() interface{} {
+			return e.Value
+		}, e.Path...)
+	})
+}
+
+// checkKey returns an error wrapping ErrSnapshotKey if the key can't be
+// snapshotted with the codec.
+func (codec SnapshotCodec) checkKey(key interface{}) error {
+	if codec.CheckKey != nil {
+		return codec.CheckKey(key)
+	}
+	return checkSnapshotKey(key)
+}
+
+// checkSnapshotKey returns an error wrapping ErrSnapshotKey if the key isn't
+// of a basic type.
+func checkSnapshotKey(key interface{}) error {
+	if key != nil {
+		switch reflect.TypeOf(key).Kind() {
+		case reflect.Bool, reflect.String,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+			reflect.Float32, reflect.Float64:
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %v of type %T", ErrSnapshotKey, key, key)
+}
+
+// checkJSONKey returns an error wrapping ErrSnapshotKey if the key isn't of a
+// type that encoding/json decodes into an interface{} as it is.
+func checkJSONKey(key interface{}) error {
+	switch key.(type) {
+	case string, bool, float64:
+		return nil
+	}
+	return fmt.Errorf("%w: %v of type %T", ErrSnapshotKey, key, key)
+}
+
+// restore decodes the entries from r with the codec and calls f with each of
+// them until the end of r. It fails with ErrSnapshotKey on an entry with a key
+// that can't be a key.
+func restore(r io.Reader, codec SnapshotCodec, f func(e snapshotEntry)) error {
+	dec := codec.NewDecoder(r)
+	for {
+		var e snapshotEntry
+		if err := dec.Decode(&e); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if !isComparable(e.Key) {
+			return fmt.Errorf("%w: %v of type %T", ErrSnapshotKey, e.Key, e.Key)
+		}
+		for _, key := range e.Path {
+			if !isComparable(key) {
+				return fmt.Errorf("%w: %v of type %T", ErrSnapshotKey, key, key)
+			}
+		}
+		f(e)
+	}
+}
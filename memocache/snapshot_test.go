@@ -0,0 +1,137 @@
+package memocache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCache_Snapshot(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		codec SnapshotCodec
+		one   interface{}
+	}{
+		{"Gob", GobCodec, 1},
+		{"JSON", JSONCodec, 1.0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewCache(&sync.Map{})
+			c.LoadOrCall("a", func() interface{} { return 1 })
+			c.LoadOrCall("b", func() interface{} { return "two" })
+			c.LoadOrCallErr("c", func() (interface{}, error) {
+				return nil, NewCachedError("c", errNotLoaded)
+			})
+			var buf bytes.Buffer
+			if err := c.Snapshot(&buf, tc.codec); err != nil {
+				t.Fatalf("Snapshot() = %v", err)
+			}
+			restored := NewCache(&sync.Map{})
+			restored.Store("b", "newer")
+			if err := restored.Restore(&buf, tc.codec); err != nil {
+				t.Fatalf("Restore() = %v", err)
+			}
+			if v, _ := restored.Load("a"); v != tc.one {
+				t.Errorf("Load(a) = %#v, want %#v", v, tc.one)
+			}
+			if v, _ := restored.Load("b"); v != "newer" {
+				t.Errorf("Load(b) = %v, want the newer value", v)
+			}
+			if _, ok := restored.Load("c"); ok {
+				t.Error("the cached error of c was restored")
+			}
+		})
+	}
+}
+
+func TestMultiLevelMap_Snapshot(t *testing.T) {
+	m := NewMultiLevelMap(nil)
+	m.LoadOrCall(func() interface{} { return "v" }, "a", "b")
+	m.LoadOrCall(func() interface{} { return "w" }, "a", "c", "d")
+	var buf bytes.Buffer
+	if err := m.Snapshot(&buf, GobCodec); err != nil {
+		t.Fatalf("Snapshot() = %v", err)
+	}
+	restored := NewMultiLevelMap(nil)
+	if err := restored.Restore(&buf, GobCodec); err != nil {
+		t.Fatalf("Restore() = %v", err)
+	}
+	if v, _ := restored.Load("a", "b"); v != "v" {
+		t.Errorf("Load(a, b) = %v, want v", v)
+	}
+	if v, _ := restored.Load("a", "c", "d"); v != "w" {
+		t.Errorf("Load(a, c, d) = %v, want w", v)
+	}
+}
+
+func TestSnapshot_Keys(t *testing.T) {
+	type userKey struct{ ID int }
+	c := NewCache(&sync.Map{})
+	c.Store(userKey{1}, "alice")
+	if err := c.Snapshot(&bytes.Buffer{}, JSONCodec); !errors.Is(err, ErrSnapshotKey) {
+		t.Errorf("Snapshot() of a struct key = %v, want %v", err, ErrSnapshotKey)
+	}
+	m := NewMultiLevelMap(nil)
+	m.LoadOrCall(func() interface{} { return "alice" }, "users", userKey{1})
+	if err := m.Snapshot(&bytes.Buffer{}, JSONCodec); !errors.Is(err, ErrSnapshotKey) {
+		t.Errorf("MultiLevelMap.Snapshot() of a struct key = %v, want %v", err, ErrSnapshotKey)
+	}
+
+	ints := NewCache(&sync.Map{})
+	ints.Store(1, "one")
+	if err := ints.Snapshot(&bytes.Buffer{}, JSONCodec); !errors.Is(err, ErrSnapshotKey) {
+		t.Errorf("Snapshot() of an int key = %v, want %v", err, ErrSnapshotKey)
+	}
+	var buf bytes.Buffer
+	if err := ints.Snapshot(&buf, GobCodec); err != nil {
+		t.Fatalf("Snapshot() of an int key with GobCodec = %v", err)
+	}
+	restored := NewCache(&sync.Map{})
+	if err := restored.Restore(&buf, GobCodec); err != nil {
+		t.Fatalf("Restore() of an int key with GobCodec = %v", err)
+	}
+	if v, _ := restored.Load(1); v != "one" {
+		t.Errorf("Load(1) = %v, want one", v)
+	}
+	floats := NewCache(&sync.Map{})
+	floats.Store(1.0, "one")
+	buf.Reset()
+	if err := floats.Snapshot(&buf, JSONCodec); err != nil {
+		t.Fatalf("Snapshot() of a float64 key = %v", err)
+	}
+	restored = NewCache(&sync.Map{})
+	if err := restored.Restore(&buf, JSONCodec); err != nil {
+		t.Fatalf("Restore() of a float64 key = %v", err)
+	}
+	if v, _ := restored.Load(1.0); v != "one" {
+		t.Errorf("Load(1.0) = %v, want one", v)
+	}
+
+	r := strings.NewReader(`{"Key":{"ID":1},"Value":"alice"}` + "\n")
+	if err := NewCache(&sync.Map{}).Restore(r, JSONCodec); !errors.Is(err, ErrSnapshotKey) {
+		t.Errorf("Restore() of a map key = %v, want %v", err, ErrSnapshotKey)
+	}
+}
+
+func TestMultiLevelMap_SnapshotErrors(t *testing.T) {
+	errValue := errors.New("a value")
+	m := NewMultiLevelMap(nil)
+	m.LoadOrCall(func() interface{} { return errValue }, "value")
+	m.LoadOrCallErr(func() (interface{}, error) {
+		return nil, NewCachedError("cached", errNotLoaded)
+	}, "cached")
+	var buf bytes.Buffer
+	if err := m.Snapshot(&buf, JSONCodec); err != nil {
+		t.Fatalf("Snapshot() = %v", err)
+	}
+	var paths []interface{}
+	restore(&buf, JSONCodec, func(e snapshotEntry) {
+		paths = append(paths, e.Path...)
+	})
+	if want := "[value]"; fmt.Sprint(paths) != want {
+		t.Errorf("snapshotted paths %v, want %v", paths, want)
+	}
+}
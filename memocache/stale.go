@@ -0,0 +1,66 @@
+package memocache
+
+import (
+	"errors"
+	"time"
+)
+
+// WithServeStaleOnError makes a Cache keep serving the expired value of a key
+// when loading the key again fails, instead of returning the error, so that an
+// outage of the backend doesn't take down the callers along with it. The
+// expired value is served for up to maxStale after it expired, or indefinitely
+// if maxStale is zero. It stays expired, so every call after a failed load
+// tries to load the key again, once for the concurrent calls. A *CachedError
+// and the errors of contexts aren't replaced by the expired value. The onStale
+// function, if not nil, is called with the key and the error of the load
+// whenever the expired value is served instead.
+func WithServeStaleOnError(maxStale time.Duration, onStale func(key interface{}, err error)) Option {
+	return func(c *config) {
+		c.serveStale = true
+		c.maxStale = maxStale
+		c.onStale = onStale
+	}
+}
+
+// staleFallback is returned by the loader of an expired key when its load
+// failed and the expired value is served instead. The Value publishes state
+// rather than failing the call.
+type staleFallback struct {
+	state *valueState
+	err   error
+}
+
+func (e *staleFallback) Error() string {
+	return e.err.Error()
+}
+
+// staleState returns the state of the expired value s to serve if loading its
+// key again fails, or nil if it can't be served.
+func (c *Cache) staleState(s *valueState) *valueState {
+	if !c.config.serveStale || s.err != nil || !s.expired() {
+		return nil
+	}
+	if c.config.maxStale > 0 && now(s.clock).UnixNano()-s.expires > int64(c.config.maxStale) {
+		return nil
+	}
+	ns := *s
+	ns.stale = true
+	ns.refreshing = false
+	return &ns
+}
+
+// serveStale returns getValue of the key falling back to the expired state
+// when it fails.
+func (c *Cache) serveStale(key interface{}, state *valueState, getValue func() (interface{}, error)) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		value, err := getValue()
+		var ce *CachedError
+		if err == nil || errors.As(err, &ce) || isContextErr(err) {
+			return value, err
+		}
+		if c.config.onStale != nil {
+			c.config.onStale(key, err)
+		}
+		return nil, &staleFallback{state: state, err: err}
+	}
+}
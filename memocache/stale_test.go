@@ -0,0 +1,109 @@
+package memocache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithServeStaleOnError(t *testing.T) {
+	clock := newFakeClock()
+	errDown := errors.New("down")
+	var staleKeys []interface{}
+	c := NewCache(&sync.Map{}, WithClock(clock), WithTTL(time.Minute),
+		WithServeStaleOnError(time.Hour, func(key interface{}, err error) {
+			if err != errDown {
+				t.Errorf("onStale(%v, %v), want %v", key, err, errDown)
+			}
+			staleKeys = append(staleKeys, key)
+		}))
+	c.LoadOrCallErr("k", func() (interface{}, error) { return 1, nil })
+	clock.Advance(time.Minute)
+
+	calls := 0
+	fail := func() (interface{}, error) {
+		calls++
+		return nil, errDown
+	}
+	for i := 0; i < 2; i++ {
+		if got, err := c.LoadOrCallErr("k", fail); got != 1 || err != nil {
+			t.Errorf("LoadOrCallErr() of the expired key = %v, %v, want the stale 1, nil", got, err)
+		}
+	}
+	if calls != 2 || len(staleKeys) != 2 {
+		t.Errorf("loader called %d times with %d stale values served, want 2 loads of the expired key", calls, len(staleKeys))
+	}
+	if _, ok := c.Load("k"); ok {
+		t.Error("Load() found the stale value, want it expired")
+	}
+	if got, err := c.LoadOrCallErr("k", func() (interface{}, error) { return 2, nil }); got != 2 || err != nil {
+		t.Errorf("LoadOrCallErr() after the backend recovers = %v, %v, want 2, nil", got, err)
+	}
+	if got, ok := c.Load("k"); !ok || got != 2 {
+		t.Errorf("Load() = %v, %v, want the fresh 2", got, ok)
+	}
+}
+
+func TestWithServeStaleOnError_Waiters(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCache(&sync.Map{}, WithClock(clock), WithTTL(time.Minute), WithServeStaleOnError(0, nil))
+	c.LoadOrCall("k", func() interface{} { return 1 })
+	clock.Advance(24 * time.Hour)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go c.LoadOrCallErr("k", func() (interface{}, error) {
+		close(started)
+		<-release
+		return nil, errors.New("down")
+	})
+	<-started
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := c.LoadOrCallErr("k", func() (interface{}, error) {
+				return nil, errors.New("down")
+			})
+			if got != 1 || err != nil {
+				t.Errorf("LoadOrCallErr() of a waiter = %v, %v, want the stale 1, nil", got, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond) // Lets the waiters wait for the load.
+	close(release)
+	wg.Wait()
+}
+
+func TestWithServeStaleOnError_Errors(t *testing.T) {
+	clock := newFakeClock()
+	errDown := errors.New("down")
+	c := NewCache(&sync.Map{}, WithClock(clock), WithTTL(time.Minute), WithServeStaleOnError(time.Minute, nil))
+	for _, key := range []string{"cached", "old"} {
+		c.LoadOrCall(key, func() interface{} { return key })
+	}
+	clock.Advance(time.Minute)
+	var ce *CachedError
+	if _, err := c.LoadOrCallErr("cached", func() (interface{}, error) {
+		return nil, NewCachedError("cached", errDown)
+	}); !errors.As(err, &ce) {
+		t.Errorf("LoadOrCallErr() = %v, want the cached error", err)
+	}
+	clock.Advance(time.Minute + time.Nanosecond)
+	if _, err := c.LoadOrCallErr("old", func() (interface{}, error) {
+		return nil, errDown
+	}); err != errDown {
+		t.Errorf("LoadOrCallErr() after maxStale = %v, want %v", err, errDown)
+	}
+
+	c = NewCache(&sync.Map{}, WithClock(clock), WithTTL(time.Minute))
+	c.LoadOrCall("k", func() interface{} { return 1 })
+	clock.Advance(time.Minute)
+	if _, err := c.LoadOrCallErr("k", func() (interface{}, error) {
+		return nil, errDown
+	}); err != errDown {
+		t.Errorf("LoadOrCallErr() without the option = %v, want %v", err, errDown)
+	}
+}
@@ -0,0 +1,112 @@
+package memocache
+
+import "sync"
+
+// WithSubtreeStats makes a MultiLevelMap count the hits and misses of its
+// subtrees down to the depth, e.g. 1 to count them per tenant of paths like
+// (tenant, object), so that MultiLevelMap.SubtreeStats can tell which
+// branches dominate the cache. Every LoadOrCall of a path is counted in each
+// of its prefixes of up to depth elements. The counters of a subtree are
+// dropped when it's pruned, so they are kept only for the branches in the
+// tree.
+func WithSubtreeStats(depth int) Option {
+	return func(c *config) {
+		c.subtreeDepth = depth
+	}
+}
+
+// subtreeNode holds the statistics of a subtree counted by WithSubtreeStats.
+type subtreeNode struct {
+	stats    counters
+	children sync.Map // Key to *subtreeNode
+}
+
+// SubtreeLen returns the number of leaf values in the subtree of the path, or
+// 1 if the path is a leaf. It counts like Size and doesn't mark the path as
+// recently used. Without a path, it's the same as Size.
+func (m *MultiLevelMap) SubtreeLen(path ...interface{}) int {
+	if len(path) == 0 {
+		return m.Size()
+	}
+	node, ok := m.load(true, path...)
+	if !ok {
+		return 0
+	}
+	if !m.isLevel(len(path), node) {
+		return 1
+	}
+	n := 0
+	m.walkLevel(node, path[:len(path):len(path)], func(path []interface{}, value interface{}) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// SubtreeStats returns a snapshot of the statistics of the LoadOrCall calls
+// made on the paths in the subtree of the path. It's zero unless the path has
+// at most the depth of WithSubtreeStats. Without a path, it's the same as
+// Stats.
+func (m *MultiLevelMap) SubtreeStats(path ...interface{}) Stats {
+	if len(path) == 0 {
+		return m.Stats()
+	}
+	if len(path) > m.config.subtreeDepth {
+		return Stats{}
+	}
+	node := &m.subtrees
+	for _, key := range m.canonical(path) {
+		child, ok := node.children.Load(key)
+		if !ok {
+			return Stats{}
+		}
+		node = child.(*subtreeNode)
+	}
+	return node.stats.snapshot()
+}
+
+// recordSubtrees counts a LoadOrCall of the path in the subtrees of its
+// prefixes if WithSubtreeStats is given.
+func (m *MultiLevelMap) recordSubtrees(path []interface{}, called bool, err error) {
+	depth := m.config.subtreeDepth
+	if depth <= 0 {
+		return
+	}
+	if depth > len(path) {
+		depth = len(path)
+	}
+	node := &m.subtrees
+	for _, key := range path[:depth] {
+		child, ok := node.children.Load(key)
+		if !ok {
+			child, _ = node.children.LoadOrStore(key, &subtreeNode{})
+		}
+		node = child.(*subtreeNode)
+		node.stats.record(called, err)
+	}
+}
+
+// dropSubtreeStats drops the statistics of the subtree of the path, or of all
+// the subtrees without a path.
+func (m *MultiLevelMap) dropSubtreeStats(path []interface{}) {
+	n := len(path)
+	if m.config.subtreeDepth <= 0 || n > m.config.subtreeDepth {
+		return
+	}
+	node := &m.subtrees
+	if n == 0 {
+		node.children.Range(func(key, value interface{}) bool {
+			node.children.Delete(key)
+			return true
+		})
+		return
+	}
+	for _, key := range path[:n-1] {
+		child, ok := node.children.Load(key)
+		if !ok {
+			return
+		}
+		node = child.(*subtreeNode)
+	}
+	node.children.Delete(path[n-1])
+}
@@ -0,0 +1,60 @@
+package memocache
+
+import (
+	"container/list"
+	"errors"
+	"testing"
+)
+
+func TestSubtreeLen(t *testing.T) {
+	m := NewMultiLevelMap(func() CacheInterface { return NewCache(NewLRUMap(list.New(), 100)) })
+	for _, p := range []Path{P("a", 1), P("a", 2), P("b", 1, "x"), P("b", 2, "y")} {
+		m.LoadOrCall(func() interface{} { return 0 }, p...)
+	}
+	for _, tc := range []struct {
+		path Path
+		want int
+	}{
+		{nil, 4},
+		{P("a"), 2},
+		{P("a", 1), 1},
+		{P("b"), 2},
+		{P("b", 1), 1},
+		{P("c"), 0},
+	} {
+		if got := m.SubtreeLen(tc.path...); got != tc.want {
+			t.Errorf("SubtreeLen(%v) = %d, want %d", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestWithSubtreeStats(t *testing.T) {
+	m := NewMultiLevelMap(nil, WithSubtreeStats(1))
+	for i := 0; i < 3; i++ {
+		m.LoadOrCall(func() interface{} { return 0 }, "a", 1)
+	}
+	m.LoadOrCall(func() interface{} { return 0 }, "a", 2)
+	m.LoadOrCallErr(func() (interface{}, error) { return 0, errors.New("fail") }, "b", 1)
+	for _, tc := range []struct {
+		path Path
+		want Stats
+	}{
+		{nil, Stats{Hits: 2, Misses: 3, LoadErrors: 1}},
+		{P("a"), Stats{Hits: 2, Misses: 2}},
+		{P("b"), Stats{Misses: 1, LoadErrors: 1}},
+		{P("a", 1), Stats{}},
+		{P("c"), Stats{}},
+	} {
+		if got := m.SubtreeStats(tc.path...); got != tc.want {
+			t.Errorf("SubtreeStats(%v) = %+v, want %+v", tc.path, got, tc.want)
+		}
+	}
+	m.Prune("a")
+	if got := m.SubtreeStats("a"); got != (Stats{}) {
+		t.Errorf("SubtreeStats(a) after Prune(a) = %+v, want zero", got)
+	}
+	m.Clear()
+	if got := m.SubtreeStats("b"); got != (Stats{}) {
+		t.Errorf("SubtreeStats(b) after Clear() = %+v, want zero", got)
+	}
+}
@@ -0,0 +1,117 @@
+package memocache
+
+import "context"
+
+// Store is a second-level store of a TieredCache, e.g. a remote cache shared
+// by the processes of a service, or the backing store of WithWriteThrough and
+// WithWriteBehind. Get returns false if the key isn't stored.
+type Store interface {
+	Get(ctx context.Context, key interface{}) (value interface{}, ok bool, err error)
+	Set(ctx context.Context, key, value interface{}) error
+	Delete(ctx context.Context, key interface{}) error
+}
+
+// CacheStore returns a Store backed by the cache, so that another cache, e.g. a
+// larger one shared by several TieredCaches, can be the second level of a
+// TieredCache. It never fails.
+func CacheStore(c ExtendedCache) Store {
+	return cacheStore{c: c}
+}
+
+type cacheStore struct {
+	c ExtendedCache
+}
+
+func (s cacheStore) Get(ctx context.Context, key interface{}) (interface{}, bool, error) {
+	value, ok := s.c.Load(key)
+	return value, ok, nil
+}
+
+func (s cacheStore) Set(ctx context.Context, key, value interface{}) error {
+	s.c.Store(key, value)
+	return nil
+}
+
+func (s cacheStore) Delete(ctx context.Context, key interface{}) error {
+	s.c.Delete(key)
+	return nil
+}
+
+// TieredCache is a cache of two levels: a first-level cache in memory and a
+// second-level Store. A key is looked up in the first level, then in the
+// second level, and only then loaded with getValue, and the value is kept in
+// both levels. The lookup in the second level and the load are made by the
+// first level's loader, so they are made once for concurrent calls of a key in
+// the process. TieredCache should be created with NewTieredCache.
+//
+// Errors of the second level don't fail the calls: a failed Get is taken as a
+// miss, and a value that failed to Set is still kept in the first level. They
+// are reported to the onError function given to NewTieredCache.
+type TieredCache struct {
+	l1      ExtendedCache
+	l2      Store
+	onError func(key interface{}, err error)
+}
+
+var _ CacheInterface = (*TieredCache)(nil)
+
+// NewTieredCache returns a new TieredCache of the caches l1 and l2. If onError
+// isn't nil, it's called with the errors of l2.
+func NewTieredCache(l1 ExtendedCache, l2 Store, onError func(key interface{}, err error)) *TieredCache {
+	return &TieredCache{l1: l1, l2: l2, onError: onError}
+}
+
+// LoadOrCall gets the value of the key from the first level, the second level
+// or getValue, in this order.
+func (t *TieredCache) LoadOrCall(key interface{}, getValue func() interface{}) interface{} {
+	value, _ := t.LoadOrCallCtx(context.Background(), key, func(ctx context.Context) (interface{}, error) {
+		return getValue(), nil
+	})
+	return value
+}
+
+// LoadOrCallErr is like LoadOrCall but getValue may fail. Errors of getValue
+// are handled by the first level, and aren't stored in the second level.
+func (t *TieredCache) LoadOrCallErr(key interface{}, getValue func() (interface{}, error)) (interface{}, error) {
+	return t.LoadOrCallCtx(context.Background(), key, func(ctx context.Context) (interface{}, error) {
+		return getValue()
+	})
+}
+
+// LoadOrCallCtx is like LoadOrCallErr but ctx is given to getValue and to the
+// second level.
+func (t *TieredCache) LoadOrCallCtx(ctx context.Context, key interface{}, getValue func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	return t.l1.LoadOrCallCtx(ctx, key, func(ctx context.Context) (interface{}, error) {
+		value, ok, err := t.l2.Get(ctx, key)
+		if err != nil {
+			t.reportError(key, err)
+		} else if ok {
+			return value, nil
+		}
+		value, err = getValue(ctx)
+		if err != nil {
+			return value, err
+		}
+		if err := t.l2.Set(ctx, key, value); err != nil {
+			t.reportError(key, err)
+		}
+		return value, nil
+	})
+}
+
+// Delete deletes the value of the key from both levels. The second level is
+// deleted first, so that a concurrent load doesn't bring the old value back to
+// the first level.
+func (t *TieredCache) Delete(key interface{}) {
+	if err := t.l2.Delete(context.Background(), key); err != nil {
+		t.reportError(key, err)
+	}
+	t.l1.Delete(key)
+}
+
+// reportError reports the error of the second level for the key.
+func (t *TieredCache) reportError(key interface{}, err error) {
+	if t.onError != nil {
+		t.onError(key, err)
+	}
+}
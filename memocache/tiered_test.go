@@ -0,0 +1,70 @@
+package memocache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// failingStore is a Store whose operations fail.
+type failingStore struct{}
+
+func (failingStore) Get(ctx context.Context, key interface{}) (interface{}, bool, error) {
+	return nil, false, errors.New("get failed")
+}
+
+func (failingStore) Set(ctx context.Context, key, value interface{}) error {
+	return errors.New("set failed")
+}
+
+func (failingStore) Delete(ctx context.Context, key interface{}) error {
+	return errors.New("delete failed")
+}
+
+func TestTieredCache(t *testing.T) {
+	l2 := NewCache(&sync.Map{})
+	var calls atomic.Int32
+	load := func() interface{} {
+		calls.Add(1)
+		return "v"
+	}
+	a := NewTieredCache(NewCache(&sync.Map{}), CacheStore(l2), nil)
+	b := NewTieredCache(NewCache(&sync.Map{}), CacheStore(l2), nil)
+	if got := a.LoadOrCall("k", load); got != "v" {
+		t.Errorf("LoadOrCall(k) on a = %v, want v", got)
+	}
+	if got := b.LoadOrCall("k", load); got != "v" {
+		t.Errorf("LoadOrCall(k) on b = %v, want v", got)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("getValue was called %d times, want 1 for both tiers", n)
+	}
+	a.Delete("k")
+	if _, ok := l2.Load("k"); ok {
+		t.Error("k survived Delete(k) in the second level")
+	}
+	a.LoadOrCall("k", load)
+	if n := calls.Load(); n != 2 {
+		t.Errorf("getValue was called %d times after Delete(k), want 2", n)
+	}
+}
+
+func TestTieredCache_StoreErrors(t *testing.T) {
+	var errs []string
+	c := NewTieredCache(NewCache(&sync.Map{}), failingStore{}, func(key interface{}, err error) {
+		errs = append(errs, err.Error())
+	})
+	if got := c.LoadOrCall("k", func() interface{} { return 1 }); got != 1 {
+		t.Errorf("LoadOrCall(k) = %v, want 1", got)
+	}
+	if got := c.LoadOrCall("k", func() interface{} { return 2 }); got != 1 {
+		t.Errorf("LoadOrCall(k) again = %v, want 1 from the first level", got)
+	}
+	c.Delete("k")
+	if want := "[get failed set failed delete failed]"; fmt.Sprint(errs) != want {
+		t.Errorf("errors = %v, want %v", errs, want)
+	}
+}
@@ -0,0 +1,181 @@
+package memocache
+
+import (
+	"container/list"
+	"fmt"
+	"hash/maphash"
+	"reflect"
+	"sync"
+)
+
+// TinyLFUMap is a LRUMap with the TinyLFU admission policy. It keeps
+// approximate access frequencies of the keys in a count-min sketch, and when
+// it's full, it only admits a new key if the key was accessed more often than
+// the least recently used key that would be evicted for it. Scans of keys
+// accessed once thus don't flush frequently accessed keys out of the cache.
+//
+// A rejected key is not stored, so a Cache backed by a TinyLFUMap calls the
+// loader of a rejected key every time until the key is admitted, and
+// concurrent calls of a rejected key may call their loaders more than once.
+// Use it for single level caches or for the leaf levels of a MultiLevelMap
+// only, since rejected inner levels lose their subtrees. Store always admits
+// the key.
+type TinyLFUMap struct {
+	*LRUMap
+	sketch *countMinSketch
+}
+
+// NewTinyLFUMap returns a new TinyLFUMap with its own list holding up to
+// maxSize keys.
+func NewTinyLFUMap(maxSize int) *TinyLFUMap {
+	return &TinyLFUMap{
+		LRUMap: NewLRUMap(list.New(), maxSize),
+		sketch: newCountMinSketch(maxSize),
+	}
+}
+
+// LoadOrStore returns the existing value for the key if present. Otherwise, it
+// stores the given value if the key is admitted and returns the value. The
+// loaded result is true if the value was loaded, false if stored or rejected.
+// The access is counted in the frequency of the key either way.
+func (t *TinyLFUMap) LoadOrStore(key, value interface{}) (actual interface{}, loaded bool) {
+	h := t.sketch.hash(key)
+	t.sketch.add(h)
+	actual, loaded, _ = t.LRUMap.loadOrStoreAdmit(key, value, func(victim interface{}) bool {
+		return t.sketch.estimate(h) > t.sketch.estimate(t.sketch.hash(victim))
+	})
+	return actual, loaded
+}
+
+// Load returns the value stored in the map for a key, or nil if no value is
+// present. The access of a found key is counted in the frequency of the key.
+// Misses are counted by LoadOrStore, so a Cache looking up a key before adding
+// it counts the access once.
+func (t *TinyLFUMap) Load(key interface{}) (value interface{}, ok bool) {
+	if value, ok = t.LRUMap.Load(key); ok {
+		t.sketch.add(t.sketch.hash(key))
+	}
+	return value, ok
+}
+
+// Number of rows of a count-min sketch, and the maximum of its counters.
+const (
+	sketchDepth      = 4
+	sketchMaxCounter = 15
+)
+
+// sketchMultipliers are the odd multipliers hashing the rows of a count-min
+// sketch independently.
+var sketchMultipliers = [sketchDepth]uint64{
+	0xc3a5c85c97cb3127, 0xb492b66fbe98f273, 0x9ae16a3b2f90404f, 0xcbf29ce484222325,
+}
+
+// countMinSketch estimates the access frequencies of keys. The counters are
+// halved periodically so that the estimates follow changes of the workload.
+type countMinSketch struct {
+	seed maphash.Seed
+
+	mu        sync.Mutex
+	rows      [sketchDepth][]uint8
+	shift     uint // 64 minus log2 of the width of the rows
+	additions int
+	resetAt   int
+}
+
+// newCountMinSketch returns a sketch sized for a cache of n keys.
+func newCountMinSketch(n int) *countMinSketch {
+	if n < 1 {
+		n = 1
+	}
+	width, shift := 16, uint(60)
+	for width < 8*n {
+		width *= 2
+		shift--
+	}
+	s := &countMinSketch{
+		seed:    maphash.MakeSeed(),
+		shift:   shift,
+		resetAt: 10 * n,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// hash returns the hash of the key.
+func (s *countMinSketch) hash(key interface{}) uint64 {
+	return maphashKey(s.seed, key)
+}
+
+// maphashKey returns the hash of the key with the seed. Strings and integers
+// are hashed directly, pointers and channels by their addresses since they
+// are compared by identity, and other keys by their formatted values.
+func maphashKey(seed maphash.Seed, key interface{}) uint64 {
+	switch k := key.(type) {
+	case string:
+		return maphash.String(seed, k)
+	case int:
+		return maphashUint64(seed, uint64(k))
+	case int64:
+		return maphashUint64(seed, uint64(k))
+	case uint64:
+		return maphashUint64(seed, k)
+	case int32:
+		return maphashUint64(seed, uint64(k))
+	case uint32:
+		return maphashUint64(seed, uint64(k))
+	}
+	switch v := reflect.ValueOf(key); v.Kind() {
+	case reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+		return maphashUint64(seed, uint64(v.Pointer()))
+	}
+	return maphash.String(seed, fmt.Sprintf("%T:%v", key, key))
+}
+
+// maphashUint64 returns the hash of an integer with the seed.
+func maphashUint64(seed maphash.Seed, v uint64) uint64 {
+	var b [8]byte
+	for i := range b {
+		b[i] = byte(v >> (8 * i))
+	}
+	return maphash.Bytes(seed, b[:])
+}
+
+// index returns the index of the counter of the hash h in the row i.
+func (s *countMinSketch) index(h uint64, i int) uint64 {
+	return (h * sketchMultipliers[i]) >> s.shift
+}
+
+// add counts an access of the key of the hash h.
+func (s *countMinSketch) add(h uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.rows {
+		if c := &s.rows[i][s.index(h, i)]; *c < sketchMaxCounter {
+			*c++
+		}
+	}
+	s.additions++
+	if s.additions >= s.resetAt {
+		for i := range s.rows {
+			for j := range s.rows[i] {
+				s.rows[i][j] /= 2
+			}
+		}
+		s.additions /= 2
+	}
+}
+
+// estimate returns the estimated number of accesses of the key of the hash h.
+func (s *countMinSketch) estimate(h uint64) uint8 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	min := uint8(sketchMaxCounter)
+	for i := range s.rows {
+		if c := s.rows[i][s.index(h, i)]; c < min {
+			min = c
+		}
+	}
+	return min
+}
@@ -0,0 +1,78 @@
+package memocache
+
+import (
+	"container/list"
+	"testing"
+)
+
+func TestTinyLFUMap_ResistsScans(t *testing.T) {
+	for name, m := range map[string]MapInterface{
+		"LRUMap":     NewLRUMap(list.New(), 100),
+		"TinyLFUMap": NewTinyLFUMap(100),
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := NewCache(m)
+			load := func(key interface{}) {
+				c.LoadOrCall(key, func() interface{} { return key })
+			}
+			for round := 0; round < 10; round++ {
+				for i := 0; i < 50; i++ {
+					load(i)
+				}
+			}
+			for i := 1000; i < 1500; i++ {
+				load(i)
+			}
+			before := c.Stats()
+			for i := 0; i < 50; i++ {
+				load(i)
+			}
+			hits := c.Stats().Hits - before.Hits
+			if _, isLRU := m.(*LRUMap); isLRU {
+				if hits != 0 {
+					t.Errorf("LRUMap kept %d hot keys after a scan, want 0", hits)
+				}
+				return
+			}
+			if hits != 50 {
+				t.Errorf("TinyLFUMap kept %d of 50 hot keys after a scan", hits)
+			}
+		})
+	}
+}
+
+func TestTinyLFUMap_AdmitsNewHotKeys(t *testing.T) {
+	c := NewCache(NewTinyLFUMap(10))
+	for i := 0; i < 10; i++ {
+		c.Store(i, i)
+	}
+	for round := 0; round < 3; round++ {
+		c.LoadOrCall("new", func() interface{} { return "new" })
+	}
+	if _, ok := c.Peek("new"); !ok {
+		t.Error("TinyLFUMap didn't admit a key accessed more than the victim")
+	}
+	if n := c.Len(); n != 10 {
+		t.Errorf("Len() = %d, want 10", n)
+	}
+}
+
+func TestCountMinSketch(t *testing.T) {
+	s := newCountMinSketch(100)
+	for i := 0; i < 5; i++ {
+		s.add(s.hash("a"))
+	}
+	s.add(s.hash(struct{ X int }{1}))
+	if got := s.estimate(s.hash("a")); got < 5 {
+		t.Errorf("estimate(a) = %d, want at least 5", got)
+	}
+	if got := s.estimate(s.hash(struct{ X int }{1})); got < 1 {
+		t.Errorf("estimate({1}) = %d, want at least 1", got)
+	}
+	for i := 0; i < s.resetAt; i++ {
+		s.add(s.hash("b"))
+	}
+	if got := s.estimate(s.hash("a")); got > 3 {
+		t.Errorf("estimate(a) = %d after aging, want at most 3", got)
+	}
+}
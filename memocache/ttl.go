@@ -0,0 +1,136 @@
+package memocache
+
+import (
+	"math/rand"
+	"time"
+)
+
+// WithTTL makes the values of a Cache expire after ttl since they were loaded
+// or stored. LoadOrCall of an expired key calls getValue again. Expired
+// entries are removed lazily when their keys are used, or by DeleteExpired.
+func WithTTL(ttl time.Duration) Option {
+	return func(c *config) {
+		c.ttl = ttl
+	}
+}
+
+// NewTTLCache returns a new cache backed by the given m whose values expire
+// after defaultTTL. It's a shorthand for NewCache(m, WithTTL(defaultTTL)).
+// Since it implements CacheInterface, it can be used by a MultiLevelMap to
+// expire both branches and leaves:
+//
+//	m := NewMultiLevelMap(func() CacheInterface {
+//		return NewTTLCache(&sync.Map{}, 10*time.Minute)
+//	})
+func NewTTLCache(m MapInterface, defaultTTL time.Duration, opts ...Option) *Cache {
+	// Copied so that appending doesn't write to the backing array of opts,
+	// which the caller may share, e.g. between the levels of a MultiLevelMap.
+	opts = append(opts[:len(opts):len(opts)], WithTTL(defaultTTL))
+	return NewCache(m, opts...)
+}
+
+// DeleteExpired deletes all expired entries. It does nothing if the backing
+// map doesn't have a Range method like *sync.Map has.
+func (c *Cache) DeleteExpired() {
+	m, ok := c.m.(mapRanger)
+	if !ok {
+		return
+	}
+	m.Range(func(key, e interface{}) bool {
+		v := e.(*Value)
+		if s := v.state.Load(); s != nil && s.expired() {
+			c.compareAndDelete(key, v, EvictionExpired)
+		}
+		return true
+	})
+}
+
+// WithTTLJitter shortens the time to live of every value by a random fraction
+// of up to jitter of it, from 0 to 1, so that the values loaded together,
+// e.g. when the cache is warmed up, don't all expire at once and stampede the
+// backend. It applies to the TTL of WithTTL and to those of LoadOrCallTTL.
+func WithTTLJitter(jitter float64) Option {
+	return func(c *config) {
+		c.ttlJitter = jitter
+	}
+}
+
+// jitterTTL returns the ttl shortened by the jitter of WithTTLJitter.
+func (c *config) jitterTTL(ttl time.Duration) time.Duration {
+	if c.ttlJitter <= 0 || ttl <= 0 {
+		return ttl
+	}
+	return ttl - time.Duration(float64(ttl)*c.ttlJitter*rand.Float64())
+}
+
+// ttlValue is a value returned by the loader of LoadOrCallTTL with its time
+// to live, which the Value publishes instead of its own.
+type ttlValue struct {
+	value interface{}
+	ttl   time.Duration
+}
+
+// LoadOrCallTTL is like LoadOrCallErr but getValue also returns the time to
+// live of the value, e.g. the max-age of an HTTP response, which replaces the
+// TTL of WithTTL for the value. A non-positive ttl means the TTL of WithTTL,
+// if any.
+func (c *Cache) LoadOrCallTTL(key interface{}, getValue func() (interface{}, time.Duration, error)) (interface{}, error) {
+	return c.LoadOrCallErr(key, func() (interface{}, error) {
+		value, ttl, err := getValue()
+		if err != nil || ttl <= 0 {
+			return value, err
+		}
+		return ttlValue{value: value, ttl: c.config.jitterTTL(ttl)}, nil
+	})
+}
+
+// GetWithExpiry is like Load but also returns when the value expires, or the
+// zero time if it never expires.
+func (c *Cache) GetWithExpiry(key interface{}) (value interface{}, expiresAt time.Time, ok bool) {
+	m, ok := c.m.(mapLoader)
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	e, ok := m.Load(key)
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	s := e.(*Value).state.Load()
+	if s == nil || s.expired() {
+		return nil, time.Time{}, false
+	}
+	if s.expires != 0 {
+		expiresAt = time.Unix(0, s.expires)
+	}
+	return s.value, expiresAt, true
+}
+
+// Touch makes the value of the key expire d from now instead of when it was
+// going to, e.g. to slide the expiration of a session whenever it's used. A
+// non-positive d makes the value never expire. It returns false if the key
+// has no ready value that hasn't expired.
+func (c *Cache) Touch(key interface{}, d time.Duration) bool {
+	m, ok := c.m.(mapLoader)
+	if !ok {
+		return false
+	}
+	e, ok := m.Load(key)
+	if !ok {
+		return false
+	}
+	v := e.(*Value)
+	for {
+		s := v.state.Load()
+		if s == nil || s.expired() {
+			return false
+		}
+		ns := *s
+		ns.expires = 0
+		if d > 0 {
+			ns.expires = now(s.clock).Add(d).UnixNano()
+		}
+		if v.state.CompareAndSwap(s, &ns) {
+			return true
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package memocache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTTLCache(t *testing.T) {
+	const ttl = time.Minute
+	clock := newFakeClock()
+	c := NewTTLCache(&sync.Map{}, ttl, WithClock(clock))
+
+	if got := c.LoadOrCall("a", func() interface{} { return 1 }); got != 1 {
+		t.Errorf("LoadOrCall() = %v, want 1", got)
+	}
+	c.Store("b", 2)
+	if got := c.LoadOrCall("a", func() interface{} { return 10 }); got != 1 {
+		t.Errorf("LoadOrCall() before expiry = %v, want 1", got)
+	}
+
+	clock.Advance(ttl)
+	if _, ok := c.Load("b"); ok {
+		t.Error("Load() found an expired value")
+	}
+	if got := c.LoadOrCall("a", func() interface{} { return 10 }); got != 10 {
+		t.Errorf("LoadOrCall() after expiry = %v, want 10", got)
+	}
+	if got := c.Len(); got != 2 {
+		t.Errorf("Len() = %d before DeleteExpired(), want 2", got)
+	}
+	c.DeleteExpired()
+	if got := c.Len(); got != 1 {
+		t.Errorf("Len() = %d after DeleteExpired(), want 1", got)
+	}
+}
+
+func TestTTLCache_multiLevelMap(t *testing.T) {
+	const ttl = time.Minute
+	clock := newFakeClock()
+	m := NewMultiLevelMap(func() CacheInterface {
+		return NewTTLCache(&sync.Map{}, ttl, WithClock(clock))
+	})
+
+	m.LoadOrCall(func() interface{} { return 1 }, "tenant", 42)
+	clock.Advance(ttl)
+	if got := m.LoadOrCall(func() interface{} { return 2 }, "tenant", 42); got != 2 {
+		t.Errorf("LoadOrCall() after expiry = %v, want 2", got)
+	}
+}
+
+func TestCache_LoadOrCallTTL(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCache(&sync.Map{}, WithClock(clock), WithTTL(time.Hour))
+	load := func(value interface{}, ttl time.Duration) func() (interface{}, time.Duration, error) {
+		return func() (interface{}, time.Duration, error) {
+			return value, ttl, nil
+		}
+	}
+	if got, err := c.LoadOrCallTTL("short", load(1, time.Minute)); got != 1 || err != nil {
+		t.Errorf("LoadOrCallTTL() = %v, %v, want 1, nil", got, err)
+	}
+	c.LoadOrCallTTL("default", load(2, 0))
+	errFail := errors.New("fail")
+	if _, err := c.LoadOrCallTTL("failed", func() (interface{}, time.Duration, error) {
+		return nil, time.Minute, errFail
+	}); err != errFail {
+		t.Errorf("LoadOrCallTTL() error = %v, want %v", err, errFail)
+	}
+	if got, ok := c.Load("short"); !ok || got != 1 {
+		t.Errorf("Load(short) = %v, %v, want 1", got, ok)
+	}
+
+	clock.Advance(time.Minute)
+	if _, ok := c.Load("short"); ok {
+		t.Error("Load(short) found the value after its own TTL")
+	}
+	if got, ok := c.Load("default"); !ok || got != 2 {
+		t.Errorf("Load(default) = %v, %v, want 2 within the TTL of the cache", got, ok)
+	}
+	if got := c.LoadOrCall("short", func() interface{} { return 3 }); got != 3 {
+		t.Errorf("LoadOrCall(short) = %v, want the reloaded 3", got)
+	}
+}
+
+func TestWithTTLJitter(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCache(&sync.Map{}, WithClock(clock), WithTTL(time.Hour), WithTTLJitter(0.5))
+	for i := 0; i < 100; i++ {
+		c.LoadOrCall(i, func() interface{} { return i })
+		c.LoadOrCallTTL(-i-1, func() (interface{}, time.Duration, error) { return i, 2 * time.Hour, nil })
+	}
+	live := func() (n int) {
+		c.Range(func(key, value interface{}) bool {
+			if key.(int) >= 0 {
+				n++
+			}
+			return true
+		})
+		return n
+	}
+	clock.Advance(30*time.Minute - time.Nanosecond)
+	if n := live(); n != 100 {
+		t.Errorf("%d values within half the TTL, want 100", n)
+	}
+	clock.Advance(15 * time.Minute)
+	if n := live(); n == 0 || n == 100 {
+		t.Errorf("%d values within 3/4 of the TTL, want some expired", n)
+	}
+	clock.Advance(15 * time.Minute)
+	if n := live(); n != 0 {
+		t.Errorf("%d values after the TTL, want 0", n)
+	}
+	clock.Advance(30 * time.Minute)
+	n := 0
+	for i := 0; i < 100; i++ {
+		if _, ok := c.Load(-i - 1); ok {
+			n++
+		}
+	}
+	if n == 0 || n == 100 {
+		t.Errorf("%d values of LoadOrCallTTL within 3/4 of their TTL, want some expired", n)
+	}
+}
+
+func TestCache_Touch(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCache(&sync.Map{}, WithClock(clock), WithTTL(time.Minute))
+	c.LoadOrCall("session", func() interface{} { return "alice" })
+	if got, expiresAt, ok := c.GetWithExpiry("session"); !ok || got != "alice" || !expiresAt.Equal(clock.Now().Add(time.Minute)) {
+		t.Errorf("GetWithExpiry() = %v, %v, %v, want alice expiring in a minute", got, expiresAt, ok)
+	}
+	for i := 0; i < 3; i++ {
+		clock.Advance(50 * time.Second)
+		if !c.Touch("session", time.Minute) {
+			t.Fatalf("Touch() = false after %d slides", i)
+		}
+	}
+	if _, expiresAt, _ := c.GetWithExpiry("session"); !expiresAt.Equal(clock.Now().Add(time.Minute)) {
+		t.Errorf("expiresAt = %v, want a minute after the last Touch", expiresAt)
+	}
+	if !c.Touch("session", 0) {
+		t.Fatal("Touch(0) = false")
+	}
+	clock.Advance(time.Hour)
+	if _, expiresAt, ok := c.GetWithExpiry("session"); !ok || !expiresAt.IsZero() {
+		t.Errorf("GetWithExpiry() = %v, %v after Touch(0), want a value never expiring", expiresAt, ok)
+	}
+
+	c.Store("gone", 1)
+	clock.Advance(time.Minute)
+	if c.Touch("gone", time.Minute) {
+		t.Error("Touch() revived an expired value")
+	}
+	if c.Touch("missing", time.Minute) {
+		t.Error("Touch() of a missing key = true")
+	}
+	if _, _, ok := c.GetWithExpiry("gone"); ok {
+		t.Error("GetWithExpiry() found an expired value")
+	}
+}
+
+func TestNewTTLCache_sharedOptions(t *testing.T) {
+	clock := newFakeClock()
+	opts := make([]Option, 1, 2)
+	opts[0] = WithClock(clock)
+	c := NewTTLCache(&sync.Map{}, time.Minute, opts...)
+	if opts[:2][1] != nil {
+		t.Error("NewTTLCache() wrote to the spare capacity of the options")
+	}
+	c.Store("k", 1)
+	clock.Advance(time.Minute)
+	if _, ok := c.Load("k"); ok {
+		t.Error("Load() found an expired value")
+	}
+}
@@ -0,0 +1,78 @@
+package memocache
+
+import (
+	"sync"
+	"time"
+)
+
+// View is a read-only view of the entries of a cache at a point in time, made
+// by Cache.View. It's never modified, so it can be iterated and shared
+// between goroutines without locks and without blocking the writers of the
+// cache, e.g. to export the contents of the cache for offline analysis.
+type View struct {
+	time    time.Time
+	entries []removal // Keys and values in no particular order
+
+	indexOnce sync.Once
+	index     map[interface{}]int // Index of the entries by key, built by Get
+}
+
+// View returns a read-only view of the keys and ready values of the cache.
+// Values being loaded, expired values and cached errors are left out. The
+// values themselves are shared with the cache rather than copied, so they
+// should be immutable as cached values should anyway. The view is made with
+// one pass of Range of the backing map: it's a consistent cut of the map if
+// its Range copies the entries under its lock like LRUMap does, and otherwise
+// each entry is taken as of when it's visited, like Range of sync.Map does.
+// The view is empty if the backing map doesn't have a Range method.
+func (c *Cache) View() *View {
+	v := &View{time: now(c.config.clock)}
+	m, ok := c.m.(mapRanger)
+	if !ok {
+		return v
+	}
+	m.Range(func(key, e interface{}) bool {
+		s := e.(*Value).state.Load()
+		if s != nil && s.err == nil && !s.expired() {
+			v.entries = append(v.entries, removal{key: key, value: s.value})
+		}
+		return true
+	})
+	return v
+}
+
+// Time returns when the view was made.
+func (v *View) Time() time.Time {
+	return v.time
+}
+
+// Len returns the number of entries of the view.
+func (v *View) Len() int {
+	return len(v.entries)
+}
+
+// Get returns the value of the key in the view. The first call builds an
+// index of the keys.
+func (v *View) Get(key interface{}) (value interface{}, ok bool) {
+	v.indexOnce.Do(func() {
+		v.index = make(map[interface{}]int, len(v.entries))
+		for i, e := range v.entries {
+			v.index[e.key] = i
+		}
+	})
+	i, ok := v.index[key]
+	if !ok {
+		return nil, false
+	}
+	return v.entries[i].value, true
+}
+
+// Range calls f sequentially for each key and value of the view. If f returns
+// false, range stops the iteration.
+func (v *View) Range(f func(key, value interface{}) bool) {
+	for _, e := range v.entries {
+		if !f(e.key, e.value) {
+			return
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package memocache
+
+import (
+	"container/list"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCache_View(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCache(NewLRUMap(list.New(), 10), WithClock(clock), WithTTL(time.Minute))
+	c.Store("expired", 0)
+	clock.Advance(time.Minute)
+	c.Store("a", 1)
+	c.Store("b", 2)
+	c.LoadOrCallErr("failed", func() (interface{}, error) {
+		return nil, NewCachedError("failed", errNotLoaded)
+	})
+
+	v := c.View()
+	c.Store("a", 10)
+	c.Delete("b")
+	c.Store("c", 3)
+
+	if !v.Time().Equal(clock.Now()) {
+		t.Errorf("Time() = %v, want %v", v.Time(), clock.Now())
+	}
+	if n := v.Len(); n != 2 {
+		t.Errorf("Len() = %d, want 2", n)
+	}
+	for key, want := range map[string]interface{}{"a": 1, "b": 2} {
+		if got, ok := v.Get(key); !ok || got != want {
+			t.Errorf("Get(%s) = %v, %v, want %v of the time of the view", key, got, ok, want)
+		}
+	}
+	for _, key := range []string{"c", "expired", "failed"} {
+		if _, ok := v.Get(key); ok {
+			t.Errorf("Get(%s) found it, want it left out", key)
+		}
+	}
+	n := 0
+	v.Range(func(key, value interface{}) bool {
+		n++
+		return false
+	})
+	if n != 1 {
+		t.Errorf("Range() visited %d entries after f returned false, want 1", n)
+	}
+}
+
+func TestCache_ViewConcurrent(t *testing.T) {
+	c := NewCache(&sync.Map{})
+	for i := 0; i < 100; i++ {
+		c.Store(i, i)
+	}
+	v := c.View()
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				if got, ok := v.Get(i); !ok || got != i {
+					t.Errorf("Get(%d) = %v, %v", i, got, ok)
+				}
+			}
+		}()
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				c.Store(i, -g)
+				c.Delete(i + 100)
+			}
+		}(g)
+	}
+	wg.Wait()
+}
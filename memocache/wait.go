@@ -0,0 +1,24 @@
+package memocache
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrWaitTimeout is returned by the calls of a Cache given WithWaitTimeout that
+// gave up waiting for the load of another goroutine.
+var ErrWaitTimeout = errors.New("memocache: timed out waiting for another load")
+
+// WithWaitTimeout bounds the time a call of a Cache waits for the value of a
+// key being loaded by another goroutine, so that a single slow loader doesn't
+// pile up every caller of the key behind it. After the timeout, the call
+// returns the result of fallback for the key if it's not nil, or
+// ErrWaitTimeout otherwise; LoadOrCall returns nil for an error. The result is
+// returned to the waiting caller only and not cached, and the slow load
+// carries on for later calls. A timed out call is counted as a miss in Stats.
+func WithWaitTimeout(timeout time.Duration, fallback func(key interface{}) (interface{}, error)) Option {
+	return func(c *config) {
+		c.waitTimeout = timeout
+		c.waitFallback = fallback
+	}
+}
@@ -0,0 +1,48 @@
+package memocache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithWaitTimeout(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		fallback func(key interface{}) (interface{}, error)
+		want     interface{}
+		wantErr  error
+	}{
+		{"error", nil, nil, ErrWaitTimeout},
+		{"fallback", func(key interface{}) (interface{}, error) {
+			return "default", nil
+		}, "default", nil},
+	} {
+		c := NewCache(&sync.Map{}, WithWaitTimeout(10*time.Millisecond, tc.fallback))
+		started := make(chan struct{})
+		release := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			c.LoadOrCall("a", func() interface{} {
+				close(started)
+				<-release
+				return "slow"
+			})
+		}()
+		<-started
+		got, err := c.LoadOrCallErr("a", func() (interface{}, error) {
+			t.Errorf("%s: getValue is called while another load is in flight", tc.name)
+			return nil, nil
+		})
+		if got != tc.want || !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s: LoadOrCallErr() = %v, %v, want %v, %v", tc.name, got, err, tc.want, tc.wantErr)
+		}
+		close(release)
+		<-done
+		if got, err := c.LoadOrCallErr("a", nil); got != "slow" || err != nil {
+			t.Errorf("%s: LoadOrCallErr() after the load = %v, %v, want slow, nil", tc.name, got, err)
+		}
+	}
+}
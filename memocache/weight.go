@@ -0,0 +1,284 @@
+package memocache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Weigher returns the cost of a value, e.g. its approximate size in bytes.
+type Weigher func(key, value interface{}) int64
+
+// WeightedLRUMap is a LRU map bounded by the total weight of its values
+// instead of the number of keys, so that a few large values can't exceed the
+// budget that many small values fit in. The weight of each value is computed
+// by a Weigher when it's stored.
+//
+// A Cache stores an empty entry before calling the loader, so the values of a
+// Cache backed by a WeightedLRUMap weigh nothing while they are being loaded
+// and are weighed by the Cache calling Reweigh once loaded. A value heavier
+// than the whole budget is evicted right after it's loaded, so it's returned
+// to the caller but not cached. WeightedLRUMap should be created with
+// NewWeightedLRUMap.
+type WeightedLRUMap struct {
+	mu        sync.Mutex
+	list      *list.List // Keys from the most recently used
+	m         map[interface{}]*list.Element
+	weigher   Weigher
+	weight    int64 // Total weight of the values
+	maxWeight int64
+
+	onEvict func(key, value interface{}, reason EvictionReason)
+	pending []removal // Removed values to notify of, guarded by mu
+}
+
+// weightedEntry is an entry of a WeightedLRUMap.
+type weightedEntry struct {
+	key, value interface{}
+	weight     int64
+}
+
+// NewWeightedLRUMap returns a new WeightedLRUMap holding values weighing up to
+// maxWeight in total as weighed by weigher.
+func NewWeightedLRUMap(maxWeight int64, weigher Weigher) *WeightedLRUMap {
+	return &WeightedLRUMap{
+		list:      list.New(),
+		m:         make(map[interface{}]*list.Element),
+		weigher:   weigher,
+		maxWeight: maxWeight,
+	}
+}
+
+// NewWeightedLRUCache returns a new cache backed by a WeightedLRUMap holding
+// values weighing up to maxWeight in total as weighed by weigher.
+func NewWeightedLRUCache(maxWeight int64, weigher Weigher, opts ...Option) *Cache {
+	return NewCache(NewWeightedLRUMap(maxWeight, weigher), opts...)
+}
+
+// SetOnEvict sets a function that is called with every value removed from the
+// map and the reason of the removal. It's called after the lock of the map is
+// released, so it may call the methods of the map. SetOnEvict should be called
+// before the map is used. A Cache wrapping the map installs its own listener,
+// which calls the one set before.
+func (w *WeightedLRUMap) SetOnEvict(f func(key, value interface{}, reason EvictionReason)) {
+	w.onEvict = f
+}
+
+// evictListener returns the listener set by SetOnEvict.
+func (w *WeightedLRUMap) evictListener() func(key, value interface{}, reason EvictionReason) {
+	return w.onEvict
+}
+
+// unlock releases the lock and notifies the listener of the values removed
+// while it was held.
+func (w *WeightedLRUMap) unlock() {
+	pending := w.pending
+	w.pending = nil
+	w.mu.Unlock()
+	for _, ev := range pending {
+		w.onEvict(ev.key, ev.value, ev.reason)
+	}
+}
+
+// removed records the removal of the entry to notify of. It should be called
+// with w.mu held.
+func (w *WeightedLRUMap) removed(en *weightedEntry, reason EvictionReason) {
+	if w.onEvict != nil {
+		w.pending = append(w.pending, removal{key: en.key, value: en.value, reason: reason})
+	}
+}
+
+// weigh returns the weight of the value of the key. An entry of a Cache is
+// weighed by its ready value, or weighs nothing if it isn't ready.
+func (w *WeightedLRUMap) weigh(key, value interface{}) int64 {
+	if v, ok := value.(*Value); ok {
+		s := v.state.Load()
+		if s == nil {
+			return 0
+		}
+		value = s.value
+	}
+	return w.weigher(key, value)
+}
+
+// LoadOrStore returns the existing value for the key if present. Otherwise, it
+// stores and returns the given value. The loaded result is true if the value
+// was loaded, false if stored. If the total weight gets over maxWeight, the
+// least recently used values are evicted.
+func (w *WeightedLRUMap) LoadOrStore(key, value interface{}) (actual interface{}, loaded bool) {
+	w.mu.Lock()
+	defer w.unlock()
+	if e, ok := w.m[key]; ok {
+		w.list.MoveToFront(e)
+		return e.Value.(*weightedEntry).value, true
+	}
+	w.add(key, value)
+	return value, false
+}
+
+// Load returns the value stored in the map for a key, or nil if no value is
+// present. The ok result indicates whether value was found in the map. A found
+// key becomes the most recently used one.
+func (w *WeightedLRUMap) Load(key interface{}) (value interface{}, ok bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	e, ok := w.m[key]
+	if !ok {
+		return nil, false
+	}
+	w.list.MoveToFront(e)
+	return e.Value.(*weightedEntry).value, true
+}
+
+// Peek is like Load but doesn't mark the key as recently used.
+func (w *WeightedLRUMap) Peek(key interface{}) (value interface{}, ok bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	e, ok := w.m[key]
+	if !ok {
+		return nil, false
+	}
+	return e.Value.(*weightedEntry).value, true
+}
+
+// Store sets the value for a key, overwriting the existing value if any. The
+// key becomes the most recently used one.
+func (w *WeightedLRUMap) Store(key, value interface{}) {
+	w.mu.Lock()
+	defer w.unlock()
+	if e, ok := w.m[key]; ok {
+		en := e.Value.(*weightedEntry)
+		w.removed(en, EvictionReplaced)
+		w.list.MoveToFront(e)
+		w.set(en, value)
+		return
+	}
+	w.add(key, value)
+}
+
+// Reweigh weighs the value of the key again if it's still value, e.g. after
+// the value was changed in place, and evicts values until the total weight
+// fits in maxWeight. A Cache calls it when the value of an entry is loaded.
+func (w *WeightedLRUMap) Reweigh(key, value interface{}) {
+	w.mu.Lock()
+	defer w.unlock()
+	if e, ok := w.m[key]; ok && e.Value.(*weightedEntry).value == value {
+		w.set(e.Value.(*weightedEntry), value)
+	}
+}
+
+// CompareAndSwap swaps the old and new values for key if the value stored in
+// the map is equal to old. The swapped key becomes the most recently used one.
+func (w *WeightedLRUMap) CompareAndSwap(key, old, new interface{}) (swapped bool) {
+	w.mu.Lock()
+	defer w.unlock()
+	e, ok := w.m[key]
+	if !ok || e.Value.(*weightedEntry).value != old {
+		return false
+	}
+	en := e.Value.(*weightedEntry)
+	w.removed(en, EvictionReplaced)
+	w.list.MoveToFront(e)
+	w.set(en, new)
+	return true
+}
+
+// CompareAndDelete deletes the entry for key if its value is equal to old.
+func (w *WeightedLRUMap) CompareAndDelete(key, old interface{}) (deleted bool) {
+	w.mu.Lock()
+	defer w.unlock()
+	e, ok := w.m[key]
+	if !ok || e.Value.(*weightedEntry).value != old {
+		return false
+	}
+	w.deleteElement(e, EvictionDeleted)
+	return true
+}
+
+// Delete deletes the value for a key.
+func (w *WeightedLRUMap) Delete(key interface{}) {
+	w.mu.Lock()
+	defer w.unlock()
+	if e, ok := w.m[key]; ok {
+		w.deleteElement(e, EvictionDeleted)
+	}
+}
+
+// Range calls f sequentially for each key and value present in the map. If f
+// returns false, range stops the iteration. Range iterates over a snapshot, so
+// f may call other methods of the map. Range doesn't change the recency of the
+// keys.
+func (w *WeightedLRUMap) Range(f func(key, value interface{}) bool) {
+	w.mu.Lock()
+	kvs := make([]removal, 0, len(w.m))
+	for _, e := range w.m {
+		en := e.Value.(*weightedEntry)
+		kvs = append(kvs, removal{key: en.key, value: en.value})
+	}
+	w.mu.Unlock()
+	for _, kv := range kvs {
+		if !f(kv.key, kv.value) {
+			return
+		}
+	}
+}
+
+// Len returns the number of keys in the map.
+func (w *WeightedLRUMap) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.m)
+}
+
+// Weight returns the total weight of the values in the map.
+func (w *WeightedLRUMap) Weight() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.weight
+}
+
+// MaxWeight returns the maximum total weight of the values in the map.
+func (w *WeightedLRUMap) MaxWeight() int64 {
+	return w.maxWeight
+}
+
+// Clear deletes all the values.
+func (w *WeightedLRUMap) Clear() {
+	w.mu.Lock()
+	defer w.unlock()
+	for e := w.list.Front(); e != nil; e = e.Next() {
+		w.removed(e.Value.(*weightedEntry), EvictionCleared)
+	}
+	w.list.Init()
+	w.m = make(map[interface{}]*list.Element)
+	w.weight = 0
+}
+
+// add adds a new key as the most recently used one. It should be called with
+// w.mu held.
+func (w *WeightedLRUMap) add(key, value interface{}) {
+	en := &weightedEntry{key: key}
+	w.m[key] = w.list.PushFront(en)
+	w.set(en, value)
+}
+
+// set sets the value of the entry en in the map and evicts values until the
+// total weight fits in maxWeight. It should be called with w.mu held.
+func (w *WeightedLRUMap) set(en *weightedEntry, value interface{}) {
+	w.weight -= en.weight
+	en.value = value
+	en.weight = w.weigh(en.key, value)
+	w.weight += en.weight
+	for w.weight > w.maxWeight && w.list.Len() > 0 {
+		w.deleteElement(w.list.Back(), EvictionCapacity)
+	}
+}
+
+// deleteElement removes the element e from the map for the reason. It should
+// be called with w.mu held.
+func (w *WeightedLRUMap) deleteElement(e *list.Element, reason EvictionReason) {
+	en := e.Value.(*weightedEntry)
+	w.list.Remove(e)
+	delete(w.m, en.key)
+	w.weight -= en.weight
+	w.removed(en, reason)
+}
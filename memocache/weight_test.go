@@ -0,0 +1,86 @@
+package memocache
+
+import (
+	"fmt"
+	"testing"
+)
+
+func ExampleWeightedLRUMap() {
+	c := NewWeightedLRUCache(10, func(key, value interface{}) int64 {
+		return int64(len(value.(string)))
+	})
+	load := func(key, value string) {
+		c.LoadOrCall(key, func() interface{} {
+			fmt.Println("loading", key)
+			return value
+		})
+	}
+	load("a", "1234")
+	load("b", "1234")
+	load("c", "1234") // Evicts a to fit in the weight of 10.
+	load("b", "1234")
+	load("a", "1234")
+	// Output:
+	// loading a
+	// loading b
+	// loading c
+	// loading a
+}
+
+func TestWeightedLRUMap_Weight(t *testing.T) {
+	var evicted []interface{}
+	m := NewWeightedLRUMap(10, func(key, value interface{}) int64 {
+		return int64(value.(int))
+	})
+	m.SetOnEvict(func(key, value interface{}, reason EvictionReason) {
+		if reason == EvictionCapacity {
+			evicted = append(evicted, key)
+		}
+	})
+	m.Store("a", 3)
+	m.Store("b", 3)
+	m.Store("c", 3)
+	if w := m.Weight(); w != 9 {
+		t.Errorf("Weight() = %d, want 9", w)
+	}
+	m.Load("a")
+	m.Store("c", 6) // Evicts b, the least recently used key.
+	if w := m.Weight(); w != 9 {
+		t.Errorf("Weight() = %d, want 9", w)
+	}
+	m.Store("d", 11) // Heavier than the budget, evicts everything.
+	if want := []interface{}{"b", "a", "c", "d"}; fmt.Sprint(evicted) != fmt.Sprint(want) {
+		t.Errorf("evicted %v, want %v", evicted, want)
+	}
+	if n, w := m.Len(), m.Weight(); n != 0 || w != 0 {
+		t.Errorf("Len(), Weight() = %d, %d, want 0, 0", n, w)
+	}
+}
+
+func TestWeightedLRUMap_Cache(t *testing.T) {
+	m := NewWeightedLRUMap(100, func(key, value interface{}) int64 {
+		return int64(len(value.([]byte)))
+	})
+	c := NewCache(m)
+	c.LoadOrCall("a", func() interface{} { return make([]byte, 40) })
+	c.LoadOrCall("b", func() interface{} { return make([]byte, 40) })
+	if w := m.Weight(); w != 80 {
+		t.Errorf("Weight() = %d, want 80", w)
+	}
+	c.LoadOrCall("c", func() interface{} { return make([]byte, 40) })
+	if w, n := m.Weight(), c.Len(); w != 80 || n != 2 {
+		t.Errorf("Weight(), Len() = %d, %d, want 80, 2", w, n)
+	}
+	if _, ok := c.Load("a"); ok {
+		t.Error("a is not evicted")
+	}
+	c.Delete("b")
+	c.Store("d", make([]byte, 10))
+	if w := m.Weight(); w != 50 {
+		t.Errorf("Weight() = %d, want 50", w)
+	}
+	c.Clear()
+	if w := m.Weight(); w != 0 {
+		t.Errorf("Weight() after Clear() = %d, want 0", w)
+	}
+}
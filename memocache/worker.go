@@ -0,0 +1,147 @@
+package memocache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// keyWorker is a running worker of LoadOrRun.
+type keyWorker struct {
+	cancel context.CancelFunc
+}
+
+// ErrWorkerReturned is returned by LoadOrRun when the worker returns without
+// setting a value.
+var ErrWorkerReturned = errors.New("memocache: worker returned without a value")
+
+// LoadOrRun is like LoadOrCall but the value of the key is kept fresh by a
+// long-lived worker instead of being loaded once. The worker is started once
+// per key, like getValue of LoadOrCall, in a new goroutine and publishes values
+// with set, e.g. from a subscription to a change stream. LoadOrRun returns the
+// first value set by the worker, or ErrWorkerReturned if the worker returns
+// without setting one, in which case nothing is cached and the next LoadOrRun
+// of the key starts a new worker; later values replace the cached value. The
+// ctx of the worker is done when the key is deleted by Delete, by a Prune of a
+// MultiLevelMap or by an eviction, or when the cache is closed. Values set
+// after that are discarded, and the next LoadOrRun of the key starts a new
+// worker.
+func (c *Cache) LoadOrRun(key interface{}, worker func(ctx context.Context, set func(value interface{}))) (interface{}, error) {
+	return c.LoadOrCallErr(key, func() (interface{}, error) {
+		return c.startWorker(key, worker)
+	})
+}
+
+// startWorker starts the worker for the key and waits for its first value.
+func (c *Cache) startWorker(key interface{}, worker func(ctx context.Context, set func(value interface{}))) (interface{}, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &keyWorker{cancel: cancel}
+	c.workerMu.Lock()
+	if old, ok := c.workers[key]; ok {
+		old.cancel()
+	}
+	if c.workers == nil {
+		c.workers = make(map[interface{}]*keyWorker)
+	}
+	c.workers[key] = w
+	atomic.StoreInt32(&c.numWorkers, int32(len(c.workers)))
+	c.workerMu.Unlock()
+
+	first := make(chan interface{}, 1)
+	started := false
+	set := func(value interface{}) {
+		c.workerMu.Lock()
+		if c.workers[key] != w {
+			c.workerMu.Unlock()
+			return
+		}
+		if !started {
+			started = true
+			first <- value
+			c.workerMu.Unlock()
+			return
+		}
+		c.workerMu.Unlock()
+		// The lock isn't held while storing, as the evictions made by the
+		// store stop workers. A stop racing with the store drops the value.
+		v := c.store(key, value)
+		if ctx.Err() != nil && !c.compareAndDelete(key, v, EvictionDeleted) && !c.canCompare() {
+			c.deleteKey(key, EvictionDeleted)
+		}
+	}
+	go func() {
+		defer func() {
+			c.workerMu.Lock()
+			defer c.workerMu.Unlock()
+			if c.workers[key] == w {
+				c.deleteWorkerLocked(key)
+			}
+			if !started {
+				started = true
+				close(first)
+			}
+		}()
+		worker(ctx, set)
+	}()
+	value, ok := <-first
+	if !ok {
+		return nil, ErrWorkerReturned
+	}
+	return value, nil
+}
+
+// stopWorker stops the worker of the key if any.
+func (c *Cache) stopWorker(key interface{}) {
+	if atomic.LoadInt32(&c.numWorkers) == 0 {
+		return
+	}
+	c.workerMu.Lock()
+	defer c.workerMu.Unlock()
+	c.deleteWorkerLocked(key)
+}
+
+// stopWorkers stops all the workers of the cache and of the caches cached in
+// it, e.g. the lower levels of a MultiLevelMap.
+func (c *Cache) stopWorkers() {
+	c.workerMu.Lock()
+	for key := range c.workers {
+		c.deleteWorkerLocked(key)
+	}
+	c.workerMu.Unlock()
+	c.Range(func(key, value interface{}) bool {
+		if sub, ok := value.(*Cache); ok {
+			sub.stopWorkers()
+		}
+		return true
+	})
+}
+
+// stopRemovedWorkers stops the worker of the key removed from the cache and the
+// workers of the Cache held by its entry e, e.g. a level of a MultiLevelMap.
+func (c *Cache) stopRemovedWorkers(key, e interface{}) {
+	c.stopWorker(key)
+	stopEntryWorkers(e)
+}
+
+// stopEntryWorkers stops the workers of the Cache held by the entry e if any.
+func stopEntryWorkers(e interface{}) {
+	v, ok := e.(*Value)
+	if !ok {
+		return
+	}
+	if s := v.state.Load(); s != nil {
+		if sub, ok := s.value.(*Cache); ok {
+			sub.stopWorkers()
+		}
+	}
+}
+
+// deleteWorkerLocked stops and removes the worker of the key. It should be
+// called with c.workerMu held.
+func (c *Cache) deleteWorkerLocked(key interface{}) {
+	if w, ok := c.workers[key]; ok {
+		w.cancel()
+		delete(c.workers, key)
+		atomic.StoreInt32(&c.numWorkers, int32(len(c.workers)))
+	}
+}
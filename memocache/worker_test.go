@@ -0,0 +1,96 @@
+package memocache
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func ExampleCache_LoadOrRun() {
+	c := NewCache(&sync.Map{})
+	updates := make(chan string)
+	updated := make(chan struct{})
+	stopped := make(chan struct{})
+	subscribe := func(ctx context.Context, set func(value interface{})) {
+		defer close(stopped)
+		set("v1")
+		for {
+			select {
+			case v := <-updates:
+				set(v)
+				updated <- struct{}{}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+
+	fmt.Println(c.LoadOrRun("config", subscribe))
+	updates <- "v2"
+	<-updated
+	// The worker is started once per key, so subscribe isn't called again.
+	fmt.Println(c.LoadOrRun("config", subscribe))
+
+	c.Delete("config")
+	<-stopped
+	fmt.Println("stopped")
+	// Output:
+	// v1 <nil>
+	// v2 <nil>
+	// stopped
+}
+
+func TestCache_LoadOrRunPrune(t *testing.T) {
+	m := NewMultiLevelMap(func() CacheInterface {
+		return NewCache(&sync.Map{})
+	})
+	m.LoadOrCall(func() interface{} { return 0 }, "a", "b", "other")
+	leaf, _ := m.findLeafNode(m.getRoot(), "a", "b")
+	sub := leaf.(*Cache)
+
+	ctxs := make(chan context.Context, 1)
+	if got, err := sub.LoadOrRun("k", func(ctx context.Context, set func(value interface{})) {
+		ctxs <- ctx
+		set(1)
+		<-ctx.Done()
+		set(2)
+	}); err != nil || got != 1 {
+		t.Fatalf("LoadOrRun() = %v, %v, want 1, nil", got, err)
+	}
+	ctx := <-ctxs
+
+	m.Prune("a")
+	<-ctx.Done()
+	if got, _ := sub.LoadOrRun("k", func(ctx context.Context, set func(value interface{})) {}); got != 1 {
+		t.Errorf("LoadOrRun() = %v after the worker stopped, want 1", got)
+	}
+}
+
+func TestCache_LoadOrRunWithoutValue(t *testing.T) {
+	c := NewCache(&sync.Map{})
+	if got, err := c.LoadOrRun("k", func(ctx context.Context, set func(value interface{})) {}); err != ErrWorkerReturned {
+		t.Errorf("LoadOrRun() = %v, %v, want ErrWorkerReturned", got, err)
+	}
+	if got, err := c.LoadOrRun("k", func(ctx context.Context, set func(value interface{})) {
+		set(1)
+	}); err != nil || got != 1 {
+		t.Errorf("LoadOrRun() = %v, %v after the failure, want 1, nil", got, err)
+	}
+}
+
+func TestCache_LoadOrRunEvicted(t *testing.T) {
+	c := NewCache(NewLRUMap(list.New(), 1))
+	ctxs := make(chan context.Context, 1)
+	if _, err := c.LoadOrRun("k", func(ctx context.Context, set func(value interface{})) {
+		ctxs <- ctx
+		set(1)
+		<-ctx.Done()
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := <-ctxs
+	c.LoadOrCall("other", func() interface{} { return 2 }) // Evicts k.
+	<-ctx.Done()
+}
@@ -0,0 +1,183 @@
+package memocache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// WithWriteThrough persists the explicit writes of a Cache or an RRCache to
+// the store: Store sets the value in the store before caching it, and Delete
+// deletes the key from the store before dropping it, and so do Store and
+// Delete of a Lease, so that the cache in front of a store doesn't need the
+// write path duplicated by hand. Loaded values aren't written, since they
+// usually come from the store. A failed write of the store doesn't fail the
+// write of the cache; the error is passed to onError if it's not nil.
+func WithWriteThrough(store Store, onError func(key interface{}, err error)) Option {
+	return func(c *config) {
+		c.writeStore = store
+		c.writeInterval = 0
+		c.onWriteError = onError
+	}
+}
+
+// WithWriteBehind is like WithWriteThrough but the writes are queued and made
+// asynchronously in batches, at most interval after they are made to the
+// cache, so that Store and Delete don't wait for a slow store. Only the last
+// write of a key in a batch is made to the store. The batches are written one
+// at a time, so the writes of a key reach the store in order. Flush writes the
+// queued writes at once, and Close flushes them before returning. The queued
+// writes are lost if the process exits before they are flushed.
+func WithWriteBehind(store Store, interval time.Duration, onError func(key interface{}, err error)) Option {
+	return func(c *config) {
+		c.writeStore = store
+		c.writeInterval = interval
+		c.onWriteError = onError
+	}
+}
+
+// writer writes the explicit writes of a cache to the store of
+// WithWriteThrough or WithWriteBehind.
+type writer struct {
+	store    Store
+	interval time.Duration // Zero for write-through
+	onError  func(key interface{}, err error)
+	clock    Clock
+
+	flushMu sync.Mutex // Held while a batch is written
+
+	mu      sync.Mutex
+	pending map[interface{}]pendingWrite
+	armed   bool          // A flush is scheduled
+	closed  chan struct{} // Closed by close to flush at once
+}
+
+// pendingWrite is a write queued by WithWriteBehind.
+type pendingWrite struct {
+	value   interface{}
+	deleted bool
+}
+
+// newWriter returns the writer of the config, or nil if there is no store.
+func newWriter(c *config) *writer {
+	if c.writeStore == nil {
+		return nil
+	}
+	return &writer{
+		store:    c.writeStore,
+		interval: c.writeInterval,
+		onError:  c.onWriteError,
+		clock:    c.clock,
+		closed:   make(chan struct{}),
+	}
+}
+
+// set writes the value of the key to the store, or queues it. It's a no-op on
+// a nil writer.
+func (w *writer) set(key, value interface{}) {
+	if w != nil {
+		w.write(key, pendingWrite{value: value})
+	}
+}
+
+// delete deletes the key from the store, or queues the deletion. It's a no-op
+// on a nil writer.
+func (w *writer) delete(key interface{}) {
+	if w != nil {
+		w.write(key, pendingWrite{deleted: true})
+	}
+}
+
+// write makes the write of the key to the store now for write-through, or
+// queues it for write-behind.
+func (w *writer) write(key interface{}, pw pendingWrite) {
+	if w.interval <= 0 {
+		w.apply(context.Background(), key, pw)
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.pending == nil {
+		w.pending = make(map[interface{}]pendingWrite)
+	}
+	w.pending[key] = pw
+	if !w.armed {
+		w.armed = true
+		go w.flushLater()
+	}
+}
+
+// flushLater flushes the queued writes after the interval, or at once if the
+// writer is closed.
+func (w *writer) flushLater() {
+	t := newTimer(w.clock, w.interval)
+	select {
+	case <-t.C():
+	case <-w.closed:
+		t.Stop()
+	}
+	w.flush(context.Background())
+}
+
+// flush writes the queued writes to the store and returns the first error.
+// It's a no-op on a nil writer.
+func (w *writer) flush(ctx context.Context) error {
+	if w == nil {
+		return nil
+	}
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+	w.mu.Lock()
+	pending := w.pending
+	w.pending = nil
+	w.armed = false
+	w.mu.Unlock()
+	var first error
+	for key, pw := range pending {
+		if err := w.apply(ctx, key, pw); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// apply makes the write of the key to the store and reports its error.
+func (w *writer) apply(ctx context.Context, key interface{}, pw pendingWrite) error {
+	var err error
+	if pw.deleted {
+		err = w.store.Delete(ctx, key)
+	} else {
+		err = w.store.Set(ctx, key, pw.value)
+	}
+	if err != nil && w.onError != nil {
+		w.onError(key, err)
+	}
+	return err
+}
+
+// close makes the scheduled flush happen at once and flushes the queued
+// writes. It's a no-op on a nil writer.
+func (w *writer) close() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	select {
+	case <-w.closed:
+	default:
+		close(w.closed)
+	}
+	w.mu.Unlock()
+	w.flush(context.Background())
+}
+
+// Flush writes the writes queued by WithWriteBehind to the store now, and
+// returns the first error of the store. It's a no-op without the option.
+func (c *Cache) Flush(ctx context.Context) error {
+	return c.writer.flush(ctx)
+}
+
+// Flush is like Cache.Flush.
+func (r *RRCache) Flush(ctx context.Context) error {
+	return r.writer.flush(ctx)
+}
@@ -0,0 +1,137 @@
+package memocache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingStore is a Store counting the writes made to the store it wraps.
+type countingStore struct {
+	Store
+	writes atomic.Int32
+}
+
+func (s *countingStore) Set(ctx context.Context, key, value interface{}) error {
+	s.writes.Add(1)
+	return s.Store.Set(ctx, key, value)
+}
+
+func (s *countingStore) Delete(ctx context.Context, key interface{}) error {
+	s.writes.Add(1)
+	return s.Store.Delete(ctx, key)
+}
+
+func TestWithWriteThrough(t *testing.T) {
+	backing := NewCache(&sync.Map{})
+	for name, c := range map[string]ExtendedCache{
+		"Cache":   NewCache(&sync.Map{}, WithWriteThrough(CacheStore(backing), nil)),
+		"RRCache": NewRRCacheLocal(10, 5, nil, WithWriteThrough(CacheStore(backing), nil)),
+	} {
+		c.Store("a", 1)
+		if got, ok := backing.Load("a"); !ok || got != 1 {
+			t.Errorf("%s: store has a = %v, %v after Store, want 1", name, got, ok)
+		}
+		c.LoadOrCall("b", func() interface{} { return 2 })
+		if _, ok := backing.Load("b"); ok {
+			t.Errorf("%s: store has the loaded b, want only explicit writes", name)
+		}
+		c.Delete("a")
+		if _, ok := backing.Load("a"); ok {
+			t.Errorf("%s: store has a after Delete", name)
+		}
+	}
+}
+
+func TestWithWriteThrough_Lease(t *testing.T) {
+	store := &countingStore{Store: CacheStore(NewCache(&sync.Map{}))}
+	c := NewCache(&sync.Map{}, WithWriteThrough(store, nil))
+	l, err := c.Lease("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Store(1); err != nil {
+		t.Fatalf("Lease.Store() error = %v", err)
+	}
+	if got, ok, _ := store.Get(context.Background(), "a"); !ok || got != 1 {
+		t.Errorf("store has a = %v, %v after Lease.Store, want 1", got, ok)
+	}
+	if err := l.Delete(); err != nil {
+		t.Fatalf("Lease.Delete() error = %v", err)
+	}
+	if _, ok, _ := store.Get(context.Background(), "a"); ok {
+		t.Error("store has a after Lease.Delete")
+	}
+	if n := store.writes.Load(); n != 2 {
+		t.Errorf("store got %d writes, want 2", n)
+	}
+}
+
+func TestWithWriteThrough_Errors(t *testing.T) {
+	var errs []interface{}
+	c := NewCache(&sync.Map{}, WithWriteThrough(failingStore{}, func(key interface{}, err error) {
+		errs = append(errs, key)
+	}))
+	c.Store("a", 1)
+	c.Delete("b")
+	if len(errs) != 2 || errs[0] != "a" || errs[1] != "b" {
+		t.Errorf("errors of keys %v, want [a b]", errs)
+	}
+	if got, ok := c.Load("a"); !ok || got != 1 {
+		t.Errorf("Load(a) = %v, %v, want 1 cached despite the failed write", got, ok)
+	}
+}
+
+func TestWithWriteBehind(t *testing.T) {
+	clock := newFakeClock()
+	backing := NewCache(&sync.Map{})
+	backing.Store("gone", 0)
+	store := &countingStore{Store: CacheStore(backing)}
+	c := NewCache(&sync.Map{}, WithClock(clock), WithWriteBehind(store, time.Second, nil))
+	c.Store("a", 1)
+	c.Store("a", 2)
+	c.Delete("gone")
+	if _, ok := backing.Load("a"); ok {
+		t.Error("store has a before the interval")
+	}
+	if !waitFor(func() bool { return clock.numTimers() == 1 }) {
+		t.Fatal("flush not scheduled")
+	}
+	clock.Advance(time.Second)
+	if !waitFor(func() bool { _, ok := backing.Load("gone"); return !ok }) {
+		t.Fatal("store has gone after the interval")
+	}
+	if got, ok := backing.Load("a"); !ok || got != 2 {
+		t.Errorf("store has a = %v, %v, want the last write 2", got, ok)
+	}
+	if n := store.writes.Load(); n != 2 {
+		t.Errorf("%d writes to the store, want 2 for the batch", n)
+	}
+
+	c.Store("b", 3)
+	if err := c.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := backing.Load("b"); !ok || got != 3 {
+		t.Errorf("store has b = %v, %v after Flush, want 3", got, ok)
+	}
+	c.Store("c", 4)
+	c.Close()
+	if got, ok := backing.Load("c"); !ok || got != 4 {
+		t.Errorf("store has c = %v, %v after Close, want 4", got, ok)
+	}
+}
+
+func TestWithWriteBehind_FlushError(t *testing.T) {
+	c := NewRRCacheLocal(10, 5, nil, WithWriteBehind(failingStore{}, time.Hour, nil))
+	defer c.Close()
+	c.Store("a", 1)
+	if err := c.Flush(context.Background()); err == nil {
+		t.Error("Flush() = nil, want the error of the store")
+	}
+	if err := c.Flush(context.Background()); err != nil {
+		t.Errorf("second Flush() = %v, want nil with nothing queued", err)
+	}
+}